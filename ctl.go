@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const ctlUsage = `usage: llama-swap ctl [flags] models list|load NAME|unload NAME|pin NAME|unpin NAME|status
+
+flags:
+`
+
+// runCtl manages a running llama-swap through its API and returns the exit
+// code
+func runCtl(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	server := flags.String("server", "http://127.0.0.1:8080", "url of the llama-swap server")
+	apiKey := flags.String("api-key", os.Getenv("LLAMA_SWAP_API_KEY"), "bearer token sent to the server, default: $LLAMA_SWAP_API_KEY")
+	jsonOutput := flags.Bool("json", false, "print JSON instead of a table")
+	timeout := flags.Duration("timeout", 10*time.Minute, "time to wait for the server, loading a model can take a while")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), ctlUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	args = flags.Args()
+	if len(args) < 2 || args[0] != "models" {
+		flags.Usage()
+		return 2
+	}
+
+	client := &ctlClient{
+		server: strings.TrimSuffix(*server, "/"),
+		apiKey: *apiKey,
+		http:   &http.Client{Timeout: *timeout},
+	}
+
+	var table ctlTable
+	var err error
+	switch {
+	case args[1] == "list" && len(args) == 2:
+		table, err = client.listModels()
+	case args[1] == "status" && len(args) == 2:
+		table, err = client.running()
+	case (args[1] == "load" || args[1] == "unload" || args[1] == "pin" || args[1] == "unpin") && len(args) == 3:
+		table, err = client.modelAction(args[2], args[1])
+	default:
+		flags.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(table)
+		return 0
+	}
+	printTable(stdout, table)
+	return 0
+}
+
+type ctlClient struct {
+	server string
+	apiKey string
+	http   *http.Client
+}
+
+// ctlRow is a line of the table output, columns in the order of headers
+type ctlRow map[string]interface{}
+
+type ctlTable struct {
+	headers []string
+	rows    []ctlRow
+}
+
+func (t ctlTable) MarshalJSON() ([]byte, error) {
+	if t.rows == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(t.rows)
+}
+
+func (c *ctlClient) do(method, path string, out interface{}) error {
+	req, err := http.NewRequest(method, c.server+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error interface{} `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != nil {
+			if nested, ok := apiErr.Error.(map[string]interface{}); ok {
+				return fmt.Errorf("%s: %v", resp.Status, nested["message"])
+			}
+			return fmt.Errorf("%s: %v", resp.Status, apiErr.Error)
+		}
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, out)
+}
+
+func (c *ctlClient) runningRows() ([]ctlRow, error) {
+	var running struct {
+		Running []ctlRow `json:"running"`
+	}
+	if err := c.do("GET", "/running", &running); err != nil {
+		return nil, err
+	}
+	return running.Running, nil
+}
+
+// listModels lists the models of /v1/models with their state
+func (c *ctlClient) listModels() (ctlTable, error) {
+	var models struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := c.do("GET", "/v1/models", &models); err != nil {
+		return ctlTable{}, err
+	}
+	running, err := c.runningRows()
+	if err != nil {
+		return ctlTable{}, err
+	}
+
+	states := make(map[string]interface{})
+	for _, row := range running {
+		if model, ok := row["model"].(string); ok {
+			states[model] = row["state"]
+		}
+	}
+
+	table := ctlTable{headers: []string{"model", "state"}}
+	for _, model := range models.Data {
+		state, found := states[model.ID]
+		if !found {
+			state = "stopped"
+		}
+		table.rows = append(table.rows, ctlRow{"model": model.ID, "state": state})
+	}
+	return table, nil
+}
+
+// running lists the processes of /running
+func (c *ctlClient) running() (ctlTable, error) {
+	rows, err := c.runningRows()
+	return ctlTable{headers: []string{"model", "state", "port", "proxy"}, rows: rows}, err
+}
+
+func (c *ctlClient) modelAction(model, action string) (ctlTable, error) {
+	var row ctlRow
+	err := c.do("POST", "/api/models/"+url.PathEscape(model)+"/"+action, &row)
+	return ctlTable{headers: []string{"model", "state"}, rows: []ctlRow{row}}, err
+}
+
+func printTable(w io.Writer, table ctlTable) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(table.headers, "\t")))
+	for _, row := range table.rows {
+		values := make([]string, len(table.headers))
+		for i, header := range table.headers {
+			if value, found := row[header]; found && value != nil {
+				values[i] = fmt.Sprint(value)
+			} else {
+				values[i] = "-"
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	tw.Flush()
+}
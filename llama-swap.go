@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mostlygeek/llama-swap/proxy"
@@ -16,10 +21,31 @@ var commit string = "abcd1234"
 var date = "unknown"
 
 func main() {
+	// `llama-swap ctl [flags] models ...` manages a running llama-swap, see ctl.go
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		os.Exit(runCtl(os.Args[2:], os.Stdout))
+	}
+
+	// `llama-swap replay [flags] FILE...` replays recorded requests, see replay.go
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:], os.Stdout))
+	}
+
+	// `llama-swap doctor [flags]` checks the models and exits
+	runDoctor := len(os.Args) > 1 && os.Args[1] == "doctor"
+	if runDoctor {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	// Define a command-line flag for the port
 	configPath := flag.String("config", "config.yaml", "config file name")
-	listenStr := flag.String("listen", ":8080", "listen ip/port")
+	configDir := flag.String("config-dir", "", "directory of YAML files merged into the config, e.g. /etc/llama-swap/conf.d")
+	listenStr := flag.String("listen", ":8080", "listen ip/port or unix:/path/to.sock")
 	showVersion := flag.Bool("version", false, "show version of build")
+	workspaceDir := flag.String("workspace", "", "directory of named config files that can be switched at runtime")
+	dryRun := flag.Bool("dry-run", false, "validate the config and exit")
+	drainTimeout := flag.Duration("drain-timeout", 30*time.Second, "max time to wait for in-flight requests when draining")
+	noReap := flag.Bool("no-reap", false, "do not stop model processes left running by a previous llama-swap")
 
 	flag.Parse() // Parse the command-line flags
 
@@ -28,32 +54,199 @@ func main() {
 		os.Exit(0)
 	}
 
-	config, err := proxy.LoadConfig(*configPath)
+	if *workspaceDir != "" && *configDir != "" {
+		fmt.Println("Error: --workspace and --config-dir can not be used together")
+		os.Exit(1)
+	}
+
+	var workspace *proxy.Workspace
+	if *workspaceDir != "" {
+		initial := strings.TrimSuffix(filepath.Base(*configPath), filepath.Ext(*configPath))
+		ws, err := proxy.OpenWorkspace(*workspaceDir, initial)
+		if err != nil {
+			fmt.Printf("Error opening workspace: %v\n", err)
+			os.Exit(1)
+		}
+
+		path, err := ws.Path(ws.Active())
+		if err != nil {
+			fmt.Printf("Error opening workspace: %v\n", err)
+			os.Exit(1)
+		}
+		*configPath = path
+		workspace = ws
+	}
+
+	if *dryRun {
+		os.Exit(validateConfig(*configPath, *configDir))
+	}
+
+	var config *proxy.Config
+	var err error
+	if *configDir != "" {
+		config, err = proxy.LoadConfigWithDir(*configPath, *configDir)
+	} else {
+		config, err = proxy.LoadConfig(*configPath)
+	}
 	if err != nil {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
 
+	if runDoctor {
+		if !proxy.Doctor(config, os.Stdout) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if mode := os.Getenv("GIN_MODE"); mode != "" {
 		gin.SetMode(mode)
 	} else {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// sockets passed by systemd, taken before model commands could inherit
+	// LISTEN_FDS, see proxy/listeners.go
+	activated, activatedNames, err := proxy.SystemdListeners()
+	if err != nil {
+		fmt.Printf("Server error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// before standby models start on the ports, see proxy/stale.go
+	if !*noReap {
+		proxy.ReapStaleProcesses(config, os.Stdout)
+	}
+
 	proxyManager := proxy.New(config)
+	proxyManager.SetDrainTimeout(*drainTimeout)
+	// the config APIs edit a single file, they are not available when the
+	// config is merged from a directory
+	if *configDir == "" {
+		proxyManager.SetConfigPath(*configPath)
+	}
+	if workspace != nil {
+		proxyManager.SetWorkspace(workspace)
+	}
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-sigChan
 		fmt.Println("Shutting down llama-swap")
+		proxy.SdNotify("STOPPING=1")
 		proxyManager.StopProcesses()
 		os.Exit(0)
 	}()
 
-	fmt.Println("llama-swap listening on " + *listenStr)
-	if err := proxyManager.Run(*listenStr); err != nil {
+	// listen.proxy in the config is used unless --listen is given
+	listenFlagSet := false
+	flag.Visit(func(f *flag.Flag) {
+		listenFlagSet = listenFlagSet || f.Name == "listen"
+	})
+	if !listenFlagSet && config.Listen.Proxy != "" {
+		*listenStr = config.Listen.Proxy
+	}
+
+	// with socket activation the socket named admin serves the management
+	// endpoints and the first other socket the proxy endpoints
+	var listener, adminListener net.Listener
+	for i, activatedListener := range activated {
+		switch {
+		case activatedNames[i] == "admin" && adminListener == nil:
+			adminListener = activatedListener
+		case listener == nil:
+			listener = activatedListener
+		default:
+			activatedListener.Close()
+		}
+	}
+	if len(activated) > 0 && listener == nil {
+		fmt.Println("Server error: socket activation without a proxy socket")
+		os.Exit(1)
+	}
+
+	if listener == nil {
+		listener, err = proxy.Listen(*listenStr)
+		if err != nil {
+			fmt.Printf("Server error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// management endpoints on their own listener, see proxy/listeners.go
+	if adminListener == nil && config.Listen.Admin != "" {
+		adminListener, err = proxy.Listen(config.Listen.Admin)
+		if err != nil {
+			fmt.Printf("Server error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// tell systemd (Type=notify) the listener is up, or once the standby
+	// models are ready with blockUntilStandbyReady
+	notifyReady := func() {
+		if err := proxy.SdNotify("READY=1"); err != nil {
+			fmt.Printf("Error notifying systemd: %v\n", err)
+		}
+		if interval, ok := proxy.WatchdogInterval(); ok {
+			go proxyManager.RunWatchdog(proxy.ListenAddr(listener), interval)
+		}
+	}
+	if config.BlockUntilStandbyReady {
+		go func() {
+			proxyManager.WaitForStandby()
+			notifyReady()
+		}()
+	} else {
+		notifyReady()
+	}
+
+	fmt.Println("llama-swap listening on " + proxy.ListenAddr(listener))
+	if adminListener != nil {
+		fmt.Println("llama-swap management endpoints listening on " + proxy.ListenAddr(adminListener))
+		err = proxyManager.RunListeners(listener, adminListener)
+	} else {
+		err = proxyManager.RunListener(listener)
+	}
+	if err != nil {
 		fmt.Printf("Server error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// validateConfig prints the issues found in the config file and returns the
+// exit code
+func validateConfig(path, dir string) int {
+	var data []byte
+	var err error
+	if dir != "" {
+		// errors of the merged config name the file they are in
+		if _, err = proxy.LoadConfigWithDir(path, dir); err == nil {
+			data, _, err = proxy.MergeConfigFiles(path, dir)
+		}
+		path = dir
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return 1
+	}
+
+	_, issues := proxy.ValidateConfig(bytes.NewReader(data))
+	for _, issue := range issues {
+		if issue.Model != "" {
+			fmt.Printf("%s: %s: %s\n", issue.Level, issue.Model, issue.Message)
+		} else {
+			fmt.Printf("%s: %s\n", issue.Level, issue.Message)
+		}
+	}
+
+	if proxy.ValidationFailed(issues) {
+		return 1
+	}
+	fmt.Printf("%s is valid\n", path)
+	return 0
+}
@@ -24,6 +24,9 @@ func main() {
 
 	silent := flag.Bool("silent", false, "disable all logging")
 
+	// accepted like llama-server's --rpc, for testing cluster workers
+	flag.String("rpc", "", "ignored, addresses of rpc-server workers")
+
 	flag.Parse() // Parse the command-line flags
 
 	// Create a new Gin router
@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gin context key for the A/B test variant of a request, test/model
+const ctxKeyABVariant = "llama-swap.abvariant"
+
+// ABTestConfig splits the requests to a published model name between
+// variants by their weight
+type ABTestConfig struct {
+	Variants []ABVariant `yaml:"variants"`
+
+	// apiKey or client sends the requests of an API key or client to the
+	// same variant, "" picks a variant for every request
+	StickyBy string `yaml:"stickyBy"`
+}
+
+type ABVariant struct {
+	Model  string `yaml:"model"`
+	Weight int    `yaml:"weight"`
+}
+
+func (c *Config) validateABTests() error {
+	for name, test := range c.ABTests {
+		if _, found := c.Models[name]; found {
+			return fmt.Errorf("abTest %s collides with model %s", name, name)
+		}
+		if model, found := c.aliases[name]; found {
+			return fmt.Errorf("abTest %s collides with an alias of model %s", name, model)
+		}
+		if _, found := c.VirtualModels[name]; found {
+			return fmt.Errorf("abTest %s collides with virtual model %s", name, name)
+		}
+		if strings.Contains(name, PROFILE_SPLIT_CHAR) {
+			return fmt.Errorf("abTest %s can not contain %s", name, PROFILE_SPLIT_CHAR)
+		}
+
+		switch test.StickyBy {
+		case "", "apiKey", "client":
+		default:
+			return fmt.Errorf("abTest %s: unknown stickyBy %s, use apiKey or client", name, test.StickyBy)
+		}
+		if len(test.Variants) == 0 {
+			return fmt.Errorf("abTest %s needs variants", name)
+		}
+		for _, variant := range test.Variants {
+			if variant.Weight <= 0 {
+				return fmt.Errorf("abTest %s: weight of variant %s must be positive", name, variant.Model)
+			}
+			if _, found := c.VirtualModels[variant.Model]; found {
+				continue
+			}
+			if _, found := c.RealModelName(variant.Model); !found {
+				return fmt.Errorf("abTest %s: unknown variant model %s", name, variant.Model)
+			}
+		}
+	}
+	return nil
+}
+
+// pick returns the variant for the sticky key, a random one without a key
+func (t ABTestConfig) pick(name, key string) ABVariant {
+	total := 0
+	for _, variant := range t.Variants {
+		total += variant.Weight
+	}
+
+	var n int
+	if key == "" {
+		n = rand.N(total)
+	} else {
+		hash := fnv.New32a()
+		hash.Write([]byte(name + "/" + key))
+		n = int(hash.Sum32() % uint32(total))
+	}
+
+	for _, variant := range t.Variants {
+		if n < variant.Weight {
+			return variant
+		}
+		n -= variant.Weight
+	}
+	return t.Variants[len(t.Variants)-1]
+}
+
+// abVariantMetrics is the usage of a variant since llama-swap started
+type abVariantMetrics struct {
+	Model string `json:"model"`
+	clientModelMetrics
+	AvgDurationMs int64 `json:"avgDurationMs"`
+}
+
+type abTestMetrics struct {
+	Name     string             `json:"name"`
+	Variants []abVariantMetrics `json:"variants"`
+}
+
+type abTestTracker struct {
+	sync.Mutex
+	// test to variant model to its usage
+	tests map[string]map[string]*clientModelMetrics
+}
+
+func newABTestTracker() *abTestTracker {
+	return &abTestTracker{tests: make(map[string]map[string]*clientModelMetrics)}
+}
+
+func (t *abTestTracker) record(test, variant string, usage clientModelMetrics) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.tests[test] == nil {
+		t.tests[test] = make(map[string]*clientModelMetrics)
+	}
+	if t.tests[test][variant] == nil {
+		t.tests[test][variant] = &clientModelMetrics{}
+	}
+	t.tests[test][variant].add(usage)
+}
+
+// list returns the configured tests and their variants, variants without
+// requests yet included
+func (t *abTestTracker) list(tests map[string]ABTestConfig) []abTestMetrics {
+	t.Lock()
+	defer t.Unlock()
+
+	list := make([]abTestMetrics, 0, len(tests))
+	for name, test := range tests {
+		metrics := abTestMetrics{Name: name, Variants: make([]abVariantMetrics, 0, len(test.Variants))}
+		for _, variant := range test.Variants {
+			usage := abVariantMetrics{Model: variant.Model}
+			if recorded := t.tests[name][variant.Model]; recorded != nil {
+				usage.clientModelMetrics = *recorded
+			}
+			if usage.Requests > 0 {
+				usage.AvgDurationMs = usage.DurationMs / usage.Requests
+			}
+			metrics.Variants = append(metrics.Variants, usage)
+		}
+		list = append(list, metrics)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// applyABTest sends requests to the published name of an A/B test to one of
+// its variants, keeping the profile prefix. It returns the model and body to
+// proxy, a func to record the request in the metrics of the variant once it
+// is handled and false when an error was sent.
+func (pm *ProxyManager) applyABTest(c *gin.Context, model string, requestBody map[string]interface{}, bodyBytes []byte) (string, []byte, func(), bool) {
+	profileName, name, found := strings.Cut(model, PROFILE_SPLIT_CHAR)
+	if !found {
+		profileName, name = "", model
+	}
+	test, found := pm.currentConfig().ABTests[name]
+	if !found {
+		return model, bodyBytes, func() {}, true
+	}
+
+	var key string
+	switch test.StickyBy {
+	case "apiKey":
+		key = quotaKey(c)
+	case "client":
+		key = requestClient(c)
+	}
+	variant := test.pick(name, key)
+	traceRequest(c, "abTest %q picked variant %q", name, variant.Model)
+	c.Set(ctxKeyABVariant, name+"/"+variant.Model)
+
+	model = variant.Model
+	if profileName != "" {
+		model = ProcessKeyName(profileName, model)
+	}
+	requestBody["model"] = model
+	bodyBytes, err := json.Marshal(requestBody)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error encoding JSON %s", err.Error()))
+		return "", nil, nil, false
+	}
+
+	start := time.Now()
+	tail := &tailCaptureWriter{ResponseWriter: c.Writer}
+	c.Writer = tail
+	return model, bodyBytes, func() {
+		usage, _ := requestUsage(c, tail)
+		metrics := clientModelMetrics{
+			Requests:         1,
+			PromptTokens:     int64(usage.PromptTokens),
+			CompletionTokens: int64(usage.CompletionTokens),
+			DurationMs:       time.Since(start).Milliseconds(),
+		}
+		if c.Writer.Status() >= http.StatusBadRequest {
+			metrics.Errors = 1
+		}
+		pm.abTests.record(name, variant.Model, metrics)
+	}, true
+}
+
+func (pm *ProxyManager) apiListABTests(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"abTests": pm.abTests.list(pm.currentConfig().ABTests)})
+}
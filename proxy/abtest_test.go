@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_ABTests(t *testing.T) {
+	tests := []struct {
+		name     string
+		abTest   string
+		expected string
+	}{
+		{"unknown model", "variants: [{model: model9, weight: 1}]", "abTest model-ab: unknown variant model model9"},
+		{"no weight", "variants: [{model: model1}]", "abTest model-ab: weight of variant model1 must be positive"},
+		{"no variants", "stickyBy: apiKey", "abTest model-ab needs variants"},
+		{"sticky", "{variants: [{model: model1, weight: 1}], stickyBy: cookie}", "abTest model-ab: unknown stickyBy cookie"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+abTests:
+  model-ab:
+    ` + tt.abTest + "\n"))
+			assert.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestABTest_Pick(t *testing.T) {
+	test := ABTestConfig{Variants: []ABVariant{{Model: "m-q4", Weight: 80}, {Model: "m-q5", Weight: 20}}}
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		counts[test.pick("m", "").Model]++
+	}
+	assert.InDelta(t, 800, counts["m-q4"], 100)
+	assert.InDelta(t, 200, counts["m-q5"], 100)
+
+	// the same key always gets the same variant
+	first := test.pick("m", "sha256:abc")
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, first, test.pick("m", "sha256:abc"))
+	}
+
+	sticky := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		sticky[test.pick("m", fmt.Sprintf("key%d", i)).Model]++
+	}
+	assert.InDelta(t, 800, sticky["m-q4"], 100)
+}
+
+func TestProxyManager_ABTest(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+			"model2": getTestSimpleResponderConfig("model2"),
+		},
+		ABTests: map[string]ABTestConfig{
+			"model-ab": {Variants: []ABVariant{{Model: "model1", Weight: 50}, {Model: "model2", Weight: 50}}, StickyBy: "apiKey"},
+		},
+	})
+	defer proxy.StopProcesses()
+
+	var first string
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model-ab"}`))
+		req.Header.Set("Authorization", "Bearer key1")
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		if first == "" {
+			first = w.Body.String()
+		}
+		assert.Equal(t, first, w.Body.String())
+	}
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/abtests", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		ABTests []abTestMetrics `json:"abTests"`
+	}
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response)) && assert.Len(t, response.ABTests, 1) {
+		assert.Equal(t, "model-ab", response.ABTests[0].Name)
+		requests := map[string]int64{}
+		for _, variant := range response.ABTests[0].Variants {
+			requests[variant.Model] = variant.Requests
+		}
+		assert.Equal(t, int64(3), requests[first])
+		assert.Len(t, requests, 2)
+	}
+}
@@ -0,0 +1,354 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gin context keys set by the proxy handlers for the access log
+const (
+	ctxKeyModel    = "llama-swap.model"
+	ctxKeyProfile  = "llama-swap.profile"
+	ctxKeySwapped  = "llama-swap.swapped"
+	ctxKeyMetadata = "llama-swap.metadata"
+)
+
+type AccessLogConfig struct {
+	// common, combined (default) or json
+	Format string `yaml:"format"`
+	Path   string `yaml:"path"`
+
+	// rotate the file when it reaches this size, 0 = never
+	RotateMB int `yaml:"rotateMB"`
+	// number of rotated files to keep
+	Keep int `yaml:"keep"`
+}
+
+func (a AccessLogConfig) validate() error {
+	switch a.Format {
+	case "", "common", "combined", "json":
+	default:
+		return fmt.Errorf("unknown accessLog format: %s", a.Format)
+	}
+
+	if a.RotateMB < 0 || a.Keep < 0 {
+		return fmt.Errorf("accessLog rotateMB and keep must not be negative")
+	}
+	return nil
+}
+
+// AccessLog writes one line per request in the Common or Combined Log Format,
+// or as a JSON object
+type AccessLog struct {
+	sync.Mutex
+
+	format string
+	out    io.Writer
+}
+
+func NewAccessLog(config AccessLogConfig) (*AccessLog, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := newRotatingFile(config.Path, int64(config.RotateMB)*1024*1024, config.Keep)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAccessLogWriter(config.Format, file), nil
+}
+
+func newAccessLogWriter(format string, out io.Writer) *AccessLog {
+	if format == "" {
+		format = "combined"
+	}
+	return &AccessLog{format: format, out: out}
+}
+
+// Middleware logs requests after they are handled
+func (a *AccessLog) Middleware(c *gin.Context) {
+	start := time.Now()
+
+	// capture these because /upstream/:model rewrites them in c.Next()
+	clientIP := c.ClientIP()
+	path := c.Request.URL.Path
+	requestLine := fmt.Sprintf("%s %s %s", c.Request.Method, c.Request.URL.RequestURI(), c.Request.Proto)
+
+	var tail *tailCaptureWriter
+	if a.format == "json" {
+		tail = &tailCaptureWriter{ResponseWriter: c.Writer}
+		c.Writer = tail
+	}
+
+	c.Next()
+
+	var line []byte
+	if a.format == "json" {
+		usage, disconnected := requestUsage(c, tail)
+		line = a.jsonLine(c, start, clientIP, path, usage, disconnected)
+	} else {
+		line = a.clfLine(c, start, clientIP, requestLine)
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	a.out.Write(append(line, '\n'))
+}
+
+func (a *AccessLog) clfLine(c *gin.Context, start time.Time, clientIP string, requestLine string) []byte {
+	user := "-"
+	if username, _, ok := c.Request.BasicAuth(); ok && username != "" {
+		user = username
+	}
+
+	size := "-"
+	if n := c.Writer.Size(); n > 0 {
+		size = strconv.Itoa(n)
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] %q %d %s",
+		clientIP,
+		user,
+		start.Format("02/Jan/2006:15:04:05 -0700"),
+		requestLine,
+		c.Writer.Status(),
+		size,
+	)
+
+	if a.format == "combined" {
+		line += fmt.Sprintf(" %q %q", dashIfEmpty(c.Request.Referer()), dashIfEmpty(c.Request.UserAgent()))
+	}
+
+	return []byte(line)
+}
+
+type accessLogRecord struct {
+	Time             string  `json:"time"`
+	ClientIP         string  `json:"client_ip"`
+	Method           string  `json:"method"`
+	Path             string  `json:"path"`
+	Status           int     `json:"status"`
+	Bytes            int     `json:"bytes"`
+	DurationMs       float64 `json:"duration_ms"`
+	UserAgent        string  `json:"user_agent"`
+	Model            string  `json:"model,omitempty"`
+	Profile          string  `json:"profile,omitempty"`
+	Swapped          bool    `json:"swapped"`
+	Tenant           string  `json:"tenant,omitempty"`
+	Client           string  `json:"client"`
+	ABVariant        string  `json:"ab_variant,omitempty"`
+	PromptTokens     int     `json:"prompt_tokens,omitempty"`
+	CompletionTokens int     `json:"completion_tokens,omitempty"`
+
+	// the tokens are estimated, see partialusage.go
+	ClientDisconnected bool `json:"client_disconnected,omitempty"`
+
+	// the model's metadata, to group records without parsing model names
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func (a *AccessLog) jsonLine(c *gin.Context, start time.Time, clientIP string, path string, usage tokenUsage, disconnected bool) []byte {
+	record := accessLogRecord{
+		Time:             start.Format(time.RFC3339Nano),
+		ClientIP:         clientIP,
+		Method:           c.Request.Method,
+		Path:             path,
+		Status:           c.Writer.Status(),
+		Bytes:            max(c.Writer.Size(), 0),
+		DurationMs:       float64(time.Since(start).Microseconds()) / 1000,
+		UserAgent:        c.Request.UserAgent(),
+		Model:            c.GetString(ctxKeyModel),
+		Profile:          c.GetString(ctxKeyProfile),
+		Swapped:          c.GetBool(ctxKeySwapped),
+		Tenant:           c.GetString(ctxKeyTenant),
+		Client:           requestClient(c),
+		ABVariant:        c.GetString(ctxKeyABVariant),
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+
+		ClientDisconnected: disconnected,
+	}
+	if metadata, ok := c.Get(ctxKeyMetadata); ok {
+		record.Metadata, _ = metadata.(map[string]string)
+	}
+	line, _ := json.Marshal(record)
+	return line
+}
+
+type tokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// tailCaptureWriter keeps the end of the response body to find the token
+// usage, which is at the end of JSON responses and SSE streams
+type tailCaptureWriter struct {
+	gin.ResponseWriter
+	tail []byte
+
+	// SSE chunks written, see partialusage.go
+	chunks int
+}
+
+const tailCaptureSize = 8 * 1024
+
+func (w *tailCaptureWriter) Write(b []byte) (int, error) {
+	w.keep(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *tailCaptureWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *tailCaptureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *tailCaptureWriter) keep(b []byte) {
+	w.countChunks(b)
+	w.tail = append(w.tail, b...)
+	if len(w.tail) > tailCaptureSize {
+		w.tail = w.tail[len(w.tail)-tailCaptureSize:]
+	}
+}
+
+func (w *tailCaptureWriter) usage() tokenUsage {
+	var usage tokenUsage
+	idx := bytes.LastIndex(w.tail, []byte(`"usage"`))
+	if idx == -1 {
+		return usage
+	}
+
+	rest := w.tail[idx+len(`"usage"`):]
+	if start := bytes.IndexByte(rest, '{'); start != -1 {
+		json.NewDecoder(bytes.NewReader(rest[start:])).Decode(&usage)
+	}
+	return usage
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// rotatingFile is an append only file that is rotated to path.1 ... path.N
+// when it grows beyond maxBytes or was opened more than maxAge ago
+type rotatingFile struct {
+	sync.Mutex
+
+	path     string
+	maxBytes int64
+	maxAge   time.Duration
+	keep     int
+
+	// nil after Close, opened again by the next Write
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+func newRotatingFile(path string, maxBytes int64, keep int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxBytes: maxBytes, keep: keep}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	// the age is kept when the file is opened again after Close
+	if r.opened.IsZero() {
+		r.opened = time.Now()
+	}
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	tooLarge := r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes
+	tooOld := r.maxAge > 0 && r.size > 0 && time.Since(r.opened) > r.maxAge
+	if tooLarge || tooOld {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+	r.opened = time.Time{}
+
+	if r.keep > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.keep))
+		for i := r.keep - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+
+	return r.open()
+}
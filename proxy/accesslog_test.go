@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog_Formats(t *testing.T) {
+	for format, pattern := range map[string]string{
+		"common":   `^192\.0\.2\.1 - bob \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /hello\?x=1 HTTP/1\.1" 200 5\n$`,
+		"combined": `^192\.0\.2\.1 - bob \[.+\] "GET /hello\?x=1 HTTP/1\.1" 200 5 "-" "test-agent"\n$`,
+	} {
+		var out bytes.Buffer
+		accessLog := newAccessLogWriter(format, &out)
+
+		engine := gin.New()
+		engine.Use(accessLog.Middleware)
+		engine.GET("/hello", func(c *gin.Context) {
+			c.String(http.StatusOK, "hello")
+		})
+
+		req := httptest.NewRequest("GET", "/hello?x=1", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.SetBasicAuth("bob", "secret")
+		req.Header.Set("User-Agent", "test-agent")
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Regexp(t, regexp.MustCompile(pattern), out.String(), format)
+	}
+}
+
+func TestAccessLog_WritesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "access.log")
+	_, err := NewAccessLog(AccessLogConfig{Path: path, Format: "apache"})
+	assert.Error(t, err)
+
+	_, err = NewAccessLog(AccessLogConfig{Path: path})
+	assert.NoError(t, err)
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
+
+func TestAccessLog_JSON(t *testing.T) {
+	var out bytes.Buffer
+	accessLog := newAccessLogWriter("json", &out)
+
+	engine := gin.New()
+	engine.Use(accessLog.Middleware)
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Set(ctxKeyModel, "model1")
+		c.Set(ctxKeyProfile, "coding")
+		c.Set(ctxKeySwapped, true)
+		c.Set(ctxKeyMetadata, map[string]string{"quant": "Q4_K_M", "backend": "llama.cpp"})
+		c.Writer.Write([]byte(`data: {"choices":[]}` + "\n\n"))
+		c.Writer.Write([]byte(`data: {"usage":{"completion_tokens":12,"prompt_tokens":34,"total_tokens":46}}` + "\n\n"))
+	})
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record accessLogRecord
+	if !assert.NoError(t, json.Unmarshal(out.Bytes(), &record)) {
+		return
+	}
+	assert.Equal(t, "/v1/chat/completions", record.Path)
+	assert.Equal(t, http.StatusOK, record.Status)
+	assert.Equal(t, "model1", record.Model)
+	assert.Equal(t, "coding", record.Profile)
+	assert.True(t, record.Swapped)
+	assert.Equal(t, 34, record.PromptTokens)
+	assert.Equal(t, 12, record.CompletionTokens)
+	assert.Equal(t, "test-agent", record.UserAgent)
+	assert.Equal(t, map[string]string{"quant": "Q4_K_M", "backend": "llama.cpp"}, record.Metadata)
+}
+
+func TestAccessLog_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	file, err := newRotatingFile(path, 10, 2)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, line := range []string{"11111111\n", "22222222\n", "33333333\n", "44444444\n"} {
+		_, err := file.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+
+	for suffix, expected := range map[string]string{
+		"":   "44444444\n",
+		".1": "33333333\n",
+		".2": "22222222\n",
+	} {
+		data, err := os.ReadFile(path + suffix)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(data))
+	}
+
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isAdminRequest checks the bearer token of the request against adminKeys
+func (pm *ProxyManager) isAdminRequest(c *gin.Context) bool {
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return false
+	}
+	for _, key := range pm.currentConfig().AdminKeys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAdmin rejects requests without an admin key
+func (pm *ProxyManager) requireAdmin(c *gin.Context) {
+	if !pm.isAdminRequest(c) {
+		pm.sendErrorResponse(c, http.StatusForbidden, "an admin key is required")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// requireAdminWhenSet rejects requests without an admin key when adminKeys
+// are set. Without them, and on the admin listener of RunListeners, the
+// request is allowed like before adminKeys existed.
+func (pm *ProxyManager) requireAdminWhenSet(c *gin.Context) {
+	if len(pm.currentConfig().AdminKeys) == 0 || c.Request.Context().Value(adminListenerKey{}) != nil {
+		c.Next()
+		return
+	}
+	pm.requireAdmin(c)
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyManager_ManagementActionsRequireAdmin(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		AdminKeys:          []string{testAdminKey},
+		Models:             map[string]ModelConfig{"model1": getTestSimpleResponderConfig("model1")},
+	})
+	defer proxy.StopProcesses()
+
+	for _, path := range []string{
+		"/api/drain",
+		"/api/config/rollback",
+		"/api/workspaces/a/activate",
+		"/api/models/model1/load",
+		"/api/models/model1/unload",
+		"/api/models/model1/pin",
+	} {
+		for _, token := range []string{"", "user-key"} {
+			req := httptest.NewRequest("POST", path, nil)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			w := httptest.NewRecorder()
+			proxy.HandlerFunc(w, req)
+			assert.Equal(t, http.StatusForbidden, w.Code, path)
+		}
+	}
+
+	assert.False(t, proxy.IsDraining())
+	assert.Empty(t, proxy.currentProcesses)
+}
+
+func TestProxyManager_ManagementReadsRequireAdmin(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		AdminKeys:          []string{testAdminKey},
+		Models:             map[string]ModelConfig{"model1": getTestSimpleResponderConfig("model1")},
+	})
+	defer proxy.StopProcesses()
+
+	for _, endpoint := range []struct{ method, path string }{
+		{"GET", "/api/config/backups"},
+		{"POST", "/api/config/validate"},
+		{"GET", "/api/logs/search"},
+		{"GET", "/api/incidents"},
+		{"GET", "/metrics"},
+	} {
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest(endpoint.method, endpoint.path, nil))
+		assert.Equal(t, http.StatusForbidden, w.Code, endpoint.path)
+
+		w = httptest.NewRecorder()
+		proxy.HandlerFunc(w, newAdminRequest(endpoint.method, endpoint.path, nil))
+		assert.NotEqual(t, http.StatusForbidden, w.Code, endpoint.path)
+	}
+
+	// the event stream does not end, only the rejection is checked
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/events", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestProxyManager_ManagementActionsWithoutAdminKeys(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": getTestSimpleResponderConfig("model1")},
+	})
+	defer proxy.StopProcesses()
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/api/drain", nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Eventually(t, proxy.IsDraining, time.Second, 10*time.Millisecond)
+}
+
+func TestProxyManager_AdminListenerTrusted(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		AdminKeys:          []string{testAdminKey},
+		Models:             map[string]ModelConfig{"model1": getTestSimpleResponderConfig("model1")},
+	})
+	defer proxy.StopProcesses()
+
+	req := httptest.NewRequest("POST", "/api/models/model1/load", nil)
+	req = req.WithContext(context.WithValue(req.Context(), adminListenerKey{}, true))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// editing the config file still needs a key
+	req = httptest.NewRequest("GET", "/api/config/models", nil)
+	req = req.WithContext(context.WithValue(req.Context(), adminListenerKey{}, true))
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,219 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// largest model field read from a multipart request
+	maxModelFieldSize = 1024
+
+	// parts before the model field up to this size are kept in memory
+	maxMemorySpool = 64 * 1024
+)
+
+// spool keeps the parts before the model field, in memory while they are
+// small and in a temporary file once they grow larger
+type spool struct {
+	buf  bytes.Buffer
+	file *os.File
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if s.file == nil && s.buf.Len()+len(p) <= maxMemorySpool {
+		return s.buf.Write(p)
+	}
+
+	if s.file == nil {
+		file, err := os.CreateTemp("", "llama-swap-multipart-*")
+		if err != nil {
+			return 0, err
+		}
+		s.file = file
+		if _, err := s.buf.WriteTo(file); err != nil {
+			return 0, err
+		}
+	}
+	return s.file.Write(p)
+}
+
+// reader returns the spooled data from the start
+func (s *spool) reader() (io.Reader, error) {
+	if s.file == nil {
+		return &s.buf, nil
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return s.file, nil
+}
+
+func (s *spool) Close() {
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+	}
+}
+
+// switchWriter lets a multipart.Writer move from the spool to the
+// upstream request once the model is known
+type switchWriter struct {
+	w io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+// proxyMultipartHandler proxies multipart/form-data requests like
+// /v1/audio/transcriptions to the model in their model field. Parts before
+// the model field are spooled, the others are streamed, so memory use does
+// not grow with the size of audio files.
+func (pm *ProxyManager) proxyMultipartHandler(c *gin.Context) {
+	mediaType, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		pm.sendErrorResponse(c, http.StatusBadRequest, "expected a multipart/form-data request")
+		return
+	}
+
+	spooled := &spool{}
+	defer spooled.Close()
+
+	reader := multipart.NewReader(c.Request.Body, params["boundary"])
+	destination := &switchWriter{w: spooled}
+	writer := multipart.NewWriter(destination)
+
+	model := ""
+	for model == "" {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			pm.sendErrorResponse(c, http.StatusBadRequest, "missing 'model' field")
+			return
+		}
+		if err != nil {
+			pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid multipart request: %v", err))
+			return
+		}
+
+		if part.FormName() == "model" {
+			value, err := io.ReadAll(io.LimitReader(part, maxModelFieldSize))
+			if err != nil {
+				pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid multipart request: %v", err))
+				return
+			}
+			model = strings.TrimSpace(string(value))
+			if model == "" {
+				pm.sendErrorResponse(c, http.StatusBadRequest, "missing 'model' field")
+				return
+			}
+			if err := writer.WriteField("model", model); err != nil {
+				pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("unable to spool request: %v", err))
+				return
+			}
+			continue
+		}
+
+		if err := copyPart(writer, part); err != nil {
+			pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid multipart request: %v", err))
+			return
+		}
+	}
+	if spooled.file != nil {
+		traceRequest(c, "spooled the parts before the model field to %s", spooled.file.Name())
+	}
+	traceRequest(c, "requested model %q", model)
+
+	// in tasktype.go
+	if !pm.checkTaskType(c, model) {
+		return
+	}
+
+	// in ttl.go, only the header as there is no JSON body
+	if ttl, hasTTL, err := requestTTL(c, map[string]interface{}{}); err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	} else if hasTTL {
+		c.Set(ctxKeyRequestTTL, ttl)
+	}
+
+	// in clients.go
+	defer pm.recordClientMetrics(c, model)()
+
+	recordUsage, ok := pm.checkQuota(c, model)
+	if !ok {
+		return
+	}
+	defer recordUsage()
+
+	release, err := pm.acquireProfileSlot(c, model)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusServiceUnavailable, "request cancelled while waiting for a free slot")
+		return
+	}
+	defer release()
+
+	process, err := pm.swapModel(model)
+	if err != nil {
+		pm.sendSwapError(c, model, err)
+		return
+	}
+	applyRequestTTL(c, process)
+	setAccessLogKeys(c, model, process)
+
+	// the spooled parts first, then the rest of the client's request
+	body, pipe := io.Pipe()
+	go func() {
+		spooledReader, err := spooled.reader()
+		if err != nil {
+			pipe.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(pipe, spooledReader); err != nil {
+			pipe.CloseWithError(err)
+			return
+		}
+		destination.w = pipe
+
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				pipe.CloseWithError(err)
+				return
+			}
+			if err := copyPart(writer, part); err != nil {
+				pipe.CloseWithError(err)
+				return
+			}
+		}
+		pipe.CloseWithError(writer.Close())
+	}()
+	defer body.Close()
+
+	c.Request.Body = body
+	c.Request.ContentLength = -1
+	c.Request.Header.Del("Content-Length")
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	process.ProxyRequest(c.Writer, c.Request)
+}
+
+func copyPart(writer *multipart.Writer, part *multipart.Part) error {
+	partWriter, err := writer.CreatePart(part.Header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(partWriter, part)
+	return err
+}
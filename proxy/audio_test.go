@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyManager_AudioMultipart(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audio, _ := io.ReadAll(file)
+		fmt.Fprintf(w, "%s model=%s language=%s file=%s", r.URL.Path, r.FormValue("model"), r.FormValue("language"), audio)
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("whisper")
+	modelConfig.Proxy = upstream.URL
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"whisper": modelConfig},
+	})
+	defer proxy.StopProcesses()
+
+	for _, path := range []string{"/v1/audio/transcriptions", "/v1/audio/translations"} {
+		// the file comes before the model and is spooled
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "audio.wav")
+		part.Write([]byte("RIFF-audio"))
+		writer.WriteField("model", "whisper")
+		writer.WriteField("language", "de")
+		writer.Close()
+
+		req := httptest.NewRequest("POST", path, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, path+" model=whisper language=de file=RIFF-audio", w.Body.String())
+	}
+}
+
+func TestProxyManager_AudioMultipartErrors(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"whisper": getTestSimpleResponderConfig("whisper")},
+	})
+	defer proxy.StopProcesses()
+
+	req := httptest.NewRequest("POST", "/v1/audio/transcriptions", strings.NewReader(`{"model":"whisper"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("language", "de")
+	writer.Close()
+
+	req = httptest.NewRequest("POST", "/v1/audio/transcriptions", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "missing 'model' field")
+}
+
+func TestSpool(t *testing.T) {
+	spooled := &spool{}
+	spooled.Write([]byte("small"))
+	assert.Nil(t, spooled.file)
+
+	large := bytes.Repeat([]byte("a"), maxMemorySpool)
+	spooled.Write(large)
+	if !assert.NotNil(t, spooled.file) {
+		return
+	}
+
+	reader, err := spooled.reader()
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(reader)
+	assert.Equal(t, append([]byte("small"), large...), data)
+
+	name := spooled.file.Name()
+	spooled.Close()
+	_, err = os.Stat(name)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestProxyManager_AudioMultipartIsStreamed(t *testing.T) {
+	received := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			if part.FormName() == "file" {
+				chunk := make([]byte, 5)
+				io.ReadFull(part, chunk)
+				received <- string(chunk)
+			}
+			io.Copy(io.Discard, part)
+		}
+		fmt.Fprint(w, "done")
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("whisper")
+	modelConfig.Proxy = upstream.URL
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"whisper": modelConfig},
+	})
+	defer proxy.StopProcesses()
+
+	body, client := io.Pipe()
+	writer := multipart.NewWriter(client)
+	req := httptest.NewRequest("POST", "/v1/audio/transcriptions", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		proxy.HandlerFunc(w, req)
+		close(done)
+	}()
+
+	writer.WriteField("model", "whisper")
+	part, _ := writer.CreateFormFile("file", "audio.wav")
+	part.Write([]byte("first"))
+
+	// the upstream gets the start of the file while it is still uploaded
+	select {
+	case chunk := <-received:
+		assert.Equal(t, "first", chunk)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the upload was not streamed to the upstream")
+	}
+
+	part.Write([]byte("second"))
+	writer.Close()
+	client.Close()
+	<-done
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "done", w.Body.String())
+}
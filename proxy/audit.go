@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// request bodies are cut after this many bytes in the audit log
+	maxAuditPayload = 64 * 1024
+
+	// entries kept in memory without an audit file
+	maxAuditEntries = 1000
+
+	defaultAuditLimit = 100
+)
+
+// AuditConfig appends the management actions to a file of JSON lines
+type AuditConfig struct {
+	Path string `yaml:"path"`
+}
+
+// AuditEntry is a management action, like unloading a model or editing the
+// config, and who sent it
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+
+	// the tenant or a hash of the API key, like quotas use
+	Key      string `json:"key"`
+	ClientIP string `json:"clientIp"`
+	Status   int    `json:"status"`
+	Payload  string `json:"payload,omitempty"`
+}
+
+// auditLog keeps the recent entries and appends all of them to the audit
+// file when one is configured
+type auditLog struct {
+	sync.Mutex
+	path    string
+	file    *os.File
+	entries []AuditEntry
+}
+
+func newAuditLog(config AuditConfig) (*auditLog, error) {
+	a := &auditLog{path: config.Path}
+	if config.Path == "" {
+		return a, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+		return a, err
+	}
+	file, err := os.OpenFile(config.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return a, err
+	}
+	a.file = file
+	return a, nil
+}
+
+func (a *auditLog) record(entry AuditEntry) error {
+	a.Lock()
+	defer a.Unlock()
+
+	a.entries = append(a.entries, entry)
+	if len(a.entries) > maxAuditEntries {
+		a.entries = append([]AuditEntry(nil), a.entries[len(a.entries)-maxAuditEntries:]...)
+	}
+
+	if a.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = a.file.Write(append(line, '\n'))
+	return err
+}
+
+// auditQuery selects entries, empty fields match all
+type auditQuery struct {
+	since time.Time
+	key   string
+	path  string
+	limit int
+}
+
+func (q auditQuery) matches(entry AuditEntry) bool {
+	return !entry.Time.Before(q.since) &&
+		(q.key == "" || entry.Key == q.key) &&
+		(q.path == "" || strings.Contains(entry.Path, q.path))
+}
+
+// search returns the last matching entries, oldest first. The audit file
+// is read when there is one, to include entries from before a restart. It is
+// read without the lock so management requests are not held up.
+func (a *auditLog) search(q auditQuery) ([]AuditEntry, error) {
+	var matched []AuditEntry
+	add := func(entry AuditEntry) {
+		if q.matches(entry) {
+			matched = append(matched, entry)
+			if len(matched) > q.limit {
+				matched = matched[1:]
+			}
+		}
+	}
+
+	a.Lock()
+	if a.file == nil {
+		for _, entry := range a.entries {
+			add(entry)
+		}
+		a.Unlock()
+		return matched, nil
+	}
+	a.Unlock()
+
+	file, err := os.Open(a.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// entries are longer than their payload once it is escaped, a decoder
+	// has no limit on their size
+	decoder := json.NewDecoder(file)
+	for {
+		var entry AuditEntry
+		err := decoder.Decode(&entry)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// an entry may be partly written
+			return matched, nil
+		}
+		if err != nil {
+			return matched, err
+		}
+		add(entry)
+	}
+}
+
+// auditMiddleware records the management actions after they are handled,
+// also those that were rejected. GET requests only read and are skipped.
+func (pm *ProxyManager) auditMiddleware(c *gin.Context) {
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		c.Next()
+		return
+	}
+
+	entry := AuditEntry{
+		Time:     time.Now(),
+		Method:   c.Request.Method,
+		Path:     c.Request.URL.RequestURI(),
+		Key:      quotaKey(c),
+		ClientIP: c.ClientIP(),
+	}
+	if c.Request.Body != nil {
+		payload, _ := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditPayload))
+		c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(payload), c.Request.Body))
+		entry.Payload = string(payload)
+	}
+
+	c.Next()
+
+	entry.Status = c.Writer.Status()
+	if err := pm.auditLog.record(entry); err != nil {
+		fmt.Fprintf(pm.logMonitor, "!!! Unable to write audit log: %v\n", err)
+	}
+}
+
+// apiListAudit returns the management actions for GET /api/audit. since, key,
+// path and limit (default 100) select them.
+func (pm *ProxyManager) apiListAudit(c *gin.Context) {
+	q := auditQuery{key: c.Query("key"), path: c.Query("path"), limit: defaultAuditLimit}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			pm.sendErrorResponse(c, http.StatusBadRequest, "since must be an RFC 3339 time")
+			return
+		}
+		q.since = t
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			pm.sendErrorResponse(c, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		q.limit = n
+	}
+
+	entries, err := pm.auditLog.search(q)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("unable to read audit log: %v", err))
+		return
+	}
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
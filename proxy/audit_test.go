@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyManager_Audit(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	config := &Config{
+		HealthCheckTimeout: 15,
+		AdminKeys:          []string{testAdminKey},
+		Audit:              AuditConfig{Path: auditPath},
+		Models:             map[string]ModelConfig{"model1": getTestSimpleResponderConfig("model1")},
+	}
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, newAdminRequest("POST", "/api/models/model1/unload", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// rejected actions are recorded too
+	req := httptest.NewRequest("PUT", "/api/config/models/model2", strings.NewReader(`{"cmd":"server"}`))
+	req.Header.Set("Authorization", "Bearer user-key")
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// reads are not recorded
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/models/model1/status", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	listAudit := func(proxy *ProxyManager, query string) (int, []AuditEntry) {
+		req := newAdminRequest("GET", "/api/audit"+query, nil)
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		var response struct {
+			Entries []AuditEntry `json:"entries"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response.Entries
+	}
+
+	code, entries := listAudit(proxy, "")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "/api/models/model1/unload", entries[0].Path)
+		assert.True(t, strings.HasPrefix(entries[0].Key, "sha256:"))
+		assert.Equal(t, http.StatusOK, entries[0].Status)
+
+		assert.Equal(t, "PUT", entries[1].Method)
+		assert.Equal(t, http.StatusForbidden, entries[1].Status)
+		assert.Equal(t, `{"cmd":"server"}`, entries[1].Payload)
+		assert.NotEqual(t, entries[0].Key, entries[1].Key)
+	}
+
+	_, entries = listAudit(proxy, "?path=/unload")
+	assert.Len(t, entries, 1)
+	_, entries = listAudit(proxy, "?limit=1")
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "PUT", entries[0].Method)
+	}
+	code, _ = listAudit(proxy, "?since=yesterday")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// an admin key is required
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/audit", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	data, err := os.ReadFile(auditPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+
+	// entries from before a restart are read from the file
+	restarted := New(config)
+	defer restarted.StopProcesses()
+	_, entries = listAudit(restarted, "")
+	assert.Len(t, entries, 2)
+}
+
+func TestAuditLog_SearchLongEntries(t *testing.T) {
+	audit, err := newAuditLog(AuditConfig{Path: filepath.Join(t.TempDir(), "audit.log")})
+	assert.NoError(t, err)
+
+	// escaping makes the entry several times longer than its payload
+	payload := strings.Repeat("<|im_start|>", maxAuditPayload/12)
+	assert.NoError(t, audit.record(AuditEntry{Method: "PUT", Path: "/api/config/models/qwen", Payload: payload}))
+	assert.NoError(t, audit.record(AuditEntry{Method: "POST", Path: "/api/drain"}))
+
+	entries, err := audit.search(auditQuery{limit: defaultAuditLimit})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, payload, entries[0].Payload)
+		assert.Equal(t, "/api/drain", entries[1].Path)
+	}
+}
@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gin context key for the tenant returned by the external authorizer
+const ctxKeyTenant = "llama-swap.tenant"
+
+const defaultAuthTimeout = 5 * time.Second
+
+// AuthExternalConfig sends the metadata of every request to an HTTP endpoint
+// or a command that decides if it is allowed
+type AuthExternalConfig struct {
+	// POST the request metadata as JSON to this URL
+	URL string `yaml:"url"`
+	// or run this command with the request metadata as JSON on stdin
+	Cmd string `yaml:"cmd"`
+
+	// seconds to wait for a decision, default: 5
+	Timeout int `yaml:"timeout"`
+}
+
+func (a AuthExternalConfig) Enabled() bool {
+	return a.URL != "" || a.Cmd != ""
+}
+
+func (a AuthExternalConfig) validate() error {
+	if a.URL != "" && a.Cmd != "" {
+		return fmt.Errorf("authExternal requires url or cmd, not both")
+	}
+	if a.Cmd != "" {
+		if _, err := SanitizeCommand(a.Cmd); err != nil {
+			return fmt.Errorf("authExternal: invalid cmd: %v", err)
+		}
+	}
+	if a.Timeout < 0 {
+		return fmt.Errorf("authExternal timeout must not be negative")
+	}
+	return nil
+}
+
+type authRequest struct {
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Query    string            `json:"query,omitempty"`
+	ClientIP string            `json:"clientIP"`
+	Headers  map[string]string `json:"headers"`
+}
+
+// authDecision is the response of the authorizer. Tenant replaces the API key
+// for quotas and is added to JSON access log lines.
+type authDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+	Tenant string `json:"tenant"`
+}
+
+// authExternalMiddleware rejects requests denied by the external authorizer.
+// Requests are denied when the authorizer can not be reached. /health is
+// always allowed.
+func (pm *ProxyManager) authExternalMiddleware(c *gin.Context) {
+	authConfig := pm.currentConfig().AuthExternal
+	if !authConfig.Enabled() || c.Request.URL.Path == "/health" {
+		c.Next()
+		return
+	}
+
+	request := authRequest{
+		Method:   c.Request.Method,
+		Path:     c.Request.URL.Path,
+		Query:    c.Request.URL.RawQuery,
+		ClientIP: c.ClientIP(),
+		Headers:  make(map[string]string),
+	}
+	for name := range c.Request.Header {
+		request.Headers[name] = c.Request.Header.Get(name)
+	}
+
+	decision, err := authorize(c.Request.Context(), authConfig, request)
+	if err != nil {
+		fmt.Fprintf(pm.logMonitor, "!!! authExternal failed: %v\n", err)
+		pm.sendErrorResponse(c, http.StatusServiceUnavailable, "authorization unavailable")
+		c.Abort()
+		return
+	}
+
+	if !decision.Allow {
+		reason := decision.Reason
+		if reason == "" {
+			reason = "request denied"
+		}
+		pm.sendErrorResponse(c, http.StatusForbidden, reason)
+		c.Abort()
+		return
+	}
+
+	if decision.Tenant != "" {
+		c.Set(ctxKeyTenant, decision.Tenant)
+	}
+	c.Next()
+}
+
+func authorize(ctx context.Context, authConfig AuthExternalConfig, request authRequest) (authDecision, error) {
+	var decision authDecision
+
+	timeout := defaultAuthTimeout
+	if authConfig.Timeout > 0 {
+		timeout = time.Duration(authConfig.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return decision, err
+	}
+
+	if authConfig.URL != "" {
+		req, err := http.NewRequestWithContext(ctx, "POST", authConfig.URL, bytes.NewReader(body))
+		if err != nil {
+			return decision, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return decision, err
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			err = json.NewDecoder(resp.Body).Decode(&decision)
+			return decision, err
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return decision, nil
+		default:
+			return decision, fmt.Errorf("authorizer responded with status %d", resp.StatusCode)
+		}
+	}
+
+	args, err := SanitizeCommand(authConfig.Cmd)
+	if err != nil {
+		return decision, err
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	output, err := cmd.Output()
+	if _, exited := err.(*exec.ExitError); exited && ctx.Err() == nil {
+		// a non zero exit denies the request, the output may have a reason
+		json.Unmarshal(output, &decision)
+		decision.Allow = false
+		return decision, nil
+	} else if err != nil {
+		return decision, err
+	}
+
+	err = json.Unmarshal(output, &decision)
+	return decision, err
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyManager_AuthExternalURL(t *testing.T) {
+	authorizer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request authRequest
+		json.NewDecoder(r.Body).Decode(&request)
+
+		switch request.Headers["Authorization"] {
+		case "Bearer good":
+			json.NewEncoder(w).Encode(authDecision{Allow: true, Tenant: "team-a"})
+		case "Bearer forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "Bearer broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			json.NewEncoder(w).Encode(authDecision{Allow: false, Reason: "unknown key for " + request.Path})
+		}
+	}))
+	defer authorizer.Close()
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		AuthExternal:       AuthExternalConfig{URL: authorizer.URL},
+	})
+
+	tests := []struct {
+		token  string
+		status int
+		body   string
+	}{
+		{"good", http.StatusOK, `"data"`},
+		{"bad", http.StatusForbidden, "unknown key for /v1/models"},
+		{"forbidden", http.StatusForbidden, "request denied"},
+		{"broken", http.StatusServiceUnavailable, "authorization unavailable"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/v1/models", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		assert.Equal(t, tt.status, w.Code, tt.token)
+		assert.Contains(t, w.Body.String(), tt.body, tt.token)
+	}
+}
+
+func TestProxyManager_AuthExternalCmd(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "authorize.sh")
+	os.WriteFile(script, []byte(`#!/bin/sh
+if grep -q '"X-Team":"a"'; then
+  echo '{"allow": true, "tenant": "team-a"}'
+else
+  echo '{"reason": "not on team a"}'
+  exit 1
+fi
+`), 0755)
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		AuthExternal:       AuthExternalConfig{Cmd: script},
+	})
+
+	var tenant string
+	proxy.ginEngine.GET("/test-tenant", func(c *gin.Context) {
+		tenant = c.GetString(ctxKeyTenant)
+	})
+
+	req := httptest.NewRequest("GET", "/test-tenant", nil)
+	req.Header.Set("X-Team", "a")
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "team-a", tenant)
+
+	req = httptest.NewRequest("GET", "/test-tenant", nil)
+	req.Header.Set("X-Team", "b")
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "not on team a")
+}
+
+func TestAuthExternalConfig_Validate(t *testing.T) {
+	assert.NoError(t, AuthExternalConfig{}.validate())
+	assert.NoError(t, AuthExternalConfig{URL: "http://auth"}.validate())
+	assert.ErrorContains(t, AuthExternalConfig{URL: "http://auth", Cmd: "auth"}.validate(), "not both")
+	assert.ErrorContains(t, AuthExternalConfig{Timeout: -1}.validate(), "must not be negative")
+}
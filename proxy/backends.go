@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"fmt"
+	"sort"
+)
+
+// request body fields of the OpenAI API, accepted by every backend
+var openAIParams = []string{
+	"model", "messages", "prompt", "input", "suffix", "echo", "stream", "stream_options",
+	"temperature", "top_p", "n", "best_of", "stop", "max_tokens", "max_completion_tokens",
+	"presence_penalty", "frequency_penalty", "logit_bias", "logprobs", "top_logprobs",
+	"user", "seed", "response_format", "tools", "tool_choice", "parallel_tool_calls",
+	"encoding_format", "dimensions", "voice", "speed",
+	// rerank
+	"query", "documents", "top_n", "return_documents",
+}
+
+// backendParams are the fields each backend accepts besides openAIParams
+var backendParams = map[string][]string{
+	"openai": {},
+	"llama.cpp": {
+		"top_k", "min_p", "typical_p", "repeat_penalty", "repeat_last_n", "penalize_nl",
+		"dry_multiplier", "dry_base", "dry_allowed_length", "dry_penalty_last_n", "dry_sequence_breakers",
+		"xtc_probability", "xtc_threshold", "mirostat", "mirostat_tau", "mirostat_eta",
+		"dynatemp_range", "dynatemp_exponent", "cache_prompt", "n_predict", "n_probs", "n_keep",
+		"n_indent", "min_keep", "samplers", "grammar", "json_schema", "id_slot", "ignore_eos",
+		"t_max_predict_ms", "timings_per_token", "return_tokens", "post_sampling_probs", "lora",
+		"chat_template_kwargs", "reasoning_format",
+		// tokenizer endpoints
+		"content", "add_special", "with_pieces", "tokens",
+	},
+	"vllm": {
+		"top_k", "min_p", "repetition_penalty", "length_penalty", "use_beam_search", "ignore_eos",
+		"min_tokens", "skip_special_tokens", "spaces_between_special_tokens", "stop_token_ids",
+		"include_stop_str_in_output", "guided_json", "guided_regex", "guided_choice",
+		"guided_grammar", "guided_decoding_backend", "chat_template", "chat_template_kwargs",
+		"add_generation_prompt", "continue_final_message", "truncate_prompt_tokens",
+		"prompt_logprobs", "priority",
+		// tokenizer endpoints
+		"add_special_tokens", "tokens",
+	},
+}
+
+func validateBackend(backend string) error {
+	if _, found := backendParams[backend]; backend != "" && !found {
+		return fmt.Errorf("unknown backend %s, use llama.cpp, vllm or openai", backend)
+	}
+	return nil
+}
+
+// filterParams drops the request body fields the model's backend does not
+// accept. Without a backend the body is returned as is.
+func (m ModelConfig) filterParams(requestBody map[string]interface{}) (map[string]interface{}, []string) {
+	extra, found := backendParams[m.Backend]
+	if !found {
+		return requestBody, nil
+	}
+
+	allowed := make(map[string]bool)
+	for _, list := range [][]string{openAIParams, extra, m.AllowParams} {
+		for _, param := range list {
+			allowed[param] = true
+		}
+	}
+
+	filtered := make(map[string]interface{}, len(requestBody))
+	var dropped []string
+	for key, value := range requestBody {
+		if allowed[key] {
+			filtered[key] = value
+		} else {
+			dropped = append(dropped, key)
+		}
+	}
+	sort.Strings(dropped)
+	return filtered, dropped
+}
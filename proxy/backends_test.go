@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelConfig_FilterParams(t *testing.T) {
+	body := map[string]interface{}{
+		"model":          "m",
+		"messages":       []interface{}{},
+		"temperature":    0.7,
+		"dry_multiplier": 0.8,
+		"xtc_threshold":  0.1,
+		"cache_prompt":   true,
+		"top_k":          40,
+		"custom":         "x",
+	}
+
+	filtered, dropped := ModelConfig{}.filterParams(body)
+	assert.Nil(t, dropped)
+	assert.Equal(t, body, filtered)
+
+	filtered, dropped = ModelConfig{Backend: "vllm", AllowParams: []string{"custom"}}.filterParams(body)
+	assert.Equal(t, []string{"cache_prompt", "dry_multiplier", "xtc_threshold"}, dropped)
+	assert.Contains(t, filtered, "top_k")
+	assert.Contains(t, filtered, "custom")
+	assert.Len(t, body, 8, "the request body is not changed")
+
+	_, dropped = ModelConfig{Backend: "llama.cpp"}.filterParams(body)
+	assert.Equal(t, []string{"custom"}, dropped)
+
+	_, dropped = ModelConfig{Backend: "openai"}.filterParams(body)
+	assert.Equal(t, []string{"cache_prompt", "custom", "dry_multiplier", "top_k", "xtc_threshold"}, dropped)
+}
+
+func TestValidateBackend(t *testing.T) {
+	assert.NoError(t, validateBackend(""))
+	assert.NoError(t, validateBackend("llama.cpp"))
+	assert.ErrorContains(t, validateBackend("tgi"), "unknown backend tgi")
+}
+
+func TestProxyManager_BackendDropsParams(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Proxy = upstream.URL
+	modelConfig.Backend = "openai"
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+	})
+	defer proxy.StopProcesses()
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1","temperature":0.5,"cache_prompt":true}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"model":"model1","temperature":0.5}`, w.Body.String())
+}
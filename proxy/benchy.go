@@ -0,0 +1,308 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultBenchyPrompt    = "Write a short story about a robot that learns to paint."
+	defaultBenchyMaxTokens = 256
+
+	// a benchmark request fails after this, loading the model is bounded by
+	// healthCheckTimeout like for any other request
+	benchyTimeout = 10 * time.Minute
+
+	// a model is benchmarked once no process has requests in flight, it is
+	// skipped when they are busy for longer than benchyIdleWait
+	benchyIdlePoll = time.Second
+	benchyIdleWait = 10 * time.Minute
+
+	// results kept for each model
+	maxBenchyResults = 30
+)
+
+// BenchyConfig benchmarks models at the times of a cron expression and
+// alerts when their tokens per second drop compared to the previous run,
+// e.g. after a driver or llama.cpp upgrade
+type BenchyConfig struct {
+	// minute hour day-of-month month day-of-week like schedules, "" = disabled
+	Schedule string   `yaml:"schedule"`
+	Models   []string `yaml:"models"`
+
+	// percent the tokens per second may drop below the previous run before
+	// a benchy.regression webhook is sent, 0 = no alerts
+	AlertThresholdPct float64 `yaml:"alertThresholdPct"`
+
+	// the chat completion request of the benchmark
+	Prompt    string `yaml:"prompt"`
+	MaxTokens int    `yaml:"maxTokens"`
+}
+
+func (c *Config) validateBenchy() error {
+	b := c.Benchy
+	if b.Schedule == "" {
+		if len(b.Models) > 0 {
+			return fmt.Errorf("benchy: models need a schedule")
+		}
+		return nil
+	}
+	if _, err := parseCron(b.Schedule); err != nil {
+		return fmt.Errorf("benchy: %v", err)
+	}
+	if len(b.Models) == 0 {
+		return fmt.Errorf("benchy: models are required")
+	}
+	for _, model := range b.Models {
+		if _, found := c.RealModelName(model); !found {
+			return fmt.Errorf("benchy: unknown model %s", model)
+		}
+	}
+	if b.AlertThresholdPct < 0 || b.MaxTokens < 0 {
+		return fmt.Errorf("benchy: alertThresholdPct and maxTokens must not be negative")
+	}
+	return nil
+}
+
+// BenchyResult is a benchmark run of a model
+type BenchyResult struct {
+	Model            string    `json:"model"`
+	Time             time.Time `json:"time"`
+	TokensPerSecond  float64   `json:"tokensPerSecond,omitempty"`
+	CompletionTokens int       `json:"completionTokens,omitempty"`
+	DurationMs       int64     `json:"durationMs,omitempty"`
+	Error            string    `json:"error,omitempty"`
+
+	// percent below the previous run, set when it is over alertThresholdPct
+	RegressionPct float64 `json:"regressionPct,omitempty"`
+}
+
+// benchyResults keeps the recent results of each model, oldest first
+type benchyResults struct {
+	sync.Mutex
+	results map[string][]BenchyResult
+
+	// a run still benchmarking when the schedule is due again is not
+	// overlapped by the next one
+	running atomic.Bool
+}
+
+func newBenchyResults() *benchyResults {
+	return &benchyResults{results: make(map[string][]BenchyResult)}
+}
+
+// previous returns the last successful result of the model
+func (b *benchyResults) previous(model string) (BenchyResult, bool) {
+	b.Lock()
+	defer b.Unlock()
+	results := b.results[model]
+	for i := len(results) - 1; i >= 0; i-- {
+		if results[i].Error == "" {
+			return results[i], true
+		}
+	}
+	return BenchyResult{}, false
+}
+
+func (b *benchyResults) add(result BenchyResult) {
+	b.Lock()
+	defer b.Unlock()
+	results := append(b.results[result.Model], result)
+	if len(results) > maxBenchyResults {
+		results = results[len(results)-maxBenchyResults:]
+	}
+	b.results[result.Model] = results
+}
+
+// benchmark sends a chat completion to the upstream and measures the
+// generation speed, from llama-server's timings when it sends them
+func (p *Process) benchmark(ctx context.Context, prompt string, maxTokens int) (BenchyResult, error) {
+	p.inFlightRequests.Add(1)
+	defer p.inFlightRequests.Done()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"model":      p.ID,
+		"messages":   []map[string]string{{"role": "user", "content": prompt}},
+		"max_tokens": maxTokens,
+		"stream":     false,
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(p.upstreamURL(), "/")+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return BenchyResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	p.config.setUpstreamHeaders(req.Header)
+
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return BenchyResult{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return BenchyResult{}, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Usage   tokenUsage `json:"usage"`
+		Timings struct {
+			PredictedPerSecond float64 `json:"predicted_per_second"`
+		} `json:"timings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return BenchyResult{}, fmt.Errorf("invalid response: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	result := BenchyResult{
+		TokensPerSecond:  response.Timings.PredictedPerSecond,
+		CompletionTokens: response.Usage.CompletionTokens,
+		DurationMs:       elapsed.Milliseconds(),
+	}
+	if result.TokensPerSecond == 0 && result.CompletionTokens > 0 {
+		result.TokensPerSecond = float64(result.CompletionTokens) / elapsed.Seconds()
+	}
+	if result.TokensPerSecond == 0 {
+		return BenchyResult{}, fmt.Errorf("no completion tokens in the response")
+	}
+	return result, nil
+}
+
+// runBenchySchedule runs benchy at the start of every minute it is due
+func (pm *ProxyManager) runBenchySchedule() {
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		// benchmarking the models can take longer than a minute
+		go pm.runDueBenchy(time.Now())
+	}
+}
+
+// runDueBenchy runs benchy when its schedule matches the minute of now
+func (pm *ProxyManager) runDueBenchy(now time.Time) {
+	benchy := pm.currentConfig().Benchy
+	if cron, err := parseCron(benchy.Schedule); err == nil && cron.matches(now) {
+		pm.runBenchy(benchy)
+	}
+}
+
+// runBenchy benchmarks the models one after another, each is loaded like
+// for a request
+func (pm *ProxyManager) runBenchy(b BenchyConfig) {
+	if !pm.benchy.running.CompareAndSwap(false, true) {
+		fmt.Fprintf(pm.logMonitor, "!!! Benchy skipped, the previous run is still benchmarking\n")
+		return
+	}
+	defer pm.benchy.running.Store(false)
+
+	prompt, maxTokens := b.Prompt, b.MaxTokens
+	if prompt == "" {
+		prompt = defaultBenchyPrompt
+	}
+	if maxTokens == 0 {
+		maxTokens = defaultBenchyMaxTokens
+	}
+
+	for _, model := range b.Models {
+		var result BenchyResult
+		if busy := pm.waitForIdleProcesses(benchyIdleWait); len(busy) > 0 {
+			result = BenchyResult{Model: model, Time: time.Now(), Error: fmt.Sprintf("skipped, %s had requests in flight", strings.Join(busy, ", "))}
+		} else {
+			result = pm.benchmarkModel(model, prompt, maxTokens)
+		}
+		if result.Error != "" {
+			fmt.Fprintf(pm.logMonitor, "!!! Benchy %s failed: %s\n", model, result.Error)
+			pm.benchy.add(result)
+			continue
+		}
+		fmt.Fprintf(pm.logMonitor, "!!! Benchy %s: %.1f tokens/s\n", model, result.TokensPerSecond)
+
+		if previous, found := pm.benchy.previous(model); found && b.AlertThresholdPct > 0 {
+			drop := (previous.TokensPerSecond - result.TokensPerSecond) / previous.TokensPerSecond * 100
+			if drop > b.AlertThresholdPct {
+				result.RegressionPct = drop
+				message := fmt.Sprintf("%.1f tokens/s is %.1f%% below the previous run of %.1f tokens/s", result.TokensPerSecond, drop, previous.TokensPerSecond)
+				fmt.Fprintf(pm.logMonitor, "!!! Benchy %s regressed: %s\n", model, message)
+				pm.sendWebhooks(pm.currentConfig().Hooks.Webhooks, EventBenchyRegression, model, message)
+				pm.events.publish(Event{Type: EventBenchyRegression, Model: model, TokensPerSecond: result.TokensPerSecond})
+			}
+		}
+		pm.benchy.add(result)
+	}
+}
+
+// waitForIdleProcesses waits up to timeout for the running processes to
+// finish their requests, a benchmark would evict them or share their GPU.
+// It returns the IDs of the processes that are still busy.
+func (pm *ProxyManager) waitForIdleProcesses(timeout time.Duration) []string {
+	deadline := time.Now().Add(timeout)
+	for {
+		var busy []string
+		pm.Lock()
+		for _, process := range pm.currentProcesses {
+			if process.inFlightCount.Load() > 0 {
+				busy = append(busy, process.ID)
+			}
+		}
+		pm.Unlock()
+
+		if len(busy) == 0 || !time.Now().Before(deadline) {
+			sort.Strings(busy)
+			return busy
+		}
+		time.Sleep(benchyIdlePoll)
+	}
+}
+
+func (pm *ProxyManager) benchmarkModel(model, prompt string, maxTokens int) BenchyResult {
+	result := BenchyResult{Model: model, Time: time.Now()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), benchyTimeout)
+	defer cancel()
+
+	process, err := pm.swapModel(model)
+	if err == nil {
+		err = process.start()
+	}
+	if err == nil {
+		var measured BenchyResult
+		if measured, err = process.benchmark(ctx, prompt, maxTokens); err == nil {
+			measured.Model, measured.Time = result.Model, result.Time
+			result = measured
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// apiListBenchy returns the recent results of each model and the next run
+func (pm *ProxyManager) apiListBenchy(c *gin.Context) {
+	response := gin.H{}
+	if cron, err := parseCron(pm.currentConfig().Benchy.Schedule); err == nil {
+		if next := cron.next(time.Now()); !next.IsZero() {
+			response["nextRun"] = next
+		}
+	}
+
+	pm.benchy.Lock()
+	results := make(map[string][]BenchyResult, len(pm.benchy.results))
+	for model, modelResults := range pm.benchy.results {
+		results[model] = append([]BenchyResult(nil), modelResults...)
+	}
+	pm.benchy.Unlock()
+
+	response["results"] = results
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Benchy(t *testing.T) {
+	config, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+benchy:
+  schedule: "0 3 * * *"
+  models: [model1]
+  alertThresholdPct: 10
+`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, BenchyConfig{Schedule: "0 3 * * *", Models: []string{"model1"}, AlertThresholdPct: 10}, config.Benchy)
+	}
+
+	_, err = LoadConfigFromBytes([]byte("models: {}\nbenchy:\n  schedule: \"0 3 * *\"\n  models: [model1]\n"))
+	assert.ErrorContains(t, err, "benchy: invalid cron")
+	_, err = LoadConfigFromBytes([]byte("models: {}\nbenchy:\n  schedule: \"0 3 * * *\"\n  models: [missing]\n"))
+	assert.ErrorContains(t, err, "benchy: unknown model missing")
+	_, err = LoadConfigFromBytes([]byte("models: {}\nbenchy:\n  models: [missing]\n"))
+	assert.ErrorContains(t, err, "benchy: models need a schedule")
+}
+
+func TestProxyManager_Benchy(t *testing.T) {
+	var tokensPerSecond atomic.Int64
+	var requests atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		requests.Add(1)
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["max_tokens"] != float64(64) {
+			http.Error(w, "unexpected max_tokens", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"usage":{"completion_tokens":64},"timings":{"predicted_per_second":%d}}`, tokensPerSecond.Load())
+	}))
+	defer upstream.Close()
+
+	webhooks := make(chan WebhookPayload, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &payload)
+		webhooks <- payload
+	}))
+	defer webhook.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Proxy = upstream.URL
+	benchy := BenchyConfig{Schedule: "0 3 * * *", Models: []string{"model1"}, AlertThresholdPct: 10, MaxTokens: 64}
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+		Hooks:              HooksConfig{Webhooks: []WebhookConfig{{URL: webhook.URL, Events: []string{EventBenchyRegression}}}},
+		Benchy:             benchy,
+	})
+	defer proxy.StopProcesses()
+
+	events := proxy.events.Subscribe()
+	defer proxy.events.Unsubscribe(events)
+
+	// only at the scheduled minute
+	tokensPerSecond.Store(100)
+	proxy.runDueBenchy(time.Date(2025, 6, 1, 2, 0, 0, 0, time.Local))
+	assert.Equal(t, int32(0), requests.Load())
+	proxy.runDueBenchy(time.Date(2025, 6, 1, 3, 0, 0, 0, time.Local))
+	assert.Equal(t, int32(1), requests.Load())
+
+	// within the threshold
+	tokensPerSecond.Store(95)
+	proxy.runBenchy(benchy)
+
+	tokensPerSecond.Store(80)
+	proxy.runBenchy(benchy)
+
+	select {
+	case payload := <-webhooks:
+		assert.Equal(t, EventBenchyRegression, payload.Event)
+		assert.Equal(t, "model1", payload.Model)
+		assert.Contains(t, payload.Message, "80.0 tokens/s is 15.8% below the previous run of 95.0 tokens/s")
+	case <-time.After(5 * time.Second):
+		t.Error("no benchy.regression webhook")
+	}
+	regressions := 0
+	for len(events) > 0 {
+		if event := <-events; event.Type == EventBenchyRegression {
+			regressions++
+			assert.Equal(t, 80.0, event.TokensPerSecond)
+		}
+	}
+	assert.Equal(t, 1, regressions)
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/benchy", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		NextRun time.Time                 `json:"nextRun"`
+		Results map[string][]BenchyResult `json:"results"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 3, response.NextRun.Hour())
+	if assert.Len(t, response.Results["model1"], 3) {
+		assert.Equal(t, 100.0, response.Results["model1"][0].TokensPerSecond)
+		assert.Equal(t, 64, response.Results["model1"][0].CompletionTokens)
+		assert.Zero(t, response.Results["model1"][1].RegressionPct)
+		assert.InDelta(t, 15.8, response.Results["model1"][2].RegressionPct, 0.1)
+	}
+}
+
+func TestProxyManager_BenchyWaitsForIdleProcesses(t *testing.T) {
+	var requests atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		requests.Add(1)
+		fmt.Fprint(w, `{"usage":{"completion_tokens":64},"timings":{"predicted_per_second":100}}`)
+	}))
+	defer upstream.Close()
+
+	model1 := getTestSimpleResponderConfig("model1")
+	model2 := getTestSimpleResponderConfig("model2")
+	model2.Proxy = upstream.URL
+	benchy := BenchyConfig{Schedule: "0 3 * * *", Models: []string{"model2"}}
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": model1, "model2": model2},
+		Benchy:             benchy,
+	})
+	defer proxy.StopProcesses()
+
+	// a run is not started while the previous one is benchmarking
+	proxy.benchy.running.Store(true)
+	proxy.runBenchy(benchy)
+	proxy.benchy.running.Store(false)
+	assert.Equal(t, int32(0), requests.Load())
+
+	// model1 is not swapped out while it serves a request
+	process, err := proxy.swapModel("model1")
+	if !assert.NoError(t, err) || !assert.NoError(t, process.start()) {
+		return
+	}
+	process.inFlightCount.Add(1)
+	finished := time.Now().Add(500 * time.Millisecond)
+	go func() {
+		time.Sleep(time.Until(finished))
+		process.inFlightCount.Add(-1)
+	}()
+
+	proxy.runBenchy(benchy)
+	assert.Equal(t, int32(1), requests.Load())
+	assert.False(t, time.Now().Before(finished))
+	assert.Equal(t, StateStopped, process.CurrentState())
+}
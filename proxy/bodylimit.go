@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readLimit is the most bytes read from a request body before the model is
+// known: the largest of the global and per-model maxRequestBodyMB, 0 when
+// there is no global limit
+func (c *Config) readLimit() int64 {
+	if c.MaxRequestBodyMB == 0 {
+		return 0
+	}
+	limit := c.MaxRequestBodyMB
+	for _, modelConfig := range c.Models {
+		limit = max(limit, modelConfig.MaxRequestBodyMB)
+	}
+	return int64(limit) << 20
+}
+
+// modelBodyLimit is the body limit in bytes of the requested model, 0 when
+// there is none
+func (c *Config) modelBodyLimit(requestedModel string) int64 {
+	_, modelName, found := strings.Cut(requestedModel, PROFILE_SPLIT_CHAR)
+	if !found {
+		modelName = requestedModel
+	}
+
+	limit := c.MaxRequestBodyMB
+	if realName, found := c.RealModelName(modelName); found && c.Models[realName].MaxRequestBodyMB > 0 {
+		limit = c.Models[realName].MaxRequestBodyMB
+	}
+	return int64(limit) << 20
+}
+
+// readRequestBody reads the body up to the configured limit. It responds
+// with a 413 and returns false when the body is larger.
+func (pm *ProxyManager) readRequestBody(c *gin.Context) ([]byte, bool) {
+	body := c.Request.Body
+	if limit := pm.currentConfig().readLimit(); limit > 0 {
+		body = http.MaxBytesReader(c.Writer, body, limit)
+	}
+
+	bodyBytes, err := io.ReadAll(body)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		sendBodyTooLarge(c, maxBytesErr.Limit)
+		return nil, false
+	}
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, "could not ready request body")
+		return nil, false
+	}
+	return bodyBytes, true
+}
+
+// checkModelBodyLimit responds with a 413 and returns false when the body is
+// larger than the limit of the requested model
+func (pm *ProxyManager) checkModelBodyLimit(c *gin.Context, requestedModel string, bodyBytes []byte) bool {
+	limit := pm.currentConfig().modelBodyLimit(requestedModel)
+	if limit > 0 && int64(len(bodyBytes)) > limit {
+		sendBodyTooLarge(c, limit)
+		return false
+	}
+	return true
+}
+
+func sendBodyTooLarge(c *gin.Context, limit int64) {
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": gin.H{
+		"message": fmt.Sprintf("request body is larger than the limit of %d MB", limit>>20),
+		"type":    "invalid_request_error",
+		"code":    "request_too_large",
+	}})
+}
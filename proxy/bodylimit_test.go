@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_BodyLimits(t *testing.T) {
+	config, err := LoadConfigFromBytes([]byte(`
+maxRequestBodyMB: 1
+models:
+  small:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+  vision:
+    cmd: server --port 9002
+    proxy: http://127.0.0.1:9002
+    aliases: [gpt-4o]
+    maxRequestBodyMB: 8
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(8<<20), config.readLimit())
+	assert.Equal(t, int64(1<<20), config.modelBodyLimit("small"))
+	assert.Equal(t, int64(8<<20), config.modelBodyLimit("gpt-4o"))
+	assert.Equal(t, int64(1<<20), config.modelBodyLimit("unknown"))
+
+	// models can not raise the limit without a global one
+	config.MaxRequestBodyMB = 0
+	assert.Equal(t, int64(0), config.readLimit())
+
+	_, err = LoadConfigFromBytes([]byte("maxRequestBodyMB: -1\nmodels: {}\n"))
+	assert.ErrorContains(t, err, "maxRequestBodyMB must not be negative")
+}
+
+func TestProxyManager_BodyLimit(t *testing.T) {
+	large := getTestSimpleResponderConfig("large")
+	large.MaxRequestBodyMB = 2
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		MaxRequestBodyMB:   1,
+		Models: map[string]ModelConfig{
+			"small": getTestSimpleResponderConfig("small"),
+			"large": large,
+		},
+	})
+	defer proxy.StopProcesses()
+
+	request := func(model string, size int) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"model":%q,"prompt":%q}`, model, strings.Repeat("a", size))
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/completions", strings.NewReader(body)))
+		return w
+	}
+
+	w := request("small", 2<<20)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	var response struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "request_too_large", response.Error.Code)
+
+	// the body is not read past the largest limit
+	w = request("large", 3<<20)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = request("large", 3<<19)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = request("small", 1<<10)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
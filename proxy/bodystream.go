@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errNoModelField = errors.New("no model field")
+
+// peekJSONModel reads the top level fields of a JSON object until it finds
+// the model field. Other values are skipped token by token, so only the
+// largest string of the skipped fields is kept in memory.
+func peekJSONModel(r io.Reader) (string, error) {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil {
+		return "", err
+	} else if token != json.Delim('{') {
+		return "", fmt.Errorf("expected a JSON object")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if key, _ := token.(string); key == "model" {
+			value, err := decoder.Token()
+			if err != nil {
+				return "", err
+			}
+			model, ok := value.(string)
+			if !ok {
+				return "", errNoModelField
+			}
+			return model, nil
+		}
+		if err := skipJSONValue(decoder); err != nil {
+			return "", err
+		}
+	}
+	return "", errNoModelField
+}
+
+func skipJSONValue(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// needsRequestBody is true when handling a request to the model requires the
+// parsed body, for routing rules, the cache or changing the body
+func (c *Config) needsRequestBody(m ModelConfig) bool {
+	return len(c.Routing) > 0 || c.Cache.Enabled ||
+		m.Filters.RenameParams != nil || m.Filters.SetParams != nil || m.Filters.RewriteSystemPrompt.Content != "" ||
+		m.Limits.enabled() || m.Backend != "" || len(m.Fallback) > 0 || m.EmbeddingBatch.MaxInputs > 0 ||
+		m.Record.Enabled || m.ShadowModel != "" || m.Hedge.Model != ""
+}
+
+// proxyStreamedBody proxies requests with a body larger than streamBodyMB
+// without reading all of it. The bytes before the model field are spooled,
+// the rest is streamed to the upstream. It returns false when the request is
+// left to proxyOAIHandler, with the body restored, and a func to remove the
+// spool once the request is handled.
+func (pm *ProxyManager) proxyStreamedBody(c *gin.Context) (bool, func()) {
+	config := pm.currentConfig()
+	if config.StreamBodyMB == 0 || c.Request.ContentLength <= int64(config.StreamBodyMB)<<20 {
+		return false, func() {}
+	}
+
+	body := c.Request.Body
+	if limit := config.readLimit(); limit > 0 {
+		body = http.MaxBytesReader(c.Writer, body, limit)
+	}
+	spooled := &spool{}
+	model, peekErr := peekJSONModel(io.TeeReader(body, spooled))
+
+	// the spooled bytes, then the rest of the client's request
+	spooledReader, err := spooled.reader()
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("unable to spool request: %v", err))
+		return true, spooled.Close
+	}
+	c.Request.Body = io.NopCloser(io.MultiReader(spooledReader, body))
+
+	// errors are reported by proxyOAIHandler
+	if peekErr != nil || model == "" {
+		return false, spooled.Close
+	}
+	if modelConfig, _, found := config.findRequestedConfig(model); !found || config.needsRequestBody(modelConfig) {
+		return false, spooled.Close
+	}
+	traceRequest(c, "requested model %q, streaming the body of %d bytes", model, c.Request.ContentLength)
+
+	if limit := config.modelBodyLimit(model); limit > 0 && c.Request.ContentLength > limit {
+		sendBodyTooLarge(c, limit)
+		return true, spooled.Close
+	}
+
+	// in tasktype.go
+	if !pm.checkTaskType(c, model) {
+		return true, spooled.Close
+	}
+
+	// in ttl.go, only the header as the body is not parsed
+	if ttl, hasTTL, err := requestTTL(c, map[string]interface{}{}); err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return true, spooled.Close
+	} else if hasTTL {
+		c.Set(ctxKeyRequestTTL, ttl)
+	}
+
+	// in clients.go
+	defer pm.recordClientMetrics(c, model)()
+
+	recordUsage, ok := pm.checkQuota(c, model)
+	if !ok {
+		return true, spooled.Close
+	}
+	defer recordUsage()
+
+	release, err := pm.acquireProfileSlot(c, model)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusServiceUnavailable, "request cancelled while waiting for a free slot")
+		return true, spooled.Close
+	}
+	defer release()
+
+	// in events.go
+	defer pm.publishTokens(c, model)()
+
+	process, err := pm.swapModel(model)
+	if err != nil {
+		pm.sendSwapError(c, model, err)
+		return true, spooled.Close
+	}
+	applyRequestTTL(c, process)
+	setAccessLogKeys(c, model, process)
+
+	if interval := config.LoadKeepAliveInterval; interval > 0 {
+		startWithKeepAlive(c, process, time.Duration(interval)*time.Second, false)
+	}
+
+	process.ProxyRequest(c.Writer, c.Request)
+	return true, spooled.Close
+}
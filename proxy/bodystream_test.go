@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyStream_PeekJSONModel(t *testing.T) {
+	model, err := peekJSONModel(strings.NewReader(`{"messages":[{"role":"user","content":"hi {\"model\": \"no\"}"}],"stream":true,"model":"qwen","n":1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "qwen", model)
+
+	_, err = peekJSONModel(strings.NewReader(`{"messages":[],"model":5}`))
+	assert.ErrorIs(t, err, errNoModelField)
+	_, err = peekJSONModel(strings.NewReader(`{"messages":[]}`))
+	assert.ErrorIs(t, err, errNoModelField)
+	_, err = peekJSONModel(strings.NewReader(`["model"]`))
+	assert.Error(t, err)
+}
+
+func TestProxyManager_StreamedBody(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		fmt.Fprintf(w, "%d %s", len(body), hex.EncodeToString(sum[:]))
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	streamed := getTestSimpleResponderConfig("streamed")
+	streamed.Proxy = upstream.URL
+	filtered := getTestSimpleResponderConfig("filtered")
+	filtered.Proxy = upstream.URL
+	filtered.Filters.SetParams = map[string]interface{}{"temperature": 0.5}
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		StreamBodyMB:       1,
+		Models:             map[string]ModelConfig{"streamed": streamed, "filtered": filtered},
+	})
+	defer proxy.StopProcesses()
+
+	// the model field after a large context, like the OpenAI Python client
+	// sends it
+	context := strings.Repeat("lorem ipsum ", 200_000)
+	body := fmt.Sprintf(`{"messages":[{"role":"user","content":"%s"}],"model":"streamed"}`, context)
+	sum := sha256.Sum256([]byte(body))
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	// sent unchanged
+	assert.Equal(t, fmt.Sprintf("%d %s", len(body), hex.EncodeToString(sum[:])), w.Body.String())
+
+	// a model changing the body gets the parsed body
+	body = fmt.Sprintf(`{"messages":[{"role":"user","content":"%s"}],"model":"filtered"}`, context)
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, fmt.Sprintf("%d", len(body)), strings.Fields(w.Body.String())[0])
+
+	// errors are reported like for other requests
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"messages":"`+context+`"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "missing or invalid 'model' key")
+}
@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	cacheHeader            = "X-LlamaSwap-Cache"
+	defaultCacheMaxEntries = 1000
+	defaultCacheTTL        = 300
+
+	// larger responses are not cached
+	maxCachedResponseSize = 4 * 1024 * 1024
+)
+
+// paths with responses that can be served again for the same request body
+var cacheablePaths = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+}
+
+type CacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// number of responses kept, the least recently used are dropped first
+	MaxEntries int `yaml:"maxEntries"`
+
+	// seconds a response is served from the cache
+	TTL int `yaml:"ttl"`
+}
+
+func (c CacheConfig) validate() error {
+	if c.MaxEntries < 0 || c.TTL < 0 {
+		return fmt.Errorf("cache maxEntries and ttl must not be negative")
+	}
+	return nil
+}
+
+type cachedResponse struct {
+	key         string
+	contentType string
+	body        []byte
+	expires     time.Time
+}
+
+// responseCache is an LRU cache of successful responses to non streaming
+// requests, keyed by path and request body
+type responseCache struct {
+	sync.Mutex
+
+	maxEntries int
+	ttl        time.Duration
+	entries    map[string]*list.Element
+	lru        *list.List
+}
+
+// newResponseCache returns nil when the cache is disabled
+func newResponseCache(config CacheConfig) *responseCache {
+	if !config.Enabled {
+		return nil
+	}
+
+	maxEntries := config.MaxEntries
+	if maxEntries == 0 {
+		maxEntries = defaultCacheMaxEntries
+	}
+	ttl := config.TTL
+	if ttl == 0 {
+		ttl = defaultCacheTTL
+	}
+
+	return &responseCache{
+		maxEntries: maxEntries,
+		ttl:        time.Duration(ttl) * time.Second,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// responseCacheKey is the same for requests that only differ in the order
+// of keys or whitespace, json.Marshal sorts map keys
+func responseCacheKey(path string, requestBody map[string]interface{}) (string, bool) {
+	if !cacheablePaths[path] {
+		return "", false
+	}
+	if stream, _ := requestBody["stream"].(bool); stream {
+		return "", false
+	}
+
+	normalized, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(append([]byte(path+"\n"), normalized...))
+	return hex.EncodeToString(sum[:]), true
+}
+
+func (rc *responseCache) get(key string, now time.Time) (*cachedResponse, bool) {
+	rc.Lock()
+	defer rc.Unlock()
+
+	element, found := rc.entries[key]
+	if !found {
+		return nil, false
+	}
+
+	entry := element.Value.(*cachedResponse)
+	if now.After(entry.expires) {
+		rc.lru.Remove(element)
+		delete(rc.entries, key)
+		return nil, false
+	}
+
+	rc.lru.MoveToFront(element)
+	return entry, true
+}
+
+func (rc *responseCache) add(entry *cachedResponse) {
+	rc.Lock()
+	defer rc.Unlock()
+
+	if element, found := rc.entries[entry.key]; found {
+		element.Value = entry
+		rc.lru.MoveToFront(element)
+		return
+	}
+
+	rc.entries[entry.key] = rc.lru.PushFront(entry)
+	for rc.lru.Len() > rc.maxEntries {
+		oldest := rc.lru.Back()
+		rc.lru.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// serveFromCache responds from the cache when possible. Otherwise it returns
+// a func to cache the response once the request is handled.
+func (pm *ProxyManager) serveFromCache(c *gin.Context, requestBody map[string]interface{}) (func(), bool) {
+	cache := pm.responseCache.Load()
+	if cache == nil {
+		return func() {}, false
+	}
+
+	key, ok := responseCacheKey(c.Request.URL.Path, requestBody)
+	if !ok {
+		return func() {}, false
+	}
+
+	if entry, found := cache.get(key, time.Now()); found {
+		traceRequest(c, "served from the response cache")
+		c.Header(cacheHeader, "hit")
+		c.Data(http.StatusOK, entry.contentType, entry.body)
+		return nil, true
+	}
+
+	c.Header(cacheHeader, "miss")
+	capture := &bodyCaptureWriter{ResponseWriter: c.Writer}
+	c.Writer = capture
+	return func() {
+		if capture.Status() != http.StatusOK || capture.overflow {
+			return
+		}
+		cache.add(&cachedResponse{
+			key:         key,
+			contentType: capture.Header().Get("Content-Type"),
+			body:        capture.body,
+			expires:     time.Now().Add(cache.ttl),
+		})
+	}, false
+}
+
+// bodyCaptureWriter keeps a copy of the response body for the cache
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body     []byte
+	overflow bool
+
+	// largest body kept, 0 = maxCachedResponseSize
+	limit int
+}
+
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	w.keep(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyCaptureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *bodyCaptureWriter) keep(b []byte) {
+	if w.overflow {
+		return
+	}
+	limit := w.limit
+	if limit == 0 {
+		limit = maxCachedResponseSize
+	}
+	if len(w.body)+len(b) > limit {
+		w.overflow = true
+		w.body = nil
+		return
+	}
+	w.body = append(w.body, b...)
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCacheKey(t *testing.T) {
+	key1, ok := responseCacheKey("/v1/embeddings", map[string]interface{}{"model": "m", "input": "hello"})
+	assert.True(t, ok)
+	key2, _ := responseCacheKey("/v1/embeddings", map[string]interface{}{"input": "hello", "model": "m"})
+	assert.Equal(t, key1, key2)
+
+	key3, _ := responseCacheKey("/v1/completions", map[string]interface{}{"input": "hello", "model": "m"})
+	assert.NotEqual(t, key1, key3)
+
+	_, ok = responseCacheKey("/v1/chat/completions", map[string]interface{}{"model": "m", "stream": true})
+	assert.False(t, ok)
+	_, ok = responseCacheKey("/v1/audio/speech", map[string]interface{}{"model": "m"})
+	assert.False(t, ok)
+}
+
+func TestResponseCache_LRUAndTTL(t *testing.T) {
+	assert.Nil(t, newResponseCache(CacheConfig{}))
+
+	cache := newResponseCache(CacheConfig{Enabled: true, MaxEntries: 2, TTL: 60})
+	now := time.Now()
+	add := func(key string) {
+		cache.add(&cachedResponse{key: key, body: []byte(key), expires: now.Add(cache.ttl)})
+	}
+
+	add("a")
+	add("b")
+	_, found := cache.get("a", now)
+	assert.True(t, found)
+
+	// b is the least recently used
+	add("c")
+	_, found = cache.get("b", now)
+	assert.False(t, found)
+	_, found = cache.get("a", now)
+	assert.True(t, found)
+
+	_, found = cache.get("c", now.Add(61*time.Second))
+	assert.False(t, found, "expired")
+	assert.Equal(t, 1, cache.lru.Len())
+}
+
+func TestProxyManager_ResponseCache(t *testing.T) {
+	var upstreamRequests atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		n := upstreamRequests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"n":%d}`, n)
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Proxy = upstream.URL
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+		Cache:              CacheConfig{Enabled: true},
+	})
+	defer proxy.StopProcesses()
+
+	request := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		return w
+	}
+
+	w := request("/v1/embeddings", `{"model":"model1","input":"hello"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "miss", w.Header().Get(cacheHeader))
+	assert.Equal(t, `{"n":1}`, w.Body.String())
+
+	// same request with different key order and whitespace
+	w = request("/v1/embeddings", `{ "input": "hello", "model": "model1" }`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hit", w.Header().Get(cacheHeader))
+	assert.Equal(t, `{"n":1}`, w.Body.String())
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/json"))
+
+	w = request("/v1/embeddings", `{"model":"model1","input":"other"}`)
+	assert.Equal(t, `{"n":2}`, w.Body.String())
+
+	// streaming requests are never cached
+	for i := 0; i < 2; i++ {
+		w = request("/v1/chat/completions", `{"model":"model1","stream":true}`)
+		assert.Empty(t, w.Header().Get(cacheHeader))
+	}
+	assert.Equal(t, int32(4), upstreamRequests.Load())
+}
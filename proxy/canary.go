@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// configCanary keeps what is needed to undo a config reload until the
+// probation of the new config ends
+type configCanary struct {
+	config     *Config
+	configPath string
+
+	// backup of the config file before it was written, "" when the reload
+	// did not write it
+	configBackup    string
+	workspaceActive string
+	until           time.Time
+}
+
+// newConfigCanary saves the current config, the lock must be held
+func (pm *ProxyManager) newConfigCanary(probationSeconds int, configBackup string) *configCanary {
+	canary := &configCanary{
+		config:       pm.currentConfig(),
+		configPath:   pm.currentConfigPath(),
+		configBackup: configBackup,
+		until:        time.Now().Add(time.Duration(probationSeconds) * time.Second),
+	}
+	if pm.workspace != nil {
+		canary.workspaceActive = pm.workspace.Active()
+	}
+	return canary
+}
+
+// changed is true when the model is new or its config differs from the
+// previous config
+func (canary *configCanary) changed(config *Config, modelID string) bool {
+	previous, found := canary.config.Models[modelID]
+	return !found || !reflect.DeepEqual(previous, config.Models[modelID])
+}
+
+// processStartFailed rolls back to the previous config when a model changed
+// by a config in probation fails to start
+func (pm *ProxyManager) processStartFailed(config *Config, modelID string, err error) {
+	pm.Lock()
+	defer pm.Unlock()
+
+	canary := pm.canary
+	if canary == nil || pm.currentConfig() != config || time.Now().After(canary.until) || !canary.changed(config, modelID) {
+		return
+	}
+	pm.canary = nil
+
+	// the file too, so the broken config does not come back on a restart
+	if canary.configBackup != "" {
+		go pm.restoreCanaryConfigFile(canary, config.ConfigBackups)
+	}
+
+	pm.addIncident(Incident{
+		Time:    time.Now(),
+		Model:   modelID,
+		Message: fmt.Sprintf("model %s failed to start during the config reload probation, rolled back to the previous config: %v", modelID, err),
+	})
+
+	pm.reloadConfig(canary.config)
+	pm.SetConfigPath(canary.configPath)
+	if pm.workspace != nil && canary.workspaceActive != "" {
+		pm.workspace.setActive(canary.workspaceActive)
+	}
+}
+
+// restoreCanaryConfigFile writes the backup of the config before the canary
+// back to the config file, unless the file was edited again since
+func (pm *ProxyManager) restoreCanaryConfigFile(canary *configCanary, keep int) {
+	pm.configEditMutex.Lock()
+	defer pm.configEditMutex.Unlock()
+
+	if newestConfigBackup(canary.configPath) != canary.configBackup {
+		fmt.Fprintf(pm.logMonitor, "!!! Not restoring config file %s, it was changed after the rolled back config\n", canary.configPath)
+		return
+	}
+	if _, err := RestoreConfigBackup(canary.configPath, canary.configBackup, keep); err != nil {
+		fmt.Fprintf(pm.logMonitor, "!!! Unable to restore config file %s: %v\n", canary.configPath, err)
+	}
+}
@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyManager_ReloadProbationRollsBack(t *testing.T) {
+	goodConfig := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+	}
+
+	proxy := New(goodConfig)
+	defer proxy.StopProcesses()
+	proxy.SetConfigPath("good.yaml")
+
+	brokenConfig := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": {
+				Cmd:           "nonexistent-command",
+				Proxy:         "http://127.0.0.1:9913",
+				CheckEndpoint: "/health",
+			},
+		},
+		ReloadProbationSeconds: 60,
+	}
+	proxy.ReloadConfig(brokenConfig)
+	proxy.SetConfigPath("broken.yaml")
+
+	request := func() int {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`))
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, request())
+
+	assert.Eventually(t, func() bool {
+		proxy.Lock()
+		defer proxy.Unlock()
+		return proxy.currentConfig() == goodConfig
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "good.yaml", proxy.currentConfigPath())
+	assert.Equal(t, http.StatusOK, request())
+
+	proxy.incidentsMutex.Lock()
+	defer proxy.incidentsMutex.Unlock()
+	if assert.NotEmpty(t, proxy.incidents) {
+		incident := proxy.incidents[len(proxy.incidents)-1]
+		assert.Equal(t, "model1", incident.Model)
+		assert.Contains(t, incident.Message, "rolled back to the previous config")
+	}
+}
+
+func TestProxyManager_NoRollbackWithoutProbation(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+	})
+	defer proxy.StopProcesses()
+
+	brokenConfig := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": {Cmd: "nonexistent-command", Proxy: "http://127.0.0.1:9913"},
+		},
+	}
+	proxy.ReloadConfig(brokenConfig)
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	time.Sleep(100 * time.Millisecond)
+	proxy.Lock()
+	defer proxy.Unlock()
+	assert.Same(t, brokenConfig, proxy.currentConfig())
+}
+
+func TestProxyManager_ReloadProbationRestoresConfigFile(t *testing.T) {
+	model1 := getTestSimpleResponderConfig("model1")
+	goodYAML := []byte("reloadProbationSeconds: 60\nmodels:\n  model1:\n    cmd: " + model1.Cmd + "\n    proxy: " + model1.Proxy + "\n")
+	brokenYAML := []byte("reloadProbationSeconds: 60\nmodels:\n  model1:\n    cmd: nonexistent-command\n    proxy: http://127.0.0.1:9913\n")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, WriteConfigFile(path, goodYAML, 5))
+	goodConfig, err := LoadConfig(path)
+	assert.NoError(t, err)
+
+	proxy := New(goodConfig)
+	defer proxy.StopProcesses()
+	proxy.SetConfigPath(path)
+
+	assert.NoError(t, WriteConfigFile(path, brokenYAML, 5))
+	brokenConfig, err := LoadConfig(path)
+	assert.NoError(t, err)
+	proxy.reloadConfigFile(brokenConfig, newestConfigBackup(path))
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	assert.Eventually(t, func() bool {
+		return proxy.currentConfig() == goodConfig
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		data, err := os.ReadFile(path)
+		return err == nil && bytes.Equal(goodYAML, data)
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestProxyManager_ReloadProbationKeepsLaterEdits(t *testing.T) {
+	model1 := getTestSimpleResponderConfig("model1")
+	goodYAML := []byte("reloadProbationSeconds: 60\nmodels:\n  model1:\n    cmd: " + model1.Cmd + "\n    proxy: " + model1.Proxy + "\n")
+	brokenYAML := []byte("reloadProbationSeconds: 60\nmodels:\n  model1:\n    cmd: nonexistent-command\n    proxy: http://127.0.0.1:9913\n")
+	editedYAML := append(append([]byte{}, brokenYAML...), []byte("  model2:\n    cmd: path/to/cmd\n    proxy: http://localhost:8080\n")...)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, WriteConfigFile(path, goodYAML, 5))
+	goodConfig, err := LoadConfig(path)
+	assert.NoError(t, err)
+
+	proxy := New(goodConfig)
+	defer proxy.StopProcesses()
+	proxy.SetConfigPath(path)
+
+	assert.NoError(t, WriteConfigFile(path, brokenYAML, 5))
+	brokenConfig, err := LoadConfig(path)
+	assert.NoError(t, err)
+	proxy.reloadConfigFile(brokenConfig, newestConfigBackup(path))
+
+	// an edit of the file while the failing model is loaded
+	proxy.configEditMutex.Lock()
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NoError(t, WriteConfigFile(path, editedYAML, 5))
+	proxy.configEditMutex.Unlock()
+
+	assert.Eventually(t, func() bool {
+		return proxy.currentConfig() == goodConfig
+	}, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, editedYAML, data)
+}
+
+func TestProxyManager_ReloadProbationIgnoresUnchangedModels(t *testing.T) {
+	broken := ModelConfig{Cmd: "nonexistent-command", Proxy: "http://127.0.0.1:9913", CheckEndpoint: "/health"}
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"broken": broken,
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+	})
+	defer proxy.StopProcesses()
+
+	newConfig := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"broken": broken,
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+		ReloadProbationSeconds: 60,
+	}
+	proxy.ReloadConfig(newConfig)
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"broken"}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Same(t, newConfig, proxy.currentConfig())
+}
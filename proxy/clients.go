@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// header naming the app sending a request, for clients without a tenant
+const clientIDHeader = "X-Client-Id"
+
+// clients seen after this many are counted as otherClient, so requests from
+// many IPs can not grow the metrics without bound
+const (
+	maxTrackedClients = 1000
+	otherClient       = "other"
+)
+
+// requestClient identifies the client of a request by the tenant from
+// authExternal or auth.oidc, the X-Client-Id header or the client IP
+func requestClient(c *gin.Context) string {
+	if tenant := c.GetString(ctxKeyTenant); tenant != "" {
+		return "tenant:" + tenant
+	}
+	if id := c.GetHeader(clientIDHeader); id != "" {
+		return "id:" + id
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// clientModelMetrics is the usage of a model by a client since llama-swap
+// started
+type clientModelMetrics struct {
+	Requests         int64 `json:"requests"`
+	Errors           int64 `json:"errors"`
+	PromptTokens     int64 `json:"promptTokens"`
+	CompletionTokens int64 `json:"completionTokens"`
+	DurationMs       int64 `json:"durationMs"`
+}
+
+func (m *clientModelMetrics) add(other clientModelMetrics) {
+	m.Requests += other.Requests
+	m.Errors += other.Errors
+	m.PromptTokens += other.PromptTokens
+	m.CompletionTokens += other.CompletionTokens
+	m.DurationMs += other.DurationMs
+}
+
+type clientMetrics struct {
+	Client string `json:"client"`
+	clientModelMetrics
+	Models map[string]clientModelMetrics `json:"models"`
+}
+
+type clientMetricsTracker struct {
+	sync.Mutex
+	// client to model to its usage
+	clients map[string]map[string]*clientModelMetrics
+}
+
+func newClientMetricsTracker() *clientMetricsTracker {
+	return &clientMetricsTracker{clients: make(map[string]map[string]*clientModelMetrics)}
+}
+
+func (t *clientMetricsTracker) record(client, model string, usage clientModelMetrics) {
+	t.Lock()
+	defer t.Unlock()
+
+	models, found := t.clients[client]
+	if !found {
+		if len(t.clients) >= maxTrackedClients {
+			client = otherClient
+			models = t.clients[client]
+		}
+		if models == nil {
+			models = make(map[string]*clientModelMetrics)
+			t.clients[client] = models
+		}
+	}
+	if models[model] == nil {
+		models[model] = &clientModelMetrics{}
+	}
+	models[model].add(usage)
+}
+
+// list returns the clients that used the most time first
+func (t *clientMetricsTracker) list() []clientMetrics {
+	t.Lock()
+	defer t.Unlock()
+
+	list := make([]clientMetrics, 0, len(t.clients))
+	for client, models := range t.clients {
+		metrics := clientMetrics{Client: client, Models: make(map[string]clientModelMetrics)}
+		for model, usage := range models {
+			metrics.Models[model] = *usage
+			metrics.add(*usage)
+		}
+		list = append(list, metrics)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].DurationMs != list[j].DurationMs {
+			return list[i].DurationMs > list[j].DurationMs
+		}
+		return list[i].Client < list[j].Client
+	})
+	return list
+}
+
+// recordClientMetrics adds the request to the metrics of its client once it
+// is handled
+func (pm *ProxyManager) recordClientMetrics(c *gin.Context, model string) func() {
+	start := time.Now()
+	tail := &tailCaptureWriter{ResponseWriter: c.Writer}
+	c.Writer = tail
+	return func() {
+		usage, _ := requestUsage(c, tail)
+		if processModel := c.GetString(ctxKeyModel); processModel != "" {
+			model = processModel
+		}
+
+		metrics := clientModelMetrics{
+			Requests:         1,
+			PromptTokens:     int64(usage.PromptTokens),
+			CompletionTokens: int64(usage.CompletionTokens),
+			DurationMs:       time.Since(start).Milliseconds(),
+		}
+		if c.Writer.Status() >= http.StatusBadRequest {
+			metrics.Errors = 1
+		}
+		pm.clientMetrics.record(requestClient(c), model, metrics)
+	}
+}
+
+func (pm *ProxyManager) apiMetricsByClient(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"clients": pm.clientMetrics.list()})
+}
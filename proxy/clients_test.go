@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientMetricsTracker_Record(t *testing.T) {
+	tracker := newClientMetricsTracker()
+	tracker.record("id:app1", "model1", clientModelMetrics{Requests: 1, PromptTokens: 10, CompletionTokens: 5, DurationMs: 100})
+	tracker.record("id:app1", "model2", clientModelMetrics{Requests: 1, Errors: 1, DurationMs: 20})
+	tracker.record("id:app2", "model1", clientModelMetrics{Requests: 1, DurationMs: 500})
+
+	list := tracker.list()
+	if assert.Len(t, list, 2) {
+		// the client using the most time first
+		assert.Equal(t, "id:app2", list[0].Client)
+		assert.Equal(t, "id:app1", list[1].Client)
+		assert.Equal(t, clientModelMetrics{Requests: 2, Errors: 1, PromptTokens: 10, CompletionTokens: 5, DurationMs: 120}, list[1].clientModelMetrics)
+		assert.Len(t, list[1].Models, 2)
+	}
+
+	// clients after maxTrackedClients are counted together
+	for i := len(tracker.clients); i < maxTrackedClients; i++ {
+		tracker.record(fmt.Sprintf("ip:10.0.%d.%d", i/256, i%256), "model1", clientModelMetrics{Requests: 1})
+	}
+	tracker.record("id:late", "model1", clientModelMetrics{Requests: 1})
+	tracker.record("id:later", "model1", clientModelMetrics{Requests: 1})
+	assert.Equal(t, int64(2), tracker.clients[otherClient]["model1"].Requests)
+	assert.NotContains(t, tracker.clients, "id:late")
+}
+
+func TestProxyManager_MetricsByClient(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": getTestSimpleResponderConfig("model1")},
+	})
+	defer proxy.StopProcesses()
+
+	for _, client := range []string{"app1", "app1", ""} {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`))
+		if client != "" {
+			req.Header.Set("X-Client-Id", client)
+		}
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/metrics/by-client", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Clients []struct {
+			Client   string `json:"client"`
+			Requests int64  `json:"requests"`
+			Models   map[string]struct {
+				Requests         int64 `json:"requests"`
+				CompletionTokens int64 `json:"completionTokens"`
+			} `json:"models"`
+		} `json:"clients"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	requests := make(map[string]int64)
+	for _, client := range response.Clients {
+		requests[client.Client] = client.Requests
+		assert.Contains(t, client.Models, "model1")
+	}
+	assert.Equal(t, map[string]int64{"id:app1": 2, "ip:192.0.2.1": 1}, requests)
+}
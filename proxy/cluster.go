@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// -tt ends the workers when their ssh connection is closed
+const defaultClusterSSH = "ssh -tt -o BatchMode=yes"
+
+// ClusterConfig starts llama.cpp rpc-server workers before the model's cmd,
+// which gets their addresses with --rpc. The model is stopped when a worker
+// exits and the workers are stopped with the model.
+type ClusterConfig struct {
+	Workers []ClusterWorker `yaml:"workers"`
+
+	// command to run the cmd of workers on other hosts, the host and cmd
+	// are appended, default: ssh -tt -o BatchMode=yes
+	SSH string `yaml:"ssh"`
+}
+
+type ClusterWorker struct {
+	// ssh destination like user@gpu2, "" runs the worker on this machine
+	Host string `yaml:"host"`
+	Cmd  string `yaml:"cmd"`
+
+	// host:port of the worker's rpc-server
+	Address string `yaml:"address"`
+}
+
+func (c ClusterConfig) enabled() bool {
+	return len(c.Workers) > 0
+}
+
+func (m ModelConfig) validateCluster() error {
+	if !m.Cluster.enabled() {
+		return nil
+	}
+	if m.Remote.enabled() {
+		return fmt.Errorf("cluster is not supported by remote models")
+	}
+	if m.Cluster.SSH != "" {
+		if _, err := SanitizeCommand(m.Cluster.SSH); err != nil {
+			return fmt.Errorf("invalid cluster ssh: %v", err)
+		}
+	}
+	for i, worker := range m.Cluster.Workers {
+		if _, err := SanitizeCommand(worker.Cmd); err != nil {
+			return fmt.Errorf("cluster worker %d: invalid cmd: %v", i, err)
+		}
+		if _, _, err := net.SplitHostPort(worker.Address); err != nil {
+			return fmt.Errorf("cluster worker %d: address must be host:port: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// rpcArgs adds --rpc with the worker addresses to the model's command, unless
+// it has --rpc already
+func (c ClusterConfig) rpcArgs(args []string) []string {
+	if slices.Contains(args, "--rpc") {
+		return args
+	}
+	addresses := make([]string, 0, len(c.Workers))
+	for _, worker := range c.Workers {
+		addresses = append(addresses, worker.Address)
+	}
+	return append(args, "--rpc", strings.Join(addresses, ","))
+}
+
+// workerArgs returns the command starting the worker
+func (c ClusterConfig) workerArgs(worker ClusterWorker) ([]string, error) {
+	if worker.Host == "" {
+		return SanitizeCommand(worker.Cmd)
+	}
+	ssh := c.SSH
+	if ssh == "" {
+		ssh = defaultClusterSSH
+	}
+	args, err := SanitizeCommand(ssh)
+	if err != nil {
+		return nil, err
+	}
+	// the cmd is run by the remote shell, on one line
+	cmd := strings.NewReplacer("\\ \n", " ", "\\\n", " ").Replace(worker.Cmd)
+	return append(args, worker.Host, strings.Join(strings.Fields(cmd), " ")), nil
+}
+
+type clusterWorker struct {
+	address string
+	cmd     *exec.Cmd
+	waiter  *cmdWaiter
+}
+
+// startCluster starts the workers and returns once all of them accept
+// connections, the state lock must be held
+func (p *Process) startCluster() error {
+	modelOutput := p.logMonitor.ModelWriter(p.ID)
+	for _, worker := range p.config.Cluster.Workers {
+		args, err := p.config.Cluster.workerArgs(worker)
+		if err != nil {
+			p.stopCluster()
+			return fmt.Errorf("cluster worker %s: %v", worker.Address, err)
+		}
+
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = modelOutput
+		cmd.Stderr = modelOutput
+		// in stale.go
+		cmd.Env = markedEnv(nil, p.ID)
+		// in procgroup_*.go
+		startInProcessGroup(cmd)
+		cmd.WaitDelay = time.Second
+
+		if err := cmd.Start(); err != nil {
+			p.stopCluster()
+			return fmt.Errorf("cluster worker %s: %v", worker.Address, err)
+		}
+		p.cluster = append(p.cluster, &clusterWorker{address: worker.Address, cmd: cmd, waiter: waitForCmd(cmd)})
+	}
+
+	deadline := time.Now().Add(time.Duration(p.healthCheckTimeout) * time.Second)
+	for _, worker := range p.cluster {
+		if err := worker.waitListening(deadline); err != nil {
+			p.stopCluster()
+			return err
+		}
+	}
+	return nil
+}
+
+// waitListening returns once the worker accepts connections
+func (w *clusterWorker) waitListening(deadline time.Time) error {
+	for {
+		conn, err := net.DialTimeout("tcp", w.address, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cluster worker %s did not accept connections in time: %v", w.address, err)
+		}
+
+		select {
+		case <-w.waiter.done:
+			return fmt.Errorf("cluster worker %s exited: %v", w.address, w.waiter.err)
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// watchCluster stops the model's command when a worker exits, the model is
+// then handled like a crashed process
+func (p *Process) watchCluster(cmd *exec.Cmd, workers []*clusterWorker) {
+	exited := make(chan *clusterWorker, len(workers))
+	for _, worker := range workers {
+		go func(worker *clusterWorker) {
+			<-worker.waiter.done
+			exited <- worker
+		}(worker)
+	}
+	worker := <-exited
+
+	p.stateMutex.RLock()
+	current, state := p.cmd, p.state
+	p.stateMutex.RUnlock()
+	if current != cmd || (state != StateReady && state != StateSleeping) {
+		return
+	}
+
+	fmt.Fprintf(p.logMonitor, "!!! Cluster worker %s of %s exited: %v, stopping the model\n", worker.address, p.ID, worker.waiter.err)
+	signalProcessGroup(cmd, syscall.SIGKILL)
+}
+
+// stopCluster stops the workers, the state lock must be held
+func (p *Process) stopCluster() {
+	if len(p.cluster) == 0 {
+		return
+	}
+	workers := p.cluster
+	p.cluster = nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultGracefulStop)
+	defer cancel()
+	for _, worker := range workers {
+		signalProcessGroup(worker.cmd, syscall.SIGTERM)
+	}
+	for _, worker := range workers {
+		select {
+		case <-worker.waiter.done:
+		case <-ctx.Done():
+			fmt.Fprintf(p.logMonitor, "XXX Cluster worker %s of %s did not stop within %v of SIGTERM, sending SIGKILL\n", worker.address, p.ID, defaultGracefulStop)
+		}
+		signalProcessGroup(worker.cmd, syscall.SIGKILL)
+		<-worker.waiter.done
+	}
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Cluster(t *testing.T) {
+	_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: llama-server --port 9001
+    proxy: http://127.0.0.1:9001
+    cluster:
+      workers:
+        - host: gpu2
+          cmd: rpc-server --port 50052
+          address: gpu2
+`))
+	assert.ErrorContains(t, err, "model model1: cluster worker 0: address must be host:port")
+}
+
+func TestClusterConfig_Args(t *testing.T) {
+	cluster := ClusterConfig{Workers: []ClusterWorker{
+		{Host: "user@gpu2", Cmd: "rpc-server --host 0.0.0.0 \\\n  --port 50052", Address: "10.0.0.2:50052"},
+		{Cmd: "rpc-server --port 50053", Address: "127.0.0.1:50053"},
+	}}
+
+	assert.Equal(t, []string{"llama-server", "--rpc", "10.0.0.2:50052,127.0.0.1:50053"}, cluster.rpcArgs([]string{"llama-server"}))
+	assert.Equal(t, []string{"llama-server", "--rpc", "10.0.0.2:50052"}, cluster.rpcArgs([]string{"llama-server", "--rpc", "10.0.0.2:50052"}))
+
+	args, err := cluster.workerArgs(cluster.Workers[0])
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ssh", "-tt", "-o", "BatchMode=yes", "user@gpu2", "rpc-server --host 0.0.0.0 --port 50052"}, args)
+
+	args, err = cluster.workerArgs(cluster.Workers[1])
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rpc-server", "--port", "50053"}, args)
+}
+
+func TestProcess_Cluster(t *testing.T) {
+	listening := func(address string) bool {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}
+
+	// simple-responders stand in for rpc-server
+	config := getTestSimpleResponderConfig("cluster")
+	var addresses []string
+	for i := 0; i < 2; i++ {
+		worker := getTestSimpleResponderConfig("worker")
+		address := strings.TrimPrefix(worker.Proxy, "http://")
+		addresses = append(addresses, address)
+		config.Cluster.Workers = append(config.Cluster.Workers, ClusterWorker{Cmd: worker.Cmd, Address: address})
+	}
+
+	process := NewProcess("cluster", 15, config, NewLogMonitorWriter(io.Discard))
+	defer process.Stop()
+
+	assert.NoError(t, process.start())
+	assert.Contains(t, strings.Join(process.cmd.Args, " "), fmt.Sprintf("--rpc %s,%s", addresses[0], addresses[1]))
+	assert.True(t, listening(addresses[0]))
+	assert.True(t, listening(addresses[1]))
+
+	// the workers are stopped with the model
+	process.Stop()
+	assert.False(t, listening(addresses[0]))
+	assert.False(t, listening(addresses[1]))
+
+	// the model is stopped when a worker exits
+	assert.NoError(t, process.start())
+	process.stateMutex.RLock()
+	process.cluster[0].cmd.Process.Kill()
+	process.stateMutex.RUnlock()
+
+	assert.Eventually(t, func() bool {
+		return process.CurrentState() == StateStopped
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.False(t, listening(addresses[1]))
+}
@@ -2,35 +2,308 @@ package proxy
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/google/shlex"
 	"gopkg.in/yaml.v3"
 )
 
+type AutoRestartConfig struct {
+	MaxRetries     int `yaml:"maxRetries"`
+	BackoffSeconds int `yaml:"backoffSeconds"`
+}
+
 type ModelConfig struct {
 	Cmd           string   `yaml:"cmd"`
 	Proxy         string   `yaml:"proxy"`
 	Aliases       []string `yaml:"aliases"`
 	Env           []string `yaml:"env"`
 	CheckEndpoint string   `yaml:"checkEndpoint"`
+	CheckCmd      string   `yaml:"checkCmd"`
 	UnloadAfter   int      `yaml:"ttl"`
 	Unlisted      bool     `yaml:"unlisted"`
+
+	// estimated GPU memory used by the model, see VRAMConfig
+	VramEstimateMB int `yaml:"vramEstimateMB"`
+
+	AutoRestart AutoRestartConfig `yaml:"autoRestart"`
+
+	// keep checking a ready model, see liveness.go
+	LivenessCheck LivenessCheckConfig `yaml:"livenessCheck"`
+
+	// restart the model once it ran this long, e.g. 6h, when no requests
+	// are in flight. Restarts are up to maxLifetimeJitter earlier so models
+	// started together are not restarted together, see lifetime.go
+	MaxLifetime       string `yaml:"maxLifetime"`
+	MaxLifetimeJitter string `yaml:"maxLifetimeJitter"`
+
+	// models to try when this one fails to start or responds with a 5xx
+	Fallback []string `yaml:"fallback"`
+
+	// arguments used by `llama-swap doctor` to check the binary runs
+	ProbeArgs string `yaml:"probeArgs"`
+
+	// start at boot and keep running when other models are swapped in
+	Standby bool `yaml:"standby"`
+
+	// models started, in order, before this one and kept running while it
+	// is loaded, see dependencies.go
+	DependsOn []string `yaml:"dependsOn"`
+
+	// free form details like quant, size or backend, added to JSON access
+	// log records
+	Metadata map[string]string `yaml:"metadata"`
+
+	// requests with a higher priority are served first when their profile
+	// uses priority scheduling
+	Priority int `yaml:"priority"`
+
+	// seconds until a request to the upstream is aborted, 0 = no limit
+	RequestTimeout int `yaml:"requestTimeout"`
+	// seconds without data from the upstream before a response is aborted
+	IdleStreamTimeout int `yaml:"idleStreamTimeout"`
+
+	// re-chunking of text/event-stream responses, see sse.go
+	SplitLargeChunks bool `yaml:"splitLargeChunks"`
+	SSEFlushInterval int  `yaml:"sseFlushInterval"`
+	SSEPadding       int  `yaml:"ssePadding"`
+
+	// seconds to wait after SIGTERM before sending SIGKILL, 0 = default
+	GracefulStopSeconds int `yaml:"gracefulStopSeconds"`
+
+	// served by another machine instead of cmd, see remote.go
+	Remote RemoteConfig `yaml:"remote"`
+
+	// process (default) stops the model when swapping, sleep puts a vLLM
+	// server to sleep with sleepLevel 1 (default) or 2, see sleep.go
+	SwapMode   string `yaml:"swapMode"`
+	SleepLevel int    `yaml:"sleepLevel"`
+
+	// change request bodies before they are sent, see filters.go
+	Filters ModelFilters `yaml:"filters"`
+
+	// drop request body fields the backend does not accept: llama.cpp,
+	// vllm or openai, see backends.go. allowParams are kept in addition.
+	Backend     string   `yaml:"backend"`
+	AllowParams []string `yaml:"allowParams"`
+
+	// cap the max_tokens of requests, see limits.go
+	Limits LimitsConfig `yaml:"limits"`
+
+	// chat, completion, embedding, rerank or audio. Requests to the
+	// endpoints of other tasks are rejected, see tasktype.go
+	TaskType string `yaml:"taskType"`
+
+	// for upstreams that require HTTPS or their own API keys
+	ProxyTLS        ProxyTLSConfig    `yaml:"proxyTLS"`
+	UpstreamHeaders map[string]string `yaml:"upstreamHeaders"`
+
+	// send a copy of shadowPercent (default 100) of the requests to this
+	// model to a standby model to compare them, see shadow.go
+	ShadowModel   string `yaml:"shadowModel"`
+	ShadowPercent int    `yaml:"shadowPercent"`
+
+	// also send requests to a standby model when this one has not
+	// responded in time, see hedge.go
+	Hedge HedgeConfig `yaml:"hedge"`
+
+	// rpc-server workers started with the model, see cluster.go
+	Cluster ClusterConfig `yaml:"cluster"`
+
+	// send requests again after a transient upstream error, see retry.go
+	Retry RetryConfig `yaml:"retry"`
+
+	// split large /v1/embeddings requests, see embeddings.go
+	EmbeddingBatch EmbeddingBatchConfig `yaml:"embeddingBatch"`
+
+	// largest request body in MB, overrides the global maxRequestBodyMB
+	MaxRequestBodyMB int `yaml:"maxRequestBodyMB"`
+
+	// override the config's macros for this model, see macros.go
+	Macros map[string]string `yaml:"macros"`
+
+	// also write the command's output to this file, see modellog.go. It is
+	// rotated when it reaches logFileRotateMB or is older than
+	// logFileRotateHours, keeping logFileKeep rotated files.
+	LogFile            string `yaml:"logFile"`
+	LogFileRotateMB    int    `yaml:"logFileRotateMB"`
+	LogFileRotateHours int    `yaml:"logFileRotateHours"`
+	LogFileKeep        int    `yaml:"logFileKeep"`
+
+	// write requests and responses to JSON lines files for `llama-swap
+	// replay`, see record.go
+	Record RecordConfig `yaml:"record"`
 }
 
 func (m *ModelConfig) SanitizedCommand() ([]string, error) {
 	return SanitizeCommand(m.Cmd)
 }
 
+type VRAMConfig struct {
+	// nvidia-smi, rocm-smi or none
+	Provider string `yaml:"provider"`
+
+	// total VRAM that models may use, 0 = no limit
+	BudgetMB int `yaml:"budgetMB"`
+
+	// command run before a model starts, it exits with 0 when there is
+	// enough free memory. See vram.go for its environment.
+	CheckCmd string `yaml:"checkCmd"`
+
+	// seconds to wait for enough free memory before a start fails
+	CheckWaitSeconds int `yaml:"checkWaitSeconds"`
+}
+
+// Enabled is true when llama-swap can decide if models fit together
+func (v VRAMConfig) Enabled() bool {
+	return (v.Provider != "" && v.Provider != "none") || v.BudgetMB > 0
+}
+
 type Config struct {
 	HealthCheckTimeout int                    `yaml:"healthCheckTimeout"`
 	LogRequests        bool                   `yaml:"logRequests"`
 	Models             map[string]ModelConfig `yaml:"models"`
 	Profiles           map[string][]string    `yaml:"profiles"`
 
+	// range of the ports assigned to models with ${PORT} in their cmd,
+	// default 5800 to 6799, see ports.go
+	StartPort int `yaml:"startPort"`
+	EndPort   int `yaml:"endPort"`
+
+	// GPU indexes assigned to models with ${GPU_INDEX} in their cmd or env
+	GPUs []int `yaml:"gpus"`
+
+	// ${NAME} values replaced in cmd, proxy, checkCmd, checkEndpoint and env
+	// of all models, see macros.go
+	Macros map[string]string `yaml:"macros"`
+
+	// forward requests to this llama-swap while draining instead of a 503
+	DrainPeer string `yaml:"drainPeer"`
+
+	VRAM VRAMConfig `yaml:"vram"`
+
+	// seconds after this config is reloaded in which a model failing to
+	// start rolls back to the previous config, 0 = disabled
+	ReloadProbationSeconds int `yaml:"reloadProbationSeconds"`
+
+	// number of backups kept when llama-swap writes the config file
+	ConfigBackups int `yaml:"configBackups"`
+
+	// models served by another model with request parameters overridden,
+	// see virtualmodels.go
+	VirtualModels map[string]VirtualModelConfig `yaml:"virtualModels"`
+
+	// split requests to a published model name between models, see
+	// abtest.go
+	ABTests map[string]ABTestConfig `yaml:"abTests"`
+
+	// pick models by request attributes, see routing.go
+	Routing []RoutingRule `yaml:"routing"`
+
+	AccessLog AccessLogConfig `yaml:"accessLog"`
+
+	// record management actions, see audit.go
+	Audit AuditConfig `yaml:"audit"`
+
+	// keep the logs on disk for /api/logs/search, see loghistory.go
+	LogHistory LogHistoryConfig `yaml:"logHistory"`
+
+	// serve repeated requests from memory, see cache.go
+	Cache CacheConfig `yaml:"cache"`
+
+	// add the llama-server /props of running models to /v1/models
+	ProbeUpstreamProps bool `yaml:"probeUpstreamProps"`
+
+	// largest request body in MB read by llama-swap, 0 = no limit, see
+	// bodylimit.go
+	MaxRequestBodyMB int `yaml:"maxRequestBodyMB"`
+
+	// requests with a larger body in MB are streamed to the model instead
+	// of being read into memory, when the model does not need the parsed
+	// body. 0 = disabled, see bodystream.go
+	StreamBodyMB int `yaml:"streamBodyMB"`
+
+	// cmd of models added with the Ollama /api/create, the model file and
+	// parameters are appended, see ollamamodels.go
+	OllamaCreateCmd string `yaml:"ollamaCreateCmd"`
+
+	// separate addresses of the proxy and management endpoints, see
+	// listeners.go
+	Listen ListenConfig `yaml:"listen"`
+
+	// /health responds with a 503 until the standby models started with
+	// llama-swap are ready, and systemd is notified after them
+	BlockUntilStandbyReady bool `yaml:"blockUntilStandbyReady"`
+
+	// add the exit code and last log lines of a model that failed to start
+	// to the JSON error, see lasterror.go
+	StartupErrorDetails bool `yaml:"startupErrorDetails"`
+
+	// HEAD requests to inference endpoints respond 200 when this model is
+	// loaded, see readiness.go
+	HeadReadinessModel string `yaml:"headReadinessModel"`
+
+	// HTTP status for requests with an unknown model, 404 (default) or 400
+	UnknownModelStatus int `yaml:"unknownModelStatus"`
+
+	// seconds between keep-alives sent to clients waiting for a model to
+	// load, see keepalive.go. 0 = disabled
+	LoadKeepAliveInterval int `yaml:"loadKeepAliveInterval"`
+
+	// SIGTERM processes found listening on the proxy port of a model that is
+	// not running, see incidents.go
+	ReclaimPorts bool `yaml:"reclaimPorts"`
+
+	// allow or deny requests with an external authorizer, see authexternal.go
+	AuthExternal AuthExternalConfig `yaml:"authExternal"`
+
+	// validate bearer tokens as JWTs, see oidc.go
+	Auth AuthConfig `yaml:"auth"`
+
+	// notify other services about process events, see webhooks.go
+	Hooks HooksConfig `yaml:"hooks"`
+
+	// benchmark models on a schedule and alert on regressions, see benchy.go
+	Benchy BenchyConfig `yaml:"benchy"`
+
+	// CORS headers per path, the first matching policy is used, see cors.go
+	CORS CORSPolicies `yaml:"cors"`
+
+	// bearer tokens allowed to trace requests and read traces, see trace.go
+	AdminKeys []string `yaml:"adminKeys"`
+
+	// usage limits per model and API key, see quota.go
+	Quotas map[string]QuotaConfig `yaml:"quotas"`
+
+	// concurrency limits shared by the models of a profile, see scheduler.go
+	ProfileScheduling map[string]SchedulingConfig `yaml:"profileScheduling"`
+
+	// with vram configured, loading a profile only stops the other models
+	// needed to make room, in the order of lru, priority or ttl, see
+	// eviction.go. Without a policy loading a profile stops all of them.
+	ProfileEvictionPolicy map[string]string `yaml:"profileEvictionPolicy"`
+
+	// preload or unload models and profiles at times of the day, see
+	// schedules.go
+	Schedules []ScheduleConfig `yaml:"schedules"`
+
+	// resolve requested names that are not exact model IDs or aliases
+	ModelMatching ModelMatchingConfig `yaml:"modelMatching"`
+
+	// resolve model IDs and aliases ignoring case
+	CaseInsensitiveModels bool `yaml:"caseInsensitiveModels"`
+
 	// map aliases to actual model IDs
 	aliases map[string]string
+
+	// lower cased model IDs and aliases to actual model IDs
+	lowerNames map[string]string
+
+	// normalized model IDs and aliases to actual model IDs, see modelMatching
+	matchNames map[string]string
 }
 
 func (c *Config) RealModelName(search string) (string, bool) {
@@ -38,11 +311,75 @@ func (c *Config) RealModelName(search string) (string, bool) {
 		return search, true
 	} else if name, found := c.aliases[search]; found {
 		return name, found
+	} else if name, found := c.lowerNames[strings.ToLower(search)]; found && c.CaseInsensitiveModels {
+		return name, found
+	} else if name, found := c.matchModelName(search); found {
+		return name, found
 	} else {
 		return "", false
 	}
 }
 
+// ModelMatchingConfig resolves requested names that do not exactly match a
+// model ID or alias, like file names sent by the llama.cpp web UI
+type ModelMatchingConfig struct {
+	// match when the requested name is the start of the name of one model,
+	// followed by a separator like - or .
+	Fuzzy bool `yaml:"fuzzy"`
+
+	// removed from requested and configured names before matching
+	StripSuffixes []string `yaml:"stripSuffixes"`
+
+	// same as caseInsensitiveModels, also used for fuzzy matches
+	CaseInsensitive bool `yaml:"caseInsensitive"`
+}
+
+func (m ModelMatchingConfig) enabled() bool {
+	return m.Fuzzy || len(m.StripSuffixes) > 0 || m.CaseInsensitive
+}
+
+func (m ModelMatchingConfig) normalize(name string) string {
+	if m.CaseInsensitive {
+		name = strings.ToLower(name)
+	}
+	for _, suffix := range m.StripSuffixes {
+		if m.CaseInsensitive {
+			suffix = strings.ToLower(suffix)
+		}
+		name = strings.TrimSuffix(name, suffix)
+	}
+	return name
+}
+
+// matchModelName resolves search with the modelMatching rules
+func (c *Config) matchModelName(search string) (string, bool) {
+	if c.matchNames == nil {
+		return "", false
+	}
+
+	search = c.ModelMatching.normalize(search)
+	if name, found := c.matchNames[search]; found {
+		return name, true
+	}
+
+	if !c.ModelMatching.Fuzzy || search == "" {
+		return "", false
+	}
+
+	// the prefix has to match a single model
+	match := ""
+	for name, modelName := range c.matchNames {
+		if len(name) <= len(search) || !strings.HasPrefix(name, search) || !strings.ContainsRune("-_.:/ ", rune(name[len(search)])) {
+			continue
+		}
+		if match != "" && match != modelName {
+			return "", false
+		}
+		match = modelName
+	}
+	return match, match != ""
+}
+
 func (c *Config) FindConfig(modelName string) (ModelConfig, string, bool) {
 	if realName, found := c.RealModelName(modelName); !found {
 		return ModelConfig{}, "", false
@@ -51,14 +388,37 @@ func (c *Config) FindConfig(modelName string) (ModelConfig, string, bool) {
 	}
 }
 
+// findRequestedConfig is FindConfig for requested names that can have a
+// profile prefix
+func (c *Config) findRequestedConfig(requestedModel string) (ModelConfig, string, bool) {
+	_, modelName, found := strings.Cut(requestedModel, PROFILE_SPLIT_CHAR)
+	if !found {
+		modelName = requestedModel
+	}
+	return c.FindConfig(modelName)
+}
+
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
+	return LoadConfigFromBytes(data)
+}
+
+func LoadConfigFromReader(r io.Reader) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadConfigFromBytes(data)
+}
+
+func LoadConfigFromBytes(data []byte) (*Config, error) {
 	var config Config
-	err = yaml.Unmarshal(data, &config)
+	err := yaml.Unmarshal(data, &config)
 	if err != nil {
 		return nil, err
 	}
@@ -67,14 +427,326 @@ func LoadConfig(path string) (*Config, error) {
 		config.HealthCheckTimeout = 15
 	}
 
+	if _, err := newVRAMProvider(config.VRAM.Provider); err != nil {
+		return nil, err
+	}
+
+	if config.VRAM.CheckCmd != "" {
+		if _, err := SanitizeCommand(config.VRAM.CheckCmd); err != nil {
+			return nil, fmt.Errorf("vram: invalid checkCmd: %v", err)
+		}
+	}
+	if config.VRAM.CheckWaitSeconds < 0 {
+		return nil, fmt.Errorf("vram: checkWaitSeconds must not be negative")
+	}
+
+	if err := config.AccessLog.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.LogHistory.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Listen.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Cache.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Hooks.validate(); err != nil {
+		return nil, err
+	}
+
+	for _, policy := range config.CORS {
+		if err := policy.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, rule := range config.Routing {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("routing[%d]: %v", i, err)
+		}
+	}
+
+	if config.MaxRequestBodyMB < 0 {
+		return nil, fmt.Errorf("maxRequestBodyMB must not be negative")
+	}
+	if config.StreamBodyMB < 0 {
+		return nil, fmt.Errorf("streamBodyMB must not be negative")
+	}
+
+	if config.LoadKeepAliveInterval < 0 {
+		return nil, fmt.Errorf("loadKeepAliveInterval must not be negative")
+	}
+
+	for profileName, scheduling := range config.ProfileScheduling {
+		if _, found := config.Profiles[profileName]; !found {
+			return nil, fmt.Errorf("profileScheduling: unknown profile %s", profileName)
+		}
+		if err := scheduling.validate(); err != nil {
+			return nil, fmt.Errorf("profileScheduling %s: %v", profileName, err)
+		}
+	}
+
+	for profileName, policy := range config.ProfileEvictionPolicy {
+		if _, found := config.Profiles[profileName]; !found {
+			return nil, fmt.Errorf("profileEvictionPolicy: unknown profile %s", profileName)
+		}
+		if err := validateEvictionPolicy(policy); err != nil {
+			return nil, fmt.Errorf("profileEvictionPolicy %s: %v", profileName, err)
+		}
+	}
+
+	if err := config.AuthExternal.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Auth.OIDC.validate(); err != nil {
+		return nil, err
+	}
+
+	for modelName := range config.Quotas {
+		if _, found := config.Models[modelName]; !found {
+			return nil, fmt.Errorf("quotas: unknown model %s", modelName)
+		}
+	}
+
+	switch config.UnknownModelStatus {
+	case 0:
+		config.UnknownModelStatus = 404
+	case 400, 404:
+	default:
+		return nil, fmt.Errorf("unknownModelStatus must be 400 or 404")
+	}
+
+	if config.HeadReadinessModel != "" {
+		if _, found := config.Models[config.HeadReadinessModel]; !found {
+			return nil, fmt.Errorf("headReadinessModel %s is not a model", config.HeadReadinessModel)
+		}
+	}
+
+	if err := config.validatePorts(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateMacros(); err != nil {
+		return nil, err
+	}
+
+	if config.ConfigBackups <= 0 {
+		config.ConfigBackups = defaultConfigBackups
+	}
+
 	// Populate the aliases map
+	modelNames := make([]string, 0, len(config.Models))
+	for modelName := range config.Models {
+		modelNames = append(modelNames, modelName)
+	}
+	sort.Strings(modelNames)
+
 	config.aliases = make(map[string]string)
-	for modelName, modelConfig := range config.Models {
-		for _, alias := range modelConfig.Aliases {
+	for _, modelName := range modelNames {
+		for _, alias := range config.Models[modelName].Aliases {
+			if _, found := config.Models[alias]; found && alias != modelName {
+				return nil, fmt.Errorf("alias %s of model %s collides with model %s", alias, modelName, alias)
+			}
+			if other, found := config.aliases[alias]; found && other != modelName {
+				return nil, fmt.Errorf("alias %s is used by models %s and %s", alias, other, modelName)
+			}
 			config.aliases[alias] = modelName
 		}
 	}
 
+	if config.ModelMatching.CaseInsensitive {
+		config.CaseInsensitiveModels = true
+	}
+
+	if config.ModelMatching.enabled() {
+		config.matchNames = make(map[string]string)
+		addName := func(name, modelName string) error {
+			normalized := config.ModelMatching.normalize(name)
+			if other, found := config.matchNames[normalized]; found && other != modelName {
+				return fmt.Errorf("modelMatching: %s of model %s matches the same name as model %s", name, modelName, other)
+			}
+			config.matchNames[normalized] = modelName
+			return nil
+		}
+
+		for _, modelName := range modelNames {
+			if err := addName(modelName, modelName); err != nil {
+				return nil, err
+			}
+			for _, alias := range config.Models[modelName].Aliases {
+				if err := addName(alias, modelName); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if config.CaseInsensitiveModels {
+		config.lowerNames = make(map[string]string)
+		addName := func(name, modelName string) error {
+			lower := strings.ToLower(name)
+			if other, found := config.lowerNames[lower]; found && other != modelName {
+				return fmt.Errorf("%s of model %s only differs by case from a name of model %s", name, modelName, other)
+			}
+			config.lowerNames[lower] = modelName
+			return nil
+		}
+
+		for _, modelName := range modelNames {
+			if err := addName(modelName, modelName); err != nil {
+				return nil, err
+			}
+			for _, alias := range config.Models[modelName].Aliases {
+				if err := addName(alias, modelName); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for modelName, modelConfig := range config.Models {
+		modelConfig, err := config.expandMacros(modelName, modelConfig)
+		if err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+		config.Models[modelName] = modelConfig
+		if modelConfig.usesGPUMacro() && len(config.GPUs) == 0 {
+			return nil, fmt.Errorf("model %s: uses %s but no gpus are configured", modelName, gpuMacro)
+		}
+
+		if modelConfig.CheckCmd != "" {
+			if _, err := SanitizeCommand(modelConfig.CheckCmd); err != nil {
+				return nil, fmt.Errorf("model %s: invalid checkCmd: %v", modelName, err)
+			}
+		}
+
+		if modelConfig.GracefulStopSeconds < 0 || modelConfig.RequestTimeout < 0 || modelConfig.IdleStreamTimeout < 0 || modelConfig.MaxRequestBodyMB < 0 {
+			return nil, fmt.Errorf("model %s: gracefulStopSeconds, requestTimeout, idleStreamTimeout and maxRequestBodyMB must not be negative", modelName)
+		}
+
+		if err := modelConfig.validateCluster(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := modelConfig.Retry.validate(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := modelConfig.validateSSE(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := validateUnixProxy(modelConfig); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := modelConfig.Remote.validate(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+		if modelConfig.Remote.enabled() {
+			if modelConfig.Cmd != "" || (modelConfig.Proxy != "" && modelConfig.Proxy != modelConfig.Remote.Host) {
+				return nil, fmt.Errorf("model %s: remote models can not have a cmd or a different proxy", modelName)
+			}
+			modelConfig.Proxy = modelConfig.Remote.Host
+			config.Models[modelName] = modelConfig
+		}
+
+		if err := modelConfig.validatePortMacro(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+		if modelConfig.usesPortMacro() && modelConfig.Proxy == "" {
+			modelConfig.Proxy = defaultPortProxy
+			config.Models[modelName] = modelConfig
+		}
+
+		if err := modelConfig.EmbeddingBatch.validate(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := modelConfig.validateLivenessCheck(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := modelConfig.validateLogFile(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := modelConfig.validateSwapMode(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := modelConfig.Record.validate(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := modelConfig.Filters.validate(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := validateBackend(modelConfig.Backend); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := validateTaskType(modelConfig.TaskType); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := modelConfig.Limits.validate(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := modelConfig.validateLifetime(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := modelConfig.ProxyTLS.validate(); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		for _, fallback := range modelConfig.Fallback {
+			if _, found := config.RealModelName(fallback); !found {
+				return nil, fmt.Errorf("model %s: unknown fallback model %s", modelName, fallback)
+			}
+		}
+
+		if err := config.validateShadowModel(modelName, modelConfig); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+
+		if err := config.validateHedge(modelName, modelConfig.Hedge); err != nil {
+			return nil, fmt.Errorf("model %s: %v", modelName, err)
+		}
+	}
+
+	if err := config.validateVirtualModels(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateABTests(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateDependencies(); err != nil {
+		return nil, err
+	}
+
+	for i, schedule := range config.Schedules {
+		if err := config.validateSchedule(schedule); err != nil {
+			return nil, fmt.Errorf("schedules[%d]: %v", i, err)
+		}
+	}
+
+	if err := config.validateBenchy(); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
 
@@ -3,6 +3,7 @@ package proxy
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -70,6 +71,8 @@ profiles:
 			},
 		},
 		HealthCheckTimeout: 15,
+		ConfigBackups:      5,
+		UnknownModelStatus: 404,
 		Profiles: map[string][]string{
 			"test": {"model1", "model2"},
 		},
@@ -174,3 +177,131 @@ func TestConfig_SanitizeCommand(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, args)
 }
+
+func TestConfig_UnknownFallbackModel(t *testing.T) {
+	_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: path/to/cmd
+    proxy: http://localhost:8080
+    fallback:
+      - model2
+`))
+	assert.ErrorContains(t, err, "unknown fallback model model2")
+}
+
+func TestConfig_AliasCollisions(t *testing.T) {
+	_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: path/to/cmd
+    aliases: [shared]
+  model2:
+    cmd: path/to/cmd
+    aliases: [shared]
+`))
+	assert.ErrorContains(t, err, "alias shared is used by models model1 and model2")
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: path/to/cmd
+    aliases: [model2]
+  model2:
+    cmd: path/to/cmd
+`))
+	assert.ErrorContains(t, err, "alias model2 of model model1 collides with model model2")
+}
+
+func TestConfig_CaseInsensitiveModels(t *testing.T) {
+	content := `
+caseInsensitiveModels: true
+models:
+  Llama-3.2-1B:
+    cmd: path/to/cmd
+    aliases: [GPT-4o-mini]
+  qwen:
+    cmd: path/to/cmd
+`
+	config, err := LoadConfigFromBytes([]byte(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for search, expected := range map[string]string{
+		"llama-3.2-1b": "Llama-3.2-1B",
+		"gpt-4o-MINI":  "Llama-3.2-1B",
+		"QWEN":         "qwen",
+	} {
+		realName, found := config.RealModelName(search)
+		assert.True(t, found, search)
+		assert.Equal(t, expected, realName)
+	}
+
+	_, err = LoadConfigFromBytes([]byte(content + "  Qwen:\n    cmd: path/to/cmd\n"))
+	assert.ErrorContains(t, err, "only differs by case")
+
+	// case sensitive by default
+	config, err = LoadConfigFromBytes([]byte(content[len("caseInsensitiveModels: true\n"):]))
+	assert.NoError(t, err)
+	_, found := config.RealModelName("QWEN")
+	assert.False(t, found)
+}
+
+func TestConfig_ModelMatching(t *testing.T) {
+	content := `
+modelMatching:
+  fuzzy: true
+  stripSuffixes: [".gguf"]
+  caseInsensitive: true
+models:
+  Llama-3.2-1B-Instruct-Q4_K_M.gguf:
+    cmd: path/to/cmd
+  Qwen2.5-7B-Instruct-Q4_K_M:
+    cmd: path/to/cmd
+    aliases: [qwen-chat]
+  Qwen2.5-7B-Coder-Q8_0:
+    cmd: path/to/cmd
+`
+	config, err := LoadConfigFromBytes([]byte(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, config.CaseInsensitiveModels)
+
+	for search, expected := range map[string]string{
+		"llama-3.2-1b":                      "Llama-3.2-1B-Instruct-Q4_K_M.gguf",
+		"Llama-3.2-1B-Instruct-Q4_K_M":      "Llama-3.2-1B-Instruct-Q4_K_M.gguf",
+		"qwen2.5-7b-instruct-q4_k_m.GGUF":   "Qwen2.5-7B-Instruct-Q4_K_M",
+		"qwen2.5-7b-coder":                  "Qwen2.5-7B-Coder-Q8_0",
+		"QWEN-CHAT":                         "Qwen2.5-7B-Instruct-Q4_K_M",
+		"Llama-3.2-1B-Instruct-Q4_K_M.gguf": "Llama-3.2-1B-Instruct-Q4_K_M.gguf",
+	} {
+		realName, found := config.RealModelName(search)
+		assert.True(t, found, search)
+		assert.Equal(t, expected, realName, search)
+	}
+
+	for _, search := range []string{
+		"qwen2.5-7b",  // matches two models
+		"llama-3.2-1", // ends inside a word
+		"llama-3.2-1b-instruct-q4_k_m-extra",
+		"",
+	} {
+		_, found := config.RealModelName(search)
+		assert.False(t, found, search)
+	}
+
+	// names that normalize to the same name are an error
+	_, err = LoadConfigFromBytes([]byte(content + "  qwen2.5-7b-coder-q8_0.gguf:\n    cmd: path/to/cmd\n"))
+	assert.ErrorContains(t, err, "matches the same name")
+
+	// exact matches only without fuzzy
+	config, err = LoadConfigFromBytes([]byte(strings.Replace(content, "fuzzy: true", "fuzzy: false", 1)))
+	assert.NoError(t, err)
+	_, found := config.RealModelName("llama-3.2-1b")
+	assert.False(t, found)
+	realName, found := config.RealModelName("llama-3.2-1b-instruct-q4_k_m")
+	assert.True(t, found)
+	assert.Equal(t, "Llama-3.2-1B-Instruct-Q4_K_M.gguf", realName)
+}
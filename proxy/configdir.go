@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sections of the config where every entry must be defined in one file
+var configDirUniqueSections = []string{"models", "profiles"}
+
+// LoadConfigWithDir loads the config file merged with the YAML files of dir,
+// see MergeConfigFiles
+func LoadConfigWithDir(path, dir string) (*Config, error) {
+	data, sources, err := MergeConfigFiles(path, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := LoadConfigFromBytes(data)
+	if err != nil {
+		return nil, attributeConfigError(err, sources)
+	}
+	return config, nil
+}
+
+// ConfigDirFiles lists the .yaml and .yml files of dir in the order they are
+// merged, sorted by name
+func ConfigDirFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// MergeConfigFiles deep merges the config file, when it exists, and the YAML
+// files of dir into one config. Maps are merged, lists are appended and
+// later files replace the other values of earlier ones. A model or profile
+// defined in more than one file is an error. The returned sources map
+// "models.<id>" and "profiles.<name>" to the file defining them.
+func MergeConfigFiles(path, dir string) ([]byte, map[string]string, error) {
+	files, err := ConfigDirFiles(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("config dir: %v", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append([]string{path}, files...)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no config file %s and no YAML files in %s", path, dir)
+	}
+
+	merged := make(map[string]interface{})
+	sources := make(map[string]string)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", file, err)
+		}
+
+		for _, section := range configDirUniqueSections {
+			entries, _ := doc[section].(map[string]interface{})
+			for name := range entries {
+				key := section + "." + name
+				if defined, found := sources[key]; found {
+					return nil, nil, fmt.Errorf("%s: %s %s is already defined in %s", file, strings.TrimSuffix(section, "s"), name, defined)
+				}
+				sources[key] = file
+			}
+		}
+
+		if err := mergeConfigMaps(merged, doc, ""); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, sources, nil
+}
+
+func mergeConfigMaps(dst, src map[string]interface{}, path string) error {
+	for key, value := range src {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+
+		existing, found := dst[key]
+		if !found || existing == nil {
+			dst[key] = value
+			continue
+		}
+
+		switch existing := existing.(type) {
+		case map[string]interface{}:
+			valueMap, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s is a map in an earlier file", keyPath)
+			}
+			if err := mergeConfigMaps(existing, valueMap, keyPath); err != nil {
+				return err
+			}
+		case []interface{}:
+			valueList, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("%s is a list in an earlier file", keyPath)
+			}
+			dst[key] = append(existing, valueList...)
+		default:
+			dst[key] = value
+		}
+	}
+	return nil
+}
+
+// attributeConfigError adds the file defining the model or profile an error
+// of the merged config is about
+func attributeConfigError(err error, sources map[string]string) error {
+	message := err.Error()
+	for key, file := range sources {
+		section, name, _ := strings.Cut(key, ".")
+		if strings.HasPrefix(message, strings.TrimSuffix(section, "s")+" "+name+":") {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_LoadConfigWithDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "conf.d")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	write := func(path, content string) {
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	configPath := filepath.Join(root, "config.yaml")
+	write(configPath, `
+healthCheckTimeout: 30
+macros:
+  server: llama-server --port ${PORT}
+routing:
+  - {headers: {X-Task: code}, model: qwen}
+`)
+	write(filepath.Join(dir, "20-llama.yml"), `
+healthCheckTimeout: 60
+models:
+  llama:
+    cmd: ${server} -m llama.gguf
+profiles:
+  both: [qwen, llama]
+`)
+	write(filepath.Join(dir, "10-qwen.yaml"), `
+macros:
+  model_dir: /models
+models:
+  qwen:
+    cmd: ${server} -m ${model_dir}/qwen.gguf
+routing:
+  - {headers: {X-Task: chat}, model: llama}
+`)
+	write(filepath.Join(dir, "notes.txt"), "not a config")
+
+	config, err := LoadConfigWithDir(configPath, dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// later files replace the values of earlier ones
+	assert.Equal(t, 60, config.HealthCheckTimeout)
+	assert.Equal(t, []string{"qwen", "llama"}, config.Profiles["both"])
+	// macros of all files are merged
+	assert.Contains(t, config.Models["qwen"].Cmd, "-m /models/qwen.gguf")
+	// lists are appended in the order of the files
+	if assert.Len(t, config.Routing, 2) {
+		assert.Equal(t, "qwen", config.Routing[0].Model)
+		assert.Equal(t, "llama", config.Routing[1].Model)
+	}
+
+	// a model in two files
+	write(filepath.Join(dir, "30-qwen-again.yaml"), `
+models:
+  qwen:
+    cmd: llama-server --port ${PORT}
+`)
+	_, err = LoadConfigWithDir(configPath, dir)
+	assert.EqualError(t, err, filepath.Join(dir, "30-qwen-again.yaml")+": model qwen is already defined in "+filepath.Join(dir, "10-qwen.yaml"))
+	assert.NoError(t, os.Remove(filepath.Join(dir, "30-qwen-again.yaml")))
+
+	// errors of the merged config name the file of the model
+	write(filepath.Join(dir, "30-broken.yaml"), `
+models:
+  broken:
+    cmd: llama-server --port ${PORT}
+    taskType: vision
+`)
+	_, err = LoadConfigWithDir(configPath, dir)
+	assert.ErrorContains(t, err, filepath.Join(dir, "30-broken.yaml")+`: model broken: unknown taskType "vision"`)
+
+	// YAML errors name the file
+	write(filepath.Join(dir, "30-broken.yaml"), "models: [")
+	_, err = LoadConfigWithDir(configPath, dir)
+	assert.ErrorContains(t, err, filepath.Join(dir, "30-broken.yaml")+": yaml:")
+	assert.NoError(t, os.Remove(filepath.Join(dir, "30-broken.yaml")))
+
+	// the config file is optional
+	assert.NoError(t, os.Remove(configPath))
+	write(filepath.Join(dir, "00-macros.yaml"), "macros:\n  server: llama-server --port ${PORT}\n")
+	config, err = LoadConfigWithDir(configPath, dir)
+	if assert.NoError(t, err) {
+		assert.Len(t, config.Models, 2)
+	}
+}
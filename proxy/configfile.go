@@ -0,0 +1,273 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultConfigBackups = 5
+	configBackupInfix    = ".bak."
+
+	// backups of the same millisecond, see writeConfigBackup
+	maxConfigBackupSeq = 1000
+)
+
+// WriteConfigFile atomically replaces the config file at path with data. The
+// previous version is kept as a timestamped backup next to it and only the
+// newest keep backups are retained.
+func WriteConfigFile(path string, data []byte, keep int) error {
+	if _, err := LoadConfigFromBytes(data); err != nil {
+		return fmt.Errorf("refusing to write invalid config: %v", err)
+	}
+
+	if current, err := os.ReadFile(path); err == nil {
+		if err := writeConfigBackup(path, current, time.Now().Format("20060102-150405.000")); err != nil {
+			return fmt.Errorf("unable to write config backup: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	return pruneConfigBackups(path, keep)
+}
+
+// writeConfigBackup writes data to a new backup of path named after stamp.
+// Backups made within the same millisecond get a sequence suffix that still
+// sorts chronologically.
+func writeConfigBackup(path string, data []byte, stamp string) error {
+	for seq := 0; seq < maxConfigBackupSeq; seq++ {
+		backupPath := path + configBackupInfix + stamp
+		if seq > 0 {
+			backupPath += fmt.Sprintf("-%03d", seq)
+		}
+		f, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	return fmt.Errorf("too many backups at %s", stamp)
+}
+
+// newestConfigBackup is the backup WriteConfigFile made last, "" when there
+// is none
+func newestConfigBackup(path string) string {
+	backups, err := ConfigBackups(path)
+	if err != nil || len(backups) == 0 {
+		return ""
+	}
+	return backups[0]
+}
+
+// ConfigBackups returns the backup file names of path, newest first
+func ConfigBackups(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + configBackupInfix + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]string, 0, len(matches))
+	for _, match := range matches {
+		backups = append(backups, filepath.Base(match))
+	}
+
+	// the timestamp suffix sorts chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// RestoreConfigBackup replaces the config at path with the named backup, or
+// the newest one when name is empty, and returns the restored config.
+func RestoreConfigBackup(path string, name string, keep int) (*Config, error) {
+	backups, err := ConfigBackups(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("no config backups available")
+	}
+
+	if name == "" {
+		name = backups[0]
+	} else if strings.ContainsAny(name, `/\`) || !strings.HasPrefix(name, filepath.Base(path)+configBackupInfix) {
+		return nil, fmt.Errorf("invalid backup name: %s", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := LoadConfigFromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("backup %s is not a valid config: %v", name, err)
+	}
+
+	if err := WriteConfigFile(path, data, keep); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func pruneConfigBackups(path string, keep int) error {
+	backups, err := ConfigBackups(path)
+	if err != nil {
+		return err
+	}
+
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(filepath.Dir(path), backups[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConfigModels returns the models section of the YAML config data as written
+// in the file, without defaults
+func ConfigModels(data []byte) (map[string]interface{}, error) {
+	var config struct {
+		Models map[string]interface{} `yaml:"models"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if config.Models == nil {
+		config.Models = make(map[string]interface{})
+	}
+	return config.Models, nil
+}
+
+// ConfigDiff returns a unified diff of two versions of the config file at path
+func ConfigDiff(path string, before, after []byte) string {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(before)),
+		B:        difflib.SplitLines(string(after)),
+		FromFile: path,
+		ToFile:   path,
+		Context:  3,
+	})
+	return diff
+}
+
+// SetConfigModel adds or replaces the model id in the YAML config data. The
+// rest of the document, including comments, is kept. It returns true when the
+// model was added.
+func SetConfigModel(data []byte, id string, model map[string]interface{}) ([]byte, bool, error) {
+	var value yaml.Node
+	if err := value.Encode(model); err != nil {
+		return nil, false, err
+	}
+
+	added := false
+	out, err := editConfigModels(data, func(models *yaml.Node) error {
+		for i := 0; i < len(models.Content); i += 2 {
+			if models.Content[i].Value == id {
+				models.Content[i+1] = &value
+				return nil
+			}
+		}
+
+		added = true
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: id}
+		models.Content = append(models.Content, key, &value)
+		return nil
+	})
+	return out, added, err
+}
+
+// DeleteConfigModel removes the model id from the YAML config data
+func DeleteConfigModel(data []byte, id string) ([]byte, error) {
+	return editConfigModels(data, func(models *yaml.Node) error {
+		for i := 0; i < len(models.Content); i += 2 {
+			if models.Content[i].Value == id {
+				models.Content = append(models.Content[:i], models.Content[i+2:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("model %s not found", id)
+	})
+}
+
+// editConfigModels calls edit with the mapping node of the models section,
+// which is created when it is missing
+func editConfigModels(data []byte, edit func(models *yaml.Node) error) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config is not a YAML mapping")
+	}
+
+	var models *yaml.Node
+	for i := 0; i < len(root.Content); i += 2 {
+		if root.Content[i].Value == "models" {
+			models = root.Content[i+1]
+			break
+		}
+	}
+	if models == nil {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "models"}
+		models = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		root.Content = append(root.Content, key, models)
+	} else if models.Tag == "!!null" {
+		*models = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	} else if models.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("models is not a YAML mapping")
+	}
+
+	if err := edit(models); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,248 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testConfigYAML(modelName string) []byte {
+	return []byte("models:\n  " + modelName + ":\n    cmd: path/to/cmd\n    proxy: http://localhost:8080\n")
+}
+
+func TestConfigFile_WriteKeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	for _, name := range []string{"a", "b", "c", "d"} {
+		assert.NoError(t, WriteConfigFile(path, testConfigYAML(name), 2))
+	}
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, testConfigYAML("d"), data)
+
+	backups, err := ConfigBackups(path)
+	assert.NoError(t, err)
+	if assert.Len(t, backups, 2) {
+		newest, _ := os.ReadFile(filepath.Join(filepath.Dir(path), backups[0]))
+		assert.Equal(t, testConfigYAML("c"), newest)
+	}
+}
+
+func TestConfigFile_BackupsOfTheSameMillisecond(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, writeConfigBackup(path, testConfigYAML(name), "20250101-120000.000"))
+	}
+
+	backups, err := ConfigBackups(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"config.yaml.bak.20250101-120000.000-002",
+		"config.yaml.bak.20250101-120000.000-001",
+		"config.yaml.bak.20250101-120000.000",
+	}, backups)
+	newest, _ := os.ReadFile(filepath.Join(filepath.Dir(path), backups[0]))
+	assert.Equal(t, testConfigYAML("c"), newest)
+}
+
+func TestConfigFile_WriteRejectsInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, WriteConfigFile(path, testConfigYAML("a"), 2))
+	assert.Error(t, WriteConfigFile(path, []byte("models: [not valid"), 2))
+
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, testConfigYAML("a"), data)
+}
+
+func TestConfigFile_RestoreBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, WriteConfigFile(path, testConfigYAML("a"), 5))
+	time.Sleep(2 * time.Millisecond)
+	assert.NoError(t, WriteConfigFile(path, testConfigYAML("b"), 5))
+
+	_, err := RestoreConfigBackup(path, "../../etc/passwd", 5)
+	assert.Error(t, err)
+
+	config, err := RestoreConfigBackup(path, "", 5)
+	assert.NoError(t, err)
+	assert.Contains(t, config.Models, "a")
+
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, testConfigYAML("a"), data)
+}
+
+func TestProxyManager_RollbackConfigEndpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, WriteConfigFile(path, testConfigYAML("old"), 5))
+	time.Sleep(2 * time.Millisecond)
+	assert.NoError(t, WriteConfigFile(path, testConfigYAML("new"), 5))
+
+	config, err := LoadConfig(path)
+	assert.NoError(t, err)
+
+	proxy := New(config)
+	proxy.SetConfigPath(path)
+	defer proxy.StopProcesses()
+
+	req := httptest.NewRequest("POST", "/api/config/rollback", bytes.NewBufferString(`{}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, proxy.currentConfig().Models, "old")
+	assert.NotContains(t, proxy.currentConfig().Models, "new")
+}
+
+func TestConfigFile_SetAndDeleteModel(t *testing.T) {
+	data := []byte(`# global settings
+healthCheckTimeout: 60
+
+models:
+  # the first model
+  a:
+    cmd: path/to/cmd
+    proxy: http://localhost:8080
+`)
+
+	data, added, err := SetConfigModel(data, "org/b", map[string]interface{}{
+		"cmd":     "path/to/b",
+		"proxy":   "http://localhost:8081",
+		"aliases": []interface{}{"bee"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, added)
+
+	data, added, err = SetConfigModel(data, "a", map[string]interface{}{"cmd": "path/to/a2", "proxy": "http://localhost:8080"})
+	assert.NoError(t, err)
+	assert.False(t, added)
+
+	config, err := LoadConfigFromBytes(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 60, config.HealthCheckTimeout)
+		assert.Equal(t, "path/to/a2", config.Models["a"].Cmd)
+		assert.Equal(t, []string{"bee"}, config.Models["org/b"].Aliases)
+	}
+	assert.Contains(t, string(data), "# global settings")
+	assert.Contains(t, string(data), "# the first model")
+
+	data, err = DeleteConfigModel(data, "a")
+	assert.NoError(t, err)
+	_, err = DeleteConfigModel(data, "a")
+	assert.ErrorContains(t, err, "not found")
+
+	models, err := ConfigModels(data)
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+	assert.Contains(t, models, "org/b")
+
+	// the models section is added when it is missing
+	data, added, err = SetConfigModel([]byte("healthCheckTimeout: 30\n"), "c", map[string]interface{}{"cmd": "path/to/c"})
+	assert.NoError(t, err)
+	assert.True(t, added)
+	assert.Equal(t, "healthCheckTimeout: 30\nmodels:\n  c:\n    cmd: path/to/c\n", string(data))
+}
+
+func TestProxyManager_ConfigModelsEndpoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, append([]byte("adminKeys: [admin-key]\n"), testConfigYAML("a")...), 0644))
+
+	config, err := LoadConfig(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	proxy := New(config)
+	proxy.SetConfigPath(path)
+	defer proxy.StopProcesses()
+
+	request := func(method, target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, request("GET", "/api/config/models", "", "").Code)
+	assert.Equal(t, http.StatusForbidden, request("PUT", "/api/config/models/b", "user-key", `{"cmd":"x"}`).Code)
+
+	w := request("GET", "/api/config/models", "admin-key", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"a":{"cmd":"path/to/cmd"`)
+
+	w = request("PUT", "/api/config/models/org/b", "admin-key", `{"cmd":"path/to/b","proxy":"http://localhost:8081"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, proxy.currentConfig().Models, "org/b")
+
+	w = request("GET", "/api/config/models/org/b", "admin-key", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "path/to/b")
+
+	w = request("PUT", "/api/config/models/org/b", "admin-key", `{"cmd":"path/to/b2","proxy":"http://localhost:8081"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "path/to/b2", proxy.currentConfig().Models["org/b"].Cmd)
+
+	// dry runs return the entry and a diff without writing the file
+	before, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	w = request("PUT", "/api/config/models/c?dryRun=true", "admin-key", `{"cmd":"path/to/c","proxy":"http://localhost:8082"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var preview struct {
+		DryRun bool                   `json:"dryRun"`
+		ID     string                 `json:"id"`
+		Diff   string                 `json:"diff"`
+		Model  map[string]interface{} `json:"model"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	assert.True(t, preview.DryRun)
+	assert.Equal(t, "c", preview.ID)
+	assert.Equal(t, "path/to/c", preview.Model["cmd"])
+	assert.Contains(t, preview.Diff, "+++ "+path)
+	assert.Contains(t, preview.Diff, "+    cmd: path/to/c\n")
+
+	w = request("DELETE", "/api/config/models/a?dryRun=true", "admin-key", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"a"`)
+	assert.NotContains(t, w.Body.String(), `"model"`)
+	assert.Contains(t, w.Body.String(), `-  a:`)
+
+	w = request("PUT", "/api/config/models/c?dryRun=true", "admin-key", `{"cmd":"path/to/c","aliases":["a"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid config")
+
+	after, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+	backups, err := ConfigBackups(path)
+	assert.NoError(t, err)
+	// one per PUT of org/b
+	assert.Len(t, backups, 2)
+	assert.NotContains(t, proxy.currentConfig().Models, "c")
+	assert.Contains(t, proxy.currentConfig().Models, "a")
+
+	// invalid configs are not written
+	w = request("PUT", "/api/config/models/c", "admin-key", `{"cmd":"path/to/c","aliases":["a"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid config")
+	assert.NotContains(t, proxy.currentConfig().Models, "c")
+
+	assert.Equal(t, http.StatusNoContent, request("DELETE", "/api/config/models/a", "admin-key", "").Code)
+	assert.Equal(t, http.StatusNotFound, request("DELETE", "/api/config/models/a", "admin-key", "").Code)
+	assert.NotContains(t, proxy.currentConfig().Models, "a")
+
+	onDisk, err := LoadConfig(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"admin-key"}, onDisk.AdminKeys)
+		assert.NotContains(t, onDisk.Models, "a")
+		assert.Equal(t, "path/to/b2", onDisk.Models["org/b"].Cmd)
+	}
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultCORSAllowHeaders = "Content-Type, Authorization"
+
+// CORSPolicies is a list of policies, or a single policy for every path
+type CORSPolicies []CORSPolicy
+
+func (p *CORSPolicies) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		var policy CORSPolicy
+		if err := value.Decode(&policy); err != nil {
+			return err
+		}
+		*p = CORSPolicies{policy}
+		return nil
+	}
+	var policies []CORSPolicy
+	if err := value.Decode(&policies); err != nil {
+		return err
+	}
+	*p = policies
+	return nil
+}
+
+// CORSPolicy sets the CORS headers for the paths it matches
+type CORSPolicy struct {
+	// exact paths, or prefixes ending with *, empty = every path
+	Paths []string `yaml:"paths"`
+
+	// origins echoed in Access-Control-Allow-Origin, * allows any origin
+	// but can not be used with allowCredentials
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+
+	// allow cookies and Authorization headers from the browser
+	AllowCredentials bool `yaml:"allowCredentials"`
+
+	// request headers allowed in preflight requests
+	AllowedHeaders []string `yaml:"allowedHeaders"`
+
+	// seconds browsers may cache a preflight response
+	MaxAge int `yaml:"maxAge"`
+}
+
+func (p CORSPolicy) validate() error {
+	if len(p.AllowedOrigins) == 0 {
+		return fmt.Errorf("cors policies need allowedOrigins")
+	}
+	if p.AllowCredentials && slices.Contains(p.AllowedOrigins, "*") {
+		return fmt.Errorf("cors allowedOrigins * can not be used with allowCredentials, list the origins")
+	}
+	if p.MaxAge < 0 {
+		return fmt.Errorf("cors maxAge must not be negative")
+	}
+	return nil
+}
+
+func (p CORSPolicy) matches(path string) bool {
+	if len(p.Paths) == 0 {
+		return true
+	}
+	for _, pattern := range p.Paths {
+		if prefix, found := strings.CutSuffix(pattern, "*"); found && strings.HasPrefix(path, prefix) {
+			return true
+		} else if pattern == path {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for origin
+func (p CORSPolicy) allowedOrigin(origin string) (string, bool) {
+	if slices.Contains(p.AllowedOrigins, origin) {
+		return origin, true
+	}
+	if slices.Contains(p.AllowedOrigins, "*") {
+		return "*", true
+	}
+	return "", false
+}
+
+// corsMiddleware applies the first cors policy matching the path. Without
+// policies every preflight request is allowed.
+func (pm *ProxyManager) corsMiddleware(c *gin.Context) {
+	policies := pm.currentConfig().CORS
+	if len(policies) == 0 {
+		// see: https://github.com/mostlygeek/llama-swap/issues/42
+		// respond with permissive OPTIONS for any endpoint
+		if c.Request.Method == "OPTIONS" {
+			c.Header("Access-Control-Allow-Origin", "*")
+			c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			c.Header("Access-Control-Allow-Headers", defaultCORSAllowHeaders)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		if origin := c.GetHeader("Origin"); origin != "" && c.Request.URL.Path == "/v1/models" {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		c.Next()
+		return
+	}
+
+	index := slices.IndexFunc(policies, func(p CORSPolicy) bool {
+		return p.matches(c.Request.URL.Path)
+	})
+	origin := c.GetHeader("Origin")
+	if index == -1 || origin == "" {
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+		return
+	}
+
+	policy := policies[index]
+	c.Header("Vary", "Origin")
+	allowOrigin, allowed := policy.allowedOrigin(origin)
+	if !allowed {
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+		return
+	}
+
+	c.Header("Access-Control-Allow-Origin", allowOrigin)
+	if policy.AllowCredentials {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+
+	if c.Request.Method == "OPTIONS" {
+		allowHeaders := defaultCORSAllowHeaders
+		if len(policy.AllowedHeaders) > 0 {
+			allowHeaders = strings.Join(policy.AllowedHeaders, ", ")
+		}
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", allowHeaders)
+		if policy.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", strconv.Itoa(policy.MaxAge))
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+
+	c.Next()
+}
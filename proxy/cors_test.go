@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyManager_CORSDefault(t *testing.T) {
+	proxy := New(&Config{HealthCheckTimeout: 15})
+
+	req := httptest.NewRequest("OPTIONS", "/v1/chat/completions", nil)
+	req.Header.Set("Origin", "https://webui.example.com")
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestProxyManager_CORSPolicies(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		CORS: []CORSPolicy{
+			{
+				Paths:            []string{"/v1/*"},
+				AllowedOrigins:   []string{"https://webui.example.com"},
+				AllowCredentials: true,
+				MaxAge:           600,
+			},
+			{
+				Paths:          []string{"/logs"},
+				AllowedOrigins: []string{"*"},
+			},
+		},
+	})
+
+	request := func(method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		return w
+	}
+
+	w := request("OPTIONS", "/v1/chat/completions", "https://webui.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://webui.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "Content-Type, Authorization", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	w = request("GET", "/v1/models", "https://webui.example.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://webui.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+
+	// other origins get no CORS headers
+	w = request("OPTIONS", "/v1/chat/completions", "https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = request("GET", "/v1/models", "https://evil.example.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = request("OPTIONS", "/logs", "https://any.example.com")
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+	// paths without a policy
+	w = request("OPTIONS", "/api/incidents", "https://webui.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSPolicy_Validate(t *testing.T) {
+	valid := CORSPolicy{Paths: []string{"/v1/*"}, AllowedOrigins: []string{"https://a"}, AllowCredentials: true}
+	assert.NoError(t, valid.validate())
+
+	assert.ErrorContains(t, CORSPolicy{Paths: []string{"/v1/*"}}.validate(), "need allowedOrigins")
+	assert.ErrorContains(t, CORSPolicy{Paths: []string{"/"}, AllowedOrigins: []string{"*"}, AllowCredentials: true}.validate(), "can not be used with allowCredentials")
+	assert.ErrorContains(t, CORSPolicy{Paths: []string{"/"}, AllowedOrigins: []string{"*"}, MaxAge: -1}.validate(), "maxAge")
+}
+
+func TestConfig_CORSSinglePolicy(t *testing.T) {
+	config, err := LoadConfigFromBytes([]byte(`
+cors:
+  allowedOrigins: ["http://192.168.1.10:3000"]
+  allowedHeaders: ["Content-Type", "Authorization", "X-Priority"]
+  allowCredentials: true
+  maxAge: 600
+models: {}
+`))
+	if !assert.NoError(t, err) || !assert.Len(t, config.CORS, 1) {
+		return
+	}
+	assert.Equal(t, []string{"http://192.168.1.10:3000"}, config.CORS[0].AllowedOrigins)
+	assert.True(t, config.CORS[0].matches("/v1/models"))
+	assert.True(t, config.CORS[0].matches("/logs"))
+
+	proxy := New(config)
+	req := httptest.NewRequest("OPTIONS", "/upstream/model1/props", nil)
+	req.Header.Set("Origin", "http://192.168.1.10:3000")
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "http://192.168.1.10:3000", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Content-Type, Authorization, X-Priority", w.Header().Get("Access-Control-Allow-Headers"))
+
+	config, err = LoadConfigFromBytes([]byte(`
+cors:
+  - paths: ["/v1/*"]
+    allowedOrigins: ["*"]
+models: {}
+`))
+	if assert.NoError(t, err) {
+		assert.Len(t, config.CORS, 1)
+	}
+}
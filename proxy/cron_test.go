@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCron_Parse(t *testing.T) {
+	for _, expr := range []string{"* * * *", "60 * * * *", "0 9 * * 1-8", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		_, err := parseCron(expr)
+		assert.Error(t, err, expr)
+	}
+
+	cron, err := parseCron("0 9 * * 1-5")
+	assert.NoError(t, err)
+	// Friday 2026-10-16
+	friday := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	assert.True(t, cron.matches(friday))
+	assert.False(t, cron.matches(friday.Add(time.Minute)))
+	assert.False(t, cron.matches(friday.AddDate(0, 0, 1)))
+	// after Friday 9:00 the next run is on Monday
+	assert.Equal(t, time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC), cron.next(friday))
+
+	// Sunday as 7, steps and lists
+	cron, err = parseCron("*/15 22,23 * * 7")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 18, 22, 0, 0, 0, time.UTC), cron.next(friday))
+	assert.Equal(t, time.Date(2026, 10, 18, 22, 15, 0, 0, time.UTC), cron.next(time.Date(2026, 10, 18, 22, 0, 30, 0, time.UTC)))
+
+	// a day-of-month or a day-of-week
+	cron, err = parseCron("0 0 1 * 0")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), cron.next(friday))
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), cron.next(time.Date(2026, 10, 25, 1, 0, 0, 0, time.UTC)))
+
+	// never
+	cron, err = parseCron("0 0 31 2 *")
+	assert.NoError(t, err)
+	assert.True(t, cron.next(friday).IsZero())
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxDecisions = 200
+
+// kinds of decisions
+const (
+	DecisionSwap     = "swap"
+	DecisionEvict    = "evict"
+	DecisionQueue    = "queue"
+	DecisionFallback = "fallback"
+	DecisionHedge    = "hedge"
+)
+
+// Decision records why llama-swap loaded, stopped or delayed a model
+type Decision struct {
+	Time  time.Time `json:"time"`
+	Kind  string    `json:"kind"`
+	Model string    `json:"model"`
+
+	// the requested model that caused the decision
+	Trigger string `json:"trigger"`
+	Reason  string `json:"reason"`
+
+	// models that were considered and kept: running models left alone by a
+	// swap or eviction, the remaining models of a fallback chain
+	Alternatives []string `json:"alternatives,omitempty"`
+}
+
+func (pm *ProxyManager) recordDecision(decision Decision) {
+	decision.Time = time.Now()
+	fmt.Fprintf(pm.logMonitor, "!!! Decision: %s %s, %s\n", decision.Kind, decision.Model, decision.Reason)
+
+	pm.decisionsMutex.Lock()
+	defer pm.decisionsMutex.Unlock()
+	pm.decisions = append(pm.decisions, decision)
+	if len(pm.decisions) > maxDecisions {
+		pm.decisions = pm.decisions[len(pm.decisions)-maxDecisions:]
+	}
+}
+
+// apiListDecisions returns the recent decisions, oldest first. The model and
+// kind query parameters filter them.
+func (pm *ProxyManager) apiListDecisions(c *gin.Context) {
+	model, kind := c.Query("model"), c.Query("kind")
+
+	pm.decisionsMutex.Lock()
+	defer pm.decisionsMutex.Unlock()
+
+	decisions := []Decision{}
+	for _, decision := range pm.decisions {
+		if (model == "" || decision.Model == model || decision.Trigger == model) && (kind == "" || decision.Kind == kind) {
+			decisions = append(decisions, decision)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"decisions": decisions})
+}
+
+// recordSwapDecisions records a swap to modelIDs and the processes it stopped
+// or put to sleep
+func (pm *ProxyManager) recordSwapDecisions(requestedModel string, modelIDs, stopped, slept, kept []string, fitsVRAM bool) {
+	for _, modelID := range stopped {
+		pm.recordDecision(Decision{
+			Kind:    DecisionEvict,
+			Model:   modelID,
+			Trigger: requestedModel,
+			Reason:  fmt.Sprintf("stopped to swap in %s", strings.Join(modelIDs, ", ")),
+		})
+	}
+	for _, modelID := range slept {
+		pm.recordDecision(Decision{
+			Kind:    DecisionEvict,
+			Model:   modelID,
+			Trigger: requestedModel,
+			Reason:  fmt.Sprintf("put to sleep to swap in %s", strings.Join(modelIDs, ", ")),
+		})
+	}
+
+	reason := "not running"
+	if fitsVRAM {
+		reason = "not running, fits into VRAM next to the running models"
+	} else if evicted := append(slices.Clone(stopped), slept...); len(evicted) > 0 {
+		reason = fmt.Sprintf("not running, evicted %s", strings.Join(evicted, ", "))
+	}
+	for _, modelID := range modelIDs {
+		pm.recordDecision(Decision{
+			Kind:         DecisionSwap,
+			Model:        modelID,
+			Trigger:      requestedModel,
+			Reason:       reason,
+			Alternatives: kept,
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyManager_DecisionsEndpoint(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+			"model2": getTestSimpleResponderConfig("model2"),
+		},
+	})
+	defer proxy.StopProcesses()
+
+	for _, modelName := range []string{"model1", "model2"} {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(fmt.Sprintf(`{"model":"%s"}`, modelName)))
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	listDecisions := func(query string) []Decision {
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/decisions"+query, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Decisions []Decision `json:"decisions"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Decisions
+	}
+
+	decisions := listDecisions("")
+	if assert.Len(t, decisions, 3) {
+		assert.Equal(t, Decision{Kind: DecisionSwap, Model: "model1", Trigger: "model1", Reason: "not running"}, withoutTime(decisions[0]))
+		assert.Equal(t, Decision{Kind: DecisionEvict, Model: "model1", Trigger: "model2", Reason: "stopped to swap in model2"}, withoutTime(decisions[1]))
+		assert.Equal(t, Decision{Kind: DecisionSwap, Model: "model2", Trigger: "model2", Reason: "not running, evicted model1"}, withoutTime(decisions[2]))
+	}
+
+	assert.Len(t, listDecisions("?kind=evict"), 1)
+	assert.Len(t, listDecisions("?model=model1"), 2)
+}
+
+func TestProxyManager_VRAMEvictionDecision(t *testing.T) {
+	config := &Config{
+		HealthCheckTimeout: 15,
+		VRAM:               VRAMConfig{BudgetMB: 20000},
+		Models:             map[string]ModelConfig{},
+	}
+	for _, modelName := range []string{"model1", "model2", "model3"} {
+		modelConfig := getTestSimpleResponderConfig(modelName)
+		modelConfig.VramEstimateMB = 8000
+		config.Models[modelName] = modelConfig
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	for _, modelName := range []string{"model1", "model2", "model3"} {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(fmt.Sprintf(`{"model":"%s"}`, modelName)))
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	var evictions []Decision
+	for _, decision := range proxy.decisions {
+		if decision.Kind == DecisionEvict {
+			evictions = append(evictions, withoutTime(decision))
+		}
+	}
+	assert.Equal(t, []Decision{{
+		Kind:         DecisionEvict,
+		Model:        "model1",
+		Trigger:      "model3",
+		Reason:       "least recently used, stopped to free 8000MB VRAM for model3",
+		Alternatives: []string{"model2"},
+	}}, evictions)
+}
+
+func TestProxyManager_DecisionsAreBounded(t *testing.T) {
+	proxy := New(&Config{HealthCheckTimeout: 15})
+	for i := 0; i < maxDecisions+10; i++ {
+		proxy.recordDecision(Decision{Kind: DecisionSwap, Model: fmt.Sprintf("model%d", i)})
+	}
+	assert.Len(t, proxy.decisions, maxDecisions)
+	assert.Equal(t, "model10", proxy.decisions[0].Model)
+}
+
+func withoutTime(decision Decision) Decision {
+	decision.Time = time.Time{}
+	return decision
+}
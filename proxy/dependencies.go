@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// validateDependencies replaces the names in dependsOn with model IDs and
+// rejects models that depend on themselves
+func (c *Config) validateDependencies() error {
+	for modelName, modelConfig := range c.Models {
+		if len(modelConfig.DependsOn) == 0 {
+			continue
+		}
+
+		resolved := make([]string, 0, len(modelConfig.DependsOn))
+		for _, name := range modelConfig.DependsOn {
+			realName, found := c.RealModelName(name)
+			if !found {
+				return fmt.Errorf("model %s: unknown dependsOn model %s", modelName, name)
+			}
+			resolved = append(resolved, realName)
+		}
+		modelConfig.DependsOn = resolved
+		c.Models[modelName] = modelConfig
+	}
+
+	for modelName := range c.Models {
+		if cycle := c.dependencyCycle(modelName, nil); cycle != nil {
+			return fmt.Errorf("model %s: dependsOn cycle %s", modelName, strings.Join(cycle, " -> "))
+		}
+	}
+	return nil
+}
+
+func (c *Config) dependencyCycle(modelID string, path []string) []string {
+	if slices.Contains(path, modelID) {
+		return append(path, modelID)
+	}
+	path = append(path, modelID)
+	for _, dependency := range c.Models[modelID].DependsOn {
+		if cycle := c.dependencyCycle(dependency, path); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// dependencies returns the IDs of all models the models depend on, directly
+// or through other dependencies
+func (c *Config) dependencies(modelIDs ...string) map[string]bool {
+	result := make(map[string]bool)
+	pending := slices.Clone(modelIDs)
+	for len(pending) > 0 {
+		modelID := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, dependency := range c.Models[modelID].DependsOn {
+			if !result[dependency] {
+				result[dependency] = true
+				pending = append(pending, dependency)
+			}
+		}
+	}
+	return result
+}
+
+// attachDependencies sets the processes started before process, creating
+// those that are not running. The lock must be held.
+func (pm *ProxyManager) attachDependencies(process *Process) {
+	process.dependencies = nil
+	for _, modelID := range process.config.DependsOn {
+		var dependency *Process
+		for _, p := range pm.currentProcesses {
+			if p.ID == modelID {
+				dependency = p
+				break
+			}
+		}
+
+		if dependency == nil {
+			dependency = pm.newProcess(modelID, pm.currentConfig().Models[modelID])
+			pm.currentProcesses[ProcessKeyName("", modelID)] = dependency
+			pm.attachDependencies(dependency)
+		}
+		process.dependencies = append(process.dependencies, dependency)
+	}
+}
+
+// startDependencies starts the dependencies in the order of dependsOn and
+// returns once all are ready
+func (p *Process) startDependencies() error {
+	for _, dependency := range p.dependencies {
+		if err := dependency.start(); err != nil {
+			return fmt.Errorf("dependency %s failed to start: %w", dependency.ID, err)
+		}
+	}
+	return nil
+}
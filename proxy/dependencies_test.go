@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_DependsOn(t *testing.T) {
+	config, err := LoadConfigFromBytes([]byte(`
+models:
+  rpc:
+    cmd: path/to/rpc-server
+    aliases: [rpc-server]
+  embed:
+    cmd: path/to/cmd
+    dependsOn: [rpc-server]
+  chat:
+    cmd: path/to/cmd
+    dependsOn: [embed]
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"rpc"}, config.Models["embed"].DependsOn)
+	assert.Equal(t, map[string]bool{"embed": true, "rpc": true}, config.dependencies("chat"))
+	assert.Empty(t, config.dependencies("rpc"))
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  chat:
+    cmd: path/to/cmd
+    dependsOn: [missing]
+`))
+	assert.ErrorContains(t, err, "unknown dependsOn model missing")
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  a:
+    cmd: path/to/cmd
+    dependsOn: [b]
+  b:
+    cmd: path/to/cmd
+    dependsOn: [a]
+`))
+	assert.ErrorContains(t, err, "dependsOn cycle")
+}
+
+func TestProxyManager_DependsOn(t *testing.T) {
+	chat := getTestSimpleResponderConfig("chat")
+	chat.DependsOn = []string{"embed"}
+	coder := getTestSimpleResponderConfig("coder")
+	coder.DependsOn = []string{"embed"}
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"embed": getTestSimpleResponderConfig("embed"),
+			"chat":  chat,
+			"coder": coder,
+			"other": getTestSimpleResponderConfig("other"),
+		},
+	})
+	defer proxy.StopProcesses()
+
+	request := func(modelName string) {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(fmt.Sprintf(`{"model":"%s"}`, modelName)))
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, modelName, w.Body.String())
+	}
+
+	embedKey := ProcessKeyName("", "embed")
+
+	// the dependency is ready before the model starts
+	request("chat")
+	embed := proxy.currentProcesses[embedKey]
+	if !assert.NotNil(t, embed) {
+		return
+	}
+	assert.Equal(t, StateReady, embed.CurrentState())
+
+	// and keeps running when swapping to another model that needs it
+	request("coder")
+	assert.Len(t, proxy.currentProcesses, 2)
+	assert.Same(t, embed, proxy.currentProcesses[embedKey])
+	assert.Equal(t, StateReady, embed.CurrentState())
+
+	request("other")
+	assert.Len(t, proxy.currentProcesses, 1)
+	assert.Equal(t, StateStopped, embed.CurrentState())
+}
+
+func TestProcess_DependencyFailsToStart(t *testing.T) {
+	dependency := NewProcess("dependency", 15, ModelConfig{Cmd: "/does/not/exist", Proxy: "http://127.0.0.1:1"}, NewLogMonitorWriter(io.Discard))
+	process := NewProcess("model", 15, getTestSimpleResponderConfig("model"), NewLogMonitorWriter(io.Discard))
+	process.dependencies = []*Process{dependency}
+
+	err := process.start()
+	assert.ErrorContains(t, err, "dependency dependency failed to start")
+	assert.Equal(t, StateStopped, process.CurrentState())
+}
@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultProbeArgs = "--version"
+
+// Doctor checks that every model's command can run on this machine and that
+// its proxy port is free. It writes a report to out and returns false when a
+// model will not be able to start.
+func Doctor(config *Config, out io.Writer) bool {
+	modelIDs := make([]string, 0, len(config.Models))
+	for modelID := range config.Models {
+		modelIDs = append(modelIDs, modelID)
+	}
+	sort.Strings(modelIDs)
+
+	healthy := true
+	for _, modelID := range modelIDs {
+		fmt.Fprintf(out, "%s\n", modelID)
+		for _, result := range doctorModel(config.Models[modelID]) {
+			fmt.Fprintf(out, "  [%-4s] %s\n", result.status, result.message)
+			if result.status == "FAIL" {
+				healthy = false
+			}
+		}
+	}
+
+	return healthy
+}
+
+type doctorResult struct {
+	status  string
+	message string
+}
+
+func doctorModel(modelConfig ModelConfig) []doctorResult {
+	var results []doctorResult
+	add := func(status, format string, args ...interface{}) {
+		results = append(results, doctorResult{status, fmt.Sprintf(format, args...)})
+	}
+
+	if modelConfig.Remote.enabled() {
+		add("SKIP", "cmd: served by remote %s", modelConfig.Remote.Host)
+		return results
+	}
+
+	args, err := modelConfig.SanitizedCommand()
+	if err != nil {
+		add("FAIL", "cmd: %v", err)
+		return results
+	}
+
+	binary, err := exec.LookPath(args[0])
+	if err != nil {
+		add("FAIL", "binary: %s not found", args[0])
+		return results
+	}
+	add("OK", "binary: %s", binary)
+
+	probe := strings.TrimSpace(modelConfig.ProbeArgs)
+	if probe == "" {
+		probe = defaultProbeArgs
+	}
+
+	if probe == "none" {
+		add("SKIP", "probe: disabled")
+	} else if probeArgs, err := SanitizeCommand(probe); err != nil {
+		add("FAIL", "probe: invalid probeArgs: %v", err)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, binary, probeArgs...)
+		cmd.Env = modelConfig.Env
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			add("FAIL", "probe: %s %s: %v%s", args[0], probe, err, diagnoseProbeOutput(string(output)))
+		} else {
+			add("OK", "probe: %s", lastLine(string(output)))
+		}
+	}
+
+	if result, ok := checkProxyPort(modelConfig.Proxy); ok {
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// diagnoseProbeOutput explains common reasons for binaries not running
+func diagnoseProbeOutput(output string) string {
+	switch {
+	case strings.Contains(output, "GLIBC_"):
+		return ", binary requires a newer glibc than this system has"
+	case strings.Contains(output, "libcuda") || strings.Contains(output, "libcudart") || strings.Contains(output, "libcublas"):
+		return ", CUDA libraries are not available"
+	case strings.Contains(output, "error while loading shared libraries"):
+		return ", missing shared library: " + lastLine(output)
+	}
+
+	if line := lastLine(output); line != "" {
+		return ": " + line
+	}
+	return ""
+}
+
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// checkProxyPort reports if a local proxy port is already in use
+func checkProxyPort(proxy string) (doctorResult, bool) {
+	if strings.Contains(proxy, portMacro) {
+		return doctorResult{"OK", "port: assigned when the model starts"}, true
+	}
+
+	if path, ok := unixSocketPath(proxy); ok {
+		if unixSocketInUse(path) {
+			return doctorResult{"WARN", fmt.Sprintf("socket: %s is already in use", path)}, true
+		}
+		return doctorResult{"OK", fmt.Sprintf("socket: %s is available", path)}, true
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		return doctorResult{"FAIL", fmt.Sprintf("proxy: invalid url %q", proxy)}, true
+	}
+
+	addr, ok := localProxyAddr(proxyURL)
+	if !ok {
+		return doctorResult{}, false
+	}
+
+	port := proxyURL.Port()
+	if portInUse(addr) {
+		return doctorResult{"WARN", fmt.Sprintf("port: %s is already in use", port)}, true
+	}
+	return doctorResult{"OK", fmt.Sprintf("port: %s is available", port)}, true
+}
+
+// localProxyAddr returns the host:port of proxy URLs on this machine with an
+// explicit port
+func localProxyAddr(proxyURL *url.URL) (string, bool) {
+	switch proxyURL.Hostname() {
+	case "localhost", "127.0.0.1", "::1", "0.0.0.0":
+	default:
+		return "", false
+	}
+
+	if proxyURL.Port() == "" {
+		return "", false
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), proxyURL.Port()), true
+}
+
+func portInUse(addr string) bool {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return true
+	}
+	listener.Close()
+	return false
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoctor_Report(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listener.Close()
+	usedPort := listener.Addr().(*net.TCPAddr).Port
+
+	responder := getTestSimpleResponderConfig("doctor")
+	responder.ProbeArgs = "-h"
+
+	config := &Config{
+		Models: map[string]ModelConfig{
+			"a-ok": responder,
+			"b-missing": {
+				Cmd:   "nonexistent-command --port 9999",
+				Proxy: "http://127.0.0.1:9999",
+			},
+			"c-port-used": {
+				Cmd:       responder.Cmd,
+				Proxy:     fmt.Sprintf("http://127.0.0.1:%d", usedPort),
+				ProbeArgs: "none",
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	assert.False(t, Doctor(config, &out))
+
+	report := out.String()
+	assert.Contains(t, report, "a-ok\n  [OK  ] binary:")
+	assert.Contains(t, report, "b-missing\n  [FAIL] binary: nonexistent-command not found")
+	assert.Contains(t, report, "[SKIP] probe: disabled")
+	assert.Contains(t, report, fmt.Sprintf("[WARN] port: %d is already in use", usedPort))
+}
+
+func TestDoctor_DiagnoseProbeOutput(t *testing.T) {
+	assert.Contains(t, diagnoseProbeOutput("./llama-server: /lib/libc.so.6: version `GLIBC_2.38' not found"), "newer glibc")
+	assert.Contains(t, diagnoseProbeOutput("error while loading shared libraries: libcudart.so.12"), "CUDA")
+	assert.Equal(t, ": unknown flag", diagnoseProbeOutput("usage\nunknown flag\n"))
+}
@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+type EmbeddingBatchConfig struct {
+	// most inputs sent to the upstream in one /v1/embeddings request, larger
+	// requests are split. 0 = no splitting
+	MaxInputs int `yaml:"maxInputs"`
+	// split requests sent at the same time, default 1
+	Parallel int `yaml:"parallel"`
+}
+
+func (e EmbeddingBatchConfig) validate() error {
+	if e.MaxInputs < 0 || e.Parallel < 0 {
+		return fmt.Errorf("embeddingBatch maxInputs and parallel must not be negative")
+	}
+	return nil
+}
+
+// split returns the inputs of an /v1/embeddings request in batches of at
+// most maxInputs, nil when the request does not need splitting
+func (e EmbeddingBatchConfig) split(path string, body map[string]interface{}) [][]interface{} {
+	if e.MaxInputs == 0 || path != "/v1/embeddings" {
+		return nil
+	}
+
+	inputs, ok := body["input"].([]interface{})
+	if !ok || len(inputs) <= e.MaxInputs {
+		return nil
+	}
+	// an array of token ids is a single input
+	if _, isToken := inputs[0].(float64); isToken {
+		return nil
+	}
+
+	var batches [][]interface{}
+	for start := 0; start < len(inputs); start += e.MaxInputs {
+		batches = append(batches, inputs[start:min(start+e.MaxInputs, len(inputs))])
+	}
+	return batches
+}
+
+// bufferedResponse holds the response of one batch
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(statusCode int) {
+	if b.status == 0 {
+		b.status = statusCode
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// writeTo sends the buffered response to w
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	for k, vv := range b.header {
+		for _, v := range vv {
+			w.Header().Add(k, v)
+		}
+	}
+	if b.status == 0 {
+		b.status = http.StatusBadGateway
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
+
+// proxyEmbeddingBatches sends each batch of inputs as its own request and
+// responds with the embeddings of all of them, indexed in the order of the
+// original input. The first failed batch's response is sent instead.
+func (p *Process) proxyEmbeddingBatches(w http.ResponseWriter, r *http.Request, body map[string]interface{}, batches [][]interface{}) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	responses := make([]*bufferedResponse, len(batches))
+	slots := make(chan struct{}, max(p.config.EmbeddingBatch.Parallel, 1))
+	var wg sync.WaitGroup
+
+	// the first batch that failed, the others may have been cancelled
+	var failedOnce sync.Once
+	var failed *bufferedResponse
+
+	for i, batch := range batches {
+		batchBody := make(map[string]interface{}, len(body))
+		for k, v := range body {
+			batchBody[k] = v
+		}
+		batchBody["input"] = batch
+		bodyBytes, err := json.Marshal(batchBody)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error encoding JSON %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+
+		req := r.Clone(ctx)
+		req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		req.ContentLength = int64(len(bodyBytes))
+		req.Header.Set("content-length", strconv.Itoa(len(bodyBytes)))
+
+		responses[i] = &bufferedResponse{header: make(http.Header)}
+		wg.Add(1)
+		go func(response *bufferedResponse) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-slots }()
+
+			p.ProxyRequest(response, req)
+			if response.status != http.StatusOK {
+				failedOnce.Do(func() { failed = response })
+				// the other batches are of no use
+				cancel()
+			}
+		}(responses[i])
+	}
+	wg.Wait()
+
+	if failed != nil {
+		failed.writeTo(w)
+		return
+	}
+	if r.Context().Err() != nil {
+		// the client is gone
+		return
+	}
+
+	merged, err := mergeEmbeddingResponses(responses, len(batches[0]))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(merged)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(merged)
+}
+
+// mergeEmbeddingResponses appends the data of the responses, offsetting each
+// embedding's index by the inputs of the batches before it, and adds up their
+// usage
+func mergeEmbeddingResponses(responses []*bufferedResponse, batchSize int) ([]byte, error) {
+	var merged map[string]interface{}
+	var data []interface{}
+	usage := make(map[string]float64)
+
+	for i, response := range responses {
+		var result map[string]interface{}
+		if err := json.Unmarshal(response.body.Bytes(), &result); err != nil {
+			return nil, fmt.Errorf("invalid embeddings response: %v", err)
+		}
+		if merged == nil {
+			merged = result
+		}
+
+		items, _ := result["data"].([]interface{})
+		for _, item := range items {
+			if embedding, ok := item.(map[string]interface{}); ok {
+				index, _ := embedding["index"].(float64)
+				embedding["index"] = int(index) + i*batchSize
+			}
+			data = append(data, item)
+		}
+
+		counts, _ := result["usage"].(map[string]interface{})
+		for name, count := range counts {
+			if n, ok := count.(float64); ok {
+				usage[name] += n
+			}
+		}
+	}
+
+	merged["data"] = data
+	if len(usage) > 0 {
+		merged["usage"] = usage
+	}
+	return json.Marshal(merged)
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddingBatchConfig_Split(t *testing.T) {
+	config := EmbeddingBatchConfig{MaxInputs: 2}
+	inputs := []interface{}{"a", "b", "c", "d", "e"}
+
+	batches := config.split("/v1/embeddings", map[string]interface{}{"input": inputs})
+	assert.Equal(t, [][]interface{}{{"a", "b"}, {"c", "d"}, {"e"}}, batches)
+
+	assert.Nil(t, config.split("/v1/embeddings", map[string]interface{}{"input": inputs[:2]}))
+	assert.Nil(t, config.split("/v1/embeddings", map[string]interface{}{"input": "a"}))
+	assert.Nil(t, config.split("/v1/chat/completions", map[string]interface{}{"input": inputs}))
+
+	// token ids of a single input
+	tokens := []interface{}{1.0, 2.0, 3.0}
+	assert.Nil(t, config.split("/v1/embeddings", map[string]interface{}{"input": tokens}))
+}
+
+func TestProxyManager_EmbeddingBatch(t *testing.T) {
+	var requests, active, maxActive atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			return
+		}
+		requests.Add(1)
+		n := active.Add(1)
+		defer active.Add(-1)
+		for current := maxActive.Load(); n > current && !maxActive.CompareAndSwap(current, n); current = maxActive.Load() {
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		var body struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		var data []map[string]interface{}
+		for i, input := range body.Input {
+			if input == "fail" {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"error":"out of memory"}`))
+				return
+			}
+			data = append(data, map[string]interface{}{"object": "embedding", "index": i, "embedding": []int{len(input)}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"object": "list",
+			"model":  "embed",
+			"data":   data,
+			"usage":  map[string]int{"prompt_tokens": len(body.Input), "total_tokens": len(body.Input)},
+		})
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("embed")
+	modelConfig.Proxy = upstream.URL
+	modelConfig.EmbeddingBatch = EmbeddingBatchConfig{MaxInputs: 2, Parallel: 2}
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"embed": modelConfig},
+	})
+	defer proxy.StopProcesses()
+
+	inputs := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+	body, _ := json.Marshal(map[string]interface{}{"model": "embed", "input": inputs})
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/embeddings", strings.NewReader(string(body))))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data []struct {
+			Index     int   `json:"index"`
+			Embedding []int `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Data, len(inputs)) {
+		for i, item := range response.Data {
+			assert.Equal(t, i, item.Index)
+			assert.Equal(t, []int{len(inputs[i])}, item.Embedding, fmt.Sprintf("embedding %d", i))
+		}
+	}
+	assert.Equal(t, 5, response.Usage.PromptTokens)
+	assert.Equal(t, int32(3), requests.Load())
+	assert.Equal(t, int32(2), maxActive.Load())
+
+	// a failed batch fails the request
+	body, _ = json.Marshal(map[string]interface{}{"model": "embed", "input": []string{"a", "b", "fail"}})
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/embeddings", strings.NewReader(string(body))))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "out of memory")
+}
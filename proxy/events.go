@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// events streamed by /api/events in addition to model.preloaded and
+// process.recycled
+const (
+	EventProcessState   = "process.state"
+	EventRequestTokens  = "request.tokens"
+	EventConfigReloaded = "config.reloaded"
+)
+
+// Event is a JSON object sent by /api/events
+type Event struct {
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time"`
+	Model string    `json:"model,omitempty"`
+
+	// process.state
+	State    ProcessState `json:"state,omitempty"`
+	Previous ProcessState `json:"previous,omitempty"`
+
+	// request.tokens
+	PromptTokens     int     `json:"promptTokens,omitempty"`
+	CompletionTokens int     `json:"completionTokens,omitempty"`
+	DurationMs       int64   `json:"durationMs,omitempty"`
+	TokensPerSecond  float64 `json:"tokensPerSecond,omitempty"`
+	// the client disconnected from the stream, the tokens are estimated
+	ClientDisconnected bool `json:"clientDisconnected,omitempty"`
+}
+
+// eventBus sends events to the /api/events clients
+type eventBus struct {
+	mu      sync.RWMutex
+	clients map[chan Event]bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{clients: make(map[chan Event]bool)}
+}
+
+func (b *eventBus) Subscribe() chan Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, 100)
+	b.clients[ch] = true
+	return ch
+}
+
+func (b *eventBus) Unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.clients, ch)
+	close(ch)
+}
+
+func (b *eventBus) hasClients() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.clients) > 0
+}
+
+// publish never blocks, events are dropped for clients that fall behind
+func (b *eventBus) publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for client := range b.clients {
+		select {
+		case client <- event:
+		default:
+		}
+	}
+}
+
+// publishTokens publishes the token usage of the request once it is handled.
+// The response is only captured while /api/events has clients.
+func (pm *ProxyManager) publishTokens(c *gin.Context, model string) func() {
+	if !pm.events.hasClients() {
+		return func() {}
+	}
+
+	start := time.Now()
+	tail := &tailCaptureWriter{ResponseWriter: c.Writer}
+	c.Writer = tail
+	return func() {
+		usage, disconnected := requestUsage(c, tail)
+		if usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+			return
+		}
+
+		if processModel := c.GetString(ctxKeyModel); processModel != "" {
+			model = processModel
+		}
+		duration := time.Since(start)
+		event := Event{
+			Type:             EventRequestTokens,
+			Model:            model,
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			DurationMs:       duration.Milliseconds(),
+
+			ClientDisconnected: disconnected,
+		}
+		if seconds := duration.Seconds(); seconds > 0 {
+			event.TokensPerSecond = float64(usage.CompletionTokens) / seconds
+		}
+		pm.events.publish(event)
+	}
+}
+
+// streamEventsHandler streams events as SSE. ?types= and ?model= take comma
+// separated lists to only stream some of them.
+func (pm *ProxyManager) streamEventsHandler(c *gin.Context) {
+	var types, models []string
+	if value := c.Query("types"); value != "" {
+		types = strings.Split(value, ",")
+	}
+	if value := c.Query("model"); value != "" {
+		models = strings.Split(value, ",")
+	}
+
+	// before the headers are sent, a client can act once it has them
+	ch := pm.events.Subscribe()
+	defer pm.events.Unsubscribe(ch)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	notify := c.Request.Context().Done()
+	for {
+		select {
+		case event := <-ch:
+			if len(types) > 0 && !slices.Contains(types, event.Type) {
+				continue
+			}
+			if len(models) > 0 && event.Model != "" && !slices.Contains(models, event.Model) {
+				continue
+			}
+			c.SSEvent(event.Type, event)
+			c.Writer.Flush()
+		case <-notify:
+			return
+		}
+	}
+}
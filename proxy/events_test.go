@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := newEventBus()
+	assert.False(t, bus.hasClients())
+
+	ch := bus.Subscribe()
+	assert.True(t, bus.hasClients())
+	bus.publish(Event{Type: EventConfigReloaded})
+
+	event := <-ch
+	assert.Equal(t, EventConfigReloaded, event.Type)
+	assert.False(t, event.Time.IsZero())
+
+	bus.Unsubscribe(ch)
+	assert.False(t, bus.hasClients())
+}
+
+func TestProxyManager_StreamEvents(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":34}}`))
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	model1 := getTestSimpleResponderConfig("model1")
+	model1.Proxy = upstream.URL
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": model1,
+			"model2": getTestSimpleResponderConfig("model2"),
+		},
+	})
+	defer proxy.StopProcesses()
+
+	server := httptest.NewServer(http.HandlerFunc(proxy.HandlerFunc))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/events?model=model1&types=process.state,request.tokens")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan Event)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			data, found := strings.CutPrefix(scanner.Text(), "data:")
+			if !found {
+				continue
+			}
+			var event Event
+			if json.Unmarshal([]byte(data), &event) == nil {
+				events <- event
+			}
+		}
+	}()
+	next := func() Event {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+			return Event{}
+		}
+	}
+
+	for _, model := range []string{"model1", "model2"} {
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"`+model+`"}`)))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	event := next()
+	assert.Equal(t, EventProcessState, event.Type)
+	assert.Equal(t, "model1", event.Model)
+	assert.Equal(t, StateStopped, event.Previous)
+	assert.Equal(t, StateReady, event.State)
+
+	event = next()
+	assert.Equal(t, EventRequestTokens, event.Type)
+	assert.Equal(t, 12, event.PromptTokens)
+	assert.Equal(t, 34, event.CompletionTokens)
+
+	// model2 events are filtered out
+	event = next()
+	assert.Equal(t, EventProcessState, event.Type)
+	assert.Equal(t, "model1", event.Model)
+	assert.Equal(t, StateStopped, event.State)
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// eviction policies, the order running models are stopped in when there is
+// not enough VRAM for the models being loaded
+const (
+	// least recently used first
+	evictLRU = "lru"
+	// lowest priority first, then least recently used
+	evictPriority = "priority"
+	// closest to being unloaded by their ttl first, models without a ttl
+	// last
+	evictTTL = "ttl"
+)
+
+func validateEvictionPolicy(policy string) error {
+	switch policy {
+	case evictLRU, evictPriority, evictTTL:
+		return nil
+	}
+	return fmt.Errorf("unknown eviction policy %q, expected lru, priority or ttl", policy)
+}
+
+// sortForEviction orders processes in the order policy evicts them, standby
+// models last
+func sortForEviction(processes []*Process, policy string) {
+	sort.SliceStable(processes, func(i, j int) bool {
+		pi, pj := processes[i], processes[j]
+		if pi.config.Standby != pj.config.Standby {
+			return pj.config.Standby
+		}
+
+		switch policy {
+		case evictPriority:
+			if pi.config.Priority != pj.config.Priority {
+				return pi.config.Priority < pj.config.Priority
+			}
+		case evictTTL:
+			ei, ej := ttlExpiry(pi), ttlExpiry(pj)
+			if !ei.Equal(ej) {
+				// the zero time of a model without a ttl is last
+				return !ei.IsZero() && (ej.IsZero() || ei.Before(ej))
+			}
+		}
+		return pi.LastRequestHandled().Before(pj.LastRequestHandled())
+	})
+}
+
+// ttlExpiry is when a process is unloaded by its ttl, zero without a ttl
+func ttlExpiry(p *Process) time.Time {
+	ttl, unloads := p.ttl()
+	if !unloads {
+		return time.Time{}
+	}
+	return p.LastRequestHandled().Add(ttl)
+}
+
+// evictionReason describes why a process is evicted by policy
+func evictionReason(policy string, p *Process) string {
+	switch policy {
+	case evictPriority:
+		return fmt.Sprintf("lowest priority (%d)", p.config.Priority)
+	case evictTTL:
+		if expiry := ttlExpiry(p); !expiry.IsZero() {
+			return fmt.Sprintf("closest to its ttl (%s)", expiry.Format(time.RFC3339))
+		}
+		return "least recently used without a ttl"
+	}
+	return "least recently used"
+}
+
+// stopMembersOutsideProfile stops members of a profile running with another
+// key, they are started again with the profile's key. The lock must be held.
+func (pm *ProxyManager) stopMembersOutsideProfile(profileName string, modelIDs []string) []string {
+	stopped := []string{}
+	for key, process := range pm.currentProcesses {
+		if !slices.Contains(modelIDs, process.ID) || key == ProcessKeyName(profileName, process.ID) {
+			continue
+		}
+		process.Stop()
+		delete(pm.currentProcesses, key)
+		stopped = append(stopped, process.ID)
+	}
+	sort.Strings(stopped)
+	return stopped
+}
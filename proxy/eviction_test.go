@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_ProfileEvictionPolicy(t *testing.T) {
+	_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+profiles:
+  p1: [model1]
+profileEvictionPolicy:
+  p1: newest
+`))
+	assert.ErrorContains(t, err, `profileEvictionPolicy p1: unknown eviction policy "newest"`)
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+profileEvictionPolicy:
+  p1: lru
+`))
+	assert.ErrorContains(t, err, "profileEvictionPolicy: unknown profile p1")
+}
+
+func TestEviction_SortForEviction(t *testing.T) {
+	newTestProcess := func(id string, priority, ttl int, lastRequest time.Time, standby bool) *Process {
+		process := NewProcess(id, 15, ModelConfig{Priority: priority, UnloadAfter: ttl, Standby: standby}, NewLogMonitorWriter(io.Discard))
+		process.setLastRequestHandled(lastRequest)
+		return process
+	}
+	now := time.Now()
+	processes := []*Process{
+		newTestProcess("standby", 0, 0, now.Add(-time.Hour), true),
+		newTestProcess("important", 10, 0, now.Add(-30*time.Minute), false),
+		newTestProcess("short-ttl", 5, 60, now.Add(-time.Minute), false),
+		newTestProcess("old", 5, 0, now.Add(-20*time.Minute), false),
+	}
+	order := func(policy string) []string {
+		sortForEviction(processes, policy)
+		ids := []string{}
+		for _, p := range processes {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"important", "old", "short-ttl", "standby"}, order(evictLRU))
+	assert.Equal(t, []string{"old", "short-ttl", "important", "standby"}, order(evictPriority))
+	assert.Equal(t, []string{"short-ttl", "important", "old", "standby"}, order(evictTTL))
+}
+
+func TestProxyManager_ProfileEvictionPolicy(t *testing.T) {
+	config := &Config{
+		HealthCheckTimeout:    15,
+		VRAM:                  VRAMConfig{BudgetMB: 20000},
+		Models:                map[string]ModelConfig{},
+		Profiles:              map[string][]string{"heavy": {"big1", "big2"}},
+		ProfileEvictionPolicy: map[string]string{"heavy": evictPriority},
+	}
+	for name, settings := range map[string][2]int{"embed": {4000, 10}, "chat": {8000, 0}, "big1": {8000, 0}, "big2": {8000, 0}} {
+		modelConfig := getTestSimpleResponderConfig(name)
+		modelConfig.VramEstimateMB = settings[0]
+		modelConfig.Priority = settings[1]
+		config.Models[name] = modelConfig
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	// embed is used first, lru would evict it
+	for _, model := range []string{"embed", "chat", "heavy:big1"} {
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(fmt.Sprintf(`{"model":"%s"}`, model))))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	proxy.Lock()
+	running := []string{}
+	for key := range proxy.currentProcesses {
+		running = append(running, key)
+	}
+	proxy.Unlock()
+	sort.Strings(running)
+	assert.Equal(t, []string{ProcessKeyName("", "embed"), ProcessKeyName("heavy", "big1"), ProcessKeyName("heavy", "big2")}, running)
+
+	var evictions []Decision
+	for _, decision := range proxy.decisions {
+		if decision.Kind == DecisionEvict {
+			evictions = append(evictions, withoutTime(decision))
+		}
+	}
+	assert.Equal(t, []Decision{{
+		Kind:         DecisionEvict,
+		Model:        "chat",
+		Trigger:      "heavy:big1",
+		Reason:       "lowest priority (0), stopped to free 8000MB VRAM for big1, big2",
+		Alternatives: []string{"embed"},
+	}}, evictions)
+
+	groups := buildGroups(proxy.currentConfig(), nil)
+	assert.False(t, groups[0].Exclusive)
+	assert.Equal(t, evictPriority, groups[0].EvictionPolicy)
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const fallbackModelHeader = "X-LlamaSwap-Model"
+
+// fallbackResponseWriter holds back 5xx responses so the request can be
+// retried with a fallback model. Other responses are passed through.
+type fallbackResponseWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	failed      bool
+	wroteHeader bool
+}
+
+func newFallbackResponseWriter(w http.ResponseWriter) *fallbackResponseWriter {
+	return &fallbackResponseWriter{w: w, header: make(http.Header)}
+}
+
+func (f *fallbackResponseWriter) Header() http.Header {
+	return f.header
+}
+
+func (f *fallbackResponseWriter) WriteHeader(statusCode int) {
+	if f.wroteHeader {
+		return
+	}
+	f.wroteHeader = true
+
+	if statusCode >= http.StatusInternalServerError {
+		f.failed = true
+		return
+	}
+
+	for k, vv := range f.header {
+		for _, v := range vv {
+			f.w.Header().Add(k, v)
+		}
+	}
+	f.w.WriteHeader(statusCode)
+}
+
+func (f *fallbackResponseWriter) Write(b []byte) (int, error) {
+	if !f.wroteHeader {
+		f.WriteHeader(http.StatusOK)
+	}
+	if f.failed {
+		return len(b), nil
+	}
+	return f.w.Write(b)
+}
+
+func (f *fallbackResponseWriter) Flush() {
+	if flusher, ok := f.w.(http.Flusher); ok && !f.failed {
+		flusher.Flush()
+	}
+}
+
+// filterRequestBody applies the request filters, limits and backend params
+// of process to the request body, changed is true for a copy
+func filterRequestBody(c *gin.Context, process *Process, requestBody map[string]interface{}) (body map[string]interface{}, changed bool) {
+	// in filters.go, limits.go and backends.go
+	body, filtered := process.config.Filters.apply(requestBody)
+	if filtered {
+		traceRequest(c, "applied the request filters of %s", process.ID)
+	}
+	body, limited := process.config.Limits.apply(c.Request.URL.Path, body)
+	if limited {
+		traceRequest(c, "capped the max tokens of the request to the limits of %s", process.ID)
+	}
+	body, dropped := process.config.filterParams(body)
+	if len(dropped) > 0 {
+		traceRequest(c, "dropped params not supported by the %s backend of %s: %s", process.config.Backend, process.ID, strings.Join(dropped, ", "))
+	}
+	return body, filtered || limited || len(dropped) > 0
+}
+
+// proxyWithFallback sends the request to model and, when it fails to start or
+// responds with a 5xx error, to each of its fallback models in order
+func (pm *ProxyManager) proxyWithFallback(c *gin.Context, model string, requestBody map[string]interface{}, bodyBytes []byte) {
+	chain := []string{model}
+	if modelConfig, _, found := pm.currentConfig().FindConfig(model); found {
+		chain = append(chain, modelConfig.Fallback...)
+	}
+
+	if len(chain) > 1 {
+		traceRequest(c, "fallback chain: %s", strings.Join(chain, ", "))
+	}
+
+	for i, candidate := range chain {
+		last := i == len(chain)-1
+
+		swapStart := time.Now()
+		process, err := pm.swapModel(candidate)
+		if err != nil {
+			traceRequest(c, "unable to swap to %s: %v", candidate, err)
+			if last {
+				pm.sendSwapError(c, candidate, err)
+				return
+			}
+			pm.recordDecision(Decision{
+				Kind:         DecisionFallback,
+				Model:        chain[i+1],
+				Trigger:      model,
+				Reason:       fmt.Sprintf("unable to swap to %s: %v", candidate, err),
+				Alternatives: chain[i+2:],
+			})
+			continue
+		}
+
+		applyRequestTTL(c, process)
+
+		body, changed := filterRequestBody(c, process, requestBody)
+		if i > 0 {
+			// body can be the caller's requestBody
+			body = maps.Clone(body)
+			body["model"] = candidate
+		}
+		if i > 0 || changed {
+			if bodyBytes, err = json.Marshal(body); err != nil {
+				pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error encoding JSON %s", err.Error()))
+				return
+			}
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+		// dechunk it as we already have all the body bytes see issue #11
+		c.Request.Header.Del("transfer-encoding")
+		c.Request.Header.Set("content-length", strconv.Itoa(len(bodyBytes)))
+
+		if len(chain) > 1 {
+			c.Header(fallbackModelHeader, process.ID)
+		}
+		setAccessLogKeys(c, candidate, process)
+		traceRequest(c, "resolved %s to model %s in %v, state %s", candidate, process.ID, time.Since(swapStart), process.CurrentState())
+
+		// a hedged request does not wait for the model to start
+		hedged := process.config.Hedge.Model != ""
+		if interval := pm.currentConfig().LoadKeepAliveInterval; interval > 0 && !hedged {
+			// SSE comments commit the response, fallbacks need it uncommitted
+			stream, _ := requestBody["stream"].(bool)
+			startWithKeepAlive(c, process, time.Duration(interval)*time.Second, stream && last)
+		}
+
+		// in embeddings.go
+		proxyRequest := process.ProxyRequest
+		if batches := process.config.EmbeddingBatch.split(c.Request.URL.Path, body); batches != nil {
+			traceRequest(c, "split the embeddings input into %d requests to %s", len(batches), process.ID)
+			proxyRequest = func(w http.ResponseWriter, r *http.Request) {
+				process.proxyEmbeddingBatches(w, r, body, batches)
+			}
+		}
+
+		// in hedge.go
+		if hedged {
+			proxyRequest = pm.hedgedProxyRequest(c, process, requestBody, proxyRequest)
+		}
+
+		upstreamStart := time.Now()
+		if last {
+			proxyRequest(c.Writer, c.Request)
+			traceRequest(c, "upstream %s responded with status %d after %v", process.ID, c.Writer.Status(), time.Since(upstreamStart))
+			return
+		}
+
+		fw := newFallbackResponseWriter(c.Writer)
+		proxyRequest(fw, c.Request)
+		if !fw.failed {
+			traceRequest(c, "upstream %s responded with status %d after %v", process.ID, c.Writer.Status(), time.Since(upstreamStart))
+			return
+		}
+		traceRequest(c, "upstream %s failed after %v", process.ID, time.Since(upstreamStart))
+		pm.recordDecision(Decision{
+			Kind:         DecisionFallback,
+			Model:        chain[i+1],
+			Trigger:      model,
+			Reason:       fmt.Sprintf("upstream %s failed", candidate),
+			Alternatives: chain[i+2:],
+		})
+	}
+}
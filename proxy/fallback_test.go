@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFallbackResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	fw := newFallbackResponseWriter(rec)
+	http.Error(fw, "upstream exploded", http.StatusBadGateway)
+	assert.True(t, fw.failed)
+	assert.Empty(t, rec.Body.String())
+	assert.Empty(t, rec.Header().Get("Content-Type"))
+
+	rec = httptest.NewRecorder()
+	fw = newFallbackResponseWriter(rec)
+	fw.Header().Set("Content-Type", "text/plain")
+	fw.Write([]byte("ok"))
+	assert.False(t, fw.failed)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "ok", rec.Body.String())
+}
+
+func TestProxyManager_FallbackWhenStartFails(t *testing.T) {
+	config := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"broken": {
+				Cmd:      "nonexistent-command",
+				Proxy:    "http://127.0.0.1:9913",
+				Fallback: []string{"model1"},
+			},
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"broken"}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "model1", w.Body.String())
+	assert.Equal(t, "model1", w.Header().Get(fallbackModelHeader))
+
+	// the deferred handlers of the request still see the requested model
+	w = httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"broken"}`))
+	requestBody := map[string]interface{}{"model": "broken"}
+	proxy.proxyWithFallback(c, "broken", requestBody, []byte(`{"model":"broken"}`))
+	assert.Equal(t, "model1", w.Body.String())
+	assert.Equal(t, map[string]interface{}{"model": "broken"}, requestBody)
+}
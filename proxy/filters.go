@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"fmt"
+	"maps"
+)
+
+// ModelFilters change request bodies before they are sent to the model
+type ModelFilters struct {
+	// fields renamed before the other filters are applied
+	RenameParams map[string]string `yaml:"renameParams"`
+
+	// fields set to these values, replacing those sent by the client
+	SetParams map[string]interface{} `yaml:"setParams"`
+
+	RewriteSystemPrompt SystemPromptConfig `yaml:"rewriteSystemPrompt"`
+}
+
+type SystemPromptConfig struct {
+	// prepend (default) adds content before the client's system message,
+	// override replaces it
+	Mode    string `yaml:"mode"`
+	Content string `yaml:"content"`
+}
+
+func (f ModelFilters) validate() error {
+	switch f.RewriteSystemPrompt.Mode {
+	case "", "prepend", "override":
+	default:
+		return fmt.Errorf("unknown rewriteSystemPrompt mode %s, use prepend or override", f.RewriteSystemPrompt.Mode)
+	}
+	for from, to := range f.RenameParams {
+		if from == "model" || to == "model" {
+			return fmt.Errorf("renameParams can not rename model")
+		}
+	}
+	if _, found := f.SetParams["model"]; found {
+		return fmt.Errorf("setParams can not set model")
+	}
+	return nil
+}
+
+// apply returns the filtered request body, a copy when it was changed
+func (f ModelFilters) apply(requestBody map[string]interface{}) (map[string]interface{}, bool) {
+	if len(f.RenameParams) == 0 && len(f.SetParams) == 0 && f.RewriteSystemPrompt.Content == "" {
+		return requestBody, false
+	}
+
+	body := maps.Clone(requestBody)
+	for from, to := range f.RenameParams {
+		if value, found := body[from]; found {
+			delete(body, from)
+			body[to] = value
+		}
+	}
+
+	maps.Copy(body, f.SetParams)
+
+	if f.RewriteSystemPrompt.Content != "" {
+		if messages, ok := body["messages"].([]interface{}); ok {
+			body["messages"] = f.RewriteSystemPrompt.rewrite(messages)
+		}
+	}
+	return body, true
+}
+
+// rewrite changes the first system message or adds one
+func (s SystemPromptConfig) rewrite(messages []interface{}) []interface{} {
+	for i, message := range messages {
+		m, ok := message.(map[string]interface{})
+		if !ok || m["role"] != "system" {
+			continue
+		}
+
+		content := s.Content
+		if existing, ok := m["content"].(string); ok && s.Mode != "override" {
+			content = s.Content + "\n\n" + existing
+		}
+
+		rewritten := maps.Clone(m)
+		rewritten["content"] = content
+		result := append([]interface{}{}, messages...)
+		result[i] = rewritten
+		return result
+	}
+
+	system := map[string]interface{}{"role": "system", "content": s.Content}
+	return append([]interface{}{system}, messages...)
+}
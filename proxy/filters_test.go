@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelFilters_Apply(t *testing.T) {
+	request := map[string]interface{}{
+		"model":       "m",
+		"temperature": 1.5,
+		"max_tokens":  100,
+		"messages": []interface{}{
+			map[string]interface{}{"role": "system", "content": "Be brief."},
+			map[string]interface{}{"role": "user", "content": "hi"},
+		},
+	}
+
+	body, changed := ModelFilters{}.apply(request)
+	assert.False(t, changed)
+	assert.Equal(t, request, body)
+
+	filters := ModelFilters{
+		RenameParams:        map[string]string{"max_tokens": "n_predict"},
+		SetParams:           map[string]interface{}{"temperature": 0.2, "top_p": 0.9},
+		RewriteSystemPrompt: SystemPromptConfig{Content: "You are a coding assistant."},
+	}
+	body, changed = filters.apply(request)
+	assert.True(t, changed)
+	assert.Equal(t, map[string]interface{}{
+		"model":       "m",
+		"temperature": 0.2,
+		"top_p":       0.9,
+		"n_predict":   100,
+		"messages": []interface{}{
+			map[string]interface{}{"role": "system", "content": "You are a coding assistant.\n\nBe brief."},
+			map[string]interface{}{"role": "user", "content": "hi"},
+		},
+	}, body)
+
+	// the client's request is not changed
+	assert.Equal(t, 1.5, request["temperature"])
+	assert.Equal(t, "Be brief.", request["messages"].([]interface{})[0].(map[string]interface{})["content"])
+
+	filters = ModelFilters{RewriteSystemPrompt: SystemPromptConfig{Mode: "override", Content: "Only answer in French."}}
+	body, _ = filters.apply(request)
+	assert.Equal(t, "Only answer in French.", body["messages"].([]interface{})[0].(map[string]interface{})["content"])
+
+	// a system message is added when there is none
+	body, _ = filters.apply(map[string]interface{}{
+		"messages": []interface{}{map[string]interface{}{"role": "user", "content": "hi"}},
+	})
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"role": "system", "content": "Only answer in French."},
+		map[string]interface{}{"role": "user", "content": "hi"},
+	}, body["messages"])
+}
+
+func TestModelFilters_Validate(t *testing.T) {
+	assert.NoError(t, ModelFilters{RewriteSystemPrompt: SystemPromptConfig{Mode: "override"}}.validate())
+	assert.ErrorContains(t, ModelFilters{RewriteSystemPrompt: SystemPromptConfig{Mode: "append"}}.validate(), "unknown rewriteSystemPrompt mode")
+	assert.ErrorContains(t, ModelFilters{RenameParams: map[string]string{"model": "x"}}.validate(), "can not rename model")
+	assert.ErrorContains(t, ModelFilters{SetParams: map[string]interface{}{"model": "x"}}.validate(), "can not set model")
+}
+
+func TestProxyManager_ModelFilters(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Proxy = upstream.URL
+	modelConfig.Filters = ModelFilters{SetParams: map[string]interface{}{"temperature": 0.2}}
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+	})
+	defer proxy.StopProcesses()
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1","temperature":1.5}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"model":"model1","temperature":0.2}`, w.Body.String())
+}
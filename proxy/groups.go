@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+type groupMember struct {
+	Model string       `json:"model"`
+	State ProcessState `json:"state"`
+	// the model name to request it in the group, <profile>:<model>
+	Request string `json:"request"`
+}
+
+// group is a profile, its members are loaded together
+type group struct {
+	Name string `json:"name"`
+	// loading the group stops the models that are not members, except
+	// standby and pinned ones
+	Exclusive bool `json:"exclusive"`
+	// with a profileEvictionPolicy only the models needed to make room are
+	// stopped, the group is not exclusive
+	EvictionPolicy string `json:"evictionPolicy,omitempty"`
+	// all members are ready
+	Active  bool          `json:"active"`
+	Members []groupMember `json:"members"`
+}
+
+// apiListGroups describes the profiles and the states of their members for
+// GET /api/groups
+func (pm *ProxyManager) apiListGroups(c *gin.Context) {
+	pm.Lock()
+	config := pm.currentConfig()
+	states := make(map[string]ProcessState)
+	for key, process := range pm.currentProcesses {
+		states[key] = process.stateNoWait()
+	}
+	pm.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{"groups": buildGroups(config, states)})
+}
+
+// buildGroups lists the profiles of config, states are keyed by
+// ProcessKeyName
+func buildGroups(config *Config, states map[string]ProcessState) []group {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups := make([]group, 0, len(names))
+	for _, name := range names {
+		policy := config.ProfileEvictionPolicy[name]
+		g := group{Name: name, Exclusive: policy == "", EvictionPolicy: policy, Active: true, Members: []groupMember{}}
+		for _, member := range config.Profiles[name] {
+			realName, found := config.RealModelName(member)
+			if !found {
+				continue
+			}
+			state, found := states[ProcessKeyName(name, realName)]
+			if !found {
+				state = StateStopped
+			}
+			g.Active = g.Active && state == StateReady
+			g.Members = append(g.Members, groupMember{
+				Model:   realName,
+				State:   state,
+				Request: name + PROFILE_SPLIT_CHAR + realName,
+			})
+		}
+		g.Active = g.Active && len(g.Members) > 0
+		groups = append(groups, g)
+	}
+	return groups
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildGroups(t *testing.T) {
+	config, err := LoadConfigFromBytes([]byte(`
+models:
+  chat:
+    cmd: path/to/cmd
+    aliases: [gpt]
+  coder:
+    cmd: path/to/cmd
+profiles:
+  coding: [gpt, coder]
+  solo: [coder]
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	groups := buildGroups(config, map[string]ProcessState{
+		ProcessKeyName("coding", "chat"): StateReady,
+		ProcessKeyName("solo", "coder"):  StateReady,
+	})
+	assert.Equal(t, []group{
+		{Name: "coding", Exclusive: true, Members: []groupMember{
+			{Model: "chat", State: StateReady, Request: "coding:chat"},
+			{Model: "coder", State: StateStopped, Request: "coding:coder"},
+		}},
+		{Name: "solo", Exclusive: true, Active: true, Members: []groupMember{
+			{Model: "coder", State: StateReady, Request: "solo:coder"},
+		}},
+	}, groups)
+}
+
+func TestProxyManager_ListGroups(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+			"model2": getTestSimpleResponderConfig("model2"),
+		},
+		Profiles: map[string][]string{
+			"both": {"model1", "model2"},
+		},
+	})
+	defer proxy.StopProcesses()
+
+	process, err := proxy.swapModel("both:model1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, process.start())
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/groups", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Groups []group `json:"groups"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Groups, 1) && assert.Len(t, response.Groups[0].Members, 2) {
+		assert.Equal(t, StateReady, response.Groups[0].Members[0].State)
+		assert.Equal(t, "both:model1", response.Groups[0].Members[0].Request)
+		assert.False(t, response.Groups[0].Active, "model2 is started by its first request")
+	}
+}
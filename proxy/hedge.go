@@ -0,0 +1,232 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// default wait for the first byte of the model before the request is also
+// sent to the hedge model
+const defaultHedgeAfterMs = 2000
+
+var errHedgeLost = errors.New("the other model of the hedged request responded first")
+
+// HedgeConfig sends a request also to a warm backup model when the model has
+// not responded within afterMs, e.g. while it is being loaded. The first
+// response is used and the other request is cancelled.
+type HedgeConfig struct {
+	Model   string `yaml:"model"`
+	AfterMs int    `yaml:"afterMs"`
+}
+
+func (c *Config) validateHedge(modelName string, h HedgeConfig) error {
+	if h.AfterMs < 0 {
+		return fmt.Errorf("hedge afterMs must not be negative")
+	}
+	if h.Model == "" {
+		return nil
+	}
+	backup, found := c.RealModelName(h.Model)
+	if !found {
+		return fmt.Errorf("unknown hedge model %s", h.Model)
+	}
+	if backup == modelName {
+		return fmt.Errorf("hedge model can not be the model itself")
+	}
+	// swapping the backup model in would stop the model
+	if !c.Models[backup].Standby {
+		return fmt.Errorf("hedge model %s must be a standby model", h.Model)
+	}
+	return nil
+}
+
+func (h HedgeConfig) after() time.Duration {
+	if h.AfterMs == 0 {
+		return defaultHedgeAfterMs * time.Millisecond
+	}
+	return time.Duration(h.AfterMs) * time.Millisecond
+}
+
+// hedgeRace passes the response of the first request to write to the client
+// and cancels the others
+type hedgeRace struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	winner  *hedgeWriter
+	writers []*hedgeWriter
+}
+
+type hedgeWriter struct {
+	race        *hedgeRace
+	process     *Process
+	header      http.Header
+	cancel      context.CancelFunc
+	wroteHeader bool
+}
+
+func (r *hedgeRace) add(process *Process, cancel context.CancelFunc) *hedgeWriter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	writer := &hedgeWriter{race: r, process: process, header: make(http.Header), cancel: cancel}
+	r.writers = append(r.writers, writer)
+	return writer
+}
+
+func (r *hedgeRace) currentWinner() *hedgeWriter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.winner
+}
+
+// claim is true for the first writer, the other requests are cancelled
+func (h *hedgeWriter) claim() bool {
+	h.race.mu.Lock()
+	defer h.race.mu.Unlock()
+
+	if h.race.winner == nil {
+		h.race.winner = h
+		for _, other := range h.race.writers {
+			if other != h {
+				other.cancel()
+			}
+		}
+	}
+	return h.race.winner == h
+}
+
+func (h *hedgeWriter) Header() http.Header {
+	return h.header
+}
+
+func (h *hedgeWriter) WriteHeader(statusCode int) {
+	if h.wroteHeader || !h.claim() {
+		return
+	}
+	h.wroteHeader = true
+
+	for k, vv := range h.header {
+		for _, v := range vv {
+			h.race.w.Header().Add(k, v)
+		}
+	}
+	h.race.w.Header().Set(fallbackModelHeader, h.process.ID)
+	h.race.w.WriteHeader(statusCode)
+}
+
+func (h *hedgeWriter) Write(b []byte) (int, error) {
+	if !h.claim() {
+		return 0, errHedgeLost
+	}
+	if !h.wroteHeader {
+		h.WriteHeader(http.StatusOK)
+	}
+	return h.race.w.Write(b)
+}
+
+func (h *hedgeWriter) Flush() {
+	if flusher, ok := h.race.w.(http.Flusher); ok && h.race.currentWinner() == h {
+		flusher.Flush()
+	}
+}
+
+// readyProcess returns a ready process of modelID, nil when it is not
+// running
+func (pm *ProxyManager) readyProcess(modelID string) *Process {
+	pm.Lock()
+	defer pm.Unlock()
+	for _, process := range pm.currentProcesses {
+		if process.ID == modelID && process.isReady() {
+			return process
+		}
+	}
+	return nil
+}
+
+// hedgedProxyRequest wraps proxyRequest of process to also send the request
+// to the process's hedge model when process has not responded in time. The
+// hedge model is only used when it is ready. requestBody is the body sent by
+// the client, before the filters of process were applied.
+func (pm *ProxyManager) hedgedProxyRequest(c *gin.Context, process *Process, requestBody map[string]interface{}, proxyRequest func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	hedge := process.config.Hedge
+	return func(w http.ResponseWriter, r *http.Request) {
+		bodyBytes, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("unable to read request body: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		race := &hedgeRace{w: w}
+		done := make(chan *hedgeWriter, 2)
+		run := func(process *Process, bodyBytes []byte, proxyRequest func(http.ResponseWriter, *http.Request)) {
+			ctx, cancel := context.WithCancel(r.Context())
+			writer := race.add(process, cancel)
+			req := r.Clone(ctx)
+			req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+			req.ContentLength = int64(len(bodyBytes))
+			req.Header.Set("content-length", strconv.Itoa(len(bodyBytes)))
+			go func() {
+				defer cancel()
+				proxyRequest(writer, req)
+				done <- writer
+			}()
+		}
+
+		run(process, bodyBytes, proxyRequest)
+		running := 1
+
+		timer := time.NewTimer(hedge.after())
+		defer timer.Stop()
+		for running > 0 {
+			select {
+			case <-timer.C:
+				if race.currentWinner() != nil {
+					continue
+				}
+				backupName, _ := pm.currentConfig().RealModelName(hedge.Model)
+				backup := pm.readyProcess(backupName)
+				if backup == nil {
+					traceRequest(c, "hedge model %s is not ready, waiting for %s", backupName, process.ID)
+					continue
+				}
+
+				// the filters of the backup model, not those of process
+				backupBody, _ := filterRequestBody(c, backup, requestBody)
+				backupBody = maps.Clone(backupBody)
+				backupBody["model"] = backupName
+				backupBytes, err := json.Marshal(backupBody)
+				if err != nil {
+					continue
+				}
+
+				pm.recordDecision(Decision{
+					Kind:    DecisionHedge,
+					Model:   backupName,
+					Trigger: process.ID,
+					Reason:  fmt.Sprintf("no response from %s after %v", process.ID, hedge.after()),
+				})
+				run(backup, backupBytes, backup.ProxyRequest)
+				running++
+			case <-done:
+				// the other requests were cancelled by the winner, they are
+				// waited for so none of them outlives the handler
+				running--
+			}
+		}
+
+		if winner := race.currentWinner(); winner != nil && winner.process != process {
+			traceRequest(c, "hedge model %s responded first", winner.process.ID)
+			setAccessLogKeys(c, hedge.Model, winner.process)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Hedge(t *testing.T) {
+	_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    hedge: {model: model2, afterMs: 500}
+  model2:
+    cmd: server --port 9002
+    proxy: http://127.0.0.1:9002
+`))
+	assert.ErrorContains(t, err, "model model1: hedge model model2 must be a standby model")
+}
+
+func TestProxyManager_Hedge(t *testing.T) {
+	model := getTestSimpleResponderConfig("model1")
+	model.Hedge = HedgeConfig{Model: "backup", AfterMs: 100}
+	backup := getTestSimpleResponderConfig("backup")
+	backup.Standby = true
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": model, "backup": backup},
+	})
+	defer proxy.StopProcesses()
+	proxy.WaitForStandby()
+
+	// model1 is being loaded, the backup responds first
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model1"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "backup", w.Body.String())
+	assert.Equal(t, "backup", w.Header().Get(fallbackModelHeader))
+
+	var hedges []Decision
+	for _, decision := range proxy.decisions {
+		if decision.Kind == DecisionHedge {
+			hedges = append(hedges, withoutTime(decision))
+		}
+	}
+	assert.Equal(t, []Decision{{
+		Kind:    DecisionHedge,
+		Model:   "backup",
+		Trigger: "model1",
+		Reason:  "no response from model1 after 100ms",
+	}}, hedges)
+
+	// model1 keeps loading and responds itself once it is ready
+	assert.Eventually(t, func() bool {
+		process := proxy.readyProcess("model1")
+		return process != nil
+	}, 10*time.Second, 20*time.Millisecond)
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model1"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "model1", w.Body.String())
+}
+
+func TestProxyManager_HedgeFilters(t *testing.T) {
+	received := make(chan map[string]interface{}, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		received <- body
+		w.Write([]byte("backup"))
+	}))
+	defer upstream.Close()
+
+	model := getTestSimpleResponderConfig("model1")
+	model.Hedge = HedgeConfig{Model: "backup", AfterMs: 100}
+	model.Filters = ModelFilters{
+		RenameParams: map[string]string{"max_tokens": "n_predict"},
+		SetParams:    map[string]interface{}{"top_k": 20},
+	}
+
+	// the command only keeps the process running, requests go to upstream
+	backup := getTestSimpleResponderConfig("backup")
+	backup.Proxy = upstream.URL
+	backup.Standby = true
+	backup.Filters = ModelFilters{SetParams: map[string]interface{}{"temperature": 0.2}}
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": model, "backup": backup},
+	})
+	defer proxy.StopProcesses()
+	proxy.WaitForStandby()
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model1","max_tokens":10,"temperature":1}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "backup", w.Body.String())
+
+	select {
+	case body := <-received:
+		assert.Equal(t, map[string]interface{}{"model": "backup", "max_tokens": 10.0, "temperature": 0.2}, body)
+	default:
+		t.Error("the backup model got no request")
+	}
+}
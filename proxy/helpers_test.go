@@ -2,6 +2,9 @@ package proxy
 
 import (
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -36,6 +39,15 @@ func getSimpleResponderPath() string {
 	return filepath.Join("..", "build", fmt.Sprintf("simple-responder_%s_%s", goos, goarch))
 }
 
+// testAdminKey is in the adminKeys of test configs for management requests
+const testAdminKey = "admin-key"
+
+func newAdminRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Authorization", "Bearer "+testAdminKey)
+	return req
+}
+
 func getTestSimpleResponderConfig(expectedMessage string) ModelConfig {
 	portMutex.Lock()
 	defer portMutex.Unlock()
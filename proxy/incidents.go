@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	stalePortCheckInterval = time.Minute
+	maxIncidents           = 100
+)
+
+// Incident is a problem llama-swap found outside of handling a request
+type Incident struct {
+	Time    time.Time `json:"time"`
+	Model   string    `json:"model"`
+	Port    string    `json:"port,omitempty"`
+	PID     int       `json:"pid,omitempty"`
+	Message string    `json:"message"`
+}
+
+func (pm *ProxyManager) addIncident(incident Incident) {
+	fmt.Fprintf(pm.logMonitor, "!!! Incident: %s\n", incident.Message)
+
+	pm.incidentsMutex.Lock()
+	defer pm.incidentsMutex.Unlock()
+	pm.incidents = append(pm.incidents, incident)
+	if len(pm.incidents) > maxIncidents {
+		pm.incidents = pm.incidents[len(pm.incidents)-maxIncidents:]
+	}
+}
+
+func (pm *ProxyManager) watchStalePorts() {
+	for range time.Tick(stalePortCheckInterval) {
+		pm.checkStalePorts()
+	}
+}
+
+// checkStalePorts looks for processes listening on the proxy ports of models
+// that llama-swap is not running, usually leftovers of a crash. With
+// reclaimPorts they are sent a SIGTERM.
+func (pm *ProxyManager) checkStalePorts() {
+	pm.Lock()
+	config := pm.currentConfig()
+	models := config.Models
+	reclaim := config.ReclaimPorts
+	managed := make(map[string]bool)
+	for _, process := range pm.currentProcesses {
+		if proxyURL, err := url.Parse(process.upstreamURL()); err == nil {
+			managed[proxyURL.Port()] = true
+		}
+	}
+	pm.Unlock()
+
+	checked := make(map[string]bool)
+	for modelID, modelConfig := range models {
+		proxyURL, err := url.Parse(modelConfig.Proxy)
+		if err != nil {
+			continue
+		}
+		addr, ok := localProxyAddr(proxyURL)
+		port := proxyURL.Port()
+		if !ok || managed[port] || checked[port] {
+			continue
+		}
+		checked[port] = true
+
+		if !portInUse(addr) {
+			continue
+		}
+
+		incident := Incident{Time: time.Now(), Model: modelID, Port: port}
+		incident.PID = findListenerPID(port)
+
+		switch {
+		case incident.PID == 0:
+			incident.Message = fmt.Sprintf("port %s of model %s is used by an unknown process", port, modelID)
+		case reclaim && incident.PID != os.Getpid():
+			if err := signalPID(incident.PID, syscall.SIGTERM); err != nil {
+				incident.Message = fmt.Sprintf("port %s of model %s is used by PID %d, unable to reclaim: %v", port, modelID, incident.PID, err)
+			} else {
+				incident.Message = fmt.Sprintf("port %s of model %s was used by PID %d, sent SIGTERM", port, modelID, incident.PID)
+			}
+		default:
+			incident.Message = fmt.Sprintf("port %s of model %s is used by PID %d", port, modelID, incident.PID)
+		}
+
+		pm.addIncident(incident)
+	}
+}
+
+func signalPID(pid int, sig os.Signal) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(sig)
+}
+
+// findListenerPID returns the PID listening on a TCP port, 0 when it can not
+// be found. Only supported on Linux.
+func findListenerPID(port string) int {
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return 0
+	}
+
+	inodes := make(map[string]bool)
+	for _, file := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		if data, err := os.ReadFile(file); err == nil {
+			for _, inode := range parseProcNetListeners(data, portNum) {
+				inodes["socket:["+inode+"]"] = true
+			}
+		}
+	}
+	if len(inodes) == 0 {
+		return 0
+	}
+
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		if target, err := os.Readlink(fd); err == nil && inodes[target] {
+			pid, _ := strconv.Atoi(strings.Split(fd, "/")[2])
+			return pid
+		}
+	}
+	return 0
+}
+
+// parseProcNetListeners returns the socket inodes listening on port from
+// the contents of /proc/net/tcp
+func parseProcNetListeners(data []byte, port int) []string {
+	const stateListen = "0A"
+
+	var inodes []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != stateListen {
+			continue
+		}
+
+		_, hexPort, found := strings.Cut(fields[1], ":")
+		if !found {
+			continue
+		}
+		if p, err := strconv.ParseInt(hexPort, 16, 32); err == nil && int(p) == port {
+			inodes = append(inodes, fields[9])
+		}
+	}
+	return inodes
+}
+
+func (pm *ProxyManager) apiListIncidents(c *gin.Context) {
+	pm.incidentsMutex.Lock()
+	defer pm.incidentsMutex.Unlock()
+	c.JSON(http.StatusOK, gin.H{"incidents": append([]Incident{}, pm.incidents...)})
+}
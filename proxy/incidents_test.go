@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProcNetListeners(t *testing.T) {
+	data := []byte(`  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 12345 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:D431 01 00000000:00000000 00:00000000 00000000     0        0 23456 1 0000000000000000 20 4 30 10 -1
+   2: 00000000:1F91 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 34567 1 0000000000000000 100 0 0 10 0
+`)
+
+	assert.Equal(t, []string{"12345"}, parseProcNetListeners(data, 8080))
+	assert.Equal(t, []string{"34567"}, parseProcNetListeners(data, 8081))
+	assert.Empty(t, parseProcNetListeners(data, 9000))
+}
+
+func TestProxyManager_StalePortIncident(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	config := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"stale": {
+				Cmd:   "path/to/server",
+				Proxy: fmt.Sprintf("http://127.0.0.1:%d", port),
+			},
+		},
+		// our own process is never reclaimed
+		ReclaimPorts: true,
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	req := httptest.NewRequest("GET", "/api/incidents", nil)
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Incidents []Incident `json:"incidents"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Incidents, 1) {
+		incident := response.Incidents[0]
+		assert.Equal(t, "stale", incident.Model)
+		assert.Equal(t, fmt.Sprint(port), incident.Port)
+		if runtime.GOOS == "linux" {
+			assert.Equal(t, os.Getpid(), incident.PID)
+			assert.Contains(t, incident.Message, "is used by PID")
+		}
+	}
+}
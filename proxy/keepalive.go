@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// startWithKeepAlive starts the process and sends keep-alives to the client
+// every interval until it is ready, so proxies with short idle timeouts do not
+// close the connection during long model loads. Streaming requests get SSE
+// comments, others an HTTP 102 Processing which does not commit the final
+// status. Start errors are left for ProxyRequest to report.
+func startWithKeepAlive(c *gin.Context, process *Process, interval time.Duration, sse bool) {
+	started := make(chan struct{})
+	go func() {
+		process.start()
+		close(started)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-started:
+			return
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+			sendLoadKeepAlive(c, process, sse)
+		}
+	}
+}
+
+// sendLoadKeepAlive sends a keep-alive, SSE comments include the progress
+// read from the model's output when it is known, see loadprogress.go
+func sendLoadKeepAlive(c *gin.Context, process *Process, sse bool) {
+	if sse {
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Writer.WriteHeaderNow()
+		}
+		if progress := process.progress.snapshot(); progress.Percent > 0 {
+			fmt.Fprintf(c.Writer, ": loading model %d%%\n\n", progress.Percent)
+		} else {
+			c.Writer.WriteString(": loading model\n\n")
+		}
+		c.Writer.Flush()
+		return
+	}
+
+	// gin only records the status, informational responses have to be sent
+	// with the underlying http.ResponseWriter
+	var w http.ResponseWriter = c.Writer
+	for {
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = unwrapper.Unwrap()
+	}
+	w.WriteHeader(http.StatusProcessing)
+}
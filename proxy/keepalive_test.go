@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slow loading models become ready once checkCmd exits after a delay
+func newKeepAliveTestServer(t *testing.T) *httptest.Server {
+	modelConfig := getTestSimpleResponderConfig("slow")
+	modelConfig.CheckCmd = "sleep 2.5"
+
+	proxy := New(&Config{
+		HealthCheckTimeout:    15,
+		LoadKeepAliveInterval: 1,
+		Models:                map[string]ModelConfig{"slow": modelConfig},
+	})
+	t.Cleanup(proxy.StopProcesses)
+
+	server := httptest.NewServer(http.HandlerFunc(proxy.HandlerFunc))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProxyManager_LoadKeepAliveProcessing(t *testing.T) {
+	server := newKeepAliveTestServer(t)
+
+	var informational []int
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			informational = append(informational, code)
+			return nil
+		},
+	}
+
+	req, _ := http.NewRequest("POST", server.URL+"/v1/chat/completions", bytes.NewBufferString(`{"model":"slow"}`))
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "slow", string(body))
+	assert.GreaterOrEqual(t, len(informational), 2)
+	for _, code := range informational {
+		assert.Equal(t, http.StatusProcessing, code)
+	}
+}
+
+func TestProxyManager_LoadKeepAliveSSE(t *testing.T) {
+	server := newKeepAliveTestServer(t)
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", bytes.NewBufferString(`{"model":"slow","stream":true}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(string(body), ": loading model\n\n"))
+	assert.True(t, strings.HasSuffix(string(body), "slow"))
+}
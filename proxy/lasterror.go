@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lines of the upstream's output kept for a startup failure
+const startupLogLines = 50
+
+// StartupError is a failed start or a crash of a model's command with the
+// last lines of its output
+type StartupError struct {
+	Time  time.Time
+	Model string
+	Err   error
+
+	// -1 when the command was killed or did not run
+	ExitCode int
+	Log      []string
+}
+
+func (e *StartupError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StartupError) Unwrap() error {
+	return e.Err
+}
+
+// lineTail keeps the last lines written to it
+type lineTail struct {
+	sync.Mutex
+
+	max     int
+	lines   []string
+	partial []byte
+}
+
+func newLineTail(max int) *lineTail {
+	return &lineTail{max: max}
+}
+
+func (t *lineTail) Write(p []byte) (int, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	data := append(t.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		t.lines = append(t.lines, string(bytes.TrimRight(data[:i], "\r")))
+		data = data[i+1:]
+	}
+	if len(t.lines) > t.max {
+		t.lines = append([]string(nil), t.lines[len(t.lines)-t.max:]...)
+	}
+	t.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// snapshot returns the kept lines and an unfinished last line
+func (t *lineTail) snapshot() []string {
+	t.Lock()
+	defer t.Unlock()
+
+	lines := append([]string{}, t.lines...)
+	if len(t.partial) > 0 {
+		lines = append(lines, string(t.partial))
+	}
+	if len(lines) > t.max {
+		lines = lines[len(lines)-t.max:]
+	}
+	return lines
+}
+
+func (t *lineTail) reset() {
+	t.Lock()
+	defer t.Unlock()
+	t.lines = nil
+	t.partial = nil
+}
+
+// startupError records err with the command's exit code and output as the
+// process's last error, the command must have exited
+func (p *Process) startupError(err error, waitErr error) *StartupError {
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(waitErr, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if waitErr == nil && p.cmd != nil && p.cmd.ProcessState != nil {
+		exitCode = p.cmd.ProcessState.ExitCode()
+	}
+
+	startupErr := &StartupError{
+		Time:     time.Now(),
+		Model:    p.ID,
+		Err:      err,
+		ExitCode: exitCode,
+		Log:      p.output.snapshot(),
+	}
+	p.lastError.Store(startupErr)
+	return startupErr
+}
+
+// startupErrorBody is the JSON error of a failed start with startupErrorDetails
+func startupErrorBody(err *StartupError) map[string]interface{} {
+	return map[string]interface{}{"error": map[string]interface{}{
+		"message":  "unable to start process: " + err.Error(),
+		"type":     "server_error",
+		"code":     "startup_failed",
+		"exitCode": err.ExitCode,
+		"log":      err.Log,
+	}}
+}
+
+// apiGetLastError returns the last failed start or crash of a model for
+// GET /api/models/<model>/last-error
+func (pm *ProxyManager) apiGetLastError(c *gin.Context, id string) {
+	pm.Lock()
+	modelID, found := pm.currentConfig().RealModelName(id)
+	var lastError *StartupError
+	for _, process := range pm.currentProcesses {
+		if process.ID != modelID {
+			continue
+		}
+		if err := process.lastError.Load(); err != nil && (lastError == nil || err.Time.After(lastError.Time)) {
+			lastError = err
+		}
+	}
+	pm.Unlock()
+
+	if !found {
+		pm.sendErrorResponse(c, http.StatusNotFound, "model not found")
+		return
+	}
+	if lastError == nil {
+		pm.sendErrorResponse(c, http.StatusNotFound, "no failure recorded for "+modelID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"model":    lastError.Model,
+		"time":     lastError.Time,
+		"error":    lastError.Error(),
+		"exitCode": lastError.ExitCode,
+		"log":      lastError.Log,
+	})
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineTail(t *testing.T) {
+	tail := newLineTail(2)
+	tail.Write([]byte("one\ntwo\r\nthr"))
+	tail.Write([]byte("ee\nfour"))
+	assert.Equal(t, []string{"three", "four"}, tail.snapshot())
+
+	tail.reset()
+	assert.Empty(t, tail.snapshot())
+}
+
+func TestProxyManager_LastError(t *testing.T) {
+	failing := ModelConfig{
+		Cmd:   `sh -c 'echo "loading model"; echo "CUDA error: out of memory" >&2; exit 3'`,
+		Proxy: "http://127.0.0.1:9",
+	}
+
+	for _, details := range []bool{true, false} {
+		proxy := New(&Config{
+			HealthCheckTimeout:  15,
+			StartupErrorDetails: details,
+			Models:              map[string]ModelConfig{"model1": failing},
+		})
+
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/models/model1/last-error", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model1"}`)))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+		if details {
+			var response struct {
+				Error struct {
+					Code     string   `json:"code"`
+					ExitCode int      `json:"exitCode"`
+					Log      []string `json:"log"`
+				} `json:"error"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "startup_failed", response.Error.Code)
+			assert.Equal(t, 3, response.Error.ExitCode)
+			assert.Contains(t, response.Error.Log, "CUDA error: out of memory")
+		} else {
+			assert.NotContains(t, w.Body.String(), "startup_failed")
+		}
+
+		// the failure is kept either way
+		w = httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/models/model1/last-error", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var lastError struct {
+			Model    string   `json:"model"`
+			Error    string   `json:"error"`
+			ExitCode int      `json:"exitCode"`
+			Log      []string `json:"log"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &lastError))
+		assert.Equal(t, "model1", lastError.Model)
+		assert.Contains(t, lastError.Error, "exit status 3")
+		assert.Equal(t, 3, lastError.ExitCode)
+		assert.Equal(t, []string{"loading model", "CUDA error: out of memory"}, lastError.Log)
+
+		w = httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/models/unknown/last-error", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		proxy.StopProcesses()
+	}
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os/exec"
+	"time"
+)
+
+// how often a process past its maxLifetime checks for an idle moment
+const lifetimeIdlePoll = 250 * time.Millisecond
+
+func (m ModelConfig) validateLifetime() error {
+	lifetime, spread, err := m.lifetime()
+	if err != nil {
+		return err
+	}
+	if lifetime == 0 {
+		if spread > 0 {
+			return fmt.Errorf("maxLifetimeJitter needs a maxLifetime")
+		}
+		return nil
+	}
+	if m.Remote.enabled() {
+		return fmt.Errorf("maxLifetime is not supported by remote models")
+	}
+	if spread >= lifetime {
+		return fmt.Errorf("maxLifetimeJitter must be shorter than maxLifetime")
+	}
+	return nil
+}
+
+// lifetime returns the parsed maxLifetime and maxLifetimeJitter
+func (m ModelConfig) lifetime() (time.Duration, time.Duration, error) {
+	var lifetime, spread time.Duration
+	var err error
+	if m.MaxLifetime != "" {
+		if lifetime, err = time.ParseDuration(m.MaxLifetime); err != nil || lifetime < 0 {
+			return 0, 0, fmt.Errorf("invalid maxLifetime %q, use a duration like 6h", m.MaxLifetime)
+		}
+	}
+	if m.MaxLifetimeJitter != "" {
+		if spread, err = time.ParseDuration(m.MaxLifetimeJitter); err != nil || spread < 0 {
+			return 0, 0, fmt.Errorf("invalid maxLifetimeJitter %q, use a duration like 30m", m.MaxLifetimeJitter)
+		}
+	}
+	return lifetime, spread, nil
+}
+
+// watchLifetime restarts the process once cmd ran for maxLifetime, less a
+// random part of maxLifetimeJitter, as soon as it has no requests in flight
+func (p *Process) watchLifetime(cmd *exec.Cmd) {
+	lifetime, spread, _ := p.config.lifetime()
+	if spread > 0 {
+		lifetime -= rand.N(spread)
+	}
+	time.Sleep(lifetime)
+
+	for ; ; time.Sleep(lifetimeIdlePoll) {
+		p.stateMutex.RLock()
+		current, state := p.cmd, p.state
+		p.stateMutex.RUnlock()
+
+		if current != cmd || (state != StateReady && state != StateSleeping) {
+			return
+		}
+		// a sleeping vLLM server is restarted once it is woken up
+		if state == StateSleeping || p.inFlightCount.Load() > 0 {
+			continue
+		}
+		if p.recycle(cmd) {
+			return
+		}
+	}
+}
+
+// recycle stops cmd and starts the process again, keeping its pin and ttl.
+// It returns false when a request came in first.
+func (p *Process) recycle(cmd *exec.Cmd) bool {
+	p.stateMutex.Lock()
+	if p.cmd != cmd || p.state != StateReady {
+		p.stateMutex.Unlock()
+		return true
+	}
+	if p.inFlightCount.Load() > 0 {
+		p.stateMutex.Unlock()
+		return false
+	}
+
+	fmt.Fprintf(p.logMonitor, "!!! Restarting %s after its maxLifetime of %s\n", p.ID, p.config.MaxLifetime)
+	pinned, ttl := p.pinned.Load(), p.ttlOverride.Load()
+	p.stop()
+	p.pinned.Store(pinned)
+	p.ttlOverride.Store(ttl)
+	p.stateMutex.Unlock()
+
+	if p.onRecycled != nil {
+		go p.onRecycled()
+	}
+	if err := p.start(); err != nil {
+		fmt.Fprintf(p.logMonitor, "!!! Restart of %s failed: %v\n", p.ID, err)
+	}
+	return true
+}
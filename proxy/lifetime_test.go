@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_MaxLifetime(t *testing.T) {
+	for config, expected := range map[string]string{
+		"maxLifetime: 6 hours":                       `invalid maxLifetime "6 hours"`,
+		"maxLifetimeJitter: 30m":                     "maxLifetimeJitter needs a maxLifetime",
+		"maxLifetime: 6h\n    maxLifetimeJitter: 6h": "maxLifetimeJitter must be shorter than maxLifetime",
+	} {
+		_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    ` + config))
+		assert.ErrorContains(t, err, "model model1: "+expected)
+	}
+}
+
+func TestProcess_MaxLifetime(t *testing.T) {
+	config := getTestSimpleResponderConfig("lifetime")
+	config.MaxLifetime = "1s"
+	process := NewProcess("lifetime", 15, config, NewLogMonitorWriter(io.Discard))
+	defer process.Stop()
+
+	recycled := make(chan struct{}, 1)
+	process.onRecycled = func() { recycled <- struct{}{} }
+
+	pid := func() int {
+		process.stateMutex.RLock()
+		defer process.stateMutex.RUnlock()
+		return process.cmd.Process.Pid
+	}
+
+	assert.NoError(t, process.start())
+	firstPID := pid()
+
+	// not restarted while a request is in flight
+	done := make(chan struct{})
+	go func() {
+		w := httptest.NewRecorder()
+		process.ProxyRequest(w, httptest.NewRequest("GET", "/slow-respond?echo=1&delay=2000ms", nil))
+		close(done)
+	}()
+	time.Sleep(1500 * time.Millisecond)
+	assert.Equal(t, firstPID, pid())
+
+	<-done
+	select {
+	case <-recycled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("process was not restarted")
+	}
+	assert.Eventually(t, func() bool { return process.CurrentState() == StateReady }, 5*time.Second, 50*time.Millisecond)
+	assert.NotEqual(t, firstPID, pid())
+}
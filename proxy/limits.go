@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// request body fields limiting the number of generated tokens
+var maxTokensParams = []string{"max_tokens", "max_completion_tokens", "n_predict"}
+
+// LimitsConfig caps the tokens clients can ask a model for, so a client
+// asking for max_tokens: -1 can not keep it busy. Only chat and completion
+// requests are changed.
+type LimitsConfig struct {
+	// largest max_tokens, larger values, -1 and 0 are replaced by it
+	MaxMaxTokens int `yaml:"maxMaxTokens"`
+
+	// max_tokens of requests without one, default: maxMaxTokens
+	DefaultMaxTokens int `yaml:"defaultMaxTokens"`
+
+	// requests with a larger estimated prompt are rejected, max_tokens is
+	// capped to the tokens left of it
+	MaxContextTokens int `yaml:"maxContextTokens"`
+}
+
+func (l LimitsConfig) validate() error {
+	if l.MaxMaxTokens < 0 || l.DefaultMaxTokens < 0 || l.MaxContextTokens < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	if l.MaxMaxTokens > 0 && l.DefaultMaxTokens > l.MaxMaxTokens {
+		return fmt.Errorf("limits: defaultMaxTokens must not be larger than maxMaxTokens")
+	}
+	return nil
+}
+
+func (l LimitsConfig) enabled() bool {
+	return l.MaxMaxTokens > 0 || l.DefaultMaxTokens > 0 || l.MaxContextTokens > 0
+}
+
+// limitedPath is true for the endpoints that generate tokens
+func limitedPath(path string) bool {
+	task := endpointTaskTypes[path]
+	return task == "chat" || task == "completion"
+}
+
+// apply returns the request body with its max tokens capped, a copy when it
+// was changed
+func (l LimitsConfig) apply(path string, requestBody map[string]interface{}) (map[string]interface{}, bool) {
+	if !l.enabled() || !limitedPath(path) {
+		return requestBody, false
+	}
+
+	limit := l.MaxMaxTokens
+	if l.MaxContextTokens > 0 {
+		left := l.MaxContextTokens - estimatePromptTokens(requestBody)
+		if left > 0 && (limit == 0 || left < limit) {
+			limit = left
+		}
+	}
+
+	body := maps.Clone(requestBody)
+	changed := false
+	hasMaxTokens := false
+	for _, param := range maxTokensParams {
+		value, found := body[param]
+		if !found {
+			continue
+		}
+		hasMaxTokens = true
+		tokens, ok := value.(float64)
+		if limit > 0 && (!ok || tokens <= 0 || tokens > float64(limit)) {
+			body[param] = limit
+			changed = true
+		}
+	}
+
+	if !hasMaxTokens {
+		defaultTokens := l.DefaultMaxTokens
+		if defaultTokens == 0 || (limit > 0 && defaultTokens > limit) {
+			defaultTokens = limit
+		}
+		if defaultTokens > 0 {
+			body["max_tokens"] = defaultTokens
+			changed = true
+		}
+	}
+
+	if !changed {
+		return requestBody, false
+	}
+	return body, true
+}
+
+// checkContextLimit responds with a 400 and returns false when the estimated
+// prompt of a request is larger than the maxContextTokens of the requested
+// model
+func (pm *ProxyManager) checkContextLimit(c *gin.Context, requestedModel string, requestBody map[string]interface{}) bool {
+	if !limitedPath(c.Request.URL.Path) {
+		return true
+	}
+
+	modelConfig, _, found := pm.currentConfig().findRequestedConfig(requestedModel)
+	limit := modelConfig.Limits.MaxContextTokens
+	if !found || limit == 0 {
+		return true
+	}
+
+	if promptTokens := estimatePromptTokens(requestBody); promptTokens >= limit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{
+			"message": fmt.Sprintf("the prompt of about %d tokens is larger than the context of %d tokens of model %s", promptTokens, limit, requestedModel),
+			"type":    "invalid_request_error",
+			"code":    "context_length_exceeded",
+		}})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Limits(t *testing.T) {
+	_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    limits:
+      maxMaxTokens: 1024
+      defaultMaxTokens: 2048
+`))
+	assert.ErrorContains(t, err, "model model1: limits: defaultMaxTokens must not be larger than maxMaxTokens")
+}
+
+func TestLimits_Apply(t *testing.T) {
+	limits := LimitsConfig{MaxMaxTokens: 1024, DefaultMaxTokens: 256, MaxContextTokens: 4096}
+
+	tests := []struct {
+		name     string
+		body     map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{"default", map[string]interface{}{}, map[string]interface{}{"max_tokens": 256}},
+		{"capped", map[string]interface{}{"max_tokens": float64(200000)}, map[string]interface{}{"max_tokens": 1024}},
+		{"unlimited", map[string]interface{}{"max_tokens": float64(-1), "n_predict": float64(-1)}, map[string]interface{}{"max_tokens": 1024, "n_predict": 1024}},
+		{"kept", map[string]interface{}{"max_completion_tokens": float64(512)}, nil},
+		{"context left", map[string]interface{}{"prompt": strings.Repeat("a", 4*3500)}, map[string]interface{}{"max_tokens": 256}},
+		{"context left capped", map[string]interface{}{"prompt": strings.Repeat("a", 4*3500), "max_tokens": float64(1000)}, map[string]interface{}{"max_tokens": 596}},
+	}
+	for _, test := range tests {
+		body, changed := limits.apply("/v1/completions", test.body)
+		if test.expected == nil {
+			assert.False(t, changed, test.name)
+			assert.Equal(t, test.body, body, test.name)
+			continue
+		}
+		assert.True(t, changed, test.name)
+		for key, value := range test.expected {
+			assert.Equal(t, value, body[key], test.name)
+		}
+	}
+
+	// the default is maxMaxTokens
+	body, _ := LimitsConfig{MaxMaxTokens: 1024}.apply("/v1/chat/completions", map[string]interface{}{})
+	assert.Equal(t, 1024, body["max_tokens"])
+
+	// only endpoints generating tokens are changed
+	_, changed := limits.apply("/v1/embeddings", map[string]interface{}{"input": "hello"})
+	assert.False(t, changed)
+}
+
+func TestProxyManager_ContextLimit(t *testing.T) {
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Limits = LimitsConfig{MaxContextTokens: 100}
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+	})
+	defer proxy.StopProcesses()
+
+	w := httptest.NewRecorder()
+	body := `{"model":"model1","messages":[{"role":"user","content":"` + strings.Repeat("a", 800) + `"}]}`
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "the prompt of about 200 tokens is larger than the context of 100 tokens of model model1")
+
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1","max_tokens":-1}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
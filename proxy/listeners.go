@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// prefix of listen addresses that are unix sockets, unix:/run/llama-swap.sock
+const unixListenPrefix = "unix:"
+
+// systemd passes socket activated listeners starting at this fd
+const listenFDsStart = 3
+
+type ListenConfig struct {
+	// address of the OpenAI compatible endpoints, the --listen flag takes
+	// precedence. unix:/path/to.sock listens on a unix socket.
+	Proxy string `yaml:"proxy"`
+	// address of the management endpoints, they are not served by the proxy
+	// listener when set. Empty = one listener for all endpoints.
+	Admin string `yaml:"admin"`
+}
+
+func (l ListenConfig) validate() error {
+	if l.Admin != "" && l.Admin == l.Proxy {
+		return fmt.Errorf("listen: admin and proxy need different addresses")
+	}
+	return nil
+}
+
+// unixListenPath returns the socket of a unix:/path/to.sock listen address
+func unixListenPath(addr string) (string, bool) {
+	path, found := strings.CutPrefix(addr, unixListenPrefix)
+	if !found {
+		return "", false
+	}
+	// unix:///path/to.sock like the proxy of a model
+	if strings.HasPrefix(path, "//") {
+		path = path[2:]
+	}
+	return path, path != ""
+}
+
+// Listen listens on a host:port or a unix:/path/to.sock address. A socket
+// file left by a previous llama-swap is removed first.
+func Listen(addr string) (net.Listener, error) {
+	path, ok := unixListenPath(addr)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	// in unixsocket.go
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
+// ListenAddr is the address of a listener in the form accepted by Listen
+func ListenAddr(listener net.Listener) string {
+	if listener.Addr().Network() == "unix" {
+		return unixListenPrefix + listener.Addr().String()
+	}
+	return listener.Addr().String()
+}
+
+// SystemdListeners returns the sockets passed by systemd socket activation,
+// by their FileDescriptorName, in the order of the socket unit. The
+// LISTEN_* variables are removed so model commands do not inherit them.
+// Returns nothing when llama-swap was not socket activated.
+func SystemdListeners() ([]net.Listener, []string, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil, nil
+	}
+	var names []string
+	if fdNames := os.Getenv("LISTEN_FDNAMES"); fdNames != "" {
+		names = strings.Split(fdNames, ":")
+	}
+	return listenersFromFDs(listenFDsStart, count, names)
+}
+
+// listenersFromFDs turns count inherited fds starting at start into
+// listeners
+func listenersFromFDs(start, count int, names []string) ([]net.Listener, []string, error) {
+	listeners := make([]net.Listener, 0, count)
+	listenerNames := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(start+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		file := os.NewFile(uintptr(start+i), name)
+		listener, err := net.FileListener(file)
+		// the listener has its own copy of the fd
+		file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, nil, fmt.Errorf("socket activation fd %d (%s): %v", start+i, name, err)
+		}
+		listeners = append(listeners, listener)
+		listenerNames = append(listenerNames, name)
+	}
+	return listeners, listenerNames, nil
+}
+
+// isManagementPath is true for the API, logs, upstream and UI endpoints
+func isManagementPath(path string) bool {
+	if isOllamaInferencePath(path) {
+		return false
+	}
+	switch path {
+	case "/", "/favicon.ico", "/running", "/logs", "/upstream":
+		return true
+	}
+	for _, prefix := range []string{"/api/", "/logs/", "/upstream/"} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mark the connections of the proxy and admin listeners started by
+// RunListeners
+type (
+	proxyListenerKey struct{}
+	adminListenerKey struct{}
+)
+
+// RunListeners serves the OpenAI compatible endpoints on proxyListener and
+// all endpoints on adminListener
+func (pm *ProxyManager) RunListeners(proxyListener, adminListener net.Listener) error {
+	proxyServer := &http.Server{
+		Handler: pm.ginEngine.Handler(),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, proxyListenerKey{}, true)
+		},
+	}
+	adminServer := &http.Server{
+		Handler: pm.ginEngine.Handler(),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, adminListenerKey{}, true)
+		},
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- proxyServer.Serve(proxyListener) }()
+	go func() { errs <- adminServer.Serve(adminListener) }()
+	return <-errs
+}
+
+// listenerMiddleware hides the management endpoints from the proxy listener
+func (pm *ProxyManager) listenerMiddleware(c *gin.Context) {
+	if c.Request.Context().Value(proxyListenerKey{}) != nil && isManagementPath(c.Request.URL.Path) {
+		pm.sendErrorResponse(c, http.StatusNotFound, "not found")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
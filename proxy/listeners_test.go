@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsManagementPath(t *testing.T) {
+	for _, path := range []string{"/", "/api/topology", "/api/models/m/load", "/logs", "/logs/stream", "/running", "/upstream/m/health"} {
+		assert.True(t, isManagementPath(path), path)
+	}
+	for _, path := range []string{"/v1/chat/completions", "/v1/models", "/health", "/tokenize", "/logsx"} {
+		assert.False(t, isManagementPath(path), path)
+	}
+}
+
+func TestConfig_ListenValidate(t *testing.T) {
+	config, err := LoadConfigFromBytes([]byte("listen:\n  proxy: 0.0.0.0:8080\n  admin: 127.0.0.1:9090\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, ListenConfig{Proxy: "0.0.0.0:8080", Admin: "127.0.0.1:9090"}, config.Listen)
+
+	_, err = LoadConfigFromBytes([]byte("listen:\n  proxy: :8080\n  admin: :8080\n"))
+	assert.ErrorContains(t, err, "different addresses")
+}
+
+func TestProxyManager_RunListeners(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+	})
+	defer proxy.StopProcesses()
+
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	adminListener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer proxyListener.Close()
+	defer adminListener.Close()
+	go proxy.RunListeners(proxyListener, adminListener)
+
+	status := func(listener net.Listener, path string) int {
+		resp, err := http.Get("http://" + listener.Addr().String() + path)
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, status(proxyListener, "/v1/models"))
+	assert.Equal(t, http.StatusOK, status(proxyListener, "/health"))
+	assert.Equal(t, http.StatusNotFound, status(proxyListener, "/running"))
+	assert.Equal(t, http.StatusNotFound, status(proxyListener, "/api/topology"))
+
+	assert.Equal(t, http.StatusOK, status(adminListener, "/running"))
+	assert.Equal(t, http.StatusOK, status(adminListener, "/api/topology"))
+	assert.Equal(t, http.StatusOK, status(adminListener, "/v1/models"))
+}
+
+func TestListen_UnixSocket(t *testing.T) {
+	path := shortSocketPath(t)
+
+	// a socket left by a previous llama-swap
+	stale, err := net.Listen("unix", path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := Listen("unix:" + path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listener.Close()
+	assert.Equal(t, "unix:"+path, ListenAddr(listener))
+
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	client := &http.Client{Transport: unixSocketTransport(path)}
+	resp, err := client.Get(unixUpstreamBase + "/health")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "ok", string(body))
+}
+
+func TestListen_UnixListenPath(t *testing.T) {
+	path, ok := unixListenPath("unix:/run/llama-swap.sock")
+	assert.True(t, ok)
+	assert.Equal(t, "/run/llama-swap.sock", path)
+
+	path, ok = unixListenPath("unix:///run/llama-swap.sock")
+	assert.True(t, ok)
+	assert.Equal(t, "/run/llama-swap.sock", path)
+
+	_, ok = unixListenPath(":8080")
+	assert.False(t, ok)
+}
+
+func TestListen_SystemdListenersForOtherProcess(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+
+	listeners, _, err := SystemdListeners()
+	assert.NoError(t, err)
+	assert.Empty(t, listeners)
+	_, found := os.LookupEnv("LISTEN_FDS")
+	assert.False(t, found, "LISTEN_FDS is removed")
+}
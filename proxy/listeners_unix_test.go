@@ -0,0 +1,38 @@
+//go:build !windows
+
+package proxy
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListen_ListenersFromFDs(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer tcp.Close()
+
+	// a copy of the fd, like the one passed by systemd
+	file, err := tcp.(*net.TCPListener).File()
+	if !assert.NoError(t, err) {
+		return
+	}
+	fd, err := syscall.Dup(int(file.Fd()))
+	file.Close()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	listeners, names, err := listenersFromFDs(fd, 1, []string{"admin"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listeners[0].Close()
+	assert.Equal(t, []string{"admin"}, names)
+	assert.Equal(t, tcp.Addr().String(), listeners[0].Addr().String())
+}
@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// default failed checks in a row before the livenessCheck action
+const defaultLivenessFailureThreshold = 3
+
+// longest a single liveness check may take
+const livenessCheckTimeout = 5 * time.Second
+
+type LivenessCheckConfig struct {
+	// seconds between checks of a ready model, 0 = no checks
+	Interval int `yaml:"interval"`
+	// failed checks in a row before the action, default 3
+	FailureThreshold int `yaml:"failureThreshold"`
+	// restart (default) or stop
+	Action string `yaml:"action"`
+}
+
+func (m ModelConfig) validateLivenessCheck() error {
+	l := m.LivenessCheck
+	if l.Interval < 0 || l.FailureThreshold < 0 {
+		return fmt.Errorf("livenessCheck interval and failureThreshold must not be negative")
+	}
+	switch l.Action {
+	case "", "restart", "stop":
+	default:
+		return fmt.Errorf("unknown livenessCheck action %s, use restart or stop", l.Action)
+	}
+
+	if l.Interval == 0 {
+		return nil
+	}
+	if m.Remote.enabled() {
+		return fmt.Errorf("livenessCheck is not supported by remote models")
+	}
+	if m.CheckCmd == "" && strings.TrimSpace(m.CheckEndpoint) == "none" {
+		return fmt.Errorf("livenessCheck needs a checkEndpoint or checkCmd")
+	}
+	return nil
+}
+
+// watchLiveness checks a ready process every interval with its checkEndpoint
+// or checkCmd until cmd stops. After failureThreshold failed checks in a row
+// the process is restarted or stopped.
+func (p *Process) watchLiveness(cmd *exec.Cmd) {
+	config := p.config.LivenessCheck
+	threshold := config.FailureThreshold
+	if threshold == 0 {
+		threshold = defaultLivenessFailureThreshold
+	}
+
+	failures := 0
+	for {
+		time.Sleep(jitter(time.Duration(config.Interval) * time.Second))
+
+		p.stateMutex.RLock()
+		current, state := p.cmd, p.state
+		p.stateMutex.RUnlock()
+
+		if current != cmd || (state != StateReady && state != StateSleeping) {
+			return
+		}
+		// a sleeping vLLM server only answers to wake up
+		if state == StateSleeping {
+			failures = 0
+			continue
+		}
+
+		err := p.checkLiveness()
+		if err == nil {
+			failures = 0
+			continue
+		}
+
+		failures++
+		fmt.Fprintf(p.logMonitor, "!!! Liveness check %d of %d for %s failed: %v\n", failures, threshold, p.ID, err)
+		if failures < threshold {
+			continue
+		}
+
+		p.livenessFailed(cmd, fmt.Errorf("%d liveness checks failed: %v", failures, err))
+		return
+	}
+}
+
+// livenessFailed stops the unresponsive command and starts it again unless
+// the action is stop
+func (p *Process) livenessFailed(cmd *exec.Cmd, err error) {
+	p.stateMutex.Lock()
+	if p.cmd != cmd || p.state != StateReady {
+		p.stateMutex.Unlock()
+		return
+	}
+
+	if p.onUnhealthy != nil {
+		go p.onUnhealthy(err)
+	}
+
+	// in-flight requests to a wedged server are not waited for
+	p.stop()
+	p.stateMutex.Unlock()
+
+	if p.config.LivenessCheck.Action == "stop" {
+		fmt.Fprintf(p.logMonitor, "!!! Stopped unresponsive %s\n", p.ID)
+		return
+	}
+
+	fmt.Fprintf(p.logMonitor, "!!! Restarting unresponsive %s\n", p.ID)
+	if err := p.start(); err != nil {
+		fmt.Fprintf(p.logMonitor, "!!! Restart of %s failed: %v\n", p.ID, err)
+	}
+}
+
+// checkLiveness runs checkCmd or requests checkEndpoint once
+func (p *Process) checkLiveness() error {
+	ctx, cancel := context.WithTimeout(context.Background(), livenessCheckTimeout)
+	defer cancel()
+
+	if p.config.CheckCmd != "" {
+		args, err := p.commandArgs(p.config.CheckCmd)
+		if err != nil {
+			return fmt.Errorf("invalid checkCmd: %v", err)
+		}
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Env = p.commandEnv()
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("checkCmd: %v %s", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+
+	checkEndpoint := strings.TrimSpace(p.config.CheckEndpoint)
+	if checkEndpoint == "" {
+		checkEndpoint = "/health"
+	}
+	healthURL, err := url.JoinPath(p.upstreamURL(), checkEndpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", healthURL, nil)
+	if err != nil {
+		return err
+	}
+	p.config.setUpstreamHeaders(req.Header)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", healthURL, resp.StatusCode)
+	}
+	return nil
+}
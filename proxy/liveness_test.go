@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_LivenessCheck(t *testing.T) {
+	_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    livenessCheck:
+      interval: 10
+      action: kill
+`))
+	assert.ErrorContains(t, err, "unknown livenessCheck action kill")
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    checkEndpoint: none
+    livenessCheck:
+      interval: 10
+`))
+	assert.ErrorContains(t, err, "livenessCheck needs a checkEndpoint or checkCmd")
+}
+
+func TestProcess_LivenessCheck(t *testing.T) {
+	for _, action := range []string{"restart", "stop"} {
+		t.Run(action, func(t *testing.T) {
+			wedged := filepath.Join(t.TempDir(), "wedged")
+			config := getTestSimpleResponderConfig("liveness")
+			config.CheckCmd = "sh -c 'test ! -e " + wedged + "'"
+			config.LivenessCheck = LivenessCheckConfig{Interval: 1, FailureThreshold: 2, Action: action}
+
+			process := NewProcess("liveness", 5, config, NewLogMonitorWriter(io.Discard))
+			defer process.Stop()
+
+			unhealthy := make(chan error, 1)
+			process.onUnhealthy = func(err error) {
+				os.Remove(wedged)
+				unhealthy <- err
+			}
+
+			assert.NoError(t, process.start())
+			firstPid := process.cmd.Process.Pid
+			assert.NoError(t, os.WriteFile(wedged, nil, 0644))
+
+			select {
+			case err := <-unhealthy:
+				assert.ErrorContains(t, err, "2 liveness checks failed")
+			case <-time.After(10 * time.Second):
+				t.Fatal("liveness check did not fail")
+			}
+
+			if action == "stop" {
+				assert.Eventually(t, func() bool {
+					return process.CurrentState() == StateStopped
+				}, 5*time.Second, 50*time.Millisecond)
+				return
+			}
+
+			assert.Eventually(t, func() bool {
+				return process.isReady() && process.cmd.Process.Pid != firstPid
+			}, 5*time.Second, 50*time.Millisecond)
+		})
+	}
+}
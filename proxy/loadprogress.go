@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// llama.cpp allocations, e.g. "load_tensors: CUDA0 model buffer size = 4403.49 MiB"
+	loadBufferPattern = regexp.MustCompile(`(\S+) (model|KV|compute) buffer size\s*=\s*([\d.]+) MiB`)
+
+	// vLLM, e.g. "Loading safetensors checkpoint shards:  50% Completed | 1/2"
+	loadShardsPattern = regexp.MustCompile(`Loading \S+ checkpoint shards:\s*(\d+)%`)
+)
+
+// LoadProgress is how far a model's command is with loading the model, read
+// from its output
+type LoadProgress struct {
+	Stage string `json:"stage"`
+
+	// of loading the weights, 100 once the model is loaded
+	Percent int `json:"percent"`
+
+	// memory allocated on each device
+	Buffers []LoadBuffer `json:"buffers,omitempty"`
+}
+
+type LoadBuffer struct {
+	Device string  `json:"device"`
+	Kind   string  `json:"kind"`
+	MiB    float64 `json:"mib"`
+}
+
+// loadProgressWriter parses the output of llama-server and vLLM while a
+// model is loaded. llama.cpp prints a dot for each percent of the weights
+// loaded, on a line of its own.
+type loadProgressWriter struct {
+	sync.Mutex
+	progress LoadProgress
+	line     []byte
+}
+
+func (w *loadProgressWriter) reset() {
+	w.Lock()
+	defer w.Unlock()
+	w.progress = LoadProgress{Stage: "starting"}
+	w.line = nil
+}
+
+// loaded ends the progress once the health check passed, for commands that
+// print none of the lines parsed
+func (w *loadProgressWriter) loaded() {
+	w.Lock()
+	defer w.Unlock()
+	w.progress.Stage = "loaded"
+	w.progress.Percent = 100
+}
+
+func (w *loadProgressWriter) snapshot() LoadProgress {
+	w.Lock()
+	defer w.Unlock()
+	progress := w.progress
+	progress.Buffers = append([]LoadBuffer(nil), w.progress.Buffers...)
+	return progress
+}
+
+func (w *loadProgressWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	// the progress is done once loaded, later output is not parsed
+	if w.progress.Stage == "loaded" {
+		return len(p), nil
+	}
+
+	data := append(w.line, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		w.parseLine(string(bytes.TrimRight(data[:i], "\r")))
+		data = data[i+1:]
+	}
+	w.line = append([]byte(nil), data...)
+
+	// the dots of the line being printed
+	if len(w.line) > 0 && strings.Trim(string(w.line), ".") == "" {
+		w.progress.Percent = min(len(w.line), 100)
+	}
+	return len(p), nil
+}
+
+func (w *loadProgressWriter) parseLine(line string) {
+	switch {
+	case line != "" && strings.Trim(line, ".") == "":
+		w.progress.Percent = min(len(line), 100)
+	case strings.Contains(line, "model loaded") || strings.Contains(line, "server is listening") ||
+		strings.Contains(line, "Application startup complete"):
+		w.progress.Stage = "loaded"
+		w.progress.Percent = 100
+	case strings.Contains(line, "warming up the model"):
+		w.progress.Stage = "warming up"
+	case strings.Contains(line, "load_tensors:") || strings.Contains(line, "llama_model_loader:") ||
+		strings.Contains(line, "Starting to load model"):
+		if w.progress.Stage != "warming up" {
+			w.progress.Stage = "loading"
+		}
+	}
+
+	if match := loadShardsPattern.FindStringSubmatch(line); match != nil {
+		w.progress.Stage = "loading"
+		w.progress.Percent, _ = strconv.Atoi(match[1])
+	}
+	if match := loadBufferPattern.FindStringSubmatch(line); match != nil {
+		mib, _ := strconv.ParseFloat(match[3], 64)
+		w.progress.Buffers = append(w.progress.Buffers, LoadBuffer{Device: match[1], Kind: match[2], MiB: mib})
+	}
+}
+
+// apiModelStatus returns the state of a model and the progress of its
+// last load for GET /api/models/<model>/status
+func (pm *ProxyManager) apiModelStatus(c *gin.Context, id string) {
+	// in modelcontrol.go
+	modelID, processes, found := pm.modelProcesses(id)
+	if !found {
+		pm.sendErrorResponse(c, http.StatusNotFound, "model not found")
+		return
+	}
+
+	status := gin.H{"model": modelID, "state": StateStopped}
+	for _, process := range processes {
+		state := process.stateNoWait()
+		if state == StateStopped && len(processes) > 1 {
+			continue
+		}
+		status["state"] = state
+		if progress := process.progress.snapshot(); progress.Stage != "" {
+			status["progress"] = progress
+		}
+		break
+	}
+	c.JSON(http.StatusOK, status)
+}
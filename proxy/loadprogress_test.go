@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadProgress_LlamaServer(t *testing.T) {
+	w := &loadProgressWriter{}
+	w.reset()
+
+	w.Write([]byte("main: loading model\nllama_model_loader: loaded meta data with 32 key-value pairs\n"))
+	assert.Equal(t, LoadProgress{Stage: "loading"}, w.snapshot())
+
+	w.Write([]byte("load_tensors: offloaded 29/29 layers to GPU\nload_tensors:        CUDA0 model buffer size =  4403.49 MiB\n"))
+	w.Write([]byte(strings.Repeat(".", 30)))
+	assert.Equal(t, 30, w.snapshot().Percent)
+	w.Write([]byte(strings.Repeat(".", 12)))
+	assert.Equal(t, 42, w.snapshot().Percent)
+	w.Write([]byte(strings.Repeat(".", 58) + "\n"))
+	assert.Equal(t, 100, w.snapshot().Percent)
+
+	w.Write([]byte("llama_kv_cache_unified:      CUDA0 KV buffer size =   896.00 MiB\n"))
+	w.Write([]byte("common_init_from_params: warming up the model with an empty run - please wait ... (--no-warmup to disable)\n"))
+	assert.Equal(t, "warming up", w.snapshot().Stage)
+
+	w.Write([]byte("main: model loaded\n"))
+	assert.Equal(t, LoadProgress{
+		Stage:   "loaded",
+		Percent: 100,
+		Buffers: []LoadBuffer{{Device: "CUDA0", Kind: "model", MiB: 4403.49}, {Device: "CUDA0", Kind: "KV", MiB: 896}},
+	}, w.snapshot())
+}
+
+func TestLoadProgress_VLLM(t *testing.T) {
+	w := &loadProgressWriter{}
+	w.reset()
+
+	w.Write([]byte("INFO 05-01 12:00:00 [gpu_model_runner.py:1329] Starting to load model Qwen/Qwen2.5-7B-Instruct...\n"))
+	w.Write([]byte("Loading safetensors checkpoint shards:  50% Completed | 2/4 [00:03<00:03,  1.52s/it]\n"))
+	assert.Equal(t, LoadProgress{Stage: "loading", Percent: 50}, w.snapshot())
+
+	w.Write([]byte("INFO:     Application startup complete.\n"))
+	assert.Equal(t, LoadProgress{Stage: "loaded", Percent: 100}, w.snapshot())
+}
+
+func TestProxyManager_ModelStatus(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": getTestSimpleResponderConfig("model1")},
+	})
+	defer proxy.StopProcesses()
+
+	status := func(model string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/models/"+model+"/status", nil))
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	code, body := status("model1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"model": "model1", "state": "stopped"}, body)
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model1"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	code, body = status("model1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", body["state"])
+	assert.Equal(t, map[string]interface{}{"stage": "loaded", "percent": float64(100)}, body["progress"])
+
+	code, _ = status("unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+}
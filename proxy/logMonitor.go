@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 )
 
 type LogMonitor struct {
@@ -15,6 +16,9 @@ type LogMonitor struct {
 
 	// typically this can be os.Stdout
 	stdout io.Writer
+
+	// lines kept on disk, see loghistory.go
+	history atomic.Pointer[logHistory]
 }
 
 func NewLogMonitor() *LogMonitor {
@@ -30,6 +34,25 @@ func NewLogMonitorWriter(stdout io.Writer) *LogMonitor {
 }
 
 func (w *LogMonitor) Write(p []byte) (n int, err error) {
+	return w.write("", p)
+}
+
+// ModelWriter writes the output of a model's cmd, its lines are kept in the
+// log history with the model
+func (w *LogMonitor) ModelWriter(model string) io.Writer {
+	return &modelLogWriter{monitor: w, model: model}
+}
+
+type modelLogWriter struct {
+	monitor *LogMonitor
+	model   string
+}
+
+func (m *modelLogWriter) Write(p []byte) (int, error) {
+	return m.monitor.write(m.model, p)
+}
+
+func (w *LogMonitor) write(model string, p []byte) (n int, err error) {
 	if len(p) == 0 {
 		return 0, nil
 	}
@@ -46,10 +69,21 @@ func (w *LogMonitor) Write(p []byte) (n int, err error) {
 	w.buffer = w.buffer.Next()
 	w.bufferMu.Unlock()
 
+	if history := w.history.Load(); history != nil {
+		history.write(model, bufferCopy)
+	}
+
 	w.broadcast(bufferCopy)
 	return n, nil
 }
 
+// setHistory replaces the log history, the previous one is closed
+func (w *LogMonitor) setHistory(history *logHistory) {
+	if previous := w.history.Swap(history); previous != nil {
+		previous.Close()
+	}
+}
+
 func (w *LogMonitor) GetHistory() []byte {
 	w.bufferMu.RLock()
 	defer w.bufferMu.RUnlock()
@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// default and largest number of lines returned by /api/logs/search
+const (
+	defaultLogSearchLimit = 1000
+	maxLogSearchLimit     = 10000
+)
+
+// longest unfinished line kept before it is written anyway
+const maxLogLineBytes = 64 * 1024
+
+type LogHistoryConfig struct {
+	// JSON lines file with the time and model of every log line
+	Path string `yaml:"path"`
+	// rotate the file at this size, 0 = never rotate
+	RotateMB int `yaml:"rotateMB"`
+	// number of rotated files to keep and search
+	Keep int `yaml:"keep"`
+}
+
+func (l LogHistoryConfig) validate() error {
+	if l.RotateMB < 0 || l.Keep < 0 {
+		return fmt.Errorf("logHistory: rotateMB and keep must not be negative")
+	}
+	return nil
+}
+
+// logRecord is a line of the log history
+type logRecord struct {
+	Time time.Time `json:"time"`
+	// the model whose cmd printed the line, empty for llama-swap's own lines
+	Model string `json:"model,omitempty"`
+	Line  string `json:"line"`
+}
+
+// logHistory writes the LogMonitor's lines to a file, they are searched by
+// /api/logs/search after they left the in-memory buffer
+type logHistory struct {
+	config LogHistoryConfig
+	file   *rotatingFile
+
+	mu sync.Mutex
+	// unfinished lines of each model
+	partial map[string][]byte
+	// only the first error is printed
+	failed bool
+	errOut io.Writer
+}
+
+// newLogHistory returns a history of config, the file is opened by the first
+// write. Errors are printed to errOut as writing them to the LogMonitor would
+// write to the history again.
+func newLogHistory(config LogHistoryConfig, errOut io.Writer) *logHistory {
+	return &logHistory{
+		config: config,
+		file: &rotatingFile{
+			path:     config.Path,
+			maxBytes: int64(config.RotateMB) * 1024 * 1024,
+			keep:     config.Keep,
+		},
+		partial: make(map[string][]byte),
+		errOut:  errOut,
+	}
+}
+
+// write adds the complete lines of p, the rest is kept until the next write
+// of the model
+func (h *logHistory) write(model string, p []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	pending := append(h.partial[model], p...)
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	for {
+		idx := bytes.IndexByte(pending, '\n')
+		if idx == -1 {
+			break
+		}
+		encoder.Encode(logRecord{Time: now, Model: model, Line: string(bytes.TrimRight(pending[:idx], "\r"))})
+		pending = pending[idx+1:]
+	}
+	if len(pending) > maxLogLineBytes {
+		encoder.Encode(logRecord{Time: now, Model: model, Line: string(pending)})
+		pending = nil
+	}
+	h.partial[model] = append([]byte(nil), pending...)
+
+	if out.Len() == 0 {
+		return
+	}
+	if _, err := h.file.Write(out.Bytes()); err != nil {
+		if !h.failed {
+			h.failed = true
+			fmt.Fprintf(h.errOut, "!!! Unable to write logHistory %s: %v\n", h.config.Path, err)
+		}
+	} else {
+		h.failed = false
+	}
+}
+
+func (h *logHistory) Close() error {
+	return h.file.Close()
+}
+
+// logQuery filters the log history, zero values match every line
+type logQuery struct {
+	pattern *regexp.Regexp
+	model   string
+	since   time.Time
+	limit   int
+}
+
+func (q logQuery) matches(record logRecord) bool {
+	if q.model != "" && record.Model != q.model {
+		return false
+	}
+	if !q.since.IsZero() && record.Time.Before(q.since) {
+		return false
+	}
+	return q.pattern == nil || q.pattern.MatchString(record.Line)
+}
+
+// search returns the last matching lines, oldest first. The rotated files
+// are read before the current one, those last written before since are
+// skipped.
+func (h *logHistory) search(q logQuery) ([]logRecord, error) {
+	limit := q.limit
+	if limit <= 0 {
+		limit = defaultLogSearchLimit
+	}
+
+	paths := make([]string, 0, h.config.Keep+1)
+	for i := h.config.Keep; i >= 1; i-- {
+		paths = append(paths, fmt.Sprintf("%s.%d", h.config.Path, i))
+	}
+	paths = append(paths, h.config.Path)
+
+	records := []logRecord{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !q.since.IsZero() && info.ModTime().Before(q.since) {
+			continue
+		}
+
+		if records, err = searchLogFile(path, q, records, limit); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// searchLogFile appends the matching lines of path to records, keeping the
+// last limit of them
+func searchLogFile(path string, q logQuery, records []logRecord, limit int) ([]logRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		// rotated while searching
+		return records, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 2*maxLogLineBytes)
+	for scanner.Scan() {
+		var record logRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil || !q.matches(record) {
+			continue
+		}
+		records = append(records, record)
+		if len(records) > 2*limit {
+			records = append(records[:0], records[len(records)-limit:]...)
+		}
+	}
+	if len(records) > limit {
+		records = append(records[:0], records[len(records)-limit:]...)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogHistory_WriteAndSearch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "history.log")
+	monitor := NewLogMonitorWriter(io.Discard)
+	monitor.setHistory(newLogHistory(LogHistoryConfig{Path: path}, io.Discard))
+
+	fmt.Fprintf(monitor, "!!! Starting model1\n")
+	model1 := monitor.ModelWriter("model1")
+	// lines split over writes are kept whole
+	model1.Write([]byte("slot 0: prompt "))
+	model1.Write([]byte("done\nerror: out of memory\n"))
+	monitor.ModelWriter("model2").Write([]byte("error: bad gguf\r\n"))
+
+	history := monitor.history.Load()
+	lines, err := history.search(logQuery{})
+	assert.NoError(t, err)
+	if assert.Len(t, lines, 4) {
+		assert.Equal(t, "!!! Starting model1", lines[0].Line)
+		assert.Empty(t, lines[0].Model)
+		assert.Equal(t, "slot 0: prompt done", lines[1].Line)
+		assert.Equal(t, "model1", lines[1].Model)
+		assert.Equal(t, "error: bad gguf", lines[3].Line)
+	}
+
+	lines, err = history.search(logQuery{pattern: regexp.MustCompile(`^error`), model: "model1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"error: out of memory"}, logLines(lines))
+
+	lines, err = history.search(logQuery{limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"error: out of memory", "error: bad gguf"}, logLines(lines))
+
+	lines, err = history.search(logQuery{since: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.Empty(t, lines)
+}
+
+func TestLogHistory_SearchRotatedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.log")
+	history := newLogHistory(LogHistoryConfig{Path: path, Keep: 2}, io.Discard)
+	// rotate after every line
+	history.file.maxBytes = 1
+
+	for i := 1; i <= 4; i++ {
+		history.write("", []byte(fmt.Sprintf("line %d\n", i)))
+	}
+	assert.FileExists(t, path+".2")
+	assert.NoFileExists(t, path+".3")
+
+	lines, err := history.search(logQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line 2", "line 3", "line 4"}, logLines(lines))
+}
+
+func TestProxyManager_SearchLogs(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{},
+	})
+	defer proxy.StopProcesses()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	assert.Equal(t, http.StatusNotFound, get("/api/logs/search").Code)
+
+	proxy.ReloadConfig(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{},
+		LogHistory:         LogHistoryConfig{Path: filepath.Join(t.TempDir(), "history.log")},
+	})
+	proxy.logMonitor.ModelWriter("model1").Write([]byte("error: out of memory\n"))
+
+	w := get("/api/logs/search?q=out+of&model=model1&since=1h")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Lines []logRecord `json:"lines"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{"error: out of memory"}, logLines(response.Lines))
+
+	assert.Equal(t, http.StatusBadRequest, get("/api/logs/search?q=(").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/api/logs/search?since=yesterday").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/api/logs/search?limit=0").Code)
+}
+
+func logLines(records []logRecord) []string {
+	lines := []string{}
+	for _, record := range records {
+		lines = append(lines, record.Line)
+	}
+	return lines
+}
@@ -0,0 +1,253 @@
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// replaced by the model's ID when the config is loaded
+	modelIDMacro = "${MODEL_ID}"
+
+	// replaced in cmd and env by a GPU index from the gpus pool when the
+	// model starts
+	gpuMacro = "${GPU_INDEX}"
+)
+
+var (
+	macroPattern     = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	macroNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// macros replaced when a model starts instead of when the config loads
+	runtimeMacros = map[string]bool{"PORT": true, "GPU_INDEX": true}
+)
+
+// validateMacros checks the names of the macros in config and models
+func (c *Config) validateMacros() error {
+	check := func(macros map[string]string) error {
+		for name := range macros {
+			if !macroNamePattern.MatchString(name) {
+				return fmt.Errorf("invalid macro name %q", name)
+			}
+			if runtimeMacros[name] || name == "MODEL_ID" {
+				return fmt.Errorf("macro %s is built in and can not be redefined", name)
+			}
+		}
+		return nil
+	}
+
+	if err := check(c.Macros); err != nil {
+		return fmt.Errorf("macros: %v", err)
+	}
+	for modelName, modelConfig := range c.Models {
+		if err := check(modelConfig.Macros); err != nil {
+			return fmt.Errorf("model %s: macros: %v", modelName, err)
+		}
+	}
+	return nil
+}
+
+// expandMacros replaces the config and model macros in the model's cmd,
+// proxy, checkCmd, checkEndpoint, env and logFile. Macros of the model
+// override those of the config. ${PORT} and ${GPU_INDEX} are left for the start.
+func (c *Config) expandMacros(modelName string, m ModelConfig) (ModelConfig, error) {
+	values := map[string]string{"MODEL_ID": modelName}
+	for name, value := range c.Macros {
+		values[name] = value
+	}
+	for name, value := range m.Macros {
+		values[name] = value
+	}
+	// values may use ${MODEL_ID}
+	for name, value := range values {
+		values[name] = strings.ReplaceAll(value, modelIDMacro, modelName)
+	}
+
+	var unknown []string
+	expand := func(s string) string {
+		return macroPattern.ReplaceAllStringFunc(s, func(macro string) string {
+			name := macro[2 : len(macro)-1]
+			if value, found := values[name]; found {
+				return value
+			}
+			if !runtimeMacros[name] {
+				unknown = append(unknown, macro)
+			}
+			return macro
+		})
+	}
+
+	m.Cmd = expand(m.Cmd)
+	m.Proxy = expand(m.Proxy)
+	m.CheckCmd = expand(m.CheckCmd)
+	m.CheckEndpoint = expand(m.CheckEndpoint)
+	m.LogFile = expand(m.LogFile)
+	if len(m.Env) > 0 {
+		env := make([]string, len(m.Env))
+		for i, entry := range m.Env {
+			env[i] = expand(entry)
+		}
+		m.Env = env
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return m, fmt.Errorf("unknown macro %s", unknown[0])
+	}
+	return m, nil
+}
+
+// usesGPUMacro is true when the model gets a GPU index when it starts
+func (m ModelConfig) usesGPUMacro() bool {
+	if strings.Contains(m.Cmd, gpuMacro) {
+		return true
+	}
+	for _, entry := range m.Env {
+		if strings.Contains(entry, gpuMacro) {
+			return true
+		}
+	}
+	return false
+}
+
+// gpuPool returns the pool of the configured GPU indexes
+func (c *Config) gpuPool() *valuePool {
+	if len(c.GPUs) == 0 {
+		return nil
+	}
+	return newValuePool(c.GPUs, fmt.Sprintf("GPU index in %v", c.GPUs), nil)
+}
+
+// valuePool hands out values, like ports or GPU indexes, that are not used
+// by other models. It goes round the values so a released one is not reused
+// at once. inUse optionally skips values taken outside of llama-swap.
+type valuePool struct {
+	sync.Mutex
+
+	values      []int
+	description string
+	inUse       func(int) bool
+	next        int
+	assigned    map[int]bool
+}
+
+func newValuePool(values []int, description string, inUse func(int) bool) *valuePool {
+	return &valuePool{
+		values:      values,
+		description: description,
+		inUse:       inUse,
+		assigned:    make(map[int]bool),
+	}
+}
+
+// acquire returns the next free value
+func (v *valuePool) acquire() (int, error) {
+	v.Lock()
+	defer v.Unlock()
+
+	for range v.values {
+		value := v.values[v.next]
+		v.next = (v.next + 1) % len(v.values)
+
+		if v.assigned[value] || (v.inUse != nil && v.inUse(value)) {
+			continue
+		}
+		v.assigned[value] = true
+		return value, nil
+	}
+	return 0, fmt.Errorf("no free %s", v.description)
+}
+
+func (v *valuePool) release(value int) {
+	v.Lock()
+	defer v.Unlock()
+	delete(v.assigned, value)
+}
+
+// assignMacros picks the port and GPU index for the ${PORT} and ${GPU_INDEX}
+// in the model's cmd, the state lock must be held
+func (p *Process) assignMacros() error {
+	if p.config.usesPortMacro() {
+		if p.ports == nil {
+			p.ports = defaultPorts
+		}
+		port, err := p.ports.acquire()
+		if err != nil {
+			return err
+		}
+		p.port.Store(int32(port))
+	}
+
+	if p.config.usesGPUMacro() {
+		if p.gpus == nil {
+			p.releaseMacros()
+			return fmt.Errorf("%s uses %s but no gpus are configured", p.ID, gpuMacro)
+		}
+		index, err := p.gpus.acquire()
+		if err != nil {
+			p.releaseMacros()
+			return err
+		}
+		p.gpu.Store(int32(index) + 1)
+	}
+	return nil
+}
+
+// releaseMacros returns the assigned port and GPU index, the state lock must
+// be held
+func (p *Process) releaseMacros() {
+	if port := p.port.Swap(0); port != 0 {
+		p.ports.release(int(port))
+	}
+	if gpu := p.gpu.Swap(0); gpu != 0 {
+		p.gpus.release(int(gpu) - 1)
+	}
+}
+
+// gpuIndex is the GPU index assigned at start, false when there is none
+func (p *Process) gpuIndex() (int, bool) {
+	gpu := p.gpu.Load()
+	return int(gpu) - 1, gpu != 0
+}
+
+// macroReplacer replaces the macros assigned when the process started
+func (p *Process) macroReplacer() *strings.Replacer {
+	var pairs []string
+	if port := p.port.Load(); port != 0 {
+		pairs = append(pairs, portMacro, strconv.Itoa(int(port)))
+	}
+	if index, found := p.gpuIndex(); found {
+		pairs = append(pairs, gpuMacro, strconv.Itoa(index))
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// commandArgs splits cmd and replaces the macros assigned at start
+func (p *Process) commandArgs(cmd string) ([]string, error) {
+	args, err := SanitizeCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	replacer := p.macroReplacer()
+	for i, arg := range args {
+		args[i] = replacer.Replace(arg)
+	}
+	return args, nil
+}
+
+// commandEnv is the model's env with the macros assigned at start
+func (p *Process) commandEnv() []string {
+	if p.config.Env == nil {
+		return nil
+	}
+	replacer := p.macroReplacer()
+	env := make([]string, len(p.config.Env))
+	for i, entry := range p.config.Env {
+		env[i] = replacer.Replace(entry)
+	}
+	return env
+}
@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Macros(t *testing.T) {
+	config, err := LoadConfigFromBytes([]byte(`
+macros:
+  MODEL_DIR: /models
+  SERVER: /opt/llama-server --model-alias ${MODEL_ID}
+models:
+  model1:
+    cmd: ${SERVER} -m ${MODEL_DIR}/model1.gguf
+    proxy: http://127.0.0.1:9001
+    env:
+      - HF_HOME=${MODEL_DIR}/hf
+  model2:
+    macros:
+      MODEL_DIR: /fast/${MODEL_ID}
+    cmd: ${SERVER} -m ${MODEL_DIR}/model.gguf --port ${PORT}
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "/opt/llama-server --model-alias model1 -m /models/model1.gguf", config.Models["model1"].Cmd)
+	assert.Equal(t, []string{"HF_HOME=/models/hf"}, config.Models["model1"].Env)
+	assert.Equal(t, "/opt/llama-server --model-alias model2 -m /fast/model2/model.gguf --port ${PORT}", config.Models["model2"].Cmd)
+
+	tests := []struct {
+		yaml string
+		err  string
+	}{
+		{"models:\n  model1:\n    cmd: server -m ${MODEL_DIR}/a.gguf\n", "model model1: unknown macro ${MODEL_DIR}"},
+		{"macros:\n  PORT: '8080'\nmodels: {}\n", "macros: macro PORT is built in"},
+		{"models:\n  model1:\n    cmd: server\n    macros:\n      bad-name: x\n", `model model1: macros: invalid macro name "bad-name"`},
+		{"models:\n  model1:\n    cmd: server --port 9000\n    env: ['CUDA_VISIBLE_DEVICES=${GPU_INDEX}']\n", "no gpus are configured"},
+	}
+	for _, tt := range tests {
+		_, err := LoadConfigFromBytes([]byte(tt.yaml))
+		assert.ErrorContains(t, err, tt.err)
+	}
+}
+
+func TestValuePool(t *testing.T) {
+	pool := newValuePool([]int{0, 1}, "GPU index in [0 1]", nil)
+
+	index, err := pool.acquire()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+
+	index, err = pool.acquire()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, index)
+
+	_, err = pool.acquire()
+	assert.ErrorContains(t, err, "no free GPU index in [0 1]")
+
+	pool.release(0)
+	index, err = pool.acquire()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+}
+
+func TestProcess_AssignsGPU(t *testing.T) {
+	config := getTestSimpleResponderConfig("model1")
+	config.Env = []string{"CUDA_VISIBLE_DEVICES=${GPU_INDEX}"}
+
+	process := NewProcess("model1", 15, config, NewLogMonitorWriter(io.Discard))
+	process.gpus = newValuePool([]int{3}, "GPU index in [3]", nil)
+
+	if !assert.NoError(t, process.start()) {
+		return
+	}
+	defer process.Stop()
+
+	index, found := process.gpuIndex()
+	assert.True(t, found)
+	assert.Equal(t, 3, index)
+	assert.Equal(t, []string{"CUDA_VISIBLE_DEVICES=3"}, process.commandEnv())
+
+	// a second model can not start while the only GPU is used
+	other := NewProcess("model2", 15, config, NewLogMonitorWriter(io.Discard))
+	other.gpus = process.gpus
+	assert.ErrorContains(t, other.start(), "no free GPU index")
+
+	process.Stop()
+	_, found = process.gpuIndex()
+	assert.False(t, found)
+	assert.Empty(t, process.gpus.assigned)
+}
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// modelPathAction splits /api/models/<model ID>/<action>, model IDs can have
+// slashes so it is not a gin route parameter
+func modelPathAction(c *gin.Context) (string, string, bool) {
+	path := strings.TrimPrefix(c.Param("path"), "/")
+	idx := strings.LastIndex(path, "/")
+	if idx <= 0 {
+		return "", "", false
+	}
+	return path[:idx], path[idx+1:], true
+}
+
+// apiGetModel handles GET /api/models/<model>/last-error, see lasterror.go,
+// and /api/models/<model>/status, see loadprogress.go
+func (pm *ProxyManager) apiGetModel(c *gin.Context) {
+	id, action, found := modelPathAction(c)
+	if !found {
+		pm.sendErrorResponse(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	switch action {
+	case "last-error":
+		pm.apiGetLastError(c, id)
+	case "status":
+		pm.apiModelStatus(c, id)
+	default:
+		pm.sendErrorResponse(c, http.StatusNotFound, "not found")
+	}
+}
+
+// apiModelAction loads or unloads a model for POST /api/models/<model>/load
+// and /api/models/<model>/unload. /pin and /unpin keep a running model
+// loaded, see pin.go.
+func (pm *ProxyManager) apiModelAction(c *gin.Context) {
+	id, action, found := modelPathAction(c)
+	if !found {
+		pm.sendErrorResponse(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	switch action {
+	case "load":
+		// swaps like a request for the model would
+		process, err := pm.swapModel(id)
+		if err != nil {
+			pm.sendSwapError(c, id, err)
+			return
+		}
+		if err := process.start(); err != nil {
+			pm.sendErrorResponse(c, http.StatusInternalServerError, "unable to start process: "+err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"model": process.ID, "state": process.CurrentState()})
+
+	case "unload":
+		modelID, processes, found := pm.modelProcesses(id)
+		if !found {
+			pm.sendErrorResponse(c, http.StatusNotFound, "model not found")
+			return
+		}
+		for _, process := range processes {
+			if process.CurrentState() != StateStopped {
+				process.Stop()
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"model": modelID, "state": StateStopped})
+
+	case "pin", "unpin":
+		timeout, err := parsePinTimeout(c.Query("timeout"))
+		if err != nil {
+			pm.sendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		modelID, processes, found := pm.modelProcesses(id)
+		if !found {
+			pm.sendErrorResponse(c, http.StatusNotFound, "model not found")
+			return
+		}
+
+		var running *Process
+		for _, process := range processes {
+			if state := process.CurrentState(); state == StateReady || state == StateSleeping {
+				running = process
+				break
+			}
+		}
+		if running == nil {
+			if action == "unpin" {
+				c.JSON(http.StatusOK, gin.H{"model": modelID, "state": StateStopped, "pinned": false})
+				return
+			}
+			pm.sendErrorResponse(c, http.StatusConflict, "model "+modelID+" is not running, load it first")
+			return
+		}
+
+		response := gin.H{"model": modelID, "state": running.CurrentState(), "pinned": action == "pin"}
+		if action == "unpin" {
+			running.unpin()
+		} else if pin := running.pin(timeout); !pin.until.IsZero() {
+			response["pinnedUntil"] = pin.until
+		}
+		c.JSON(http.StatusOK, response)
+
+	default:
+		pm.sendErrorResponse(c, http.StatusNotFound, "not found")
+	}
+}
+
+// modelProcesses returns the real name of the model and its processes
+func (pm *ProxyManager) modelProcesses(id string) (string, []*Process, bool) {
+	pm.Lock()
+	defer pm.Unlock()
+
+	modelID, found := pm.currentConfig().RealModelName(id)
+	var processes []*Process
+	for _, process := range pm.currentProcesses {
+		if process.ID == modelID {
+			processes = append(processes, process)
+		}
+	}
+	return modelID, processes, found
+}
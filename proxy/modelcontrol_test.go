@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyManager_LoadUnloadModel(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"org/model1": getTestSimpleResponderConfig("model1"),
+			"model2":     getTestSimpleResponderConfig("model2"),
+		},
+	})
+	defer proxy.StopProcesses()
+
+	post := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("POST", path, nil))
+		return w
+	}
+
+	w := post("/api/models/org/model1/load")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"model":"org/model1","state":"ready"}`, w.Body.String())
+	process := proxy.currentProcesses[ProcessKeyName("", "org/model1")]
+	assert.Equal(t, StateReady, process.CurrentState())
+
+	// loading another model swaps like a request
+	w = post("/api/models/model2/load")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, proxy.currentProcesses, ProcessKeyName("", "org/model1"))
+
+	w = post("/api/models/model2/unload")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"model":"model2","state":"stopped"}`, w.Body.String())
+	assert.Equal(t, StateStopped, proxy.currentProcesses[ProcessKeyName("", "model2")].CurrentState())
+
+	assert.Equal(t, http.StatusNotFound, post("/api/models/unknown/unload").Code)
+	assert.Equal(t, http.StatusNotFound, post("/api/models/model2/restart").Code)
+}
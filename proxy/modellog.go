@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// modelLog writes the output of a model's command to its logFile in addition
+// to the LogMonitor
+type modelLog struct {
+	file       *rotatingFile
+	logMonitor *LogMonitor
+
+	// only the first error is sent to the LogMonitor
+	failed atomic.Bool
+}
+
+func (m ModelConfig) validateLogFile() error {
+	if m.LogFileRotateMB < 0 || m.LogFileRotateHours < 0 || m.LogFileKeep < 0 {
+		return fmt.Errorf("logFileRotateMB, logFileRotateHours and logFileKeep must not be negative")
+	}
+	if m.LogFile == "" && (m.LogFileRotateMB > 0 || m.LogFileRotateHours > 0 || m.LogFileKeep > 0) {
+		return fmt.Errorf("logFile rotation is set without a logFile")
+	}
+	return nil
+}
+
+// newModelLog returns nil when the model has no logFile. The file is opened
+// by the first write.
+func newModelLog(config ModelConfig, logMonitor *LogMonitor) *modelLog {
+	if config.LogFile == "" {
+		return nil
+	}
+	return &modelLog{
+		file: &rotatingFile{
+			path:     config.LogFile,
+			maxBytes: int64(config.LogFileRotateMB) * 1024 * 1024,
+			maxAge:   time.Duration(config.LogFileRotateHours) * time.Hour,
+			keep:     config.LogFileKeep,
+		},
+		logMonitor: logMonitor,
+	}
+}
+
+// Write never fails, an error would stop the command's output from reaching
+// the LogMonitor
+func (l *modelLog) Write(p []byte) (int, error) {
+	if _, err := l.file.Write(p); err != nil {
+		if !l.failed.Swap(true) {
+			fmt.Fprintf(l.logMonitor, "!!! Unable to write logFile %s: %v\n", l.file.path, err)
+		}
+	} else {
+		l.failed.Store(false)
+	}
+	return len(p), nil
+}
+
+// Close closes the file until the next write
+func (l *modelLog) Close() error {
+	return l.file.Close()
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_LogFile(t *testing.T) {
+	dir := t.TempDir()
+	config, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    logFile: ` + dir + `/${MODEL_ID}.log
+`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, dir+"/model1.log", config.Models["model1"].LogFile)
+	}
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    logFileKeep: 2
+`))
+	assert.ErrorContains(t, err, "without a logFile")
+}
+
+func TestRotatingFile_MaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.log")
+	file := &rotatingFile{path: path, maxAge: time.Hour, keep: 1}
+
+	file.Write([]byte("old\n"))
+	file.Close()
+	file.opened = time.Now().Add(-2 * time.Hour)
+	file.Write([]byte("new\n"))
+
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "new\n", string(data))
+	data, _ = os.ReadFile(path + ".1")
+	assert.Equal(t, "old\n", string(data))
+}
+
+func TestProcess_LogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "model.log")
+	config := ModelConfig{
+		Cmd:     `sh -c 'echo "loading model"; echo "out of memory" >&2; exit 1'`,
+		Proxy:   "http://127.0.0.1:9",
+		LogFile: path,
+	}
+
+	process := NewProcess("model", 5, config, NewLogMonitorWriter(io.Discard))
+	assert.Error(t, process.start())
+
+	assert.Eventually(t, func() bool {
+		data, _ := os.ReadFile(path)
+		return string(data) == "loading model\nout of memory\n"
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,357 @@
+package proxy
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gin context key for the claims of a validated bearer token
+const ctxKeyClaims = "llama-swap.claims"
+
+const (
+	// allowed clock difference for exp and nbf
+	jwtLeeway = time.Minute
+
+	// shortest time between fetches of the JWKS for unknown key IDs
+	jwksRefreshInterval = time.Minute
+)
+
+// claimsContextKey stores the claims in the request context for routing
+type claimsContextKey struct{}
+
+type AuthConfig struct {
+	OIDC OIDCConfig `yaml:"oidc"`
+}
+
+// OIDCConfig validates bearer tokens as JWTs signed by the issuer
+type OIDCConfig struct {
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+
+	// default: the jwks_uri of the issuer's /.well-known/openid-configuration
+	JWKSURL string `yaml:"jwksUrl"`
+}
+
+func (o OIDCConfig) Enabled() bool {
+	return o.Issuer != ""
+}
+
+func (o OIDCConfig) validate() error {
+	if !o.Enabled() {
+		if o.Audience != "" || o.JWKSURL != "" {
+			return fmt.Errorf("auth.oidc requires an issuer")
+		}
+		return nil
+	}
+	if o.Audience == "" {
+		return fmt.Errorf("auth.oidc requires an audience")
+	}
+	return nil
+}
+
+// jwtClaims are the claims of a validated token
+type jwtClaims map[string]interface{}
+
+// has is true when the claim is value or a list containing value
+func (c jwtClaims) has(name, value string) bool {
+	switch v := c[name].(type) {
+	case string:
+		return v == value
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func requestClaims(r *http.Request) jwtClaims {
+	claims, _ := r.Context().Value(claimsContextKey{}).(jwtClaims)
+	return claims
+}
+
+// oidcValidator checks tokens with the issuer's signing keys, which are
+// fetched on first use and again when a token has an unknown key ID
+type oidcValidator struct {
+	config OIDCConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	lastFetched time.Time
+}
+
+// oidcValidator returns nil when auth.oidc is not set
+func (c *Config) oidcValidator() *oidcValidator {
+	if !c.Auth.OIDC.Enabled() {
+		return nil
+	}
+	return newOIDCValidator(c.Auth.OIDC)
+}
+
+func newOIDCValidator(config OIDCConfig) *oidcValidator {
+	return &oidcValidator{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// oidcMiddleware rejects requests without a valid bearer token. Requests with
+// an admin key and /health are always allowed.
+func (pm *ProxyManager) oidcMiddleware(c *gin.Context) {
+	validator := pm.oidc.Load()
+	if validator == nil || c.Request.URL.Path == "/health" || pm.isAdminRequest(c) {
+		c.Next()
+		return
+	}
+
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found || token == "" {
+		c.Header("WWW-Authenticate", `Bearer`)
+		pm.sendErrorResponse(c, http.StatusUnauthorized, "a bearer token is required")
+		c.Abort()
+		return
+	}
+
+	claims, err := validator.validate(c.Request.Context(), token)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		pm.sendErrorResponse(c, http.StatusUnauthorized, fmt.Sprintf("invalid bearer token: %v", err))
+		c.Abort()
+		return
+	}
+
+	c.Set(ctxKeyClaims, claims)
+	if sub, ok := claims["sub"].(string); ok && sub != "" {
+		c.Set(ctxKeyTenant, sub)
+	}
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), claimsContextKey{}, claims))
+	c.Next()
+}
+
+// validate checks the signature, issuer, audience and lifetime of token
+func (v *oidcValidator) validate(ctx context.Context, token string) (jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature")
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims")
+	}
+
+	if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !claims.has("aud", v.config.Audience) {
+		return nil, fmt.Errorf("token is not for audience %s", v.config.Audience)
+	}
+
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("token is not valid yet")
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	// RS256, PS384, ES512, ...
+	var hash crypto.Hash
+	if len(alg) == 5 {
+		switch alg[2:] {
+		case "256":
+			hash = crypto.SHA256
+		case "384":
+			hash = crypto.SHA384
+		case "512":
+			hash = crypto.SHA512
+		}
+	}
+	if hash == 0 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil {
+			return nil
+		}
+		if strings.HasPrefix(alg, "PS") && rsa.VerifyPSS(key, hash, digest, signature, nil) == nil {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if strings.HasPrefix(alg, "ES") && len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(key, digest, r, s) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("invalid signature")
+}
+
+// key returns the signing key with the ID kid
+func (v *oidcValidator) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, found := v.keys[kid]; found {
+		return key, nil
+	}
+	if time.Since(v.lastFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch signing keys: %v", err)
+	}
+	v.keys = keys
+	v.lastFetched = time.Now()
+
+	if key, found := v.keys[kid]; found {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (v *oidcValidator) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	jwksURL := v.config.JWKSURL
+	if jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(ctx, strings.TrimSuffix(v.config.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, err
+		}
+		if discovery.JWKSURI == "" {
+			return nil, fmt.Errorf("the issuer has no jwks_uri")
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(ctx, jwksURL, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch jwk.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch jwk.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	return keys, nil
+}
+
+func (v *oidcValidator) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testIssuer serves the discovery document and JWKS of an RSA and an EC key
+type testIssuer struct {
+	server *httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &testIssuer{rsaKey: rsaKey, ecKey: ecKey}
+	encode := base64.RawURLEncoding.EncodeToString
+	issuer.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"jwks_uri": issuer.server.URL + "/keys"})
+		case "/keys":
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+				{"kid": "rsa", "kty": "RSA", "use": "sig", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+				{"kid": "ec", "kty": "EC", "crv": "P-256", "x": encode(ecKey.X.FillBytes(make([]byte, 32))), "y": encode(ecKey.Y.FillBytes(make([]byte, 32)))},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return issuer
+}
+
+// token signs claims with the RSA key, or the EC key when kid is "ec"
+func (i *testIssuer) token(t *testing.T, kid string, claims map[string]interface{}) string {
+	alg := "RS256"
+	if kid == "ec" {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	if kid == "ec" {
+		r, s, err := ecdsa.Sign(rand.Reader, i.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (i *testIssuer) claims(changes map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss":    i.server.URL,
+		"aud":    []string{"llama-swap"},
+		"sub":    "alice",
+		"groups": []string{"research"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range changes {
+		claims[name] = value
+	}
+	return claims
+}
+
+func TestOIDCValidator(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.server.Close()
+
+	validator := newOIDCValidator(OIDCConfig{Issuer: issuer.server.URL, Audience: "llama-swap"})
+
+	for _, kid := range []string{"rsa", "ec"} {
+		claims, err := validator.validate(context.Background(), issuer.token(t, kid, issuer.claims(nil)))
+		if assert.NoError(t, err, kid) {
+			assert.Equal(t, "alice", claims["sub"])
+			assert.True(t, claims.has("groups", "research"))
+		}
+	}
+
+	tests := []struct {
+		token string
+		err   string
+	}{
+		{issuer.token(t, "rsa", issuer.claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})), "token expired"},
+		{issuer.token(t, "rsa", issuer.claims(map[string]interface{}{"aud": "other"})), "not for audience llama-swap"},
+		{issuer.token(t, "rsa", issuer.claims(map[string]interface{}{"iss": "https://evil.example"})), "unexpected issuer"},
+		{issuer.token(t, "unknown", issuer.claims(nil)), "unknown signing key"},
+		{"not-a-token", "malformed token"},
+	}
+	for _, tt := range tests {
+		_, err := validator.validate(context.Background(), tt.token)
+		assert.ErrorContains(t, err, tt.err)
+	}
+
+	// a changed payload does not match the signature
+	token := issuer.token(t, "rsa", issuer.claims(nil))
+	parts := strings.Split(token, ".")
+	forged, _ := json.Marshal(issuer.claims(map[string]interface{}{"sub": "mallory"}))
+	parts[1] = base64.RawURLEncoding.EncodeToString(forged)
+	_, err := validator.validate(context.Background(), strings.Join(parts, "."))
+	assert.ErrorContains(t, err, "invalid signature")
+}
+
+func TestProxyManager_OIDC(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.server.Close()
+
+	model1 := getTestSimpleResponderConfig("model1")
+	model2 := getTestSimpleResponderConfig("model2")
+	config, err := LoadConfigFromBytes([]byte(`
+adminKeys: [admin-secret]
+auth:
+  oidc:
+    issuer: ` + issuer.server.URL + `
+    audience: llama-swap
+    jwksUrl: ` + issuer.server.URL + `/keys
+routing:
+  - claims:
+      groups: research
+    model: model2
+    override: true
+models:
+  model1:
+    cmd: ` + model1.Cmd + `
+    proxy: ` + model1.Proxy + `
+  model2:
+    cmd: ` + model2.Cmd + `
+    proxy: ` + model2.Proxy + `
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	request := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model1"}`))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		return w
+	}
+
+	w := request("")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+
+	w = request("invalid.token.here")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// the groups claim routes to model2
+	w = request(issuer.token(t, "rsa", issuer.claims(nil)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "model2", w.Body.String())
+
+	w = request(issuer.token(t, "rsa", issuer.claims(map[string]interface{}{"groups": []string{"sales"}})))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "model1", w.Body.String())
+
+	// admin keys are not JWTs
+	w = request("admin-secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// the lock is held during swaps, requests must not wait for it
+	proxy.Lock()
+	done := make(chan int, 1)
+	go func() {
+		req := httptest.NewRequest("GET", "/v1/models", nil)
+		req.Header.Set("Authorization", "Bearer "+issuer.token(t, "rsa", issuer.claims(nil)))
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		done <- w.Code
+	}()
+	select {
+	case code := <-done:
+		assert.Equal(t, http.StatusOK, code)
+	case <-time.After(5 * time.Second):
+		t.Error("request waited for the lock")
+	}
+	proxy.Unlock()
+
+	_, err = LoadConfigFromBytes([]byte("auth:\n  oidc:\n    issuer: https://sso.example\nmodels: {}\n"))
+	assert.ErrorContains(t, err, "auth.oidc requires an audience")
+}
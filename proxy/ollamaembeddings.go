@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ollama endpoints proxied to models like the OpenAI endpoints, unlike the
+// other /api/ endpoints they are not management endpoints
+var ollamaInferencePaths = []string{"/api/embed", "/api/embeddings"}
+
+func isOllamaInferencePath(path string) bool {
+	return slices.Contains(ollamaInferencePaths, path)
+}
+
+// Ollama request fields passed on to /v1/embeddings, keep_alive sets the ttl
+// like on the OpenAI endpoints, see ttl.go
+var ollamaEmbedFields = []string{"keep_alive", "dimensions"}
+
+// apiOllamaEmbed serves Ollama's /api/embed, with an input string or array,
+// and the older /api/embeddings, with a single prompt, as a /v1/embeddings
+// request and translates the response back
+func (pm *ProxyManager) apiOllamaEmbed(c *gin.Context) {
+	legacy := c.Request.URL.Path == "/api/embeddings"
+
+	var request map[string]interface{}
+	if err := json.NewDecoder(c.Request.Body).Decode(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %s", err.Error())})
+		return
+	}
+
+	body := map[string]interface{}{"model": request["model"]}
+	if legacy {
+		prompt, _ := request["prompt"].(string)
+		body["input"] = prompt
+	} else if input, found := request["input"]; found {
+		body["input"] = input
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing input"})
+		return
+	}
+	for _, field := range ollamaEmbedFields {
+		if value, found := request[field]; found {
+			body[field] = value
+		}
+	}
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error encoding JSON %s", err.Error())})
+		return
+	}
+	c.Request.URL.Path = "/v1/embeddings"
+	c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	c.Request.ContentLength = int64(len(bodyBytes))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("content-length", strconv.Itoa(len(bodyBytes)))
+
+	start := time.Now()
+	response := &ollamaResponseWriter{ResponseWriter: c.Writer, header: make(http.Header)}
+	c.Writer = response
+	pm.proxyOAIHandler(c)
+	c.Writer = response.ResponseWriter
+
+	if response.Status() != http.StatusOK {
+		c.JSON(response.Status(), gin.H{"error": openAIErrorMessage(response.body.Bytes())})
+		return
+	}
+
+	var result struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+			Index     int       `json:"index"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(response.body.Bytes(), &result); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("invalid embeddings response: %v", err)})
+		return
+	}
+	sort.SliceStable(result.Data, func(i, j int) bool {
+		return result.Data[i].Index < result.Data[j].Index
+	})
+
+	if legacy {
+		embedding := []float64{}
+		if len(result.Data) > 0 {
+			embedding = result.Data[0].Embedding
+		}
+		c.JSON(http.StatusOK, gin.H{"embedding": embedding})
+		return
+	}
+
+	embeddings := make([][]float64, len(result.Data))
+	for i, item := range result.Data {
+		embeddings[i] = item.Embedding
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"model":             request["model"],
+		"embeddings":        embeddings,
+		"total_duration":    time.Since(start).Nanoseconds(),
+		"prompt_eval_count": result.Usage.PromptTokens,
+	})
+}
+
+// openAIErrorMessage returns the message of an OpenAI or llama-swap error
+// response, or the body when it has none
+func openAIErrorMessage(body []byte) string {
+	var response struct {
+		Error interface{} `json:"error"`
+	}
+	if json.Unmarshal(body, &response) == nil {
+		switch e := response.Error.(type) {
+		case string:
+			return e
+		case map[string]interface{}:
+			if message, ok := e["message"].(string); ok {
+				return message
+			}
+		}
+	}
+	return string(bytes.TrimSpace(body))
+}
+
+// ollamaResponseWriter keeps the response of the OpenAI endpoint to
+// translate it. Informational responses, like the keep-alives of
+// keepalive.go, still go to the client through Unwrap.
+type ollamaResponseWriter struct {
+	gin.ResponseWriter
+	header  http.Header
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func (w *ollamaResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *ollamaResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *ollamaResponseWriter) WriteHeader(statusCode int) {
+	if statusCode >= http.StatusOK && !w.written {
+		w.status = statusCode
+	}
+}
+
+func (w *ollamaResponseWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *ollamaResponseWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.body.Write(b)
+}
+
+func (w *ollamaResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *ollamaResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *ollamaResponseWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *ollamaResponseWriter) Written() bool {
+	return w.written
+}
+
+// Flush does nothing, the response is sent once it is translated
+func (w *ollamaResponseWriter) Flush() {}
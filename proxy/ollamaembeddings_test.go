@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyManager_OllamaEmbed(t *testing.T) {
+	requests := make(chan map[string]interface{}, 10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests <- body
+
+		var inputs []interface{}
+		switch input := body["input"].(type) {
+		case string:
+			inputs = []interface{}{input}
+		case []interface{}:
+			inputs = input
+		}
+		var data []map[string]interface{}
+		for i, input := range inputs {
+			if input == "fail" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"code":400,"message":"input is too long","type":"invalid_request_error"}}`))
+				return
+			}
+			data = append(data, map[string]interface{}{"object": "embedding", "index": i, "embedding": []float64{float64(len(input.(string))), 0.5}})
+		}
+		// out of order like some servers send them
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"object": "list",
+			"model":  "embed",
+			"data":   data,
+			"usage":  map[string]int{"prompt_tokens": 7, "total_tokens": 7},
+		})
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("embed")
+	modelConfig.Proxy = upstream.URL
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"embed": modelConfig},
+	})
+	defer proxy.StopProcesses()
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/api/embed", strings.NewReader(`{"model":"embed","input":["a","bbb"],"keep_alive":"10m","truncate":true}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var embed struct {
+		Model           string      `json:"model"`
+		Embeddings      [][]float64 `json:"embeddings"`
+		PromptEvalCount int         `json:"prompt_eval_count"`
+		TotalDuration   int64       `json:"total_duration"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &embed))
+	assert.Equal(t, "embed", embed.Model)
+	assert.Equal(t, [][]float64{{1, 0.5}, {3, 0.5}}, embed.Embeddings)
+	assert.Equal(t, 7, embed.PromptEvalCount)
+	assert.Positive(t, embed.TotalDuration)
+	// keep_alive sets the ttl and is not sent upstream, see ttl.go
+	assert.Equal(t, map[string]interface{}{"model": "embed", "input": []interface{}{"a", "bbb"}}, <-requests)
+
+	// a single input string
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/api/embed", strings.NewReader(`{"model":"embed","input":"cc"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &embed))
+	assert.Equal(t, [][]float64{{2, 0.5}}, embed.Embeddings)
+
+	// the older endpoint with a prompt
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/api/embeddings", strings.NewReader(`{"model":"embed","prompt":"dddd"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"embedding":[4,0.5]}`, w.Body.String())
+
+	// errors in Ollama's format
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/api/embed", strings.NewReader(`{"model":"embed","input":["fail"]}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"input is too long"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/api/embed", strings.NewReader(`{"model":"embed"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"missing input"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/api/embed", strings.NewReader(`{"model":"missing","input":"a"}`)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "does not exist")
+}
+
+func TestOllamaInferencePaths(t *testing.T) {
+	assert.False(t, isManagementPath("/api/embed"))
+	assert.False(t, isManagementPath("/api/embeddings"))
+	assert.True(t, isManagementPath("/api/create"))
+}
@@ -0,0 +1,307 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// metadata of models added with /api/create, only those can be deleted
+	ollamaManagedKey   = "managedBy"
+	ollamaManagedValue = "ollama-api"
+
+	// cmd of models added with /api/create when ollamaCreateCmd is not set
+	defaultOllamaCreateCmd = "llama-server --port " + portMacro
+)
+
+// llama-server flags of Modelfile PARAMETER names
+var ollamaParameterFlags = map[string]string{
+	"num_ctx":           "--ctx-size",
+	"num_batch":         "--batch-size",
+	"num_gpu":           "--n-gpu-layers",
+	"num_thread":        "--threads",
+	"num_predict":       "--n-predict",
+	"temperature":       "--temp",
+	"top_k":             "--top-k",
+	"top_p":             "--top-p",
+	"min_p":             "--min-p",
+	"typical_p":         "--typical",
+	"repeat_penalty":    "--repeat-penalty",
+	"repeat_last_n":     "--repeat-last-n",
+	"presence_penalty":  "--presence-penalty",
+	"frequency_penalty": "--frequency-penalty",
+	"mirostat":          "--mirostat",
+	"mirostat_eta":      "--mirostat-lr",
+	"mirostat_tau":      "--mirostat-ent",
+	"seed":              "--seed",
+}
+
+type ollamaCreateRequest struct {
+	Model string `json:"model"`
+	// older clients send name instead of model
+	Name string `json:"name"`
+
+	// FROM and PARAMETER lines
+	Modelfile string `json:"modelfile"`
+
+	// or the same as fields
+	From       string                 `json:"from"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// createdModelCmd builds the cmd of a model from a Modelfile: the .gguf file
+// of FROM and the PARAMETER lines as llama-server flags
+func createdModelCmd(baseCmd string, request ollamaCreateRequest) (string, error) {
+	from := request.From
+	parameters := make(map[string]string)
+	for name, value := range request.Parameters {
+		parameters[name] = fmt.Sprint(value)
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(request.Modelfile))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		instruction, args, _ := strings.Cut(line, " ")
+		args = strings.TrimSpace(args)
+		switch strings.ToUpper(instruction) {
+		case "FROM":
+			from = args
+		case "PARAMETER":
+			name, value, found := strings.Cut(args, " ")
+			if !found {
+				return "", fmt.Errorf("PARAMETER %s has no value", name)
+			}
+			parameters[name] = strings.TrimSpace(value)
+		default:
+			return "", fmt.Errorf("unsupported Modelfile instruction %s", instruction)
+		}
+	}
+
+	if from == "" {
+		return "", fmt.Errorf("missing FROM")
+	}
+	if !filepath.IsAbs(from) || filepath.Ext(from) != ".gguf" {
+		return "", fmt.Errorf("FROM must be the absolute path of a .gguf file")
+	}
+
+	args := []string{baseCmd, "-m", quoteArg(from)}
+
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flag, found := ollamaParameterFlags[name]
+		if !found {
+			return "", fmt.Errorf("unsupported PARAMETER %s", name)
+		}
+		args = append(args, flag, quoteArg(parameters[name]))
+	}
+	return strings.Join(args, " "), nil
+}
+
+// quoteArg quotes a cmd argument with spaces or quotes for shlex
+func quoteArg(arg string) string {
+	if !strings.ContainsAny(arg, " \t'\"\\") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
+
+func isOllamaManaged(model interface{}) bool {
+	m, _ := model.(map[string]interface{})
+	metadata, _ := m["metadata"].(map[string]interface{})
+	return metadata[ollamaManagedKey] == ollamaManagedValue
+}
+
+func modelAliases(model interface{}) []interface{} {
+	m, _ := model.(map[string]interface{})
+	aliases, _ := m["aliases"].([]interface{})
+	return aliases
+}
+
+// ollamaNameInUse is true when requests for name already go to a model, an
+// alias, a virtual model or an A/B test
+func ollamaNameInUse(config *Config, models map[string]interface{}, name string) bool {
+	if _, found := config.VirtualModels[name]; found {
+		return true
+	}
+	if _, found := config.ABTests[name]; found {
+		return true
+	}
+	for id, model := range models {
+		if id == name || slices.Contains(modelAliases(model), interface{}(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// editOllamaModels reads the models of the config file, lets edit change the
+// file data and writes and reloads it. With ?dryRun=true the change to model
+// id is only previewed, see sendConfigPreview.
+func (pm *ProxyManager) editOllamaModels(c *gin.Context, id string, edit func(data []byte, models map[string]interface{}) ([]byte, int, error)) {
+	pm.configEditMutex.Lock()
+	defer pm.configEditMutex.Unlock()
+
+	configPath := pm.currentConfigPath()
+	if configPath == "" {
+		pm.sendErrorResponse(c, http.StatusNotFound, "config file path not set")
+		return
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	models, err := ConfigModels(data)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	edited, status, err := edit(data, models)
+	if err != nil {
+		pm.sendErrorResponse(c, status, err.Error())
+		return
+	}
+
+	if isDryRun(c) {
+		pm.sendConfigPreview(c, configPath, data, edited, id)
+		return
+	}
+	if pm.writeAndReloadConfig(c, configPath, edited) {
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	}
+}
+
+// apiOllamaCreate adds a model from a Modelfile, or replaces one added before
+func (pm *ProxyManager) apiOllamaCreate(c *gin.Context) {
+	var request ollamaCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %s", err.Error()))
+		return
+	}
+	if request.Model == "" {
+		request.Model = request.Name
+	}
+	if request.Model == "" {
+		pm.sendErrorResponse(c, http.StatusBadRequest, "missing model")
+		return
+	}
+
+	baseCmd := pm.currentConfig().OllamaCreateCmd
+	if baseCmd == "" {
+		baseCmd = defaultOllamaCreateCmd
+	}
+	cmd, err := createdModelCmd(baseCmd, request)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	pm.editOllamaModels(c, request.Model, func(data []byte, models map[string]interface{}) ([]byte, int, error) {
+		if existing, found := models[request.Model]; found && !isOllamaManaged(existing) {
+			return nil, http.StatusConflict, fmt.Errorf("model %s exists and was not created with /api/create", request.Model)
+		} else if !found && ollamaNameInUse(pm.currentConfig(), models, request.Model) {
+			return nil, http.StatusConflict, fmt.Errorf("%s is already used by another model", request.Model)
+		}
+
+		data, _, err := SetConfigModel(data, request.Model, map[string]interface{}{
+			"cmd":      cmd,
+			"metadata": map[string]string{ollamaManagedKey: ollamaManagedValue},
+		})
+		return data, http.StatusInternalServerError, err
+	})
+}
+
+// apiOllamaCopy adds destination as an alias of a model added with
+// /api/create
+func (pm *ProxyManager) apiOllamaCopy(c *gin.Context) {
+	var request struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %s", err.Error()))
+		return
+	}
+	if request.Source == "" || request.Destination == "" {
+		pm.sendErrorResponse(c, http.StatusBadRequest, "source and destination are required")
+		return
+	}
+
+	pm.editOllamaModels(c, request.Source, func(data []byte, models map[string]interface{}) ([]byte, int, error) {
+		config := pm.currentConfig()
+		source, found := config.RealModelName(request.Source)
+		model, inFile := models[source].(map[string]interface{})
+		if !found || !inFile {
+			return nil, http.StatusNotFound, fmt.Errorf("model %s not found", request.Source)
+		}
+		if !isOllamaManaged(model) {
+			return nil, http.StatusForbidden, fmt.Errorf("model %s was not created with /api/create", request.Source)
+		}
+		if ollamaNameInUse(config, models, request.Destination) {
+			return nil, http.StatusConflict, fmt.Errorf("%s is already used by another model", request.Destination)
+		}
+
+		model["aliases"] = append(modelAliases(model), request.Destination)
+		data, _, err := SetConfigModel(data, source, model)
+		return data, http.StatusInternalServerError, err
+	})
+}
+
+// apiOllamaDelete removes a model added with /api/create or an alias of one
+func (pm *ProxyManager) apiOllamaDelete(c *gin.Context) {
+	var request struct {
+		Model string `json:"model"`
+		Name  string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %s", err.Error()))
+		return
+	}
+	if request.Model == "" {
+		request.Model = request.Name
+	}
+
+	pm.editOllamaModels(c, request.Model, func(data []byte, models map[string]interface{}) ([]byte, int, error) {
+		if model, found := models[request.Model]; found {
+			if !isOllamaManaged(model) {
+				return nil, http.StatusForbidden, fmt.Errorf("model %s was not created with /api/create", request.Model)
+			}
+			data, err := DeleteConfigModel(data, request.Model)
+			return data, http.StatusInternalServerError, err
+		}
+
+		for id, model := range models {
+			aliases := modelAliases(model)
+			index := slices.Index(aliases, interface{}(request.Model))
+			if index < 0 {
+				continue
+			}
+			if !isOllamaManaged(model) {
+				return nil, http.StatusForbidden, fmt.Errorf("%s is an alias of model %s, which was not created with /api/create", request.Model, id)
+			}
+			m := model.(map[string]interface{})
+			m["aliases"] = slices.Delete(aliases, index, index+1)
+			data, _, err := SetConfigModel(data, id, m)
+			return data, http.StatusInternalServerError, err
+		}
+		return nil, http.StatusNotFound, fmt.Errorf("model %s not found", request.Model)
+	})
+}
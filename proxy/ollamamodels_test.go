@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatedModelCmd(t *testing.T) {
+	cmd, err := createdModelCmd(defaultOllamaCreateCmd, ollamaCreateRequest{
+		Modelfile: "# my model\nFROM /models/my model.gguf\nPARAMETER temperature 0.7\nPARAMETER num_ctx 8192\n",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "llama-server --port ${PORT} -m '/models/my model.gguf' --ctx-size 8192 --temp 0.7", cmd)
+
+	cmd, err = createdModelCmd("/opt/llama-server --port ${PORT}", ollamaCreateRequest{
+		From:       "/models/qwen.gguf",
+		Parameters: map[string]interface{}{"top_k": float64(40)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "/opt/llama-server --port ${PORT} -m /models/qwen.gguf --top-k 40", cmd)
+
+	tests := []struct {
+		modelfile string
+		err       string
+	}{
+		{"PARAMETER top_k 40", "missing FROM"},
+		{"FROM llama3.2", "absolute path of a .gguf file"},
+		{"FROM /models/a.gguf\nPARAMETER stop <end>", "unsupported PARAMETER stop"},
+		{"FROM /models/a.gguf\nSYSTEM you are a pirate", "unsupported Modelfile instruction SYSTEM"},
+	}
+	for _, tt := range tests {
+		_, err := createdModelCmd(defaultOllamaCreateCmd, ollamaCreateRequest{Modelfile: tt.modelfile})
+		assert.ErrorContains(t, err, tt.err)
+	}
+}
+
+func TestProxyManager_OllamaModelManagement(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`adminKeys: [admin-key]
+virtualModels:
+  a-fast:
+    baseModel: a
+models:
+  a:
+    cmd: path/to/cmd
+    proxy: http://localhost:8080
+    aliases: [a-alias]
+`), 0644))
+
+	config, err := LoadConfig(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	proxy := New(config)
+	proxy.SetConfigPath(path)
+	defer proxy.StopProcesses()
+
+	request := func(method, target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		return w
+	}
+
+	createBody := `{"model":"mario","modelfile":"FROM /models/mario.gguf\nPARAMETER num_ctx 4096"}`
+	assert.Equal(t, http.StatusForbidden, request("POST", "/api/create", "", createBody).Code)
+
+	w := request("POST", "/api/create?dryRun=true", "admin-key", createBody)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"cmd":"llama-server --port ${PORT} -m /models/mario.gguf --ctx-size 4096"`)
+	assert.Contains(t, w.Body.String(), `+  mario:`)
+	assert.NotContains(t, proxy.currentConfig().Models, "mario")
+
+	w = request("POST", "/api/create", "admin-key", createBody)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"success"}`, w.Body.String())
+	assert.Equal(t, "llama-server --port ${PORT} -m /models/mario.gguf --ctx-size 4096", proxy.currentConfig().Models["mario"].Cmd)
+
+	// models from the config file are not replaced or deleted
+	w = request("POST", "/api/create", "admin-key", `{"model":"a","from":"/models/a.gguf"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = request("DELETE", "/api/delete", "admin-key", `{"model":"a"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request("DELETE", "/api/delete", "admin-key", `{"model":"a-alias"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = request("POST", "/api/copy", "admin-key", `{"source":"a","destination":"a-copy"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = request("POST", "/api/copy", "admin-key", `{"source":"mario","destination":"luigi"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"luigi"}, proxy.currentConfig().Models["mario"].Aliases)
+
+	// names that already route somewhere are not taken over
+	for _, name := range []string{"a", "luigi", "a-fast"} {
+		w = request("POST", "/api/copy", "admin-key", `{"source":"mario","destination":"`+name+`"}`)
+		assert.Equal(t, http.StatusConflict, w.Code, name)
+	}
+	w = request("POST", "/api/create", "admin-key", `{"model":"a-fast","from":"/models/a.gguf"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = request("DELETE", "/api/delete", "admin-key", `{"model":"luigi"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, proxy.currentConfig().Models["mario"].Aliases)
+
+	w = request("DELETE", "/api/delete", "admin-key", `{"model":"mario"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, proxy.currentConfig().Models, "mario")
+
+	w = request("DELETE", "/api/delete", "admin-key", `{"model":"mario"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
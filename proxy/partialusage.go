@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+)
+
+// start of an SSE chunk with a JSON object
+var sseChunkStart = []byte(`data: {`)
+
+// countChunks counts the SSE chunks in b, including a chunk start split over
+// the previous write
+func (w *tailCaptureWriter) countChunks(b []byte) {
+	overlap := w.tail[max(len(w.tail)-len(sseChunkStart)+1, 0):]
+	joined := make([]byte, 0, len(overlap)+len(b))
+	joined = append(append(joined, overlap...), b...)
+	w.chunks += bytes.Count(joined, sseChunkStart) - bytes.Count(overlap, sseChunkStart)
+}
+
+// llama-server's timings, in the last chunk or in every chunk with
+// timings_per_token
+type llamaTimings struct {
+	PromptN    int `json:"prompt_n"`
+	PredictedN int `json:"predicted_n"`
+}
+
+// partialUsage estimates the usage of a stream that ended before its usage
+// chunk. llama-server's timings are used when a chunk had them, otherwise
+// every chunk is counted as one completion token.
+func (w *tailCaptureWriter) partialUsage() tokenUsage {
+	idx := bytes.LastIndex(w.tail, []byte(`"timings"`))
+	if idx != -1 {
+		rest := w.tail[idx+len(`"timings"`):]
+		if start := bytes.IndexByte(rest, '{'); start != -1 {
+			var timings llamaTimings
+			if json.NewDecoder(bytes.NewReader(rest[start:])).Decode(&timings) == nil && timings.PredictedN > 0 {
+				return tokenUsage{PromptTokens: timings.PromptN, CompletionTokens: timings.PredictedN}
+			}
+		}
+	}
+	return tokenUsage{CompletionTokens: w.chunks}
+}
+
+// requestUsage is the token usage of a handled request. It is estimated with
+// partialUsage when the client disconnected from a stream before the usage
+// chunk, which is reported with disconnected.
+func requestUsage(c *gin.Context, tail *tailCaptureWriter) (usage tokenUsage, disconnected bool) {
+	usage = tail.usage()
+	if usage.PromptTokens > 0 || usage.CompletionTokens > 0 {
+		return usage, false
+	}
+	if c.Request.Context().Err() == nil || tail.chunks == 0 {
+		return usage, false
+	}
+	return tail.partialUsage(), true
+}
@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailCaptureWriter_CountChunks(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tail := &tailCaptureWriter{ResponseWriter: c.Writer}
+
+	tail.Write([]byte(`data: {"choices":[{"delta":{"content":"a"}}]}` + "\n\n" + `data: {"choices":[{"delta":{"content":"b"}}]}` + "\n\n"))
+	// a chunk start split over two writes is counted once
+	tail.Write([]byte(`dat`))
+	tail.Write([]byte(`a: {"choices":[{"delta":{"content":"c"}}]}` + "\n\n"))
+	tail.Write([]byte("data: [DONE]\n\n"))
+
+	assert.Equal(t, 3, tail.chunks)
+	assert.Equal(t, tokenUsage{CompletionTokens: 3}, tail.partialUsage())
+
+	// llama-server's timings are used when a chunk had them
+	tail.Write([]byte(`data: {"choices":[],"timings":{"prompt_n":20,"predicted_n":7}}` + "\n\n"))
+	assert.Equal(t, tokenUsage{PromptTokens: 20, CompletionTokens: 7}, tail.partialUsage())
+}
+
+func TestRequestUsage_ClientDisconnected(t *testing.T) {
+	newTail := func(ctx context.Context) (*gin.Context, *tailCaptureWriter) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil).WithContext(ctx)
+		tail := &tailCaptureWriter{ResponseWriter: c.Writer}
+		tail.Write([]byte(`data: {"choices":[{"delta":{"content":"a"}}]}` + "\n\n" + `data: {"choices":[{"delta":{"content":"b"}}]}` + "\n\n"))
+		return c, tail
+	}
+
+	// a stream without a usage chunk
+	c, tail := newTail(context.Background())
+	usage, disconnected := requestUsage(c, tail)
+	assert.Equal(t, tokenUsage{}, usage)
+	assert.False(t, disconnected)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c, tail = newTail(ctx)
+	usage, disconnected = requestUsage(c, tail)
+	assert.Equal(t, tokenUsage{CompletionTokens: 2}, usage)
+	assert.True(t, disconnected)
+
+	// the usage chunk was sent before the client disconnected
+	tail.Write([]byte(`data: {"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2}}` + "\n\n"))
+	usage, disconnected = requestUsage(c, tail)
+	assert.Equal(t, tokenUsage{PromptTokens: 5, CompletionTokens: 2}, usage)
+	assert.False(t, disconnected)
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// modelPin keeps a running model loaded, set by POST
+// /api/models/<model>/pin. until is zero when it has no timeout.
+type modelPin struct {
+	until time.Time
+}
+
+// pin keeps the process from being stopped by swaps, VRAM evictions and its
+// ttl until unpin is called or the timeout, if not 0, has passed
+func (p *Process) pin(timeout time.Duration) *modelPin {
+	pin := &modelPin{}
+	if timeout > 0 {
+		pin.until = time.Now().Add(timeout)
+	}
+	p.pinned.Store(pin)
+
+	// the reaper picks up the timeout
+	if _, unloads := p.ttl(); unloads {
+		defaultReaper.track(p)
+	}
+	return pin
+}
+
+func (p *Process) unpin() {
+	if p.pinned.Swap(nil) == nil {
+		return
+	}
+	// the ttl starts again
+	if _, unloads := p.ttl(); unloads {
+		p.setLastRequestHandled(time.Now())
+		defaultReaper.track(p)
+	}
+}
+
+// activePin is the pin of the process, nil when it is not pinned or the pin's
+// timeout has passed
+func (p *Process) activePin() *modelPin {
+	pin := p.pinned.Load()
+	if pin == nil || (!pin.until.IsZero() && !time.Now().Before(pin.until)) {
+		return nil
+	}
+	return pin
+}
+
+func (p *Process) isPinned() bool {
+	return p.activePin() != nil
+}
+
+// parsePinTimeout reads seconds or a duration like 2h, empty is no timeout
+func parsePinTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid timeout %q, use seconds or a duration like 2h", value)
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePinTimeout(t *testing.T) {
+	tests := []struct {
+		value   string
+		timeout time.Duration
+	}{
+		{"", 0},
+		{"90", 90 * time.Second},
+		{"2h", 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		timeout, err := parsePinTimeout(tt.value)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.timeout, timeout, tt.value)
+	}
+
+	for _, value := range []string{"soon", "-5", "-1h"} {
+		_, err := parsePinTimeout(value)
+		assert.ErrorContains(t, err, "invalid timeout", value)
+	}
+}
+
+func TestReaper_PinnedProcess(t *testing.T) {
+	now := time.Now()
+	p := NewProcess("p", 5, ModelConfig{UnloadAfter: 10}, NewLogMonitorWriter(io.Discard))
+	p.state = StateReady
+	p.lastRequestHandled = now
+
+	reaper := newTTLReaper()
+	reaper.processes[p] = struct{}{}
+
+	// the ttl starts again when the timeout has passed
+	p.pinned.Store(&modelPin{until: now.Add(time.Minute)})
+	assert.Equal(t, now.Add(70*time.Second), reaper.reap(now))
+
+	// without a timeout the process is no longer tracked
+	p.pinned.Store(&modelPin{})
+	assert.True(t, reaper.reap(now).IsZero())
+	assert.Empty(t, reaper.processes)
+}
+
+func TestProxyManager_PinModel(t *testing.T) {
+	model1 := getTestSimpleResponderConfig("model1")
+	model1.UnloadAfter = 1
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": model1,
+			"model2": getTestSimpleResponderConfig("model2"),
+		},
+	})
+	defer proxy.StopProcesses()
+
+	post := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("POST", path, nil))
+		return w
+	}
+
+	// only running models can be pinned
+	assert.Equal(t, http.StatusConflict, post("/api/models/model1/pin").Code)
+	assert.Equal(t, http.StatusNotFound, post("/api/models/unknown/pin").Code)
+	assert.Equal(t, http.StatusBadRequest, post("/api/models/model1/pin?timeout=soon").Code)
+
+	assert.Equal(t, http.StatusOK, post("/api/models/model1/load").Code)
+	w := post("/api/models/model1/pin")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"model":"model1","state":"ready","pinned":true}`, w.Body.String())
+	process := proxy.currentProcesses[ProcessKeyName("", "model1")]
+
+	// swaps and the ttl leave a pinned model running
+	assert.Equal(t, http.StatusOK, post("/api/models/model2/load").Code)
+	time.Sleep(1500 * time.Millisecond)
+	assert.Equal(t, StateReady, process.CurrentState())
+	assert.Equal(t, StateReady, proxy.currentProcesses[ProcessKeyName("", "model2")].CurrentState())
+
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/running", nil))
+	var running struct {
+		Running []map[string]interface{} `json:"running"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &running))
+	assert.Len(t, running.Running, 2)
+	for _, entry := range running.Running {
+		assert.Equal(t, entry["model"] == "model1", entry["pinned"] == true, entry["model"])
+	}
+
+	// the ttl starts again once unpinned
+	w = post("/api/models/model1/unpin")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"model":"model1","state":"ready","pinned":false}`, w.Body.String())
+	assert.Eventually(t, func() bool {
+		return process.CurrentState() == StateStopped
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// pins with a timeout end on their own
+	assert.Equal(t, http.StatusOK, post("/api/models/model1/load").Code)
+	process = proxy.currentProcesses[ProcessKeyName("", "model1")]
+	w = post("/api/models/model1/pin?timeout=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "pinnedUntil")
+	assert.True(t, process.isPinned())
+	assert.Eventually(t, func() bool {
+		return process.CurrentState() == StateStopped
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.False(t, process.isPinned())
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// replaced in cmd and proxy by the port assigned when the model starts
+	portMacro = "${PORT}"
+
+	defaultStartPort = 5800
+	defaultPortCount = 1000
+
+	// starts tried when the assigned port was taken by another program
+	maxPortAttempts = 3
+
+	// proxy of models with ${PORT} in their cmd and no proxy
+	defaultPortProxy = "http://127.0.0.1:" + portMacro
+)
+
+var defaultPorts = newPortAllocator(defaultStartPort, defaultStartPort+defaultPortCount-1)
+
+// usesPortMacro is true when the model's port is assigned when it starts
+func (m ModelConfig) usesPortMacro() bool {
+	return strings.Contains(m.Cmd, portMacro)
+}
+
+func (c *Config) validatePorts() error {
+	if c.StartPort == 0 && c.EndPort == 0 {
+		return nil
+	}
+	if c.StartPort == 0 {
+		c.StartPort = defaultStartPort
+	}
+	if c.EndPort == 0 {
+		c.EndPort = c.StartPort + defaultPortCount - 1
+	}
+	if c.StartPort < 1 || c.EndPort > 65535 || c.StartPort > c.EndPort {
+		return fmt.Errorf("startPort and endPort must be a range of ports between 1 and 65535")
+	}
+	return nil
+}
+
+func (m ModelConfig) validatePortMacro() error {
+	if strings.Contains(m.Proxy, portMacro) && !m.usesPortMacro() {
+		return fmt.Errorf("proxy uses %s but cmd does not", portMacro)
+	}
+	return nil
+}
+
+// portAllocator returns the pool of the configured port range
+func (c *Config) portAllocator() *valuePool {
+	if c.StartPort == 0 {
+		return defaultPorts
+	}
+	return newPortAllocator(c.StartPort, c.EndPort)
+}
+
+// newPortAllocator hands out the ports between start and end that are not
+// used by other models or programs
+func newPortAllocator(start, end int) *valuePool {
+	ports := make([]int, 0, end-start+1)
+	for port := start; port <= end; port++ {
+		ports = append(ports, port)
+	}
+	return newValuePool(ports, fmt.Sprintf("port between %d and %d", start, end), portInUseLocal)
+}
+
+// upstreamURL is the proxy url with the port assigned at start
+func (p *Process) upstreamURL() string {
+	if _, ok := unixSocketPath(p.config.Proxy); ok {
+		return unixUpstreamBase
+	}
+	if port := p.port.Load(); port != 0 {
+		return strings.ReplaceAll(p.config.Proxy, portMacro, strconv.Itoa(int(port)))
+	}
+	return p.config.Proxy
+}
+
+// portTaken is true when a start failed because another program listens on
+// the assigned port, the state lock must be held
+func (p *Process) portTaken() bool {
+	port := p.port.Load()
+	return port != 0 && portInUseLocal(int(port))
+}
+
+func portInUseLocal(port int) bool {
+	return portInUse(net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+}
@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listenPorts finds count consecutive free ports
+func listenPorts(t *testing.T, count int) int {
+	for start := 16000; start < 17000; start += count {
+		free := true
+		for port := start; port < start+count; port++ {
+			if portInUse(fmt.Sprintf("127.0.0.1:%d", port)) {
+				free = false
+			}
+		}
+		if free {
+			return start
+		}
+	}
+	t.Fatal("no free ports")
+	return 0
+}
+
+func TestPortAllocator(t *testing.T) {
+	start := listenPorts(t, 3)
+
+	// a port used by another program is skipped
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", start))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	allocator := newPortAllocator(start, start+2)
+	port, err := allocator.acquire()
+	assert.NoError(t, err)
+	assert.Equal(t, start+1, port)
+
+	port, err = allocator.acquire()
+	assert.NoError(t, err)
+	assert.Equal(t, start+2, port)
+
+	_, err = allocator.acquire()
+	assert.ErrorContains(t, err, "no free port")
+
+	allocator.release(start + 1)
+	port, err = allocator.acquire()
+	assert.NoError(t, err)
+	assert.Equal(t, start+1, port)
+}
+
+func TestConfig_PortMacro(t *testing.T) {
+	config, err := LoadConfigFromBytes([]byte(`
+startPort: 9000
+models:
+  model1:
+    cmd: llama-server --port ${PORT}
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 9999, config.EndPort)
+	assert.Equal(t, "http://127.0.0.1:${PORT}", config.Models["model1"].Proxy)
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: llama-server --port 9000
+    proxy: http://127.0.0.1:${PORT}
+`))
+	assert.ErrorContains(t, err, "proxy uses ${PORT} but cmd does not")
+
+	_, err = LoadConfigFromBytes([]byte(`
+startPort: 9000
+endPort: 8000
+models: {}
+`))
+	assert.ErrorContains(t, err, "startPort and endPort")
+}
+
+func TestProcess_AssignsPort(t *testing.T) {
+	start := listenPorts(t, 2)
+
+	config := getTestSimpleResponderConfig("model1")
+	config.Cmd = fmt.Sprintf("%s --port ${PORT} --silent --respond model1", getSimpleResponderPath())
+	config.Proxy = defaultPortProxy
+
+	process := NewProcess("model1", 15, config, NewLogMonitorWriter(io.Discard))
+	process.ports = newPortAllocator(start, start+1)
+
+	if !assert.NoError(t, process.start()) {
+		return
+	}
+	assert.Equal(t, int32(start), process.port.Load())
+	assert.Equal(t, fmt.Sprintf("http://127.0.0.1:%d", start), process.upstreamURL())
+
+	// the port is in use by the running model
+	assert.True(t, process.portTaken())
+
+	process.Stop()
+	assert.Equal(t, int32(0), process.port.Load())
+	assert.Empty(t, process.ports.assigned)
+}
+
+func TestProxyManager_ListRunning(t *testing.T) {
+	config := getTestSimpleResponderConfig("model1")
+	config.Cmd = fmt.Sprintf("%s --port ${PORT} --silent --respond model1", getSimpleResponderPath())
+	config.Proxy = defaultPortProxy
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": config},
+	})
+	defer proxy.StopProcesses()
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model1"}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "model1", w.Body.String())
+
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/running", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Running []struct {
+			Model string `json:"model"`
+			State string `json:"state"`
+			Port  int    `json:"port"`
+		} `json:"running"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Running, 1) {
+		assert.Equal(t, "model1", response.Running[0].Model)
+		assert.Equal(t, string(StateReady), response.Running[0].State)
+		assert.GreaterOrEqual(t, response.Running[0].Port, defaultStartPort)
+	}
+}
@@ -2,24 +2,38 @@ package proxy
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
+var (
+	errRequestTimeout    = errors.New("upstream request timed out")
+	errIdleStreamTimeout = errors.New("upstream response idle timeout")
+)
+
+// time to wait after SIGTERM before sending SIGKILL
+const defaultGracefulStop = 5 * time.Second
+
 type ProcessState string
 
 const (
 	StateStopped ProcessState = ProcessState("stopped")
 	StateReady   ProcessState = ProcessState("ready")
 	StateFailed  ProcessState = ProcessState("failed")
+
+	// swapMode sleep processes that freed their GPU memory, see sleep.go
+	StateSleeping ProcessState = ProcessState("sleeping")
 )
 
 type Process struct {
@@ -28,15 +42,92 @@ type Process struct {
 	ID                 string
 	config             ModelConfig
 	cmd                *exec.Cmd
+	cmdWaiter          *cmdWaiter
+	client             *http.Client
 	logMonitor         *LogMonitor
 	healthCheckTimeout int
 
+	// protected by the embedded Mutex
 	lastRequestHandled time.Time
 
 	stateMutex sync.RWMutex
 	state      ProcessState
 
 	inFlightRequests sync.WaitGroup
+	inFlightCount    atomic.Int32
+
+	// cancels a pending automatic restart after a crash
+	cancelRestart context.CancelFunc
+
+	// started before the command, see dependencies.go
+	dependencies []*Process
+
+	// rpc-server workers of the command, see cluster.go
+	cluster []*clusterWorker
+
+	// optional check before the command is started, see vramPreStartCheck
+	preStartCheck func() error
+
+	// optional callback when start fails, see configCanary
+	onStartFailed func(error)
+
+	// optional callbacks for webhooks
+	onStarted func()
+	onCrashed func(error)
+	// called when livenessCheck restarts or stops the process
+	onUnhealthy func(error)
+	// called when the process is restarted after its maxLifetime
+	onRecycled func()
+	// called with the state lock held, see events.go
+	onStateChange func(from, to ProcessState)
+
+	// ttl in seconds set by a request, see ttl.go
+	ttlOverride atomic.Pointer[int]
+
+	// set by /api/models/<model>/pin until unpinned, see pin.go
+	pinned atomic.Pointer[modelPin]
+
+	// port assigned at start for a cmd with ${PORT}, see ports.go
+	ports *valuePool
+	port  atomic.Int32
+
+	// GPU index + 1 assigned at start for a cmd with ${GPU_INDEX}, see
+	// macros.go
+	gpus *valuePool
+	gpu  atomic.Int32
+
+	// last lines of the command's output and its last failed start or
+	// crash, see lasterror.go
+	output    *lineTail
+	lastError atomic.Pointer[StartupError]
+
+	// parsed from the command's output, see loadprogress.go
+	progress *loadProgressWriter
+
+	// the model's logFile, nil when not set, see modellog.go
+	log *modelLog
+
+	// send the exit code and output of a failed start in the JSON error
+	startupErrorDetails bool
+
+	// fetch the upstream's /props once ready, see props.go
+	probeProps bool
+	props      atomic.Pointer[map[string]interface{}]
+}
+
+// cmdWaiter calls cmd.Wait() once, done is closed after err is set
+type cmdWaiter struct {
+	done chan struct{}
+	err  error
+}
+
+func waitForCmd(cmd *exec.Cmd) *cmdWaiter {
+	w := &cmdWaiter{done: make(chan struct{})}
+	go func() {
+		w.err = cmd.Wait()
+		close(w.done)
+	}()
+	return w
 }
 
 func NewProcess(ID string, healthCheckTimeout int, config ModelConfig, logMonitor *LogMonitor) *Process {
@@ -45,6 +136,9 @@ func NewProcess(ID string, healthCheckTimeout int, config ModelConfig, logMonito
 		config:             config,
 		cmd:                nil,
 		logMonitor:         logMonitor,
+		output:             newLineTail(startupLogLines),
+		progress:           &loadProgressWriter{},
+		log:                newModelLog(config, logMonitor),
 		healthCheckTimeout: healthCheckTimeout,
 		state:              StateStopped,
 	}
@@ -52,6 +146,14 @@ func NewProcess(ID string, healthCheckTimeout int, config ModelConfig, logMonito
 
 // start the process and returns when it is ready
 func (p *Process) start() error {
+	err := p.startProcess()
+	if err != nil && p.onStartFailed != nil {
+		go p.onStartFailed(err)
+	}
+	return err
+}
+
+func (p *Process) startProcess() error {
 
 	p.stateMutex.Lock()
 	defer p.stateMutex.Unlock()
@@ -64,20 +166,106 @@ func (p *Process) start() error {
 		return fmt.Errorf("process is in a failed state and can not be restarted")
 	}
 
-	args, err := p.config.SanitizedCommand()
+	if err := p.startDependencies(); err != nil {
+		return err
+	}
+
+	if p.preStartCheck != nil {
+		if err := p.preStartCheck(); err != nil {
+			return err
+		}
+	}
+
+	// in sleep.go
+	if p.state == StateSleeping {
+		err := p.wakeUp()
+		if err == nil {
+			return nil
+		}
+		fmt.Fprintf(p.logMonitor, "!!! Unable to wake up %s, restarting it: %v\n", p.ID, err)
+		p.stop()
+	}
+
+	var err error
+	if p.client == nil {
+		if p.client, err = p.config.upstreamClient(); err != nil {
+			return err
+		}
+	}
+
+	// in remote.go
+	if p.config.Remote.enabled() {
+		return p.startRemote()
+	}
+
+	// in macros.go, another program may take the port before the command
+	// binds it
+	for attempt := 1; ; attempt++ {
+		if err := p.assignMacros(); err != nil {
+			return err
+		}
+
+		err := p.startCommand()
+		if err == nil {
+			return nil
+		}
+		p.stopCluster()
+
+		retry := attempt < maxPortAttempts && p.portTaken()
+		if retry {
+			fmt.Fprintf(p.logMonitor, "!!! Port %d of %s is used by another program, retrying with the next free port\n", p.port.Load(), p.ID)
+		}
+		p.releaseMacros()
+		if !retry {
+			return err
+		}
+		p.setState(StateStopped)
+	}
+}
+
+// startCommand runs the command and waits for it to become healthy, the state
+// lock must be held
+func (p *Process) startCommand() error {
+	args, err := p.commandArgs(p.config.Cmd)
 	if err != nil {
 		return fmt.Errorf("unable to get sanitized command: %v", err)
 	}
 
+	// in cluster.go
+	if p.config.Cluster.enabled() {
+		if err := p.startCluster(); err != nil {
+			return p.startupError(err, nil)
+		}
+		args = p.config.Cluster.rpcArgs(args)
+	}
+
 	p.cmd = exec.Command(args[0], args[1:]...)
-	p.cmd.Stdout = p.logMonitor
-	p.cmd.Stderr = p.logMonitor
-	p.cmd.Env = p.config.Env
+	p.output.reset()
+	p.progress.reset()
+	modelOutput := p.logMonitor.ModelWriter(p.ID)
+	var output io.Writer = io.MultiWriter(modelOutput, p.output, p.progress)
+	if p.log != nil {
+		output = io.MultiWriter(modelOutput, p.output, p.progress, p.log)
+	}
+	p.cmd.Stdout = output
+	p.cmd.Stderr = output
+	// in stale.go
+	p.cmd.Env = markedEnv(p.commandEnv(), p.ID)
+	// in unixsocket.go
+	if path, ok := unixSocketPath(p.config.Proxy); ok {
+		if err := removeStaleSocket(path); err != nil {
+			fmt.Fprintf(p.logMonitor, "!!! Unable to remove stale socket %s: %v\n", path, err)
+		}
+	}
+	// in procgroup_*.go
+	startInProcessGroup(p.cmd)
+	// children in the group can keep the output open after the command exits
+	p.cmd.WaitDelay = time.Second
 
 	err = p.cmd.Start()
 
 	if err != nil {
-		return err
+		return p.startupError(err, nil)
 	}
 
 	// One of three things can happen at this stage:
@@ -88,61 +276,167 @@ func (p *Process) start() error {
 	// only in the third case will the process be considered Ready to accept
 	healthCheckContext, cancelHealthCheck := context.WithCancelCause(context.Background())
 	defer cancelHealthCheck(nil) // clean up
-	cmdWaitChan := make(chan error, 1)
+	p.cmdWaiter = waitForCmd(p.cmd)
+	if p.log != nil {
+		// opened again by the next start's output
+		go func(waiter *cmdWaiter) {
+			<-waiter.done
+			p.log.Close()
+		}(p.cmdWaiter)
+	}
 	healthCheckChan := make(chan error, 1)
 
-	go func() {
-		// possible cmd exits early
-		cmdWaitChan <- p.cmd.Wait()
-	}()
-
 	go func() {
 		<-time.After(250 * time.Millisecond) // give process a bit of time to start
-		healthCheckChan <- p.checkHealthEndpoint(healthCheckContext)
+		if p.config.CheckCmd != "" {
+			healthCheckChan <- p.checkHealthCommand(healthCheckContext)
+		} else {
+			healthCheckChan <- p.checkHealthEndpoint(healthCheckContext, time.Duration(p.healthCheckTimeout)*time.Second)
+		}
 	}()
 
 	select {
-	case err := <-cmdWaitChan:
-		p.state = StateFailed
+	case <-p.cmdWaiter.done:
+		signalProcessGroup(p.cmd, syscall.SIGKILL)
+		p.setState(StateFailed)
+		err := p.cmdWaiter.err
 		if err != nil {
 			err = fmt.Errorf("command [%s] %s", strings.Join(p.cmd.Args, " "), err.Error())
 		} else {
 			err = fmt.Errorf("command [%s] exited unexpected", strings.Join(p.cmd.Args, " "))
 		}
 		cancelHealthCheck(err)
-		return err
+		return p.startupError(err, p.cmdWaiter.err)
 	case err := <-healthCheckChan:
 		if err != nil {
-			p.state = StateFailed
-			return err
+			// don't leave the upstream running when it never became ready
+			signalProcessGroup(p.cmd, syscall.SIGKILL)
+			<-p.cmdWaiter.done
+			p.setState(StateFailed)
+			return p.startupError(err, p.cmdWaiter.err)
 		}
 	}
 
-	if p.config.UnloadAfter > 0 {
-		// start a goroutine to check every second if
-		// the process should be stopped
-		go func() {
-			maxDuration := time.Duration(p.config.UnloadAfter) * time.Second
+	go p.watchForCrash(p.cmd, p.cmdWaiter)
+	if len(p.cluster) > 0 {
+		go p.watchCluster(p.cmd, p.cluster)
+	}
 
-			for range time.Tick(time.Second) {
-				if p.state != StateReady {
-					return
-				}
+	p.setReady()
+	return nil
+}
 
-				// wait for all inflight requests to complete and ticker
-				p.inFlightRequests.Wait()
+// setReady finishes a successful start, the state lock must be held
+func (p *Process) setReady() {
+	// in loadprogress.go
+	p.progress.loaded()
 
-				if time.Since(p.lastRequestHandled) > maxDuration {
-					fmt.Fprintf(p.logMonitor, "!!! Unloading model %s, TTL of %ds reached.\n", p.ID, p.config.UnloadAfter)
-					p.Stop()
-					return
-				}
+	if _, unloads := p.ttl(); unloads {
+		// a new process has not handled a request yet, the TTL starts now
+		p.setLastRequestHandled(time.Now())
+		defaultReaper.track(p)
+	}
+
+	if p.probeProps {
+		go p.fetchUpstreamProps()
+	}
+	if p.onStarted != nil {
+		go p.onStarted()
+	}
+	// in liveness.go
+	if p.config.LivenessCheck.Interval > 0 && p.cmd != nil {
+		go p.watchLiveness(p.cmd)
+	}
+	// in lifetime.go
+	if p.config.MaxLifetime != "" && p.cmd != nil {
+		go p.watchLifetime(p.cmd)
+	}
+
+	p.setState(StateReady)
+}
+
+// setState changes the state, the state lock must be held
+func (p *Process) setState(state ProcessState) {
+	from := p.state
+	p.state = state
+	if from != state && p.onStateChange != nil {
+		p.onStateChange(from, state)
+	}
+}
+
+// watchForCrash detects when a ready process exits without Stop() being called
+func (p *Process) watchForCrash(cmd *exec.Cmd, waiter *cmdWaiter) {
+	<-waiter.done
+
+	p.stateMutex.Lock()
+	defer p.stateMutex.Unlock()
+
+	if p.cmd != cmd || (p.state != StateReady && p.state != StateSleeping) {
+		return
+	}
+
+	fmt.Fprintf(p.logMonitor, "!!! Process for %s exited unexpectedly: %v\n", p.ID, waiter.err)
+	signalProcessGroup(cmd, syscall.SIGKILL)
+	p.stopCluster()
+	p.startupError(fmt.Errorf("process exited unexpectedly: %v", waiter.err), waiter.err)
+	p.setState(StateStopped)
+	p.props.Store(nil)
+	p.releaseMacros()
+	p.ttlOverride.Store(nil)
+	p.pinned.Store(nil)
+
+	if p.onCrashed != nil {
+		go p.onCrashed(waiter.err)
+	}
+
+	if p.config.AutoRestart.MaxRetries > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancelRestart = cancel
+		go p.autoRestart(ctx)
+	}
+}
+
+// autoRestart starts a crashed process again with an exponential backoff
+// between attempts. The process is left failed once all retries are used.
+func (p *Process) autoRestart(ctx context.Context) {
+	maxRetries := p.config.AutoRestart.MaxRetries
+	backoff := time.Duration(max(p.config.AutoRestart.BackoffSeconds, 1)) * time.Second
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if p.CurrentState() == StateReady {
+			// started again by a request
+			return
+		}
+
+		fmt.Fprintf(p.logMonitor, "!!! Restarting %s, attempt %d of %d\n", p.ID, attempt, maxRetries)
+		err := p.start()
+		if err == nil {
+			if ctx.Err() != nil {
+				// Stop() was called while restarting
+				p.Stop()
 			}
-		}()
+			return
+		}
+
+		fmt.Fprintf(p.logMonitor, "!!! Restart of %s failed: %v\n", p.ID, err)
+		backoff *= 2
+
+		if attempt < maxRetries {
+			p.stateMutex.Lock()
+			if p.state == StateFailed {
+				p.setState(StateStopped)
+			}
+			p.stateMutex.Unlock()
+		}
 	}
 
-	p.state = StateReady
-	return nil
+	fmt.Fprintf(p.logMonitor, "!!! Giving up restarting %s after %d attempts\n", p.ID, maxRetries)
 }
 
 func (p *Process) Stop() {
@@ -152,35 +446,57 @@ func (p *Process) Stop() {
 	p.stateMutex.Lock()
 	defer p.stateMutex.Unlock()
 
-	if p.state != StateReady {
+	if p.cancelRestart != nil {
+		p.cancelRestart()
+		p.cancelRestart = nil
+	}
+
+	p.stop()
+}
+
+// stop ends the command of a ready or sleeping process, the state lock must
+// be held
+func (p *Process) stop() {
+	if p.state != StateReady && p.state != StateSleeping {
 		fmt.Fprintf(p.logMonitor, "!!! Info - Stop() called but Process State is not READY\n")
 		return
 	}
+	defer p.releaseMacros()
+	defer p.ttlOverride.Store(nil)
+	defer p.pinned.Store(nil)
+
+	// remote machines are left running, they sleep on their own
+	if p.config.Remote.enabled() {
+		p.setState(StateStopped)
+		p.props.Store(nil)
+		return
+	}
 
 	if p.cmd == nil || p.cmd.Process == nil {
 		// this situation should never happen... but if it does just update the state
 		fmt.Fprintf(p.logMonitor, "!!! State is Ready but Command is nil.\n")
-		p.state = StateStopped
+		p.setState(StateStopped)
+		p.props.Store(nil)
 		return
 	}
 
-	sigtermTimeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	gracefulStop := defaultGracefulStop
+	if p.config.GracefulStopSeconds > 0 {
+		gracefulStop = time.Duration(p.config.GracefulStopSeconds) * time.Second
+	}
 
-	sigtermNormal := make(chan error, 1)
-	go func() {
-		sigtermNormal <- p.cmd.Wait()
-	}()
+	sigtermTimeout, cancel := context.WithTimeout(context.Background(), gracefulStop)
+	defer cancel()
 
-	p.cmd.Process.Signal(syscall.SIGTERM)
+	signalProcessGroup(p.cmd, syscall.SIGTERM)
 
 	select {
 	case <-sigtermTimeout.Done():
-		fmt.Fprintf(p.logMonitor, "XXX Process for %s timed out waiting to stop, sending SIGKILL to PID: %d\n", p.ID, p.cmd.Process.Pid)
-		p.cmd.Process.Kill()
-		p.cmd.Wait()
-	case err := <-sigtermNormal:
-		if err != nil {
+		fmt.Fprintf(p.logMonitor, "XXX Process for %s did not stop within %v of SIGTERM, sending SIGKILL to PID: %d\n", p.ID, gracefulStop, p.cmd.Process.Pid)
+		signalProcessGroup(p.cmd, syscall.SIGKILL)
+		<-p.cmdWaiter.done
+	case <-p.cmdWaiter.done:
+		if err := p.cmdWaiter.err; err != nil {
 			if err.Error() != "wait: no child processes" {
 				// possible that simple-responder for testing is just not
 				// existing right, so suppress those errors.
@@ -189,7 +505,24 @@ func (p *Process) Stop() {
 		}
 	}
 
-	p.state = StateStopped
+	// children that ignored the SIGTERM
+	signalProcessGroup(p.cmd, syscall.SIGKILL)
+	p.stopCluster()
+
+	p.setState(StateStopped)
+	p.props.Store(nil)
+}
+
+func (p *Process) LastRequestHandled() time.Time {
+	p.Lock()
+	defer p.Unlock()
+	return p.lastRequestHandled
+}
+
+func (p *Process) setLastRequestHandled(t time.Time) {
+	p.Lock()
+	defer p.Unlock()
+	p.lastRequestHandled = t
 }
 
 func (p *Process) CurrentState() ProcessState {
@@ -198,7 +531,7 @@ func (p *Process) CurrentState() ProcessState {
 	return p.state
 }
 
-func (p *Process) checkHealthEndpoint(ctxFromStart context.Context) error {
+func (p *Process) checkHealthEndpoint(ctxFromStart context.Context, maxDuration time.Duration) error {
 	if p.config.Proxy == "" {
 		return fmt.Errorf("no upstream available to check /health")
 	}
@@ -214,14 +547,12 @@ func (p *Process) checkHealthEndpoint(ctxFromStart context.Context) error {
 		checkEndpoint = "/health"
 	}
 
-	proxyTo := p.config.Proxy
-	maxDuration := time.Second * time.Duration(p.healthCheckTimeout)
+	proxyTo := p.upstreamURL()
 	healthURL, err := url.JoinPath(proxyTo, checkEndpoint)
 	if err != nil {
 		return fmt.Errorf("failed to create health url with with %s and path %s", proxyTo, checkEndpoint)
 	}
 
-	client := &http.Client{}
 	startTime := time.Now()
 
 	for {
@@ -229,11 +560,12 @@ func (p *Process) checkHealthEndpoint(ctxFromStart context.Context) error {
 		if err != nil {
 			return err
 		}
+		p.config.setUpstreamHeaders(req.Header)
 
 		ctx, cancel := context.WithTimeout(ctxFromStart, time.Second)
 		defer cancel()
 		req = req.WithContext(ctx)
-		resp, err := client.Do(req)
+		resp, err := p.client.Do(req)
 
 		ttl := (maxDuration - time.Since(startTime)).Seconds()
 
@@ -251,9 +583,9 @@ func (p *Process) checkHealthEndpoint(ctxFromStart context.Context) error {
 			// wait a bit longer for TCP connection issues
 			if strings.Contains(err.Error(), "connection refused") {
 				fmt.Fprintf(p.logMonitor, "Connection refused on %s, ttl %.0fs\n", healthURL, ttl)
-				time.Sleep(5 * time.Second)
+				time.Sleep(jitter(5 * time.Second))
 			} else {
-				time.Sleep(time.Second)
+				time.Sleep(jitter(time.Second))
 			}
 
 			if ttl < 0 {
@@ -272,37 +604,129 @@ func (p *Process) checkHealthEndpoint(ctxFromStart context.Context) error {
 			return fmt.Errorf("failed to check health from: %s", healthURL)
 		}
 
-		time.Sleep(time.Second)
+		time.Sleep(jitter(time.Second))
+	}
+}
+
+// jitter randomizes d by up to ±20% to spread out periodic checks
+func jitter(d time.Duration) time.Duration {
+	spread := int64(d) / 5
+	if spread <= 0 {
+		return d
+	}
+	return d + time.Duration(rand.Int64N(2*spread+1)-spread)
+}
+
+// checkHealthCommand runs checkCmd until it exits with 0
+func (p *Process) checkHealthCommand(ctxFromStart context.Context) error {
+	args, err := p.commandArgs(p.config.CheckCmd)
+	if err != nil {
+		return fmt.Errorf("invalid checkCmd: %v", err)
+	}
+
+	maxDuration := time.Second * time.Duration(p.healthCheckTimeout)
+	startTime := time.Now()
+
+	for {
+		ctx, cancel := context.WithTimeout(ctxFromStart, 5*time.Second)
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Env = p.commandEnv()
+		output, err := cmd.CombinedOutput()
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+
+		if cause := context.Cause(ctxFromStart); cause != nil {
+			return cause
+		}
+
+		ttl := (maxDuration - time.Since(startTime)).Seconds()
+		if ttl < 0 {
+			return fmt.Errorf("checkCmd [%s] failed: %v %s", p.config.CheckCmd, err, strings.TrimSpace(string(output)))
+		}
+
+		fmt.Fprintf(p.logMonitor, "checkCmd not ready: %v, ttl %.0fs\n", err, ttl)
+		time.Sleep(jitter(time.Second))
 	}
 }
 
 func (p *Process) ProxyRequest(w http.ResponseWriter, r *http.Request) {
 
 	p.inFlightRequests.Add(1)
+	p.inFlightCount.Add(1)
 
 	defer func() {
-		p.lastRequestHandled = time.Now()
+		p.setLastRequestHandled(time.Now())
+		p.inFlightCount.Add(-1)
 		p.inFlightRequests.Done()
+
+		// the reaper picks up a ttl set by this request
+		if _, unloads := p.ttl(); unloads && p.ttlOverride.Load() != nil {
+			defaultReaper.track(p)
+		}
 	}()
 
 	if p.CurrentState() != StateReady {
 		if err := p.start(); err != nil {
+			var vramErr *InsufficientVRAMError
+			if errors.As(err, &vramErr) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{
+					"message": vramErr.Error(),
+					"type":    "server_error",
+					"code":    "insufficient_vram",
+				}})
+				return
+			}
+
+			// in lasterror.go
+			var startupErr *StartupError
+			if p.startupErrorDetails && errors.As(err, &startupErr) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(startupErrorBody(startupErr))
+				return
+			}
+
 			errstr := fmt.Sprintf("unable to start process: %s", err)
 			http.Error(w, errstr, http.StatusInternalServerError)
 			return
 		}
 	}
 
-	proxyTo := p.config.Proxy
-	client := &http.Client{}
-	req, err := http.NewRequestWithContext(r.Context(), r.Method, proxyTo+r.URL.String(), r.Body)
+	// cancelled when the client disconnects or a timeout is reached
+	ctx, cancel := context.WithCancelCause(r.Context())
+	defer cancel(nil)
+
+	if p.config.RequestTimeout > 0 {
+		timer := time.AfterFunc(time.Duration(p.config.RequestTimeout)*time.Second, func() {
+			cancel(errRequestTimeout)
+		})
+		defer timer.Stop()
+	}
+
+	proxyTo := p.upstreamURL()
+	req, err := http.NewRequestWithContext(ctx, r.Method, proxyTo+r.URL.String(), r.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	req.Header = r.Header.Clone()
-	resp, err := client.Do(req)
+	p.config.setUpstreamHeaders(req.Header)
+
+	// in retry.go
+	resp, err := p.doWithRetry(req)
 	if err != nil {
+		if errors.Is(context.Cause(ctx), errRequestTimeout) {
+			http.Error(w, errRequestTimeout.Error(), http.StatusGatewayTimeout)
+			return
+		}
+		if p.config.Remote.enabled() && r.Context().Err() == nil {
+			p.remoteUnreachable(err)
+		}
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
@@ -314,12 +738,31 @@ func (p *Process) ProxyRequest(w http.ResponseWriter, r *http.Request) {
 	}
 	w.WriteHeader(resp.StatusCode)
 
+	var idleTimer *time.Timer
+	idleTimeout := time.Duration(p.config.IdleStreamTimeout) * time.Second
+	if idleTimeout > 0 {
+		idleTimer = time.AfterFunc(idleTimeout, func() {
+			cancel(errIdleStreamTimeout)
+		})
+		defer idleTimer.Stop()
+	}
+
+	// in sse.go
+	var out io.Writer = w
+	if sse := p.config.newSSEWriter(w, resp.Header.Get("Content-Type")); sse != nil {
+		out = sse
+		defer sse.finish()
+	}
+
 	// faster than io.Copy when streaming
 	buf := make([]byte, 32*1024)
 	for {
 		n, err := resp.Body.Read(buf)
 		if n > 0 {
-			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
+			if idleTimer != nil {
+				idleTimer.Reset(idleTimeout)
+			}
+			if _, writeErr := out.Write(buf[:n]); writeErr != nil {
 				return
 			}
 			if flusher, ok := w.(http.Flusher); ok {
@@ -330,6 +773,11 @@ func (p *Process) ProxyRequest(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 		if err != nil {
+			if cause := context.Cause(ctx); errors.Is(cause, errRequestTimeout) || errors.Is(cause, errIdleStreamTimeout) {
+				// the status is already sent, end the response early
+				fmt.Fprintf(p.logMonitor, "!!! Upstream %s: %v\n", p.ID, cause)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusBadGateway)
 			return
 		}
@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
@@ -67,6 +68,40 @@ func TestProcess_BrokenModelConfig(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "unable to start process")
 }
 
+func TestProcess_CheckCmd(t *testing.T) {
+	dir := t.TempDir()
+	readyFile := dir + "/ready"
+
+	config := getTestSimpleResponderConfig("checkcmd")
+	config.CheckCmd = fmt.Sprintf("test -f %s", readyFile)
+
+	process := NewProcess("checkcmd", 5, config, NewLogMonitorWriter(io.Discard))
+	defer process.Stop()
+
+	go func() {
+		time.Sleep(1500 * time.Millisecond)
+		os.WriteFile(readyFile, nil, 0644)
+	}()
+
+	start := time.Now()
+	assert.NoError(t, process.start())
+	assert.Equal(t, StateReady, process.CurrentState())
+	assert.GreaterOrEqual(t, time.Since(start), 1500*time.Millisecond)
+}
+
+func TestProcess_CheckCmdTimeout(t *testing.T) {
+	config := getTestSimpleResponderConfig("checkcmdfail")
+	config.CheckCmd = "false"
+
+	process := NewProcess("checkcmdfail", 2, config, NewLogMonitorWriter(io.Discard))
+	defer process.Stop()
+
+	err := process.start()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "checkCmd [false] failed")
+	}
+}
+
 func TestProcess_UnloadAfterTTL(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping long auto unload TTL test")
@@ -190,3 +225,131 @@ func TestProcess_HTTPRequestsHaveTimeToFinish(t *testing.T) {
 		assert.Equal(t, key, result)
 	}
 }
+
+func TestProcess_CrashMarksStopped(t *testing.T) {
+	config := getTestSimpleResponderConfig("crash")
+	process := NewProcess("crash", 5, config, NewLogMonitorWriter(io.Discard))
+	defer process.Stop()
+
+	assert.NoError(t, process.start())
+	assert.Equal(t, StateReady, process.CurrentState())
+
+	process.cmd.Process.Kill()
+	assert.Eventually(t, func() bool {
+		return process.CurrentState() == StateStopped
+	}, 2*time.Second, 50*time.Millisecond)
+
+	// the next request starts it again
+	req := httptest.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	process.ProxyRequest(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, StateReady, process.CurrentState())
+}
+
+func TestProcess_AutoRestartAfterCrash(t *testing.T) {
+	config := getTestSimpleResponderConfig("restart")
+	config.AutoRestart = AutoRestartConfig{MaxRetries: 2, BackoffSeconds: 1}
+	process := NewProcess("restart", 5, config, NewLogMonitorWriter(io.Discard))
+	defer process.Stop()
+
+	assert.NoError(t, process.start())
+	firstPid := process.cmd.Process.Pid
+
+	process.cmd.Process.Kill()
+	assert.Eventually(t, func() bool {
+		process.stateMutex.RLock()
+		defer process.stateMutex.RUnlock()
+		return process.state == StateReady && process.cmd.Process.Pid != firstPid
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestProcess_StopCancelsAutoRestart(t *testing.T) {
+	config := getTestSimpleResponderConfig("norestart")
+	config.AutoRestart = AutoRestartConfig{MaxRetries: 2, BackoffSeconds: 1}
+	process := NewProcess("norestart", 5, config, NewLogMonitorWriter(io.Discard))
+
+	assert.NoError(t, process.start())
+	process.cmd.Process.Kill()
+	assert.Eventually(t, func() bool {
+		return process.CurrentState() == StateStopped
+	}, 2*time.Second, 50*time.Millisecond)
+
+	process.Stop()
+	time.Sleep(1500 * time.Millisecond)
+	assert.Equal(t, StateStopped, process.CurrentState())
+}
+
+func TestProcess_GracefulStopEscalatesToSIGKILL(t *testing.T) {
+	config := ModelConfig{
+		Cmd:                 `sh -c "trap '' TERM; while true; do sleep 0.1; done"`,
+		Proxy:               "http://127.0.0.1:1",
+		CheckEndpoint:       "none",
+		GracefulStopSeconds: 1,
+	}
+
+	var logs bytes.Buffer
+	process := NewProcess("ignores-sigterm", 5, config, NewLogMonitorWriter(&logs))
+	assert.NoError(t, process.start())
+
+	start := time.Now()
+	process.Stop()
+	elapsed := time.Since(start)
+
+	assert.Equal(t, StateStopped, process.CurrentState())
+	assert.GreaterOrEqual(t, elapsed, time.Second)
+	assert.Less(t, elapsed, 3*time.Second)
+	assert.Contains(t, logs.String(), "did not stop within 1s of SIGTERM, sending SIGKILL")
+}
+
+func TestProcess_RequestTimeouts(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-time.After(3 * time.Second):
+			case <-r.Context().Done():
+			}
+		case "/stream":
+			w.Write([]byte("data: first\n\n"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(3 * time.Second):
+				w.Write([]byte("data: second\n\n"))
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	config := getTestSimpleResponderConfig("timeouts")
+	config.Proxy = upstream.URL
+	config.RequestTimeout = 2
+	config.IdleStreamTimeout = 1
+
+	process := NewProcess("timeouts", 5, config, NewLogMonitorWriter(io.Discard))
+	defer process.Stop()
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	process.ProxyRequest(w, httptest.NewRequest("GET", "/slow", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Less(t, time.Since(start), 3*time.Second)
+
+	start = time.Now()
+	w = httptest.NewRecorder()
+	process.ProxyRequest(w, httptest.NewRequest("GET", "/stream", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "data: first\n\n", w.Body.String())
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestProcess_Jitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Second)
+		assert.GreaterOrEqual(t, d, 800*time.Millisecond)
+		assert.LessOrEqual(t, d, 1200*time.Millisecond)
+	}
+	assert.Equal(t, time.Duration(0), jitter(0))
+}
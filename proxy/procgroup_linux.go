@@ -0,0 +1,8 @@
+package proxy
+
+import "syscall"
+
+// the command is killed when llama-swap dies, even with a SIGKILL
+func processGroupAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true, Pdeathsig: syscall.SIGKILL}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !windows
+
+package proxy
+
+import "syscall"
+
+func processGroupAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package proxy
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// startInProcessGroup makes the command the leader of a new process group,
+// so children it starts, like llama-server under a shell wrapper, are
+// stopped with it
+func startInProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = processGroupAttr()
+}
+
+// signalProcessGroup sends sig to every process of the command's group
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	err := syscall.Kill(-cmd.Process.Pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+	if err != nil {
+		return cmd.Process.Signal(sig)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+//go:build !windows
+
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// processAlive is false for processes that exited, including zombies
+func processAlive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	return err != nil || !strings.Contains(string(stat), ") Z ")
+}
+
+func TestProcess_StopsShellWrapperChildren(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	config := getTestSimpleResponderConfig("wrapped")
+	// the shell waits for its child, which is left running by a SIGTERM to
+	// the shell alone
+	config.Cmd = fmt.Sprintf("sh -c '%s & echo $! > %s; wait'", config.Cmd, pidFile)
+
+	process := NewProcess("wrapped", 5, config, NewLogMonitorWriter(io.Discard))
+	if !assert.NoError(t, process.start()) {
+		return
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if !assert.NoError(t, err) {
+		process.Stop()
+		return
+	}
+	childPid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	assert.True(t, processAlive(childPid))
+
+	process.Stop()
+	assert.Eventually(t, func() bool {
+		return !processAlive(childPid)
+	}, 5*time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,17 @@
+package proxy
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Windows has no process groups that can be signalled, only the command
+// itself is stopped
+func startInProcessGroup(cmd *exec.Cmd) {}
+
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if sig == syscall.SIGKILL {
+		return cmd.Process.Kill()
+	}
+	return cmd.Process.Signal(sig)
+}
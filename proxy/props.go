@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const propsFetchTimeout = 5 * time.Second
+
+// fetchUpstreamProps gets the llama-server /props of a ready process and
+// keeps the fields shown in /v1/models
+func (p *Process) fetchUpstreamProps() {
+	ctx, cancel := context.WithTimeout(context.Background(), propsFetchTimeout)
+	defer cancel()
+
+	propsURL := strings.TrimSuffix(p.upstreamURL(), "/") + "/props"
+	req, err := http.NewRequestWithContext(ctx, "GET", propsURL, nil)
+	if err != nil {
+		return
+	}
+	p.config.setUpstreamHeaders(req.Header)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		fmt.Fprintf(p.logMonitor, "!!! Unable to fetch %s for %s: %v\n", propsURL, p.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(p.logMonitor, "!!! Unable to fetch %s for %s: status %d\n", propsURL, p.ID, resp.StatusCode)
+		return
+	}
+
+	var upstream struct {
+		DefaultGenerationSettings struct {
+			NCtx int `json:"n_ctx"`
+		} `json:"default_generation_settings"`
+		TotalSlots   int    `json:"total_slots"`
+		ModelPath    string `json:"model_path"`
+		ChatTemplate string `json:"chat_template"`
+		BuildInfo    string `json:"build_info"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&upstream); err != nil {
+		fmt.Fprintf(p.logMonitor, "!!! Invalid %s for %s: %v\n", propsURL, p.ID, err)
+		return
+	}
+
+	props := make(map[string]interface{})
+	if upstream.DefaultGenerationSettings.NCtx > 0 {
+		props["n_ctx"] = upstream.DefaultGenerationSettings.NCtx
+	}
+	if upstream.TotalSlots > 0 {
+		props["total_slots"] = upstream.TotalSlots
+	}
+	if upstream.ModelPath != "" {
+		props["model_path"] = upstream.ModelPath
+	}
+	if upstream.ChatTemplate != "" {
+		props["chat_template"] = upstream.ChatTemplate
+	}
+	if upstream.BuildInfo != "" {
+		props["build_info"] = upstream.BuildInfo
+	}
+
+	p.props.Store(&props)
+}
+
+// runningModelProps returns the props of running models by model ID
+func (pm *ProxyManager) runningModelProps() map[string]map[string]interface{} {
+	pm.Lock()
+	defer pm.Unlock()
+
+	result := make(map[string]map[string]interface{})
+	for _, process := range pm.currentProcesses {
+		if props := process.props.Load(); props != nil {
+			result[process.ID] = *props
+		}
+	}
+	return result
+}
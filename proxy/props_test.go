@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyManager_ProbeUpstreamProps(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/props" {
+			w.Write([]byte(`{
+				"default_generation_settings": {"n_ctx": 8192, "temperature": 0.8},
+				"total_slots": 4,
+				"model_path": "/models/qwen.gguf",
+				"chat_template": "chatml"
+			}`))
+		}
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Proxy = upstream.URL
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": modelConfig,
+			"model2": getTestSimpleResponderConfig("model2"),
+		},
+		ProbeUpstreamProps: true,
+	})
+	defer proxy.StopProcesses()
+
+	listModels := func() map[string]map[string]interface{} {
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("GET", "/v1/models", nil))
+
+		var response struct {
+			Data []struct {
+				ID    string                 `json:"id"`
+				Props map[string]interface{} `json:"props"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		props := make(map[string]map[string]interface{})
+		for _, model := range response.Data {
+			props[model.ID] = model.Props
+		}
+		return props
+	}
+
+	assert.Nil(t, listModels()["model1"], "not running")
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Eventually(t, func() bool {
+		return listModels()["model1"] != nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	props := listModels()
+	assert.Equal(t, map[string]interface{}{
+		"n_ctx":         float64(8192),
+		"total_slots":   float64(4),
+		"model_path":    "/models/qwen.gguf",
+		"chat_template": "chatml",
+	}, props["model1"])
+	assert.Nil(t, props["model2"])
+
+	proxy.StopProcesses()
+	assert.Nil(t, listModels()["model1"], "stopped")
+}
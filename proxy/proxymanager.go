@@ -1,15 +1,19 @@
 package proxy
 
 import (
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"math"
+	"net"
 	"net/http"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -19,21 +23,119 @@ const (
 	PROFILE_SPLIT_CHAR = ":"
 )
 
+var ErrModelNotFound = errors.New("model not found")
+
 type ProxyManager struct {
 	sync.Mutex
 
-	config           *Config
+	// replaced on reload, read it once per request with currentConfig
+	config           atomic.Pointer[Config]
+	configPath       atomic.Pointer[string]
+	configEditMutex  sync.Mutex
+	workspace        *Workspace
 	currentProcesses map[string]*Process
 	logMonitor       *LogMonitor
 	ginEngine        *gin.Engine
+	vramProvider     VRAMProvider
+
+	// replaced on reload, read without the lock on every request
+	schedulers atomic.Pointer[map[string]*requestScheduler]
+
+	// ports of models with ${PORT} in their cmd, see ports.go
+	ports *valuePool
+
+	// GPU indexes of models with ${GPU_INDEX} in their cmd, see macros.go
+	gpus *valuePool
+
+	// validates bearer tokens when auth.oidc is set, see oidc.go
+	oidc atomic.Pointer[oidcValidator]
+
+	// closed when the standby models started by New are ready or failed
+	standbyStarted chan struct{}
+
+	incidentsMutex sync.Mutex
+	incidents      []Incident
+
+	// recent swap, eviction and queue decisions, see decisions.go
+	decisionsMutex sync.Mutex
+	decisions      []Decision
+
+	// recent debug traces, see trace.go
+	tracesMutex sync.Mutex
+	traces      []*requestTrace
+
+	// usage of models with quotas, see quota.go
+	quotas *quotaTracker
+
+	// usage of models per client, see clients.go
+	clientMetrics *clientMetricsTracker
+
+	// usage of the variants of A/B tests, see abtest.go
+	abTests *abTestTracker
+
+	// management actions, see audit.go
+	auditLog *auditLog
+
+	// responses of models and their shadow models, see shadow.go
+	shadows *shadowTracker
+
+	// open recording files, see record.go
+	recorder *trafficRecorder
+
+	// last runs of the schedules, see schedules.go
+	schedules *scheduleRunner
+
+	// clients of /api/events, see events.go
+	events *eventBus
+
+	// previous config kept during a reload probation
+	canary *configCanary
+
+	// nil when the cache is disabled, see cache.go
+	responseCache atomic.Pointer[responseCache]
+
+	// recent benchmark results, see benchy.go
+	benchy *benchyResults
+
+	// rendered /v1/models response, see listModelsHandler
+	modelsCacheMutex sync.Mutex
+	modelsCache      []byte
+	modelsETag       string
+	modelsCreated    int64
+
+	// draining state, see proxymanager_drain.go
+	draining     atomic.Bool
+	switching    atomic.Bool
+	drainTimeout time.Duration
+	inFlight     atomic.Int64
 }
 
 func New(config *Config) *ProxyManager {
 	pm := &ProxyManager{
-		config:           config,
 		currentProcesses: make(map[string]*Process),
 		logMonitor:       NewLogMonitor(),
 		ginEngine:        gin.New(),
+		drainTimeout:     defaultDrainTimeout,
+		quotas:           newQuotaTracker(),
+		clientMetrics:    newClientMetricsTracker(),
+		abTests:          newABTestTracker(),
+		shadows:          newShadowTracker(),
+		recorder:         newTrafficRecorder(),
+		schedules:        newScheduleRunner(),
+		events:           newEventBus(),
+		benchy:           newBenchyResults(),
+		ports:            config.portAllocator(),
+		gpus:             config.gpuPool(),
+		standbyStarted:   make(chan struct{}),
+	}
+	pm.config.Store(config)
+	pm.setRequestState(config)
+	pm.setLogHistory(config.LogHistory)
+
+	if provider, err := newVRAMProvider(config.VRAM.Provider); err != nil {
+		fmt.Fprintf(pm.logMonitor, "!!! VRAM provider disabled: %v\n", err)
+	} else {
+		pm.vramProvider = provider
 	}
 
 	if config.LogRequests {
@@ -69,18 +171,37 @@ func New(config *Config) *ProxyManager {
 		})
 	}
 
-	// see: https://github.com/mostlygeek/llama-swap/issues/42
-	// respond with permissive OPTIONS for any endpoint
-	pm.ginEngine.Use(func(c *gin.Context) {
-		if c.Request.Method == "OPTIONS" {
-			c.Header("Access-Control-Allow-Origin", "*")
-			c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			c.AbortWithStatus(204)
-			return
+	if config.AccessLog.Path != "" {
+		if accessLog, err := NewAccessLog(config.AccessLog); err != nil {
+			fmt.Fprintf(pm.logMonitor, "!!! Access log disabled: %v\n", err)
+		} else {
+			pm.ginEngine.Use(accessLog.Middleware)
 		}
-		c.Next()
-	})
+	}
+
+	// in audit.go
+	auditLog, err := newAuditLog(config.Audit)
+	if err != nil {
+		fmt.Fprintf(pm.logMonitor, "!!! Audit log file disabled: %v\n", err)
+	}
+	pm.auditLog = auditLog
+
+	// in listeners.go
+	pm.ginEngine.Use(pm.listenerMiddleware)
+
+	// in cors.go
+	pm.ginEngine.Use(pm.corsMiddleware)
+
+	// after CORS so preflight requests are answered while draining
+	pm.ginEngine.Use(pm.drainMiddleware)
+
+	// in oidc.go and authexternal.go, after OPTIONS as preflight requests
+	// have no credentials
+	pm.ginEngine.Use(pm.oidcMiddleware)
+	pm.ginEngine.Use(pm.authExternalMiddleware)
+
+	// in trace.go
+	pm.ginEngine.Use(pm.traceMiddleware)
 
 	// Set up routes using the Gin engine
 	pm.ginEngine.POST("/v1/chat/completions", pm.proxyOAIHandler)
@@ -91,15 +212,124 @@ func New(config *Config) *ProxyManager {
 	pm.ginEngine.POST("/v1/embeddings", pm.proxyOAIHandler)
 	pm.ginEngine.POST("/v1/rerank", pm.proxyOAIHandler)
 
+	// in ollamaembeddings.go, Ollama embeddings sent to /v1/embeddings
+	pm.ginEngine.POST("/api/embed", pm.apiOllamaEmbed)
+	pm.ginEngine.POST("/api/embeddings", pm.apiOllamaEmbed)
+
 	// Support audio/speech endpoint
 	pm.ginEngine.POST("/v1/audio/speech", pm.proxyOAIHandler)
 
+	// in audio.go, multipart requests with the model in a form field
+	pm.ginEngine.POST("/v1/audio/transcriptions", pm.proxyMultipartHandler)
+	pm.ginEngine.POST("/v1/audio/translations", pm.proxyMultipartHandler)
+
+	// llama-server's tokenizer endpoints, for token counting tools
+	pm.ginEngine.POST("/tokenize", pm.proxyOAIHandler)
+	pm.ginEngine.POST("/detokenize", pm.proxyOAIHandler)
+	pm.ginEngine.POST("/apply-template", pm.proxyOAIHandler)
+
 	pm.ginEngine.GET("/v1/models", pm.listModelsHandler)
+	pm.ginEngine.GET("/running", pm.listRunningHandler)
+
+	// in readiness.go
+	for _, path := range inferencePaths {
+		pm.ginEngine.GET(path, pm.inferenceMethodHandler)
+		pm.ginEngine.HEAD(path, pm.inferenceMethodHandler)
+	}
 
 	// in proxymanager_loghandlers.go
 	pm.ginEngine.GET("/logs", pm.sendLogsHandlers)
 	pm.ginEngine.GET("/logs/stream", pm.streamLogsHandler)
 	pm.ginEngine.GET("/logs/streamSSE", pm.streamLogsHandlerSSE)
+	pm.ginEngine.GET("/api/logs/search", pm.requireAdminWhenSet, pm.apiSearchLogs)
+
+	// in proxymanager_drain.go
+	pm.ginEngine.GET("/api/drain", pm.apiDrainStatus)
+	pm.ginEngine.POST("/api/drain", pm.auditMiddleware, pm.requireAdminWhenSet, pm.apiStartDrain)
+	pm.ginEngine.DELETE("/api/drain", pm.auditMiddleware, pm.requireAdminWhenSet, pm.apiStopDrain)
+
+	// in proxymanager_confighandlers.go
+	pm.ginEngine.GET("/api/config/backups", pm.requireAdminWhenSet, pm.apiListConfigBackups)
+	pm.ginEngine.POST("/api/config/rollback", pm.auditMiddleware, pm.requireAdminWhenSet, pm.apiRollbackConfig)
+	pm.ginEngine.POST("/api/config/validate", pm.requireAdminWhenSet, pm.apiValidateConfig)
+	pm.ginEngine.GET("/api/workspaces", pm.apiListWorkspaces)
+
+	// editing models requires an admin key, see admin.go
+	configModels := pm.ginEngine.Group("/api/config/models", pm.auditMiddleware, pm.requireAdmin)
+	configModels.GET("", pm.apiListConfigModels)
+	configModels.GET("/*id", pm.apiGetConfigModel)
+	configModels.PUT("/*id", pm.apiPutConfigModel)
+	configModels.DELETE("/*id", pm.apiDeleteConfigModel)
+	pm.ginEngine.POST("/api/workspaces/:name/activate", pm.auditMiddleware, pm.requireAdminWhenSet, pm.apiActivateWorkspace)
+
+	// Ollama model management mapped to config edits, see ollamamodels.go
+	pm.ginEngine.POST("/api/create", pm.auditMiddleware, pm.requireAdmin, pm.apiOllamaCreate)
+	pm.ginEngine.POST("/api/copy", pm.auditMiddleware, pm.requireAdmin, pm.apiOllamaCopy)
+	pm.ginEngine.DELETE("/api/delete", pm.auditMiddleware, pm.requireAdmin, pm.apiOllamaDelete)
+
+	// in quota.go
+	pm.ginEngine.GET("/api/quotas", pm.apiListQuotas)
+
+	// in clients.go
+	pm.ginEngine.GET("/api/metrics/by-client", pm.apiMetricsByClient)
+
+	// in abtest.go
+	pm.ginEngine.GET("/api/abtests", pm.apiListABTests)
+
+	// in incidents.go
+	pm.ginEngine.GET("/api/incidents", pm.requireAdminWhenSet, pm.apiListIncidents)
+
+	// in decisions.go
+	pm.ginEngine.GET("/api/decisions", pm.apiListDecisions)
+
+	// in shadow.go
+	pm.ginEngine.GET("/api/shadows", pm.apiListShadows)
+
+	// in events.go
+	pm.ginEngine.GET("/api/events", pm.requireAdminWhenSet, pm.streamEventsHandler)
+
+	// in modelcontrol.go, /api/models/<model ID>/last-error and status
+	pm.ginEngine.GET("/api/models/*path", pm.apiGetModel)
+
+	// in modelcontrol.go, /api/models/<model ID>/load, unload, pin and unpin
+	// require an admin key like the other management actions when adminKeys
+	// are set, see admin.go
+	pm.ginEngine.POST("/api/models/*path", pm.auditMiddleware, pm.requireAdminWhenSet, pm.apiModelAction)
+
+	// in topology.go
+	pm.ginEngine.GET("/api/topology", pm.apiTopology)
+
+	// in benchy.go
+	pm.ginEngine.GET("/api/benchy", pm.apiListBenchy)
+
+	// in groups.go
+	pm.ginEngine.GET("/api/groups", pm.apiListGroups)
+
+	// in schedules.go
+	pm.ginEngine.GET("/api/schedules", pm.apiListSchedules)
+
+	// in upstreamstatus.go
+	pm.ginEngine.GET("/api/upstreams/status", pm.apiUpstreamsStatus)
+
+	// in upstreammetrics.go
+	pm.ginEngine.GET("/metrics", pm.requireAdminWhenSet, pm.metricsHandler)
+
+	// in trace.go
+	pm.ginEngine.GET("/api/traces/:requestID", pm.requireAdmin, pm.apiGetTrace)
+
+	// in audit.go, the management actions above are recorded with
+	// auditMiddleware
+	pm.ginEngine.GET("/api/audit", pm.requireAdmin, pm.apiListAudit)
+
+	// liveness of llama-swap itself, used by the systemd watchdog. With
+	// blockUntilStandbyReady it is not ready until the standby models started.
+	pm.ginEngine.GET("/health", func(c *gin.Context) {
+		if pm.currentConfig().BlockUntilStandbyReady && !pm.StandbyStarted() {
+			c.String(http.StatusServiceUnavailable, "starting standby models")
+			return
+		}
+		c.String(http.StatusOK, "OK")
+	})
 
 	pm.ginEngine.GET("/upstream", pm.upstreamIndex)
 	pm.ginEngine.Any("/upstream/:model_id/*upstreamPath", pm.proxyToUpstream)
@@ -132,17 +362,128 @@ func New(config *Config) *ProxyManager {
 	// Disable console color for testing
 	gin.DisableConsoleColor()
 
+	pm.checkStalePorts()
+	go pm.watchStalePorts()
+	go pm.runBenchySchedule()
+	go pm.runSchedules()
+
+	started := pm.startStandbyProcesses()
+	go func() {
+		started.Wait()
+		close(pm.standbyStarted)
+	}()
+
 	return pm
 }
 
+// StandbyStarted is true once the standby models started with llama-swap
+// are ready or failed to start
+func (pm *ProxyManager) StandbyStarted() bool {
+	select {
+	case <-pm.standbyStarted:
+		return true
+	default:
+		return false
+	}
+}
+
+// WaitForStandby waits for the standby models started with llama-swap
+func (pm *ProxyManager) WaitForStandby() {
+	<-pm.standbyStarted
+}
+
 func (pm *ProxyManager) Run(addr ...string) error {
 	return pm.ginEngine.Run(addr...)
 }
 
+func (pm *ProxyManager) RunListener(listener net.Listener) error {
+	return pm.ginEngine.RunListener(listener)
+}
+
+// currentConfig is the config in use. A reload replaces it instead of
+// changing it, so a request keeps a consistent view by reading it once.
+func (pm *ProxyManager) currentConfig() *Config {
+	return pm.config.Load()
+}
+
 func (pm *ProxyManager) HandlerFunc(w http.ResponseWriter, r *http.Request) {
 	pm.ginEngine.ServeHTTP(w, r)
 }
 
+// SetConfigPath sets the file used when the config is changed through the API
+func (pm *ProxyManager) SetConfigPath(path string) {
+	pm.configPath.Store(&path)
+}
+
+// currentConfigPath is the config file, "" when it is not set
+func (pm *ProxyManager) currentConfigPath() string {
+	if path := pm.configPath.Load(); path != nil {
+		return *path
+	}
+	return ""
+}
+
+// SetWorkspace enables switching between the named configs of w at runtime
+func (pm *ProxyManager) SetWorkspace(w *Workspace) {
+	pm.workspace = w
+}
+
+// ReloadConfig stops all running processes and switches to config. With
+// reloadProbationSeconds the previous config is restored when a model fails
+// to start during the probation, see canary.go.
+func (pm *ProxyManager) ReloadConfig(config *Config) {
+	pm.reloadConfigFile(config, "")
+}
+
+// reloadConfigFile is ReloadConfig after the config file was written.
+// configBackup is the backup of the previous file, which is restored too
+// when the probation fails.
+func (pm *ProxyManager) reloadConfigFile(config *Config, configBackup string) {
+	pm.Lock()
+	defer pm.Unlock()
+
+	pm.canary = nil
+	if config.ReloadProbationSeconds > 0 {
+		pm.canary = pm.newConfigCanary(config.ReloadProbationSeconds, configBackup)
+	}
+
+	pm.reloadConfig(config)
+}
+
+// reloadConfig switches to config, the lock must be held
+func (pm *ProxyManager) reloadConfig(config *Config) {
+	pm.stopProcesses()
+	pm.config.Store(config)
+	pm.setRequestState(config)
+	pm.ports = config.portAllocator()
+	pm.gpus = config.gpuPool()
+	pm.setLogHistory(config.LogHistory)
+	pm.recorder.closeAll()
+	pm.invalidateModelsList()
+
+	if provider, err := newVRAMProvider(config.VRAM.Provider); err != nil {
+		fmt.Fprintf(pm.logMonitor, "!!! VRAM provider disabled: %v\n", err)
+		pm.vramProvider = nil
+	} else {
+		pm.vramProvider = provider
+	}
+
+	// after the vram provider, processes use it before they start
+	pm.startStandbyProcesses()
+
+	fmt.Fprintf(pm.logMonitor, "!!! Config reloaded\n")
+	pm.events.publish(Event{Type: EventConfigReloaded})
+}
+
+// setRequestState replaces the state of config that requests read without
+// the lock, which is held during swaps
+func (pm *ProxyManager) setRequestState(config *Config) {
+	schedulers := newRequestSchedulers(config)
+	pm.schedulers.Store(&schedulers)
+	pm.responseCache.Store(newResponseCache(config.Cache))
+	pm.oidc.Store(config.oidcValidator())
+}
+
 func (pm *ProxyManager) StopProcesses() {
 	pm.Lock()
 	defer pm.Unlock()
@@ -163,39 +504,208 @@ func (pm *ProxyManager) stopProcesses() {
 	pm.currentProcesses = make(map[string]*Process)
 }
 
-func (pm *ProxyManager) listModelsHandler(c *gin.Context) {
-	data := []interface{}{}
-	for id, modelConfig := range pm.config.Models {
-		if modelConfig.Unlisted {
+// stopProcessesForSwap stops the running processes before swapping to
+// modelIDs. Standby and pinned processes and the dependencies of modelIDs are
+// kept unless their model is a member of profileName, which would start a
+// second copy of it. Processes with swapMode sleep are put to sleep instead. It
+// returns the IDs of the stopped and the sleeping models.
+func (pm *ProxyManager) stopProcessesForSwap(profileName string, modelIDs []string) ([]string, []string) {
+	config := pm.currentConfig()
+	keep := make(map[string]bool)
+	for _, process := range pm.currentProcesses {
+		if process.config.Standby || process.isPinned() {
+			keep[process.ID] = true
+			modelIDs = append(modelIDs, process.ID)
+		}
+	}
+	for modelID := range config.dependencies(modelIDs...) {
+		keep[modelID] = true
+	}
+
+	stopped, slept := []string{}, []string{}
+	for key, process := range pm.currentProcesses {
+		member := slices.Contains(config.Profiles[profileName], process.ID)
+		if (keep[process.ID] || process.isSleeping()) && !member {
 			continue
 		}
 
-		data = append(data, map[string]interface{}{
-			"id":       id,
-			"object":   "model",
-			"created":  time.Now().Unix(),
-			"owned_by": "llama-swap",
-		})
+		// members are started again with the key of the profile
+		if member {
+			process.Stop()
+		} else if pm.sleepOrStop(process) {
+			slept = append(slept, process.ID)
+			continue
+		}
+		delete(pm.currentProcesses, key)
+		stopped = append(stopped, process.ID)
 	}
+	sort.Strings(stopped)
+	sort.Strings(slept)
+	return stopped, slept
+}
 
-	// Set the Content-Type header to application/json
-	c.Header("Content-Type", "application/json")
+// startStandbyProcesses starts the standby models in the background, all at
+// once. The wait group is done when each one is ready or failed to start.
+func (pm *ProxyManager) startStandbyProcesses() *sync.WaitGroup {
+	config := pm.currentConfig()
+	started := &sync.WaitGroup{}
+	for modelID, modelConfig := range config.Models {
+		if !modelConfig.Standby {
+			continue
+		}
+
+		processKey := ProcessKeyName("", modelID)
+		if _, found := pm.currentProcesses[processKey]; found {
+			continue
+		}
 
-	if origin := c.Request.Header.Get("Origin"); origin != "" {
-		c.Header("Access-Control-Allow-Origin", origin)
+		process := pm.newProcess(modelID, modelConfig)
+		pm.currentProcesses[processKey] = process
+		pm.attachDependencies(process)
+		webhooks := config.Hooks.Webhooks
+		started.Add(1)
+		go func() {
+			defer started.Done()
+			if err := process.start(); err != nil {
+				fmt.Fprintf(pm.logMonitor, "!!! Unable to start standby model %s: %v\n", modelID, err)
+				return
+			}
+			pm.sendWebhooks(webhooks, EventModelPreloaded, modelID, "")
+			pm.events.publish(Event{Type: EventModelPreloaded, Model: modelID})
+		}()
 	}
+	return started
+}
 
-	// Encode the data as JSON and write it to the response writer
-	if err := json.NewEncoder(c.Writer).Encode(map[string]interface{}{"data": data}); err != nil {
-		pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error encoding JSON %s", err.Error()))
+func (pm *ProxyManager) listModelsHandler(c *gin.Context) {
+	body, etag := pm.renderedModelsList()
+	c.Header("ETag", etag)
+
+	if c.Request.Header.Get("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
 		return
 	}
+
+	c.Data(http.StatusOK, "application/json", body)
+}
+
+// listRunningHandler lists the processes with their state and the port and
+// GPU index assigned to models with ${PORT} or ${GPU_INDEX} in their cmd
+func (pm *ProxyManager) listRunningHandler(c *gin.Context) {
+	pm.Lock()
+	processes := make([]*Process, 0, len(pm.currentProcesses))
+	for _, process := range pm.currentProcesses {
+		processes = append(processes, process)
+	}
+	pm.Unlock()
+
+	sort.Slice(processes, func(i, j int) bool {
+		return processes[i].ID < processes[j].ID
+	})
+
+	running := make([]gin.H, 0, len(processes))
+	for _, process := range processes {
+		entry := gin.H{
+			"model": process.ID,
+			"state": process.stateNoWait(),
+			"proxy": process.proxyAddress(),
+		}
+		if port := process.port.Load(); port != 0 {
+			entry["port"] = port
+		}
+		if index, found := process.gpuIndex(); found {
+			entry["gpu"] = index
+		}
+		if pin := process.activePin(); pin != nil {
+			entry["pinned"] = true
+			if !pin.until.IsZero() {
+				entry["pinnedUntil"] = pin.until
+			}
+		}
+		running = append(running, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{"running": running})
+}
+
+// renderedModelsList returns the cached /v1/models response and its ETag,
+// rendering it once per config
+func (pm *ProxyManager) renderedModelsList() ([]byte, string) {
+	config := pm.currentConfig()
+	var props map[string]map[string]interface{}
+	if config.ProbeUpstreamProps {
+		props = pm.runningModelProps()
+	}
+
+	pm.modelsCacheMutex.Lock()
+	defer pm.modelsCacheMutex.Unlock()
+
+	if pm.modelsCreated == 0 {
+		pm.modelsCreated = time.Now().Unix()
+	}
+
+	// the props of running models change the response
+	if config.ProbeUpstreamProps {
+		return pm.renderModelsList(pm.modelsCreated, props)
+	}
+
+	if pm.modelsCache == nil {
+		pm.modelsCache, pm.modelsETag = pm.renderModelsList(pm.modelsCreated, nil)
+	}
+	return pm.modelsCache, pm.modelsETag
+}
+
+func (pm *ProxyManager) renderModelsList(created int64, props map[string]map[string]interface{}) ([]byte, string) {
+	config := pm.currentConfig()
+	ids := make([]string, 0, len(config.Models))
+	for id, modelConfig := range config.Models {
+		if !modelConfig.Unlisted {
+			ids = append(ids, id)
+		}
+	}
+	for id, virtual := range config.VirtualModels {
+		if !virtual.Unlisted {
+			ids = append(ids, id)
+		}
+	}
+	for id := range config.ABTests {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	data := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		entry := map[string]interface{}{
+			"id":       id,
+			"object":   "model",
+			"created":  created,
+			"owned_by": "llama-swap",
+		}
+		if modelProps, found := props[id]; found {
+			entry["props"] = modelProps
+		}
+		data = append(data, entry)
+	}
+
+	// a map always encodes
+	body, _ := json.Marshal(map[string]interface{}{"data": data})
+	sum := sha256.Sum256(body)
+
+	return append(body, '\n'), `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func (pm *ProxyManager) invalidateModelsList() {
+	pm.modelsCacheMutex.Lock()
+	defer pm.modelsCacheMutex.Unlock()
+	pm.modelsCache = nil
+	pm.modelsCreated = 0
 }
 
 func (pm *ProxyManager) swapModel(requestedModel string) (*Process, error) {
 	pm.Lock()
 	defer pm.Unlock()
 
+	config := pm.currentConfig()
+
 	// Check if requestedModel contains a PROFILE_SPLIT_CHAR
 	profileName, modelName := "", requestedModel
 	if idx := strings.Index(requestedModel, PROFILE_SPLIT_CHAR); idx != -1 {
@@ -204,21 +714,21 @@ func (pm *ProxyManager) swapModel(requestedModel string) (*Process, error) {
 	}
 
 	if profileName != "" {
-		if _, found := pm.config.Profiles[profileName]; !found {
-			return nil, fmt.Errorf("model group not found %s", profileName)
+		if _, found := config.Profiles[profileName]; !found {
+			return nil, fmt.Errorf("%w: model group not found %s", ErrModelNotFound, profileName)
 		}
 	}
 
 	// de-alias the real model name and get a real one
-	realModelName, found := pm.config.RealModelName(modelName)
+	realModelName, found := config.RealModelName(modelName)
 	if !found {
-		return nil, fmt.Errorf("could not find modelID for %s", requestedModel)
+		return nil, fmt.Errorf("%w: could not find modelID for %s", ErrModelNotFound, requestedModel)
 	}
 
 	// check if model is part of the profile
 	if profileName != "" {
 		found := false
-		for _, item := range pm.config.Profiles[profileName] {
+		for _, item := range config.Profiles[profileName] {
 			if item == realModelName {
 				found = true
 				break
@@ -226,48 +736,236 @@ func (pm *ProxyManager) swapModel(requestedModel string) (*Process, error) {
 		}
 
 		if !found {
-			return nil, fmt.Errorf("model %s part of profile %s", realModelName, profileName)
+			return nil, fmt.Errorf("%w: model %s not part of profile %s", ErrModelNotFound, realModelName, profileName)
 		}
 	}
 
 	// exit early when already running, otherwise stop everything and swap
 	requestedProcessKey := ProcessKeyName(profileName, realModelName)
 
-	if process, found := pm.currentProcesses[requestedProcessKey]; found {
+	if process, found := pm.currentProcesses[requestedProcessKey]; found && !process.isSleeping() {
 		return process, nil
 	}
 
-	// stop all running models
-	pm.stopProcesses()
+	modelIDs := []string{realModelName}
+	if profileName != "" {
+		modelIDs = modelIDs[:0]
+		for _, modelName := range config.Profiles[profileName] {
+			if realModelName, found := config.RealModelName(modelName); found {
+				modelIDs = append(modelIDs, realModelName)
+			}
+		}
+	}
+
+	// stop all running models unless the requested models fit next to them,
+	// profiles only stop some with a profileEvictionPolicy
+	policy := evictLRU
+	if profileName != "" {
+		policy = config.ProfileEvictionPolicy[profileName]
+	}
+	fitsVRAM := false
+	stopped, slept := []string{}, []string{}
+	if policy != "" {
+		if profileName != "" {
+			// in eviction.go
+			stopped = pm.stopMembersOutsideProfile(profileName, modelIDs)
+		}
+		fitsVRAM = pm.makeVRAMRoom(profileName, modelIDs, requestedModel, policy)
+	}
+	if !fitsVRAM {
+		swapStopped, swapSlept := pm.stopProcessesForSwap(profileName, modelIDs)
+		stopped = append(stopped, swapStopped...)
+		slept = swapSlept
+	}
 
-	if profileName == "" {
-		modelConfig, modelID, found := pm.config.FindConfig(realModelName)
+	kept := []string{}
+	for _, process := range pm.currentProcesses {
+		if !slices.Contains(modelIDs, process.ID) {
+			kept = append(kept, process.ID)
+		}
+	}
+	sort.Strings(kept)
+
+	processes := make([]*Process, 0, len(modelIDs))
+	for _, realModelName := range modelIDs {
+		modelConfig, modelID, found := config.FindConfig(realModelName)
 		if !found {
 			return nil, fmt.Errorf("could not find configuration for %s", realModelName)
 		}
 
-		process := NewProcess(modelID, pm.config.HealthCheckTimeout, modelConfig, pm.logMonitor)
+		// sleeping processes are woken up when they are started
 		processKey := ProcessKeyName(profileName, modelID)
-		pm.currentProcesses[processKey] = process
-	} else {
-		for _, modelName := range pm.config.Profiles[profileName] {
-			if realModelName, found := pm.config.RealModelName(modelName); found {
-				modelConfig, modelID, found := pm.config.FindConfig(realModelName)
-				if !found {
-					return nil, fmt.Errorf("could not find configuration for %s in group %s", realModelName, profileName)
-				}
-
-				process := NewProcess(modelID, pm.config.HealthCheckTimeout, modelConfig, pm.logMonitor)
-				processKey := ProcessKeyName(profileName, modelID)
-				pm.currentProcesses[processKey] = process
-			}
+		process, found := pm.currentProcesses[processKey]
+		if !found {
+			process = pm.newProcess(modelID, modelConfig)
+			pm.currentProcesses[processKey] = process
 		}
+		processes = append(processes, process)
 	}
 
+	// after all members of a profile exist, dependencies on them use them
+	for _, process := range processes {
+		pm.attachDependencies(process)
+	}
+
+	// in decisions.go
+	pm.recordSwapDecisions(requestedModel, modelIDs, stopped, slept, kept, fitsVRAM)
+	pm.sendWebhooks(config.Hooks.Webhooks, EventSwapOccurred, requestedModel, "")
+
 	// requestedProcessKey should exist due to swap
 	return pm.currentProcesses[requestedProcessKey], nil
 }
 
+// newProcess creates a process for a model, the lock must be held
+func (pm *ProxyManager) newProcess(modelID string, modelConfig ModelConfig) *Process {
+	config := pm.currentConfig()
+	process := NewProcess(modelID, config.HealthCheckTimeout, modelConfig, pm.logMonitor)
+	process.preStartCheck = pm.vramPreStartCheck(modelID, modelConfig)
+	process.probeProps = config.ProbeUpstreamProps
+	process.startupErrorDetails = config.StartupErrorDetails
+	process.ports = pm.ports
+	process.gpus = pm.gpus
+
+	process.onStartFailed = func(err error) {
+		pm.processStartFailed(config, modelID, err)
+	}
+
+	// in webhooks.go
+	process.onStarted = func() {
+		pm.sendWebhooks(config.Hooks.Webhooks, EventProcessStarted, modelID, "")
+	}
+	process.onCrashed = func(err error) {
+		pm.sendWebhooks(config.Hooks.Webhooks, EventProcessCrashed, modelID, fmt.Sprintf("exited unexpectedly: %v", err))
+	}
+	process.onUnhealthy = func(err error) {
+		pm.sendWebhooks(config.Hooks.Webhooks, EventProcessUnhealthy, modelID, err.Error())
+	}
+	process.onRecycled = func() {
+		message := fmt.Sprintf("restarted after its maxLifetime of %s", modelConfig.MaxLifetime)
+		pm.sendWebhooks(config.Hooks.Webhooks, EventProcessRecycled, modelID, message)
+		pm.events.publish(Event{Type: EventProcessRecycled, Model: modelID})
+	}
+
+	// in events.go
+	process.onStateChange = func(from, to ProcessState) {
+		pm.events.publish(Event{Type: EventProcessState, Model: modelID, Previous: from, State: to})
+	}
+	return process
+}
+
+// makeVRAMRoom stops processes in the order of the eviction policy until the
+// models of profileName fit into the available VRAM, see eviction.go. Members
+// already running in the profile are kept. It returns false when the decision
+// can not be made or the models do not fit, in which case all processes
+// should be stopped. trigger is the requested model recorded in the
+// decisions.
+func (pm *ProxyManager) makeVRAMRoom(profileName string, modelIDs []string, trigger, policy string) bool {
+	config := pm.currentConfig()
+	if !config.VRAM.Enabled() {
+		return false
+	}
+
+	need := 0
+	running := []string{}
+	for _, modelID := range modelIDs {
+		if process, found := pm.currentProcesses[ProcessKeyName(profileName, modelID)]; found && !process.isSleeping() {
+			continue
+		}
+		if config.Models[modelID].VramEstimateMB <= 0 {
+			return false
+		}
+		need += config.Models[modelID].VramEstimateMB
+		running = append(running, modelID)
+	}
+
+	usedMB := 0
+	candidates := make([]*Process, 0, len(pm.currentProcesses))
+	members := make(map[*Process]bool)
+	for key, process := range pm.currentProcesses {
+		// sleeping models freed their GPU memory
+		if process.isSleeping() {
+			continue
+		}
+
+		// every running model needs an estimate to know if there is room
+		if process.config.VramEstimateMB <= 0 {
+			return false
+		}
+		usedMB += process.config.VramEstimateMB
+		running = append(running, process.ID)
+		if slices.Contains(modelIDs, process.ID) && key == ProcessKeyName(profileName, process.ID) {
+			members[process] = true
+		} else {
+			candidates = append(candidates, process)
+		}
+	}
+
+	// dependencies that are not running need room too, those of the models
+	// and of the running models are not stopped
+	for dependency := range config.dependencies(modelIDs...) {
+		if !slices.Contains(running, dependency) {
+			if config.Models[dependency].VramEstimateMB <= 0 {
+				return false
+			}
+			need += config.Models[dependency].VramEstimateMB
+		}
+	}
+	protected := config.dependencies(running...)
+
+	availableMB := math.MaxInt
+	if config.VRAM.BudgetMB > 0 {
+		availableMB = config.VRAM.BudgetMB - usedMB
+	}
+	if pm.vramProvider != nil {
+		freeMB, err := pm.vramProvider.FreeMB()
+		if err != nil {
+			fmt.Fprintf(pm.logMonitor, "!!! Unable to read free VRAM: %v\n", err)
+			return false
+		}
+		availableMB = min(availableMB, freeMB)
+	}
+
+	// in eviction.go, standby models are evicted last
+	sortForEviction(candidates, policy)
+
+	for i, process := range candidates {
+		if availableMB >= need {
+			break
+		}
+		if protected[process.ID] || process.isPinned() {
+			continue
+		}
+
+		// the models evicted after this one
+		alternatives := []string{}
+		for _, other := range candidates[i+1:] {
+			if !protected[other.ID] && !other.isPinned() {
+				alternatives = append(alternatives, other.ID)
+			}
+		}
+
+		action := "put to sleep"
+		if !pm.sleepOrStop(process) {
+			action = "stopped"
+			for key, p := range pm.currentProcesses {
+				if p == process {
+					delete(pm.currentProcesses, key)
+				}
+			}
+		}
+		pm.recordDecision(Decision{
+			Kind:         DecisionEvict,
+			Model:        process.ID,
+			Trigger:      trigger,
+			Reason:       fmt.Sprintf("%s, %s to free %dMB VRAM for %s", evictionReason(policy, process), action, process.config.VramEstimateMB, strings.Join(modelIDs, ", ")),
+			Alternatives: alternatives,
+		})
+		availableMB += process.config.VramEstimateMB
+	}
+
+	return availableMB >= need
+}
+
 func (pm *ProxyManager) proxyToUpstream(c *gin.Context) {
 	requestedModel := c.Param("model_id")
 
@@ -277,8 +975,10 @@ func (pm *ProxyManager) proxyToUpstream(c *gin.Context) {
 	}
 
 	if process, err := pm.swapModel(requestedModel); err != nil {
-		pm.sendErrorResponse(c, http.StatusNotFound, fmt.Sprintf("unable to swap to model, %s", err.Error()))
+		pm.sendSwapError(c, requestedModel, err)
 	} else {
+		setAccessLogKeys(c, requestedModel, process)
+
 		// rewrite the path
 		c.Request.URL.Path = c.Param("upstreamPath")
 		process.ProxyRequest(c.Writer, c.Request)
@@ -292,7 +992,7 @@ func (pm *ProxyManager) upstreamIndex(c *gin.Context) {
 
 	// Extract keys and sort them
 	var modelIDs []string
-	for modelID, modelConfig := range pm.config.Models {
+	for modelID, modelConfig := range pm.currentConfig().Models {
 		if modelConfig.Unlisted {
 			continue
 		}
@@ -311,9 +1011,16 @@ func (pm *ProxyManager) upstreamIndex(c *gin.Context) {
 }
 
 func (pm *ProxyManager) proxyOAIHandler(c *gin.Context) {
-	bodyBytes, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		pm.sendErrorResponse(c, http.StatusBadRequest, "could not ready request body")
+	// in bodystream.go
+	streamed, removeSpool := pm.proxyStreamedBody(c)
+	defer removeSpool()
+	if streamed {
+		return
+	}
+
+	// in bodylimit.go
+	bodyBytes, ok := pm.readRequestBody(c)
+	if !ok {
 		return
 	}
 
@@ -322,24 +1029,128 @@ func (pm *ProxyManager) proxyOAIHandler(c *gin.Context) {
 		pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %s", err.Error()))
 		return
 	}
-	model, ok := requestBody["model"].(string)
-	if !ok {
+	model, _ := requestBody["model"].(string)
+	traceRequest(c, "requested model %q", model)
+	if routedModel := pm.currentConfig().RouteModel(c.Request, requestBody, model); routedModel != model {
+		traceRequest(c, "routing rules changed the model to %q", routedModel)
+		model = routedModel
+		requestBody["model"] = model
+		var err error
+		if bodyBytes, err = json.Marshal(requestBody); err != nil {
+			pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error encoding JSON %s", err.Error()))
+			return
+		}
+	}
+
+	if model == "" {
 		pm.sendErrorResponse(c, http.StatusBadRequest, "missing or invalid 'model' key")
 		return
 	}
 
-	if process, err := pm.swapModel(model); err != nil {
-		pm.sendErrorResponse(c, http.StatusNotFound, fmt.Sprintf("unable to swap to model, %s", err.Error()))
+	// in abtest.go
+	model, bodyBytes, recordVariant, ok := pm.applyABTest(c, model, requestBody, bodyBytes)
+	if !ok {
 		return
-	} else {
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	}
+	defer recordVariant()
 
-		// dechunk it as we already have all the body bytes see issue #11
-		c.Request.Header.Del("transfer-encoding")
-		c.Request.Header.Add("content-length", strconv.Itoa(len(bodyBytes)))
+	// in virtualmodels.go
+	if model, bodyBytes, ok = pm.applyVirtualModel(c, model, requestBody, bodyBytes); !ok {
+		return
+	}
 
-		process.ProxyRequest(c.Writer, c.Request)
+	if !pm.checkModelBodyLimit(c, model, bodyBytes) {
+		return
+	}
+
+	// in tasktype.go
+	if !pm.checkTaskType(c, model) {
+		return
+	}
+
+	// in limits.go
+	if !pm.checkContextLimit(c, model, requestBody) {
+		return
 	}
+
+	// in ttl.go
+	_, keepAlive := requestBody["keep_alive"]
+	ttl, hasTTL, err := requestTTL(c, requestBody)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if hasTTL {
+		traceRequest(c, "requested a ttl of %ds", ttl)
+		c.Set(ctxKeyRequestTTL, ttl)
+	}
+	if keepAlive {
+		if bodyBytes, err = json.Marshal(requestBody); err != nil {
+			pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error encoding JSON %s", err.Error()))
+			return
+		}
+	}
+
+	// in clients.go
+	defer pm.recordClientMetrics(c, model)()
+
+	recordUsage, ok := pm.checkQuota(c, model)
+	if !ok {
+		return
+	}
+	defer recordUsage()
+
+	// in cache.go
+	cacheResponse, served := pm.serveFromCache(c, requestBody)
+	if served {
+		return
+	}
+	defer cacheResponse()
+
+	release, err := pm.acquireProfileSlot(c, model)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusServiceUnavailable, "request cancelled while waiting for a free slot")
+		return
+	}
+	defer release()
+
+	// in record.go
+	defer pm.recordTraffic(c, model, requestBody)()
+
+	// in shadow.go
+	defer pm.mirrorToShadow(c, model, requestBody)()
+
+	// in events.go
+	defer pm.publishTokens(c, model)()
+
+	// in fallback.go
+	pm.proxyWithFallback(c, model, requestBody, bodyBytes)
+}
+
+// sendSwapError responds to a failed swap. Unknown models get an OpenAI style
+// model_not_found error on /v1 endpoints.
+func (pm *ProxyManager) sendSwapError(c *gin.Context, requestedModel string, err error) {
+	if !errors.Is(err, ErrModelNotFound) {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("unable to swap to model, %s", err.Error()))
+		return
+	}
+
+	statusCode := pm.currentConfig().UnknownModelStatus
+	if statusCode == 0 {
+		statusCode = http.StatusNotFound
+	}
+
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/") {
+		c.JSON(statusCode, gin.H{"error": gin.H{
+			"message": fmt.Sprintf("The model `%s` does not exist, %s", requestedModel, err.Error()),
+			"type":    "invalid_request_error",
+			"param":   "model",
+			"code":    "model_not_found",
+		}})
+		return
+	}
+
+	pm.sendErrorResponse(c, statusCode, fmt.Sprintf("unable to swap to model, %s", err.Error()))
 }
 
 func (pm *ProxyManager) sendErrorResponse(c *gin.Context, statusCode int, message string) {
@@ -352,6 +1163,18 @@ func (pm *ProxyManager) sendErrorResponse(c *gin.Context, statusCode int, messag
 	}
 }
 
+// setAccessLogKeys records the model details of the request for the access log
+func setAccessLogKeys(c *gin.Context, requestedModel string, process *Process) {
+	c.Set(ctxKeyModel, process.ID)
+	if len(process.config.Metadata) > 0 {
+		c.Set(ctxKeyMetadata, process.config.Metadata)
+	}
+	if profileName, _, found := strings.Cut(requestedModel, PROFILE_SPLIT_CHAR); found {
+		c.Set(ctxKeyProfile, profileName)
+	}
+	c.Set(ctxKeySwapped, process.CurrentState() != StateReady)
+}
+
 func ProcessKeyName(groupName, modelName string) string {
 	return groupName + PROFILE_SPLIT_CHAR + modelName
 }
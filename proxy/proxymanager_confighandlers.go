@@ -0,0 +1,309 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (pm *ProxyManager) apiListConfigBackups(c *gin.Context) {
+	configPath := pm.currentConfigPath()
+	if configPath == "" {
+		pm.sendErrorResponse(c, http.StatusNotFound, "config file path not set")
+		return
+	}
+
+	backups, err := ConfigBackups(configPath)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"backups": backups})
+}
+
+// apiRollbackConfig restores a config backup and reloads it. The backup is
+// selected with the optional "backup" field, default is the newest one.
+func (pm *ProxyManager) apiRollbackConfig(c *gin.Context) {
+	configPath := pm.currentConfigPath()
+	if configPath == "" {
+		pm.sendErrorResponse(c, http.StatusNotFound, "config file path not set")
+		return
+	}
+
+	var request struct {
+		Backup string `json:"backup"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %s", err.Error()))
+			return
+		}
+	}
+
+	config, err := RestoreConfigBackup(configPath, request.Backup, pm.currentConfig().ConfigBackups)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("rollback failed: %s", err.Error()))
+		return
+	}
+
+	pm.reloadConfigFile(config, newestConfigBackup(configPath))
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (pm *ProxyManager) apiListWorkspaces(c *gin.Context) {
+	if pm.workspace == nil {
+		pm.sendErrorResponse(c, http.StatusNotFound, "workspace not enabled, start with --workspace")
+		return
+	}
+
+	names, err := pm.workspace.Names()
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"active": pm.workspace.Active(), "configs": names})
+}
+
+// apiActivateWorkspace switches to another config of the workspace. New
+// requests are held off like when draining while in-flight requests finish.
+func (pm *ProxyManager) apiActivateWorkspace(c *gin.Context) {
+	if pm.workspace == nil {
+		pm.sendErrorResponse(c, http.StatusNotFound, "workspace not enabled, start with --workspace")
+		return
+	}
+
+	name := c.Param("name")
+	path, err := pm.workspace.Path(name)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("unable to load config %s: %s", name, err.Error()))
+		return
+	}
+
+	if pm.draining.Load() {
+		pm.sendErrorResponse(c, http.StatusConflict, "llama-swap is draining")
+		return
+	}
+	if !pm.switching.CompareAndSwap(false, true) {
+		pm.sendErrorResponse(c, http.StatusConflict, "a config switch is in progress")
+		return
+	}
+	defer pm.switching.Store(false)
+
+	fmt.Fprintf(pm.logMonitor, "!!! Switching to workspace config %s\n", name)
+	pm.waitForInFlight()
+	if pm.draining.Load() {
+		pm.sendErrorResponse(c, http.StatusConflict, "llama-swap is draining")
+		return
+	}
+
+	// edits of the models API go to the config file of the new config
+	pm.configEditMutex.Lock()
+	pm.ReloadConfig(config)
+	pm.SetConfigPath(path)
+	pm.workspace.setActive(name)
+	pm.configEditMutex.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{"active": name})
+}
+
+// apiValidateConfig checks the YAML config in the request body without
+// loading it
+func (pm *ProxyManager) apiValidateConfig(c *gin.Context) {
+	_, issues := ValidateConfig(c.Request.Body)
+	if issues == nil {
+		issues = []ValidationIssue{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"valid":  !ValidationFailed(issues),
+		"issues": issues,
+	})
+}
+
+// apiListConfigModels returns the models as written in the config file
+func (pm *ProxyManager) apiListConfigModels(c *gin.Context) {
+	models, ok := pm.readConfigModels(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"models": models})
+}
+
+func (pm *ProxyManager) apiGetConfigModel(c *gin.Context) {
+	models, ok := pm.readConfigModels(c)
+	if !ok {
+		return
+	}
+
+	id := configModelID(c)
+	model, found := models[id]
+	if !found {
+		pm.sendErrorResponse(c, http.StatusNotFound, fmt.Sprintf("model %s not found", id))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "model": model})
+}
+
+// apiPutConfigModel adds or replaces a model in the config file and reloads
+// it. The body is the model's config as a JSON object. With ?dryRun=true the
+// change is only previewed, see sendConfigPreview.
+func (pm *ProxyManager) apiPutConfigModel(c *gin.Context) {
+	if pm.currentConfigPath() == "" {
+		pm.sendErrorResponse(c, http.StatusNotFound, "config file path not set")
+		return
+	}
+
+	id := configModelID(c)
+	if id == "" {
+		pm.sendErrorResponse(c, http.StatusBadRequest, "missing model ID")
+		return
+	}
+
+	var model map[string]interface{}
+	if err := c.ShouldBindJSON(&model); err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %s", err.Error()))
+		return
+	}
+
+	pm.configEditMutex.Lock()
+	defer pm.configEditMutex.Unlock()
+
+	configPath := pm.currentConfigPath()
+	current, err := os.ReadFile(configPath)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	data, added, err := SetConfigModel(current, id, model)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if isDryRun(c) {
+		pm.sendConfigPreview(c, configPath, current, data, id)
+		return
+	}
+	if !pm.writeAndReloadConfig(c, configPath, data) {
+		return
+	}
+
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{"id": id, "model": model})
+}
+
+func (pm *ProxyManager) apiDeleteConfigModel(c *gin.Context) {
+	if pm.currentConfigPath() == "" {
+		pm.sendErrorResponse(c, http.StatusNotFound, "config file path not set")
+		return
+	}
+
+	pm.configEditMutex.Lock()
+	defer pm.configEditMutex.Unlock()
+
+	configPath := pm.currentConfigPath()
+	current, err := os.ReadFile(configPath)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id := configModelID(c)
+	data, err := DeleteConfigModel(current, id)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if isDryRun(c) {
+		pm.sendConfigPreview(c, configPath, current, data, id)
+		return
+	}
+	if pm.writeAndReloadConfig(c, configPath, data) {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// configModelID is the model ID of /api/config/models/*id, it may contain
+// slashes like Qwen/Qwen2.5-7B
+func configModelID(c *gin.Context) string {
+	return strings.TrimPrefix(c.Param("id"), "/")
+}
+
+func (pm *ProxyManager) readConfigModels(c *gin.Context) (map[string]interface{}, bool) {
+	configPath := pm.currentConfigPath()
+	if configPath == "" {
+		pm.sendErrorResponse(c, http.StatusNotFound, "config file path not set")
+		return nil, false
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	models, err := ConfigModels(data)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return models, true
+}
+
+// isDryRun is true for config edits with ?dryRun=true
+func isDryRun(c *gin.Context) bool {
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+	return dryRun
+}
+
+// sendConfigPreview responds with the entry of model id in the edited config
+// data, none when it was deleted, and a unified diff of the config file,
+// without writing it. Invalid configs are rejected with a 400 like by
+// writeAndReloadConfig.
+func (pm *ProxyManager) sendConfigPreview(c *gin.Context, configPath string, current, data []byte, id string) {
+	if _, err := LoadConfigFromBytes(data); err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid config: %s", err.Error()))
+		return
+	}
+
+	response := gin.H{"dryRun": true, "id": id, "diff": ConfigDiff(configPath, current, data)}
+	if models, err := ConfigModels(data); err == nil {
+		if model, found := models[id]; found {
+			response["model"] = model
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// writeAndReloadConfig validates data, writes it to the config file and
+// reloads it. Invalid configs are rejected with a 400.
+func (pm *ProxyManager) writeAndReloadConfig(c *gin.Context, configPath string, data []byte) bool {
+	config, err := LoadConfigFromBytes(data)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid config: %s", err.Error()))
+		return false
+	}
+
+	if err := WriteConfigFile(configPath, data, pm.currentConfig().ConfigBackups); err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return false
+	}
+
+	fmt.Fprintf(pm.logMonitor, "!!! Config file %s changed with the models API\n", configPath)
+	pm.reloadConfigFile(config, newestConfigBackup(configPath))
+	return true
+}
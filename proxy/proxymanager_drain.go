@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultDrainTimeout = 30 * time.Second
+
+func (pm *ProxyManager) SetDrainTimeout(timeout time.Duration) {
+	pm.drainTimeout = timeout
+}
+
+// drainMiddleware tracks in-flight requests and rejects (or forwards to the
+// drain peer) new ones once the ProxyManager is draining or switching configs.
+// Management, log and health endpoints are always served.
+func (pm *ProxyManager) drainMiddleware(c *gin.Context) {
+	path := c.Request.URL.Path
+	if (strings.HasPrefix(path, "/api/") && !isOllamaInferencePath(path)) || strings.HasPrefix(path, "/logs") || path == "/health" {
+		c.Next()
+		return
+	}
+
+	// count the request before checking the state so Drain() can not miss it
+	pm.inFlight.Add(1)
+	defer pm.inFlight.Add(-1)
+
+	if !pm.draining.Load() && !pm.switching.Load() {
+		c.Next()
+		return
+	}
+
+	if drainPeer := pm.currentConfig().DrainPeer; drainPeer != "" {
+		peerURL, err := url.Parse(drainPeer)
+		if err != nil {
+			pm.sendErrorResponse(c, http.StatusBadGateway, fmt.Sprintf("invalid drainPeer: %s", err.Error()))
+			c.Abort()
+			return
+		}
+		httputil.NewSingleHostReverseProxy(peerURL).ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+		return
+	}
+
+	retryAfter := int(math.Ceil(pm.drainTimeout.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	if pm.draining.Load() {
+		pm.sendErrorResponse(c, http.StatusServiceUnavailable, "llama-swap is draining, try again later")
+	} else {
+		pm.sendErrorResponse(c, http.StatusServiceUnavailable, "llama-swap is switching configs, try again later")
+	}
+	c.Abort()
+}
+
+// Drain stops accepting new requests, waits up to the drain timeout for
+// in-flight requests to complete and then stops all upstream processes.
+func (pm *ProxyManager) Drain() {
+	if !pm.draining.CompareAndSwap(false, true) {
+		return
+	}
+
+	fmt.Fprintf(pm.logMonitor, "!!! Draining, waiting up to %v for %d in-flight requests\n", pm.drainTimeout, pm.inFlight.Load())
+	pm.waitForInFlight()
+	if !pm.draining.Load() {
+		fmt.Fprintf(pm.logMonitor, "!!! Drain cancelled, processes kept\n")
+		return
+	}
+	pm.StopProcesses()
+	fmt.Fprintf(pm.logMonitor, "!!! Drain complete, all processes stopped\n")
+}
+
+// Undrain accepts new requests again, models are loaded by them as usual.
+// A drain still waiting for in-flight requests does not stop the processes.
+func (pm *ProxyManager) Undrain() {
+	if pm.draining.CompareAndSwap(true, false) {
+		fmt.Fprintf(pm.logMonitor, "!!! Drain cancelled, accepting requests\n")
+	}
+}
+
+// waitForInFlight waits up to the drain timeout for in-flight requests
+func (pm *ProxyManager) waitForInFlight() {
+	deadline := time.Now().Add(pm.drainTimeout)
+	for pm.inFlight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if n := pm.inFlight.Load(); n > 0 {
+		fmt.Fprintf(pm.logMonitor, "!!! Drain timeout reached with %d requests in-flight\n", n)
+	}
+}
+
+func (pm *ProxyManager) IsDraining() bool {
+	return pm.draining.Load()
+}
+
+func (pm *ProxyManager) apiStartDrain(c *gin.Context) {
+	go pm.Drain()
+	c.JSON(http.StatusAccepted, gin.H{"status": "draining"})
+}
+
+func (pm *ProxyManager) apiStopDrain(c *gin.Context) {
+	pm.Undrain()
+	c.JSON(http.StatusOK, gin.H{"status": "accepting"})
+}
+
+func (pm *ProxyManager) apiDrainStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"draining": pm.draining.Load(),
+		"inFlight": pm.inFlight.Load(),
+	})
+}
@@ -3,7 +3,10 @@ package proxy
 import (
 	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -111,3 +114,62 @@ func (pm *ProxyManager) streamLogsHandlerSSE(c *gin.Context) {
 		}
 	}
 }
+
+// setLogHistory keeps the logs on disk when logHistory has a path, an
+// unchanged config keeps the current history
+func (pm *ProxyManager) setLogHistory(config LogHistoryConfig) {
+	if current := pm.logMonitor.history.Load(); current != nil && current.config == config {
+		return
+	}
+	var history *logHistory
+	if config.Path != "" {
+		history = newLogHistory(config, pm.logMonitor.stdout)
+	}
+	pm.logMonitor.setHistory(history)
+}
+
+// apiSearchLogs searches the log history for GET /api/logs/search. q is a
+// regular expression, since a time (RFC 3339) or a duration before now like
+// 2h. limit is the number of last matching lines returned.
+func (pm *ProxyManager) apiSearchLogs(c *gin.Context) {
+	history := pm.logMonitor.history.Load()
+	if history == nil {
+		pm.sendErrorResponse(c, http.StatusNotFound, "logHistory is not enabled")
+		return
+	}
+
+	query := logQuery{model: c.Query("model"), limit: defaultLogSearchLimit}
+	if q := c.Query("q"); q != "" {
+		pattern, err := regexp.Compile(q)
+		if err != nil {
+			pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid q: %v", err))
+			return
+		}
+		query.pattern = pattern
+	}
+	if since := c.Query("since"); since != "" {
+		if t, err := time.Parse(time.RFC3339, since); err == nil {
+			query.since = t
+		} else if d, err := time.ParseDuration(since); err == nil && d >= 0 {
+			query.since = time.Now().Add(-d)
+		} else {
+			pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("invalid since %q, use a time like 2025-01-02T15:04:05Z or a duration like 2h", since))
+			return
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > maxLogSearchLimit {
+			pm.sendErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLogSearchLimit))
+			return
+		}
+		query.limit = n
+	}
+
+	lines, err := history.search(query)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lines": lines})
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -211,6 +212,48 @@ func TestProxyManager_ListModelsHandler(t *testing.T) {
 	assert.Empty(t, expectedModels, "not all expected models were returned")
 }
 
+func TestProxyManager_ListModelsETag(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+	})
+
+	listModels := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/models", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		return w
+	}
+
+	w := listModels("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// cached until the config changes
+	w = listModels(etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	proxy.ReloadConfig(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+			"model2": getTestSimpleResponderConfig("model2"),
+		},
+	})
+
+	w = listModels(etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), "model2")
+}
+
 func TestProxyManager_ProfileNonMember(t *testing.T) {
 
 	model1 := "path1/model1"
@@ -254,3 +297,309 @@ func TestProxyManager_ProfileNonMember(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, w.Code)
 	}
 }
+
+func TestProxyManager_Drain(t *testing.T) {
+	config := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	reqBody := `{"model":"model1"}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(reqBody))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, proxy.currentProcesses, 1)
+
+	proxy.SetDrainTimeout(2 * time.Second)
+	proxy.Drain()
+	assert.True(t, proxy.IsDraining())
+	assert.Len(t, proxy.currentProcesses, 0)
+
+	req = httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(reqBody))
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	// management endpoints are still available
+	req = httptest.NewRequest("GET", "/api/drain", nil)
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"draining":true`)
+
+	// preflight requests are still answered
+	req = httptest.NewRequest("OPTIONS", "/v1/chat/completions", nil)
+	req.Header.Set("Origin", "https://webui.example.com")
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// undrain, the model is loaded again by the next request
+	req = httptest.NewRequest("DELETE", "/api/drain", nil)
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, proxy.IsDraining())
+
+	req = httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(reqBody))
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "model1", w.Body.String())
+}
+
+func TestProxyManager_DrainForwardsToPeer(t *testing.T) {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("from peer " + r.URL.Path))
+	}))
+	defer peer.Close()
+
+	config := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+		DrainPeer: peer.URL,
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+	proxy.Drain()
+
+	// httputil.ReverseProxy requires a CloseNotifier, use a real server
+	srv := httptest.NewServer(http.HandlerFunc(proxy.HandlerFunc))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v1/chat/completions", "application/json", bytes.NewBufferString(`{"model":"model1"}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "from peer /v1/chat/completions", string(body))
+}
+
+func TestProxyManager_VRAMBudgetKeepsModelsThatFit(t *testing.T) {
+	model1 := getTestSimpleResponderConfig("model1")
+	model1.VramEstimateMB = 4000
+	model2 := getTestSimpleResponderConfig("model2")
+	model2.VramEstimateMB = 4000
+	model3 := getTestSimpleResponderConfig("model3")
+	model3.VramEstimateMB = 6000
+
+	config := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": model1,
+			"model2": model2,
+			"model3": model3,
+		},
+		VRAM: VRAMConfig{BudgetMB: 10000},
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	for _, modelName := range []string{"model1", "model2"} {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(fmt.Sprintf(`{"model":"%s"}`, modelName)))
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Len(t, proxy.currentProcesses, 2)
+
+	// model3 only fits after evicting the least recently used model1
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model3"}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, proxy.currentProcesses, 2)
+	assert.NotContains(t, proxy.currentProcesses, ProcessKeyName("", "model1"))
+	assert.Contains(t, proxy.currentProcesses, ProcessKeyName("", "model2"))
+	assert.Contains(t, proxy.currentProcesses, ProcessKeyName("", "model3"))
+}
+
+func TestProxyManager_StandbyModelSurvivesSwaps(t *testing.T) {
+	standby := getTestSimpleResponderConfig("standby")
+	standby.Standby = true
+
+	config := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"standby": standby,
+			"model1":  getTestSimpleResponderConfig("model1"),
+			"model2":  getTestSimpleResponderConfig("model2"),
+		},
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	standbyKey := ProcessKeyName("", "standby")
+	proxy.Lock()
+	standbyProcess, found := proxy.currentProcesses[standbyKey]
+	proxy.Unlock()
+	if !assert.True(t, found, "standby model is started at boot") {
+		return
+	}
+	assert.Eventually(t, func() bool {
+		return standbyProcess.CurrentState() == StateReady
+	}, 5*time.Second, 50*time.Millisecond)
+
+	for _, modelName := range []string{"model1", "model2", "standby"} {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(fmt.Sprintf(`{"model":"%s"}`, modelName)))
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, modelName, w.Body.String())
+	}
+
+	assert.Len(t, proxy.currentProcesses, 2)
+	assert.Same(t, standbyProcess, proxy.currentProcesses[standbyKey])
+	assert.Equal(t, StateReady, standbyProcess.CurrentState())
+}
+
+func TestProxyManager_BlockUntilStandbyReady(t *testing.T) {
+	standby1 := getTestSimpleResponderConfig("standby1")
+	standby1.Standby = true
+	standby2 := getTestSimpleResponderConfig("standby2")
+	standby2.Standby = true
+
+	proxy := New(&Config{
+		HealthCheckTimeout:     15,
+		BlockUntilStandbyReady: true,
+		Models:                 map[string]ModelConfig{"standby1": standby1, "standby2": standby2},
+	})
+	defer proxy.StopProcesses()
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	proxy.WaitForStandby()
+	for _, process := range proxy.currentProcesses {
+		assert.Equal(t, StateReady, process.CurrentState())
+	}
+
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestProxyManager_TokenizerEndpoints(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", r.URL.Path, body)
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Proxy = upstream.URL
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+	})
+	defer proxy.StopProcesses()
+
+	for _, path := range []string{"/tokenize", "/detokenize", "/apply-template"} {
+		req := httptest.NewRequest("POST", path, bytes.NewBufferString(`{"model":"model1","content":"hi"}`))
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, path+` {"model":"model1","content":"hi"}`, w.Body.String())
+	}
+}
+
+func TestProxyManager_UnknownModelResponses(t *testing.T) {
+	config := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+		Profiles: map[string][]string{
+			"test": {"model1"},
+		},
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	for _, path := range []string{"/v1/chat/completions", "/v1/completions", "/v1/embeddings", "/v1/rerank", "/v1/audio/speech"} {
+		for _, model := range []string{"nope", "test:nope", "nope:model1"} {
+			req := httptest.NewRequest("POST", path, bytes.NewBufferString(fmt.Sprintf(`{"model":"%s"}`, model)))
+			w := httptest.NewRecorder()
+			proxy.HandlerFunc(w, req)
+			assert.Equal(t, http.StatusNotFound, w.Code, "%s %s", path, model)
+
+			var response struct {
+				Error struct {
+					Code  string `json:"code"`
+					Param string `json:"param"`
+				} `json:"error"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "model_not_found", response.Error.Code)
+			assert.Equal(t, "model", response.Error.Param)
+		}
+
+		// missing model is still a bad request
+		req := httptest.NewRequest("POST", path, bytes.NewBufferString(`{}`))
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/upstream/nope/health", nil)
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// legacy status code
+	config.UnknownModelStatus = http.StatusBadRequest
+	req = httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"nope"}`))
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "model_not_found")
+}
+
+func TestProxyManager_ReloadWhileRouting(t *testing.T) {
+	newConfig := func(modelID string) *Config {
+		return &Config{
+			HealthCheckTimeout: 15,
+			Models: map[string]ModelConfig{
+				modelID: {Cmd: "path/to/cmd", Proxy: "http://127.0.0.1:9999"},
+			},
+		}
+	}
+	proxy := New(newConfig("model1"))
+	defer proxy.StopProcesses()
+
+	// requests read the config while it is replaced, see currentConfig
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			proxy.ReloadConfig(newConfig(fmt.Sprintf("model%d", i%2+1)))
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		for _, path := range []string{"/v1/models", "/api/topology", "/api/groups"} {
+			w := httptest.NewRecorder()
+			proxy.HandlerFunc(w, httptest.NewRequest("GET", path, nil))
+			assert.Equal(t, http.StatusOK, w.Code, path)
+		}
+	}
+	wg.Wait()
+}
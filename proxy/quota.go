@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// QuotaAmount is a count that can be written with a k, M or G suffix
+type QuotaAmount int64
+
+func (q *QuotaAmount) UnmarshalYAML(value *yaml.Node) error {
+	s := strings.TrimSpace(value.Value)
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier, s = 1_000, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "M"):
+		multiplier, s = 1_000_000, strings.TrimSuffix(s, "M")
+	case strings.HasSuffix(s, "G"):
+		multiplier, s = 1_000_000_000, strings.TrimSuffix(s, "G")
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid quota amount: %s", value.Value)
+	}
+	*q = QuotaAmount(n * float64(multiplier))
+	return nil
+}
+
+// QuotaConfig limits the usage of a model per API key, 0 = no limit
+type QuotaConfig struct {
+	DailyTokens     QuotaAmount `yaml:"dailyTokens"`
+	MonthlyTokens   QuotaAmount `yaml:"monthlyTokens"`
+	DailyRequests   QuotaAmount `yaml:"dailyRequests"`
+	MonthlyRequests QuotaAmount `yaml:"monthlyRequests"`
+}
+
+// quotaUsage is the usage of a model by an API key in the current day and
+// month
+type quotaUsage struct {
+	Key             string `json:"key"`
+	Model           string `json:"model"`
+	Day             string `json:"day"`
+	Month           string `json:"month"`
+	DailyTokens     int64  `json:"dailyTokens"`
+	MonthlyTokens   int64  `json:"monthlyTokens"`
+	DailyRequests   int64  `json:"dailyRequests"`
+	MonthlyRequests int64  `json:"monthlyRequests"`
+
+	// for evicting the least recently used usage
+	lastSeen time.Time
+}
+
+// reset starts new periods when the day or month has changed
+func (u *quotaUsage) reset(now time.Time) {
+	if day := now.Format("2006-01-02"); u.Day != day {
+		u.Day, u.DailyTokens, u.DailyRequests = day, 0, 0
+	}
+	if month := now.Format("2006-01"); u.Month != month {
+		u.Month, u.MonthlyTokens, u.MonthlyRequests = month, 0, 0
+	}
+}
+
+// exceeded describes the first limit that is used up
+func (u *quotaUsage) exceeded(quota QuotaConfig) string {
+	switch {
+	case quota.DailyTokens > 0 && u.DailyTokens >= int64(quota.DailyTokens):
+		return fmt.Sprintf("daily token quota of %d", quota.DailyTokens)
+	case quota.MonthlyTokens > 0 && u.MonthlyTokens >= int64(quota.MonthlyTokens):
+		return fmt.Sprintf("monthly token quota of %d", quota.MonthlyTokens)
+	case quota.DailyRequests > 0 && u.DailyRequests >= int64(quota.DailyRequests):
+		return fmt.Sprintf("daily request quota of %d", quota.DailyRequests)
+	case quota.MonthlyRequests > 0 && u.MonthlyRequests >= int64(quota.MonthlyRequests):
+		return fmt.Sprintf("monthly request quota of %d", quota.MonthlyRequests)
+	}
+	return ""
+}
+
+// usages kept, the least recently used one is evicted for a new one so random
+// API keys can not grow them without bound
+const maxQuotaUsages = 10000
+
+type quotaTracker struct {
+	sync.Mutex
+	usage map[string]*quotaUsage
+}
+
+func newQuotaTracker() *quotaTracker {
+	return &quotaTracker{usage: make(map[string]*quotaUsage)}
+}
+
+func (q *quotaTracker) get(key, model string, now time.Time) *quotaUsage {
+	id := key + "\x00" + model
+	usage, found := q.usage[id]
+	if !found {
+		if len(q.usage) >= maxQuotaUsages {
+			q.prune(now)
+		}
+		if len(q.usage) >= maxQuotaUsages {
+			q.evictLeastRecentlyUsed()
+		}
+		usage = &quotaUsage{Key: key, Model: model}
+		q.usage[id] = usage
+	}
+	usage.lastSeen = now
+	usage.reset(now)
+	return usage
+}
+
+// prune removes the usage of keys that did not send requests this month
+func (q *quotaTracker) prune(now time.Time) {
+	month := now.Format("2006-01")
+	for id, usage := range q.usage {
+		if usage.Month != month {
+			delete(q.usage, id)
+		}
+	}
+}
+
+func (q *quotaTracker) evictLeastRecentlyUsed() {
+	var oldestID string
+	var oldest time.Time
+	for id, usage := range q.usage {
+		if oldestID == "" || usage.lastSeen.Before(oldest) {
+			oldestID, oldest = id, usage.lastSeen
+		}
+	}
+	delete(q.usage, oldestID)
+}
+
+// check returns a description of the exceeded limit, or "" when the request
+// is allowed
+func (q *quotaTracker) check(key, model string, quota QuotaConfig, now time.Time) string {
+	q.Lock()
+	defer q.Unlock()
+	return q.get(key, model, now).exceeded(quota)
+}
+
+func (q *quotaTracker) record(key, model string, tokens int64, now time.Time) {
+	q.Lock()
+	defer q.Unlock()
+	usage := q.get(key, model, now)
+	usage.DailyRequests++
+	usage.MonthlyRequests++
+	usage.DailyTokens += tokens
+	usage.MonthlyTokens += tokens
+}
+
+func (q *quotaTracker) list(now time.Time) []quotaUsage {
+	q.Lock()
+	defer q.Unlock()
+
+	list := make([]quotaUsage, 0, len(q.usage))
+	for _, usage := range q.usage {
+		usage.reset(now)
+		list = append(list, *usage)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Key != list[j].Key {
+			return list[i].Key < list[j].Key
+		}
+		return list[i].Model < list[j].Model
+	})
+	return list
+}
+
+// quotaKey identifies the client by the tenant from authExternal or a hash of
+// its bearer token so the token is not exposed by /api/quotas
+func quotaKey(c *gin.Context) string {
+	if tenant := c.GetString(ctxKeyTenant); tenant != "" {
+		return "tenant:" + tenant
+	}
+
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+// checkQuota enforces the model's quota. It returns false when the request
+// was rejected, otherwise a func to record the usage once it is handled.
+func (pm *ProxyManager) checkQuota(c *gin.Context, requestedModel string) (func(), bool) {
+	_, modelName, found := strings.Cut(requestedModel, PROFILE_SPLIT_CHAR)
+	if !found {
+		modelName = requestedModel
+	}
+
+	config := pm.currentConfig()
+	realName, found := config.RealModelName(modelName)
+	if !found {
+		return func() {}, true
+	}
+	quota, found := config.Quotas[realName]
+	if !found {
+		return func() {}, true
+	}
+
+	key := quotaKey(c)
+	if exceeded := pm.quotas.check(key, realName, quota, time.Now()); exceeded != "" {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": gin.H{
+			"message": fmt.Sprintf("%s for model %s exceeded", exceeded, realName),
+			"type":    "rate_limit_error",
+			"code":    "quota_exceeded",
+		}})
+		return nil, false
+	}
+
+	// token usage is at the end of the response
+	tail := &tailCaptureWriter{ResponseWriter: c.Writer}
+	c.Writer = tail
+	return func() {
+		// a client that disconnects from a stream still uses tokens
+		usage, _ := requestUsage(c, tail)
+		pm.quotas.record(key, realName, int64(usage.PromptTokens+usage.CompletionTokens), time.Now())
+	}, true
+}
+
+func (pm *ProxyManager) apiListQuotas(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"usage": pm.quotas.list(time.Now())})
+}
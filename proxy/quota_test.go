@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_QuotaAmounts(t *testing.T) {
+	config, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: path/to/cmd --port 8080
+    proxy: "http://localhost:8080"
+quotas:
+  model1:
+    dailyTokens: 5M
+    monthlyTokens: 1.5G
+    monthlyRequests: 10k
+`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, QuotaConfig{
+			DailyTokens:     5_000_000,
+			MonthlyTokens:   1_500_000_000,
+			MonthlyRequests: 10_000,
+		}, config.Quotas["model1"])
+	}
+
+	_, err = LoadConfigFromBytes([]byte("quotas:\n  model1:\n    dailyTokens: lots\n"))
+	assert.ErrorContains(t, err, "invalid quota amount: lots")
+
+	_, err = LoadConfigFromBytes([]byte("quotas:\n  missing:\n    dailyTokens: 5\n"))
+	assert.ErrorContains(t, err, "quotas: unknown model missing")
+}
+
+func TestQuotaTracker_Reset(t *testing.T) {
+	tracker := newQuotaTracker()
+	quota := QuotaConfig{DailyTokens: 100, MonthlyTokens: 150}
+
+	day1 := time.Date(2025, 1, 30, 12, 0, 0, 0, time.Local)
+	tracker.record("key", "model1", 100, day1)
+	assert.Equal(t, "daily token quota of 100", tracker.check("key", "model1", quota, day1))
+	assert.Equal(t, "", tracker.check("other", "model1", quota, day1))
+
+	// a new day resets the daily tokens
+	day2 := day1.Add(24 * time.Hour)
+	assert.Equal(t, "", tracker.check("key", "model1", quota, day2))
+	tracker.record("key", "model1", 50, day2)
+	assert.Equal(t, "monthly token quota of 150", tracker.check("key", "model1", quota, day2))
+
+	// and a new month the monthly tokens
+	day3 := day2.Add(24 * time.Hour)
+	assert.Equal(t, "", tracker.check("key", "model1", quota, day3))
+}
+
+func TestQuotaTracker_Bounded(t *testing.T) {
+	tracker := newQuotaTracker()
+	quota := QuotaConfig{DailyRequests: 1}
+
+	lastMonth := time.Date(2025, 1, 15, 12, 0, 0, 0, time.Local)
+	tracker.record("old", "model1", 0, lastMonth)
+
+	now := lastMonth.AddDate(0, 1, 0)
+	for i := 0; i < maxQuotaUsages; i++ {
+		tracker.record(fmt.Sprintf("key%d", i), "model1", 0, now.Add(time.Duration(i)*time.Millisecond))
+	}
+	// keys from earlier months made room
+	assert.Len(t, tracker.usage, maxQuotaUsages)
+
+	// key0 is used again, key1 is the least recently used
+	later := now.Add(time.Hour)
+	assert.Equal(t, "daily request quota of 1", tracker.check("key0", "model1", quota, later))
+	tracker.record("new1", "model1", 0, later)
+	assert.Len(t, tracker.usage, maxQuotaUsages)
+	assert.NotContains(t, tracker.usage, "key1\x00model1")
+
+	// new keys get their own quota
+	assert.Equal(t, "", tracker.check("new2", "model1", quota, later))
+	assert.Equal(t, "daily request quota of 1", tracker.check("new1", "model1", quota, later))
+	assert.Len(t, tracker.usage, maxQuotaUsages)
+}
+
+func TestProxyManager_QuotaExceeded(t *testing.T) {
+	config := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+		Quotas: map[string]QuotaConfig{
+			"model1": {DailyRequests: 2},
+		},
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	request := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, request("key-a").Code)
+	assert.Equal(t, http.StatusOK, request("key-a").Code)
+
+	w := request("key-a")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "daily request quota of 2 for model model1 exceeded")
+
+	// quotas are per API key
+	assert.Equal(t, http.StatusOK, request("key-b").Code)
+
+	req := httptest.NewRequest("GET", "/api/quotas", nil)
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+
+	var response struct {
+		Usage []quotaUsage `json:"usage"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Usage, 2) {
+		for _, usage := range response.Usage {
+			assert.Equal(t, "model1", usage.Model)
+			assert.NotContains(t, usage.Key, "key-")
+		}
+	}
+}
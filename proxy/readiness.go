@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// paths of the inference endpoints, they only accept POST
+var inferencePaths = []string{
+	"/v1/chat/completions",
+	"/v1/completions",
+	"/v1/embeddings",
+	"/v1/rerank",
+	"/v1/audio/speech",
+	"/v1/audio/transcriptions",
+	"/v1/audio/translations",
+	"/tokenize",
+	"/detokenize",
+	"/apply-template",
+}
+
+// inferenceMethodHandler answers GET and HEAD requests to inference
+// endpoints, sent by health checkers, with a 405. With headReadinessModel a
+// HEAD request checks if that model is loaded without starting it.
+func (pm *ProxyManager) inferenceMethodHandler(c *gin.Context) {
+	pm.Lock()
+	model := pm.currentConfig().HeadReadinessModel
+	var process *Process
+	for _, p := range pm.currentProcesses {
+		if p.ID == model {
+			process = p
+			break
+		}
+	}
+	pm.Unlock()
+
+	if c.Request.Method == http.MethodHead && model != "" {
+		if process != nil && process.isReady() {
+			c.Status(http.StatusOK)
+		} else {
+			c.Status(http.StatusServiceUnavailable)
+		}
+		return
+	}
+
+	c.Header("Allow", "POST, OPTIONS")
+	pm.sendErrorResponse(c, http.StatusMethodNotAllowed, "method not allowed, use POST")
+}
+
+// isReady is CurrentState() == StateReady without waiting for a start
+func (p *Process) isReady() bool {
+	return p.stateNoWait() == StateReady
+}
+
+// stateNoWait is CurrentState(), or starting while a start holds the lock
+func (p *Process) stateNoWait() ProcessState {
+	if !p.stateMutex.TryRLock() {
+		return ProcessState("starting")
+	}
+	defer p.stateMutex.RUnlock()
+	return p.state
+}
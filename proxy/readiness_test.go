@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyManager_InferenceMethodNotAllowed(t *testing.T) {
+	proxy := New(&Config{HealthCheckTimeout: 15})
+
+	for _, path := range inferencePaths {
+		for _, method := range []string{"GET", "HEAD"} {
+			w := httptest.NewRecorder()
+			proxy.HandlerFunc(w, httptest.NewRequest(method, path, nil))
+			assert.Equal(t, http.StatusMethodNotAllowed, w.Code, method+" "+path)
+			assert.Equal(t, "POST, OPTIONS", w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestProxyManager_HeadReadiness(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+		HeadReadinessModel: "model1",
+	})
+	defer proxy.StopProcesses()
+
+	head := func() int {
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("HEAD", "/v1/chat/completions", nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, head(), "not loaded")
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusOK, head())
+
+	// GET is still not allowed
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/v1/chat/completions", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	proxy.StopProcesses()
+	assert.Equal(t, http.StatusServiceUnavailable, head())
+
+	_, err := LoadConfigFromBytes([]byte("headReadinessModel: missing\nmodels:\n  model1:\n    cmd: path/to/cmd\n"))
+	assert.ErrorContains(t, err, "headReadinessModel missing is not a model")
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ttlReaper unloads processes once their TTL has expired. A single goroutine
+// sleeps until the next expiry of all tracked processes instead of every
+// process polling on its own.
+type ttlReaper struct {
+	mu        sync.Mutex
+	processes map[*Process]struct{}
+	wake      chan struct{}
+	started   bool
+}
+
+var defaultReaper = newTTLReaper()
+
+func newTTLReaper() *ttlReaper {
+	return &ttlReaper{
+		processes: make(map[*Process]struct{}),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// track adds a ready process with a TTL to the reaper
+func (r *ttlReaper) track(p *Process) {
+	r.mu.Lock()
+	r.processes[p] = struct{}{}
+	if !r.started {
+		r.started = true
+		go r.run()
+	}
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *ttlReaper) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		next := r.reap(time.Now())
+
+		if next.IsZero() {
+			// nothing to track, wait until a process is added
+			<-r.wake
+			continue
+		}
+
+		timer.Reset(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-r.wake:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+	}
+}
+
+// reap stops expired processes and returns the time of the next expiry
+func (r *ttlReaper) reap(now time.Time) time.Time {
+	// copy the processes, Process.start() holds the state lock while tracking
+	r.mu.Lock()
+	processes := make([]*Process, 0, len(r.processes))
+	for p := range r.processes {
+		processes = append(processes, p)
+	}
+	r.mu.Unlock()
+
+	var next time.Time
+	for _, p := range processes {
+		if p.CurrentState() != StateReady {
+			r.untrack(p)
+			continue
+		}
+
+		// in ttl.go, requests can change the ttl
+		ttl, unloads := p.ttl()
+		if !unloads {
+			r.untrack(p)
+			continue
+		}
+		expires := p.LastRequestHandled().Add(ttl)
+
+		// in pin.go, the ttl starts again when the pin's timeout has passed
+		if pin := p.pinned.Load(); pin != nil {
+			if pin.until.IsZero() {
+				// tracked again once unpinned
+				r.untrack(p)
+				continue
+			}
+			if until := pin.until.Add(ttl); until.After(expires) {
+				expires = until
+			}
+		}
+
+		if p.inFlightCount.Load() > 0 {
+			// lastRequestHandled moves once the requests are done
+			expires = now.Add(max(ttl, time.Second))
+		} else if !now.Before(expires) {
+			r.untrack(p)
+			go func() {
+				fmt.Fprintf(p.logMonitor, "!!! Unloading model %s, TTL of %v reached.\n", p.ID, ttl)
+				p.Stop()
+			}()
+			continue
+		}
+
+		if next.IsZero() || expires.Before(next) {
+			next = expires
+		}
+	}
+
+	return next
+}
+
+func (r *ttlReaper) untrack(p *Process) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.processes, p)
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaper_NextExpiry(t *testing.T) {
+	newReadyProcess := func(ttl int, lastRequest time.Time) *Process {
+		p := NewProcess("p", 5, ModelConfig{UnloadAfter: ttl}, NewLogMonitorWriter(io.Discard))
+		p.state = StateReady
+		p.lastRequestHandled = lastRequest
+		return p
+	}
+
+	now := time.Now()
+	reaper := newTTLReaper()
+	p1 := newReadyProcess(10, now)
+	p2 := newReadyProcess(60, now)
+	stopped := newReadyProcess(1, now)
+	stopped.state = StateStopped
+
+	for _, p := range []*Process{p1, p2, stopped} {
+		reaper.processes[p] = struct{}{}
+	}
+
+	next := reaper.reap(now)
+	assert.Equal(t, now.Add(10*time.Second), next)
+	assert.Len(t, reaper.processes, 2, "stopped processes are no longer tracked")
+
+	// busy processes are checked again a TTL from now
+	p1.inFlightCount.Add(1)
+	next = reaper.reap(now.Add(20 * time.Second))
+	assert.Equal(t, now.Add(30*time.Second), next)
+	assert.Len(t, reaper.processes, 2)
+}
@@ -0,0 +1,330 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// larger responses are not recorded
+const maxRecordedResponseSize = 16 * 1024 * 1024
+
+// request body fields that are not written to recordings
+var recordDroppedFields = []string{"user"}
+
+// RecordConfig writes the requests to a model and their responses to
+// dir/<model>.jsonl, to be replayed with `llama-swap replay`
+type RecordConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"`
+
+	// share of the requests recorded, 0 (default) or 1 records all
+	SampleRate float64 `yaml:"sampleRate"`
+}
+
+func (r RecordConfig) validate() error {
+	if r.SampleRate < 0 || r.SampleRate > 1 {
+		return fmt.Errorf("record: sampleRate must be between 0 and 1")
+	}
+	if r.Enabled && r.Dir == "" {
+		return fmt.Errorf("record: dir is required")
+	}
+	return nil
+}
+
+// Recording is a line of a recording file. Headers are not kept, they can
+// have API keys.
+type Recording struct {
+	Time       time.Time       `json:"time"`
+	Model      string          `json:"model"`
+	Path       string          `json:"path"`
+	Request    json.RawMessage `json:"request"`
+	Status     int             `json:"status"`
+	DurationMs int64           `json:"durationMs"`
+	// true when the response was an SSE stream, Response is then the chunks
+	// put together into a single response
+	Stream   bool            `json:"stream"`
+	Response json.RawMessage `json:"response"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// recordingPath is the file of model's recordings in dir
+func recordingPath(dir, model string) string {
+	return filepath.Join(dir, unsafeFileChars.ReplaceAllString(model, "_")+".jsonl")
+}
+
+// trafficRecorder keeps the recording files open between requests
+type trafficRecorder struct {
+	sync.Mutex
+	files map[string]*rotatingFile
+}
+
+func newTrafficRecorder() *trafficRecorder {
+	return &trafficRecorder{files: make(map[string]*rotatingFile)}
+}
+
+func (t *trafficRecorder) write(path string, recording Recording) error {
+	line, err := json.Marshal(recording)
+	if err != nil {
+		return err
+	}
+
+	t.Lock()
+	file, found := t.files[path]
+	if !found {
+		// opened by the first write
+		file = &rotatingFile{path: path}
+		t.files[path] = file
+	}
+	t.Unlock()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// closeAll closes the files of a previous config
+func (t *trafficRecorder) closeAll() {
+	t.Lock()
+	defer t.Unlock()
+	for path, file := range t.files {
+		file.Close()
+		delete(t.files, path)
+	}
+}
+
+// recordTraffic records a sampled request to a model with record enabled.
+// The returned func must be called when the request is done to write the
+// recording.
+func (pm *ProxyManager) recordTraffic(c *gin.Context, model string, requestBody map[string]interface{}) func() {
+	modelConfig, realName, found := pm.currentConfig().FindConfig(model)
+	if !found || !modelConfig.Record.Enabled {
+		return func() {}
+	}
+	if rate := modelConfig.Record.SampleRate; rate > 0 && rand.Float64() >= rate {
+		return func() {}
+	}
+
+	sanitized := make(map[string]interface{}, len(requestBody))
+	for k, v := range requestBody {
+		sanitized[k] = v
+	}
+	for _, field := range recordDroppedFields {
+		delete(sanitized, field)
+	}
+	request, err := json.Marshal(sanitized)
+	if err != nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	path := c.Request.URL.Path
+	capture := &bodyCaptureWriter{ResponseWriter: c.Writer, limit: maxRecordedResponseSize}
+	c.Writer = capture
+
+	return func() {
+		if capture.overflow {
+			fmt.Fprintf(pm.logMonitor, "!!! Not recording a response of %s larger than %d bytes\n", realName, maxRecordedResponseSize)
+			return
+		}
+
+		recording := Recording{
+			Time:       start,
+			Model:      realName,
+			Path:       path,
+			Request:    request,
+			Status:     capture.Status(),
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if strings.HasPrefix(capture.Header().Get("Content-Type"), "text/event-stream") {
+			recording.Stream = true
+			recording.Response = reassembleStream(capture.body)
+		} else {
+			recording.Response = rawResponse(capture.body)
+		}
+
+		file := recordingPath(modelConfig.Record.Dir, realName)
+		if err := pm.recorder.write(file, recording); err != nil {
+			fmt.Fprintf(pm.logMonitor, "!!! Unable to record to %s: %v\n", file, err)
+		}
+	}
+}
+
+// rawResponse keeps a JSON body as is and other bodies as a JSON string
+func rawResponse(body []byte) json.RawMessage {
+	if json.Valid(body) && len(bytes.TrimSpace(body)) > 0 {
+		return body
+	}
+	quoted, _ := json.Marshal(string(body))
+	return quoted
+}
+
+// streamChunk is the part of an OpenAI SSE chunk that is put together
+type streamChunk struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index        int     `json:"index"`
+		Text         string  `json:"text"`
+		FinishReason *string `json:"finish_reason"`
+		Delta        struct {
+			Role             string `json:"role"`
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			ToolCalls        []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Type     string `json:"type"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage json.RawMessage `json:"usage"`
+}
+
+type recordedToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type recordedMessage struct {
+	Role             string             `json:"role"`
+	Content          string             `json:"content"`
+	ReasoningContent string             `json:"reasoning_content,omitempty"`
+	ToolCalls        []recordedToolCall `json:"tool_calls,omitempty"`
+}
+
+type recordedChoice struct {
+	Index        int              `json:"index"`
+	Text         *string          `json:"text,omitempty"`
+	Message      *recordedMessage `json:"message,omitempty"`
+	FinishReason string           `json:"finish_reason,omitempty"`
+}
+
+type recordedResponse struct {
+	ID      string           `json:"id,omitempty"`
+	Object  string           `json:"object,omitempty"`
+	Created int64            `json:"created,omitempty"`
+	Model   string           `json:"model,omitempty"`
+	Choices []recordedChoice `json:"choices"`
+	Usage   json.RawMessage  `json:"usage,omitempty"`
+}
+
+// reassembleStream puts the chunks of an OpenAI SSE stream together into the
+// response the request would have had without streaming. Chat deltas become a
+// message, completion texts are joined. A stream without JSON chunks is kept
+// as a string.
+func reassembleStream(body []byte) json.RawMessage {
+	var response recordedResponse
+	choices := make(map[int]*recordedChoice)
+	var order []int
+	parsed := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), maxRecordedResponseSize)
+	for scanner.Scan() {
+		data, found := bytes.CutPrefix(scanner.Bytes(), []byte("data:"))
+		if !found {
+			continue
+		}
+		var chunk streamChunk
+		if json.Unmarshal(bytes.TrimSpace(data), &chunk) != nil {
+			continue
+		}
+		parsed = true
+
+		if response.ID == "" {
+			response.ID = chunk.ID
+		}
+		if response.Object == "" {
+			response.Object = strings.TrimSuffix(chunk.Object, ".chunk")
+		}
+		if response.Created == 0 {
+			response.Created = chunk.Created
+		}
+		if response.Model == "" {
+			response.Model = chunk.Model
+		}
+		if len(chunk.Usage) > 0 && string(chunk.Usage) != "null" {
+			response.Usage = chunk.Usage
+		}
+
+		for _, delta := range chunk.Choices {
+			choice, found := choices[delta.Index]
+			if !found {
+				choice = &recordedChoice{Index: delta.Index}
+				choices[delta.Index] = choice
+				order = append(order, delta.Index)
+			}
+			if delta.FinishReason != nil {
+				choice.FinishReason = *delta.FinishReason
+			}
+
+			if delta.Text != "" {
+				if choice.Text == nil {
+					choice.Text = new(string)
+				}
+				*choice.Text += delta.Text
+			}
+
+			d := delta.Delta
+			if d.Role == "" && d.Content == "" && d.ReasoningContent == "" && len(d.ToolCalls) == 0 {
+				continue
+			}
+			if choice.Message == nil {
+				choice.Message = &recordedMessage{Role: "assistant"}
+			}
+			if d.Role != "" {
+				choice.Message.Role = d.Role
+			}
+			choice.Message.Content += d.Content
+			choice.Message.ReasoningContent += d.ReasoningContent
+			for _, call := range d.ToolCalls {
+				for len(choice.Message.ToolCalls) <= call.Index {
+					choice.Message.ToolCalls = append(choice.Message.ToolCalls, recordedToolCall{Type: "function"})
+				}
+				recorded := &choice.Message.ToolCalls[call.Index]
+				if call.ID != "" {
+					recorded.ID = call.ID
+				}
+				if call.Type != "" {
+					recorded.Type = call.Type
+				}
+				recorded.Function.Name += call.Function.Name
+				recorded.Function.Arguments += call.Function.Arguments
+			}
+		}
+	}
+
+	if !parsed {
+		return rawResponse(body)
+	}
+
+	response.Choices = make([]recordedChoice, 0, len(order))
+	for _, index := range order {
+		response.Choices = append(response.Choices, *choices[index])
+	}
+	assembled, err := json.Marshal(response)
+	if err != nil {
+		return rawResponse(body)
+	}
+	return assembled
+}
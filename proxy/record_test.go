@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Record(t *testing.T) {
+	_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    record:
+      enabled: true
+`))
+	assert.ErrorContains(t, err, "record: dir is required")
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    record:
+      enabled: true
+      dir: /tmp/recordings
+      sampleRate: 1.5
+`))
+	assert.ErrorContains(t, err, "record: sampleRate must be between 0 and 1")
+}
+
+func TestRecord_ReassembleStream(t *testing.T) {
+	stream := strings.Join([]string{
+		`data: {"id":"c1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+		``,
+		`data: {"id":"c1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+		``,
+		`data: {"id":"c1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"t1","type":"function","function":{"name":"get","arguments":"{\"a\""}}]}}]}`,
+		``,
+		`data: {"id":"c1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":":1}"}}]},"finish_reason":"tool_calls"}]}`,
+		``,
+		`data: {"id":"c1","object":"chat.completion.chunk","model":"m","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":4}}`,
+		``,
+		`data: [DONE]`,
+		``,
+	}, "\n")
+
+	assert.JSONEq(t, `{
+		"id": "c1",
+		"object": "chat.completion",
+		"model": "m",
+		"choices": [{
+			"index": 0,
+			"message": {
+				"role": "assistant",
+				"content": "Hello",
+				"tool_calls": [{"id": "t1", "type": "function", "function": {"name": "get", "arguments": "{\"a\":1}"}}]
+			},
+			"finish_reason": "tool_calls"
+		}],
+		"usage": {"prompt_tokens": 3, "completion_tokens": 4}
+	}`, string(reassembleStream([]byte(stream))))
+
+	completion := "data: {\"object\":\"text_completion\",\"choices\":[{\"index\":0,\"text\":\"a\"}]}\n\ndata: {\"choices\":[{\"index\":0,\"text\":\"b\",\"finish_reason\":\"stop\"}]}\n\n"
+	assert.JSONEq(t, `{"object":"text_completion","choices":[{"index":0,"text":"ab","finish_reason":"stop"}]}`, string(reassembleStream([]byte(completion))))
+
+	assert.Equal(t, `"not a stream"`, string(reassembleStream([]byte("not a stream"))))
+}
+
+func TestProxyManager_Record(t *testing.T) {
+	dir := t.TempDir()
+	model := getTestSimpleResponderConfig("model1")
+	model.Record = RecordConfig{Enabled: true, Dir: dir}
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"org/model1": model},
+	})
+	defer proxy.StopProcesses()
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"org/model1","user":"alice","messages":[]}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "model1", w.Body.String())
+
+	file, err := os.Open(filepath.Join(dir, "org_model1.jsonl"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	assert.True(t, scanner.Scan())
+	var recording Recording
+	assert.NoError(t, json.Unmarshal(scanner.Bytes(), &recording))
+	assert.Equal(t, "org/model1", recording.Model)
+	assert.Equal(t, "/v1/chat/completions", recording.Path)
+	assert.Equal(t, http.StatusOK, recording.Status)
+	assert.JSONEq(t, `{"model":"org/model1","messages":[]}`, string(recording.Request))
+	assert.Equal(t, `"model1"`, string(recording.Response))
+	assert.False(t, scanner.Scan())
+}
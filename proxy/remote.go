@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	defaultWakeTimeout      = 120
+	defaultWakeOnLANAddress = "255.255.255.255:9"
+)
+
+// RemoteConfig is for models served by another machine, like a llama-swap or
+// llama-server on a workstation that sleeps when idle. llama-swap does not
+// run a command for them.
+type RemoteConfig struct {
+	// URL of the remote server, used as the model's proxy
+	Host string `yaml:"host"`
+
+	// a Wake-on-LAN packet is sent to this MAC address before the first
+	// request, optional
+	MacAddress string `yaml:"macAddress"`
+
+	// where the Wake-on-LAN packet is sent, default: 255.255.255.255:9
+	BroadcastAddress string `yaml:"broadcastAddress"`
+
+	// seconds to wait for the remote to be healthy, default: 120
+	WakeTimeout int `yaml:"wakeTimeout"`
+}
+
+func (r RemoteConfig) enabled() bool {
+	return r.Host != ""
+}
+
+func (r RemoteConfig) validate() error {
+	if !r.enabled() {
+		if r.MacAddress != "" || r.BroadcastAddress != "" || r.WakeTimeout != 0 {
+			return fmt.Errorf("remote.host is required")
+		}
+		return nil
+	}
+	if r.MacAddress != "" {
+		if _, err := net.ParseMAC(r.MacAddress); err != nil {
+			return fmt.Errorf("invalid remote.macAddress: %v", err)
+		}
+	}
+	if r.WakeTimeout < 0 {
+		return fmt.Errorf("remote.wakeTimeout must not be negative")
+	}
+	return nil
+}
+
+// startRemote wakes the remote machine and waits until it is healthy, the
+// state lock must be held
+func (p *Process) startRemote() error {
+	remote := p.config.Remote
+	if remote.MacAddress != "" {
+		address := remote.BroadcastAddress
+		if address == "" {
+			address = defaultWakeOnLANAddress
+		}
+		fmt.Fprintf(p.logMonitor, "!!! Sending Wake-on-LAN to %s for %s\n", remote.MacAddress, p.ID)
+		if err := sendWakeOnLAN(remote.MacAddress, address); err != nil {
+			return fmt.Errorf("unable to send Wake-on-LAN packet: %v", err)
+		}
+	}
+
+	wakeTimeout := remote.WakeTimeout
+	if wakeTimeout == 0 {
+		wakeTimeout = defaultWakeTimeout
+	}
+	if err := p.checkHealthEndpoint(context.Background(), time.Duration(wakeTimeout)*time.Second); err != nil {
+		return fmt.Errorf("remote %s did not become healthy: %v", remote.Host, err)
+	}
+
+	p.setReady()
+	return nil
+}
+
+// remoteUnreachable marks a remote as stopped so the next request wakes it
+// again
+func (p *Process) remoteUnreachable(err error) {
+	p.stateMutex.Lock()
+	defer p.stateMutex.Unlock()
+
+	if p.state == StateReady {
+		fmt.Fprintf(p.logMonitor, "!!! Remote %s for %s is unreachable: %v\n", p.config.Remote.Host, p.ID, err)
+		p.setState(StateStopped)
+	}
+}
+
+// sendWakeOnLAN sends a magic packet, 6 bytes of 0xff followed by the MAC
+// address 16 times, over UDP
+func sendWakeOnLAN(macAddress, address string) error {
+	mac, err := net.ParseMAC(macAddress)
+	if err != nil {
+		return err
+	}
+
+	packet := append(bytes.Repeat([]byte{0xff}, 6), bytes.Repeat(mac, 16)...)
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(packet)
+	return err
+}
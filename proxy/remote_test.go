@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listenWakeOnLAN(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestSendWakeOnLAN(t *testing.T) {
+	conn := listenWakeOnLAN(t)
+	assert.NoError(t, sendWakeOnLAN("aa:bb:cc:dd:ee:ff", conn.LocalAddr().String()))
+
+	packet := make([]byte, 200)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(packet)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 102, n)
+	assert.Equal(t, bytes.Repeat([]byte{0xff}, 6), packet[:6])
+	assert.Equal(t, []byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}, packet[96:102])
+
+	assert.Error(t, sendWakeOnLAN("not-a-mac", conn.LocalAddr().String()))
+}
+
+func TestProcess_Remote(t *testing.T) {
+	wol := listenWakeOnLAN(t)
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("remote " + r.URL.Path))
+	}))
+
+	config := ModelConfig{
+		Proxy: remote.URL,
+		Remote: RemoteConfig{
+			Host:             remote.URL,
+			MacAddress:       "aa:bb:cc:dd:ee:ff",
+			BroadcastAddress: wol.LocalAddr().String(),
+			WakeTimeout:      5,
+		},
+	}
+	process := NewProcess("big-model", 5, config, NewLogMonitorWriter(io.Discard))
+
+	w := httptest.NewRecorder()
+	process.ProxyRequest(w, httptest.NewRequest("GET", "/v1/models", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "remote /v1/models", w.Body.String())
+	assert.Equal(t, StateReady, process.CurrentState())
+
+	packet := make([]byte, 200)
+	wol.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := wol.Read(packet)
+	assert.NoError(t, err)
+	assert.Equal(t, 102, n)
+
+	// an unreachable remote is woken again by the next request
+	remote.Close()
+	w = httptest.NewRecorder()
+	process.ProxyRequest(w, httptest.NewRequest("GET", "/v1/models", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, StateStopped, process.CurrentState())
+
+	process.Stop()
+}
+
+func TestConfig_RemoteModels(t *testing.T) {
+	config, err := LoadConfigFromBytes([]byte(`
+models:
+  big:
+    remote:
+      host: http://workstation:8080
+      macAddress: aa:bb:cc:dd:ee:ff
+`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "http://workstation:8080", config.Models["big"].Proxy)
+	}
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  big:
+    cmd: llama-server
+    remote:
+      host: http://workstation:8080
+`))
+	assert.ErrorContains(t, err, "can not have a cmd")
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  big:
+    remote:
+      host: http://workstation:8080
+      macAddress: nope
+`))
+	assert.ErrorContains(t, err, "invalid remote.macAddress")
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  big:
+    cmd: llama-server
+    remote:
+      macAddress: aa:bb:cc:dd:ee:ff
+`))
+	assert.ErrorContains(t, err, "remote.host is required")
+}
@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// request bodies are kept in memory to send them again, larger requests are
+// not retried. Bodies streamed with streamBodyMB are always larger.
+const maxRetryBodyBytes = 1 << 20
+
+// transient errors of the upstream connection
+const (
+	retryConnectionReset   = "connection_reset"
+	retryConnectionRefused = "connection_refused"
+)
+
+var defaultRetryOn = []string{"502", "503", retryConnectionReset}
+
+// RetryConfig sends a request to the upstream again after a transient
+// error, before anything was sent to the client
+type RetryConfig struct {
+	Max int `yaml:"max"`
+
+	// HTTP status codes, connection_reset and connection_refused
+	On        []string `yaml:"on"`
+	BackoffMs int      `yaml:"backoffMs"`
+}
+
+func (r RetryConfig) validate() error {
+	if r.Max < 0 || r.BackoffMs < 0 {
+		return fmt.Errorf("retry max and backoffMs must not be negative")
+	}
+	for _, on := range r.On {
+		if on == retryConnectionReset || on == retryConnectionRefused {
+			continue
+		}
+		if status, err := strconv.Atoi(on); err != nil || status < 400 || status > 599 {
+			return fmt.Errorf("unknown retry on %s, use an HTTP error status, %s or %s", on, retryConnectionReset, retryConnectionRefused)
+		}
+	}
+	return nil
+}
+
+// transient returns the reason to retry a request, "" when it is not
+func (r RetryConfig) transient(resp *http.Response, err error) string {
+	var reason string
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		reason = retryConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		reason = retryConnectionReset
+	case err == nil:
+		reason = strconv.Itoa(resp.StatusCode)
+	}
+
+	on := r.On
+	if len(on) == 0 {
+		on = defaultRetryOn
+	}
+	if reason == "" || !slices.Contains(on, reason) {
+		return ""
+	}
+	return reason
+}
+
+// retryBody returns the body of req to send it again. Larger bodies, like
+// streamed JSON bodies, are passed on from what was read and ok is false.
+func retryBody(req *http.Request) (body []byte, ok bool, err error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true, nil
+	}
+	if req.ContentLength > maxRetryBodyBytes {
+		return nil, false, nil
+	}
+	body, err = io.ReadAll(io.LimitReader(req.Body, maxRetryBodyBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(body) > maxRetryBodyBytes {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		return nil, false, nil
+	}
+	return body, true, nil
+}
+
+// resendable requests can be sent again when the upstream may have received
+// them, as no response reached the client yet. Streamed responses are not,
+// the upstream may have started generating them.
+func resendable(method string, body []byte) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	var request struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(body, &request) != nil || !request.Stream
+}
+
+// doWithRetry sends req to the upstream and retries it as configured. Error
+// statuses are retried as the upstream did not handle the request. After
+// connection errors, streamed completions are only retried when they were
+// not written to the upstream.
+func (p *Process) doWithRetry(req *http.Request) (*http.Response, error) {
+	retry := p.config.Retry
+	if retry.Max == 0 {
+		return p.client.Do(req)
+	}
+
+	body, ok, err := retryBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return p.client.Do(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		var wrote atomic.Bool
+		attemptReq := req.Clone(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			WroteHeaders: func() { wrote.Store(true) },
+		}))
+		if body != nil {
+			attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+			attemptReq.ContentLength = int64(len(body))
+		}
+
+		resp, err := p.client.Do(attemptReq)
+		reason := retry.transient(resp, err)
+		if err != nil && wrote.Load() && !resendable(req.Method, body) {
+			reason = ""
+		}
+		if reason == "" || attempt >= retry.Max || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		backoff := time.Duration(retry.BackoffMs) * time.Millisecond << attempt
+		fmt.Fprintf(p.logMonitor, "!!! Upstream %s: %s, retrying in %v, attempt %d of %d\n", p.ID, reason, backoff, attempt+1, retry.Max)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Retry(t *testing.T) {
+	_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    retry:
+      max: 2
+      on: [502, 200]
+`))
+	assert.ErrorContains(t, err, "model model1: unknown retry on 200")
+
+	config, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    retry:
+      max: 2
+      on: [503, connection_reset]
+      backoffMs: 10
+`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, RetryConfig{Max: 2, On: []string{"503", "connection_reset"}, BackoffMs: 10}, config.Models["model1"].Retry)
+	}
+}
+
+func TestProxyManager_Retry(t *testing.T) {
+	var attempts atomic.Int32
+	var failures atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		attempts.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if failures.Add(-1) >= 0 {
+			if strings.Contains(string(body), "reset") || r.URL.Query().Has("reset") {
+				// close the connection without a response
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			http.Error(w, "loading model", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Proxy = upstream.URL
+	modelConfig.Retry = RetryConfig{Max: 2, BackoffMs: 10}
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+	})
+	defer proxy.StopProcesses()
+
+	largeBody := `{"model":"model1","prompt":"` + strings.Repeat("a", maxRetryBodyBytes) + `"}`
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		failures int32
+		attempts int32
+		status   int
+	}{
+		{"no errors", "POST", "/v1/completions", `{"model":"model1"}`, 0, 1, http.StatusOK},
+		{"503", "POST", "/v1/completions", `{"model":"model1"}`, 2, 3, http.StatusOK},
+		{"retries used up", "POST", "/v1/completions", `{"model":"model1"}`, 3, 3, http.StatusServiceUnavailable},
+
+		// nothing reached the client yet
+		{"connection reset", "POST", "/v1/completions", `{"model":"model1","prompt":"reset"}`, 1, 2, http.StatusOK},
+		{"connection reset embeddings", "POST", "/v1/embeddings", `{"model":"model1","input":"reset"}`, 1, 2, http.StatusOK},
+		{"connection reset idempotent", "GET", "/upstream/model1/props?reset", "", 1, 2, http.StatusOK},
+		{"connection reset chunked body", "POST", "/upstream/model1/v1/embeddings", `{"input":"reset"}`, 1, 2, http.StatusOK},
+
+		// the upstream may have started streaming the completion
+		{"connection reset stream", "POST", "/v1/completions", `{"model":"model1","prompt":"reset","stream":true}`, 1, 1, http.StatusBadGateway},
+
+		// not kept in memory to send it again
+		{"large body", "POST", "/v1/completions", largeBody, 1, 1, http.StatusServiceUnavailable},
+		{"large chunked body", "POST", "/upstream/model1/v1/completions", largeBody, 1, 1, http.StatusServiceUnavailable},
+		{"large chunked body passed on", "POST", "/upstream/model1/v1/completions", largeBody, 0, 1, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts.Store(0)
+			failures.Store(tt.failures)
+
+			var body io.Reader
+			if tt.body != "" {
+				// without a length, sent chunked to the upstream
+				body = io.MultiReader(strings.NewReader(tt.body))
+			}
+			w := httptest.NewRecorder()
+			proxy.HandlerFunc(w, httptest.NewRequest(tt.method, tt.path, body))
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.attempts, attempts.Load())
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
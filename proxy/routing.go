@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RoutingRule picks a model from request attributes. All conditions that are
+// set must match. Rules only apply to requests without a model unless
+// Override is set.
+type RoutingRule struct {
+	// conditions
+	PathPrefix      string            `yaml:"pathPrefix"`
+	Headers         map[string]string `yaml:"headers"`
+	MinPromptTokens int               `yaml:"minPromptTokens"`
+	MaxPromptTokens int               `yaml:"maxPromptTokens"`
+
+	// claims of the bearer token validated by auth.oidc, a list claim like
+	// groups matches when it contains the value
+	Claims map[string]string `yaml:"claims"`
+
+	// results, Model is used when the ModelFromHeader header is empty
+	Model           string `yaml:"model"`
+	ModelFromHeader string `yaml:"modelFromHeader"`
+	Override        bool   `yaml:"override"`
+}
+
+func (r RoutingRule) validate() error {
+	if r.Model == "" && r.ModelFromHeader == "" {
+		return fmt.Errorf("routing rule requires model or modelFromHeader")
+	}
+	return nil
+}
+
+// RouteModel applies the routing rules and returns the model to use for the
+// request. requestedModel is returned when no rule matches.
+func (c *Config) RouteModel(r *http.Request, requestBody map[string]interface{}, requestedModel string) string {
+	promptTokens := -1
+	for _, rule := range c.Routing {
+		if requestedModel != "" && !rule.Override {
+			continue
+		}
+
+		if rule.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, rule.PathPrefix) {
+			continue
+		}
+
+		if !headersMatch(r.Header, rule.Headers) {
+			continue
+		}
+
+		if !claimsMatch(requestClaims(r), rule.Claims) {
+			continue
+		}
+
+		if rule.MinPromptTokens > 0 || rule.MaxPromptTokens > 0 {
+			if promptTokens < 0 {
+				promptTokens = estimatePromptTokens(requestBody)
+			}
+			if rule.MinPromptTokens > 0 && promptTokens < rule.MinPromptTokens {
+				continue
+			}
+			if rule.MaxPromptTokens > 0 && promptTokens > rule.MaxPromptTokens {
+				continue
+			}
+		}
+
+		model := rule.Model
+		if rule.ModelFromHeader != "" {
+			if hint := strings.TrimSpace(r.Header.Get(rule.ModelFromHeader)); hint != "" {
+				model = hint
+			}
+		}
+
+		if model != "" {
+			return model
+		}
+	}
+
+	return requestedModel
+}
+
+// headersMatch checks the header values, an empty value only requires the
+// header to be present
+func headersMatch(header http.Header, want map[string]string) bool {
+	for name, value := range want {
+		got := header.Get(name)
+		if got == "" || (value != "" && got != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// estimatePromptTokens is a rough estimate of ~4 characters per token of the
+// prompt and message contents
+func estimatePromptTokens(requestBody map[string]interface{}) int {
+	chars := 0
+
+	var count func(v interface{})
+	count = func(v interface{}) {
+		switch val := v.(type) {
+		case string:
+			chars += len(val)
+		case []interface{}:
+			for _, item := range val {
+				count(item)
+			}
+		case map[string]interface{}:
+			// message objects and content parts
+			count(val["content"])
+			count(val["text"])
+		}
+	}
+
+	count(requestBody["prompt"])
+	count(requestBody["messages"])
+	count(requestBody["input"])
+
+	return chars / 4
+}
+
+// claimsMatch checks the claims of the request's bearer token
+func claimsMatch(claims jwtClaims, want map[string]string) bool {
+	for name, value := range want {
+		if !claims.has(name, value) {
+			return false
+		}
+	}
+	return true
+}
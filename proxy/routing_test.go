@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouting_RouteModel(t *testing.T) {
+	config := &Config{
+		Routing: []RoutingRule{
+			{ModelFromHeader: "X-Model-Hint"},
+			{PathPrefix: "/v1/embeddings", Model: "embedder"},
+			{Headers: map[string]string{"Origin": "http://webui.lan"}, Model: "webui-default"},
+			{MinPromptTokens: 1000, Model: "long-context", Override: true},
+			{Model: "default"},
+		},
+	}
+
+	newReq := func(path string, headers map[string]string) *http.Request {
+		req := httptest.NewRequest("POST", path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req
+	}
+
+	body := map[string]interface{}{"messages": []interface{}{
+		map[string]interface{}{"role": "user", "content": "hello"},
+	}}
+	longBody := map[string]interface{}{"messages": []interface{}{
+		map[string]interface{}{"role": "user", "content": strings.Repeat("a", 4000)},
+	}}
+
+	assert.Equal(t, "hinted", config.RouteModel(newReq("/v1/chat/completions", map[string]string{"X-Model-Hint": "hinted"}), body, ""))
+	assert.Equal(t, "embedder", config.RouteModel(newReq("/v1/embeddings", nil), body, ""))
+	assert.Equal(t, "webui-default", config.RouteModel(newReq("/v1/chat/completions", map[string]string{"Origin": "http://webui.lan"}), body, ""))
+	assert.Equal(t, "default", config.RouteModel(newReq("/v1/chat/completions", nil), body, ""))
+
+	// rules without override keep the requested model
+	assert.Equal(t, "requested", config.RouteModel(newReq("/v1/embeddings", nil), body, "requested"))
+	assert.Equal(t, "long-context", config.RouteModel(newReq("/v1/chat/completions", nil), longBody, "requested"))
+}
+
+func TestRouting_EstimatePromptTokens(t *testing.T) {
+	body := map[string]interface{}{
+		"messages": []interface{}{
+			map[string]interface{}{"role": "system", "content": "12345678"},
+			map[string]interface{}{"role": "user", "content": []interface{}{
+				map[string]interface{}{"type": "text", "text": "abcdefgh"},
+			}},
+		},
+	}
+	assert.Equal(t, 4, estimatePromptTokens(body))
+	assert.Equal(t, 2, estimatePromptTokens(map[string]interface{}{"prompt": "12345678"}))
+}
+
+func TestProxyManager_RoutesRequestWithoutModel(t *testing.T) {
+	config := &Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+		Routing: []RoutingRule{{Model: "model1"}},
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"messages":[]}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "model1")
+}
@@ -0,0 +1,188 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// priorityHeader overrides the model's priority for a request
+const priorityHeader = "X-Priority"
+
+// SchedulingConfig limits the concurrent requests across all models of a
+// profile so a GPU is not oversubscribed by the profile's models
+type SchedulingConfig struct {
+	MaxConcurrentRequests int `yaml:"maxConcurrentRequests"`
+
+	// fifo (default) or priority, which serves waiting requests with the
+	// highest priority first
+	Scheduling string `yaml:"scheduling"`
+}
+
+func (s SchedulingConfig) validate() error {
+	switch s.Scheduling {
+	case "", "fifo", "priority":
+	default:
+		return fmt.Errorf("unknown scheduling policy: %s", s.Scheduling)
+	}
+
+	if s.MaxConcurrentRequests < 0 {
+		return fmt.Errorf("maxConcurrentRequests must not be negative")
+	}
+	return nil
+}
+
+// requestScheduler is a counting semaphore that hands out free slots in
+// fifo or priority order
+type requestScheduler struct {
+	sync.Mutex
+
+	limit    int
+	priority bool
+	active   int
+	seq      uint64
+	waiting  []*schedulerWaiter
+}
+
+type schedulerWaiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+}
+
+func newRequestScheduler(config SchedulingConfig) *requestScheduler {
+	return &requestScheduler{
+		limit:    config.MaxConcurrentRequests,
+		priority: config.Scheduling == "priority",
+	}
+}
+
+// acquire blocks until the request can run or ctx is done. The returned
+// release func must be called when the request is done.
+func (s *requestScheduler) acquire(ctx context.Context, priority int) (func(), error) {
+	s.Lock()
+	if s.limit <= 0 || (s.active < s.limit && len(s.waiting) == 0) {
+		s.active++
+		s.Unlock()
+		return s.release, nil
+	}
+
+	s.seq++
+	waiter := &schedulerWaiter{priority: priority, seq: s.seq, ready: make(chan struct{})}
+	s.waiting = append(s.waiting, waiter)
+	s.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return s.release, nil
+	case <-ctx.Done():
+		s.Lock()
+		defer s.Unlock()
+		for i, w := range s.waiting {
+			if w == waiter {
+				s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+				return nil, ctx.Err()
+			}
+		}
+		// the slot was handed over while giving up, pass it on
+		s.active--
+		s.next()
+		return nil, ctx.Err()
+	}
+}
+
+// load returns the number of running and waiting requests
+func (s *requestScheduler) load() (int, int) {
+	s.Lock()
+	defer s.Unlock()
+	return s.active, len(s.waiting)
+}
+
+func (s *requestScheduler) release() {
+	s.Lock()
+	defer s.Unlock()
+	s.active--
+	s.next()
+}
+
+// next hands free slots to waiting requests, the lock must be held
+func (s *requestScheduler) next() {
+	for s.active < s.limit && len(s.waiting) > 0 {
+		idx := 0
+		if s.priority {
+			for i, w := range s.waiting {
+				if w.priority > s.waiting[idx].priority {
+					idx = i
+				}
+			}
+		}
+
+		waiter := s.waiting[idx]
+		s.waiting = append(s.waiting[:idx], s.waiting[idx+1:]...)
+		s.active++
+		close(waiter.ready)
+	}
+}
+
+// newRequestSchedulers creates the schedulers for the profiles that limit
+// their concurrent requests
+func newRequestSchedulers(config *Config) map[string]*requestScheduler {
+	schedulers := make(map[string]*requestScheduler)
+	for profileName, scheduling := range config.ProfileScheduling {
+		if scheduling.MaxConcurrentRequests > 0 {
+			schedulers[profileName] = newRequestScheduler(scheduling)
+		}
+	}
+	return schedulers
+}
+
+// acquireProfileSlot waits for the requested profile's concurrency limit.
+// It returns a release func, which is a no-op when the profile has no limit.
+func (pm *ProxyManager) acquireProfileSlot(c *gin.Context, requestedModel string) (func(), error) {
+	profileName, modelName, found := strings.Cut(requestedModel, PROFILE_SPLIT_CHAR)
+	if !found {
+		return func() {}, nil
+	}
+
+	scheduler := (*pm.schedulers.Load())[profileName]
+	priority := 0
+	config := pm.currentConfig()
+	if realName, ok := config.RealModelName(modelName); ok {
+		modelName = realName
+		priority = config.Models[realName].Priority
+	}
+
+	if scheduler == nil {
+		return func() {}, nil
+	}
+
+	if value := c.GetHeader(priorityHeader); value != "" {
+		if headerPriority, err := strconv.Atoi(value); err == nil {
+			priority = headerPriority
+		}
+	}
+
+	if active, waiting := scheduler.load(); active >= scheduler.limit || waiting > 0 {
+		policy := "fifo"
+		if scheduler.priority {
+			policy = "priority"
+		}
+		pm.recordDecision(Decision{
+			Kind:    DecisionQueue,
+			Model:   modelName,
+			Trigger: requestedModel,
+			Reason: fmt.Sprintf("profile %s runs %d of %d requests with %d waiting, queued by %s with priority %d",
+				profileName, active, scheduler.limit, waiting, policy, priority),
+		})
+	}
+
+	waitStart := time.Now()
+	release, err := scheduler.acquire(c.Request.Context(), priority)
+	traceRequest(c, "waited %v for a slot in profile %s with priority %d", time.Since(waitStart), profileName, priority)
+	return release, err
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// queueRequests adds waiting requests one at a time so their order is known
+func queueRequests(t *testing.T, s *requestScheduler, priorities []int) (*sync.WaitGroup, *[]int) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var order []int
+
+	for _, priority := range priorities {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := s.acquire(context.Background(), priority)
+			if !assert.NoError(t, err) {
+				return
+			}
+			mu.Lock()
+			order = append(order, priority)
+			mu.Unlock()
+			release()
+		}()
+
+		assert.Eventually(t, func() bool {
+			s.Lock()
+			defer s.Unlock()
+			return len(s.waiting) > 0 && s.waiting[len(s.waiting)-1].priority == priority
+		}, time.Second, time.Millisecond)
+	}
+
+	return &wg, &order
+}
+
+func TestRequestScheduler_FIFO(t *testing.T) {
+	s := newRequestScheduler(SchedulingConfig{MaxConcurrentRequests: 1})
+
+	release, err := s.acquire(context.Background(), 0)
+	assert.NoError(t, err)
+
+	wg, order := queueRequests(t, s, []int{1, 5, 3})
+	release()
+	wg.Wait()
+
+	assert.Equal(t, []int{1, 5, 3}, *order)
+	assert.Equal(t, 0, s.active)
+}
+
+func TestRequestScheduler_Priority(t *testing.T) {
+	s := newRequestScheduler(SchedulingConfig{MaxConcurrentRequests: 1, Scheduling: "priority"})
+
+	release, err := s.acquire(context.Background(), 0)
+	assert.NoError(t, err)
+
+	wg, order := queueRequests(t, s, []int{1, 5, 3, 5})
+	release()
+	wg.Wait()
+
+	assert.Equal(t, []int{5, 5, 3, 1}, *order)
+	assert.Equal(t, 0, s.active)
+}
+
+func TestRequestScheduler_CancelWhileWaiting(t *testing.T) {
+	s := newRequestScheduler(SchedulingConfig{MaxConcurrentRequests: 1})
+
+	release, err := s.acquire(context.Background(), 0)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = s.acquire(ctx, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, s.waiting)
+
+	release()
+	release, err = s.acquire(context.Background(), 0)
+	assert.NoError(t, err)
+	release()
+	assert.Equal(t, 0, s.active)
+}
+
+func TestConfig_ProfileSchedulingValidation(t *testing.T) {
+	base := `
+models:
+  model1:
+    cmd: path/to/cmd --port 8080
+    proxy: "http://localhost:8080"
+profiles:
+  test:
+    - model1
+`
+	config, err := LoadConfigFromBytes([]byte(base + `
+profileScheduling:
+  test:
+    maxConcurrentRequests: 2
+    scheduling: priority
+`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, SchedulingConfig{MaxConcurrentRequests: 2, Scheduling: "priority"}, config.ProfileScheduling["test"])
+	}
+
+	_, err = LoadConfigFromBytes([]byte(base + `
+profileScheduling:
+  missing:
+    maxConcurrentRequests: 2
+`))
+	assert.ErrorContains(t, err, "unknown profile missing")
+
+	_, err = LoadConfigFromBytes([]byte(base + `
+profileScheduling:
+  test:
+    scheduling: lifo
+`))
+	assert.ErrorContains(t, err, "unknown scheduling policy")
+}
@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleConfig preloads or unloads a model or a profile at the times of a
+// cron expression, in the local time of llama-swap
+type ScheduleConfig struct {
+	// minute hour day-of-month month day-of-week, e.g. "0 9 * * 1-5"
+	Cron string `yaml:"cron"`
+	// preload or unload
+	Action string `yaml:"action"`
+
+	// one of a model or a profile, called group like in /api/groups
+	Model string `yaml:"model"`
+	Group string `yaml:"group"`
+}
+
+func (c *Config) validateSchedule(s ScheduleConfig) error {
+	if _, err := parseCron(s.Cron); err != nil {
+		return err
+	}
+	if s.Action != "preload" && s.Action != "unload" {
+		return fmt.Errorf("unknown action %q, expected preload or unload", s.Action)
+	}
+	if (s.Model == "") == (s.Group == "") {
+		return fmt.Errorf("one of model or group is required")
+	}
+	if s.Model != "" {
+		if _, found := c.RealModelName(s.Model); !found {
+			return fmt.Errorf("unknown model %s", s.Model)
+		}
+	}
+	if s.Group != "" {
+		if members, found := c.Profiles[s.Group]; !found || len(members) == 0 {
+			return fmt.Errorf("unknown group %s", s.Group)
+		}
+	}
+	return nil
+}
+
+// target is the model or the group of a schedule
+func (s ScheduleConfig) target() string {
+	if s.Group != "" {
+		return "group " + s.Group
+	}
+	return "model " + s.Model
+}
+
+// scheduleRun is the last time a schedule ran
+type scheduleRun struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error,omitempty"`
+}
+
+// scheduleRunner runs the schedules of the current config every minute
+type scheduleRunner struct {
+	sync.Mutex
+	// keyed by scheduleKey, kept across reloads for unchanged schedules
+	lastRuns map[string]scheduleRun
+}
+
+func newScheduleRunner() *scheduleRunner {
+	return &scheduleRunner{lastRuns: make(map[string]scheduleRun)}
+}
+
+func scheduleKey(s ScheduleConfig) string {
+	return s.Cron + "|" + s.Action + "|" + s.target()
+}
+
+// runSchedules checks the schedules at the start of every minute
+func (pm *ProxyManager) runSchedules() {
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		// loading a model can take longer than a minute
+		go pm.runDueSchedules(time.Now())
+	}
+}
+
+// runDueSchedules runs the schedules matching the minute of now, one after
+// another in the order of the config
+func (pm *ProxyManager) runDueSchedules(now time.Time) {
+	for _, schedule := range pm.currentConfig().Schedules {
+		cron, err := parseCron(schedule.Cron)
+		if err != nil || !cron.matches(now) {
+			continue
+		}
+
+		fmt.Fprintf(pm.logMonitor, "!!! Schedule %q: %s %s\n", schedule.Cron, schedule.Action, schedule.target())
+		run := scheduleRun{Time: now}
+		if err := pm.runSchedule(schedule); err != nil {
+			fmt.Fprintf(pm.logMonitor, "!!! Schedule %q failed: %v\n", schedule.Cron, err)
+			run.Error = err.Error()
+		}
+
+		pm.schedules.Lock()
+		pm.schedules.lastRuns[scheduleKey(schedule)] = run
+		pm.schedules.Unlock()
+	}
+}
+
+func (pm *ProxyManager) runSchedule(schedule ScheduleConfig) error {
+	if schedule.Action == "preload" {
+		requested := schedule.Model
+		if schedule.Group != "" {
+			// loads all members of the profile
+			requested = schedule.Group + PROFILE_SPLIT_CHAR + pm.currentConfig().Profiles[schedule.Group][0]
+		}
+		process, err := pm.swapModel(requested)
+		if err != nil {
+			return err
+		}
+		if schedule.Group == "" {
+			return process.start()
+		}
+
+		pm.Lock()
+		var members []*Process
+		for key, p := range pm.currentProcesses {
+			if strings.HasPrefix(key, schedule.Group+PROFILE_SPLIT_CHAR) {
+				members = append(members, p)
+			}
+		}
+		pm.Unlock()
+		for _, member := range members {
+			if err := member.start(); err != nil {
+				return fmt.Errorf("unable to start %s: %v", member.ID, err)
+			}
+		}
+		return nil
+	}
+
+	// unload stops the model or the members of the group wherever they run
+	config := pm.currentConfig()
+	stop := make(map[string]bool)
+	if schedule.Model != "" {
+		modelID, _ := config.RealModelName(schedule.Model)
+		stop[modelID] = true
+	}
+	for _, member := range config.Profiles[schedule.Group] {
+		if modelID, found := config.RealModelName(member); found {
+			stop[modelID] = true
+		}
+	}
+
+	pm.Lock()
+	var processes []*Process
+	for _, process := range pm.currentProcesses {
+		if stop[process.ID] {
+			processes = append(processes, process)
+		}
+	}
+	pm.Unlock()
+	for _, process := range processes {
+		if process.CurrentState() != StateStopped {
+			process.Stop()
+		}
+	}
+	return nil
+}
+
+// apiListSchedules lists the schedules with their next and last run
+func (pm *ProxyManager) apiListSchedules(c *gin.Context) {
+	now := time.Now()
+	pm.schedules.Lock()
+	defer pm.schedules.Unlock()
+
+	schedules := make([]gin.H, 0, len(pm.currentConfig().Schedules))
+	for _, schedule := range pm.currentConfig().Schedules {
+		entry := gin.H{"cron": schedule.Cron, "action": schedule.Action}
+		if schedule.Model != "" {
+			entry["model"] = schedule.Model
+		} else {
+			entry["group"] = schedule.Group
+		}
+		if cron, err := parseCron(schedule.Cron); err == nil {
+			if next := cron.next(now); !next.IsZero() {
+				entry["nextRun"] = next
+			}
+		}
+		if run, found := pm.schedules.lastRuns[scheduleKey(schedule)]; found {
+			entry["lastRun"] = run
+		}
+		schedules = append(schedules, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Schedules(t *testing.T) {
+	base := `
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+profiles:
+  heavy: [model1]
+schedules:
+`
+	_, err := LoadConfigFromBytes([]byte(base + `  - {cron: "0 9 * * 1-5", action: preload, model: model1}
+  - {cron: "0 22 * * *", action: unload, group: heavy}
+`))
+	assert.NoError(t, err)
+
+	_, err = LoadConfigFromBytes([]byte(base + `  - {cron: "0 9 * * 1-5", action: warm, model: model1}`))
+	assert.ErrorContains(t, err, `schedules[0]: unknown action "warm"`)
+
+	_, err = LoadConfigFromBytes([]byte(base + `  - {cron: "0 9 * * 1-5", action: preload, model: model1, group: heavy}`))
+	assert.ErrorContains(t, err, "one of model or group is required")
+
+	_, err = LoadConfigFromBytes([]byte(base + `  - {cron: "0 9 * * 1-5", action: unload, group: light}`))
+	assert.ErrorContains(t, err, "unknown group light")
+}
+
+func TestProxyManager_RunDueSchedules(t *testing.T) {
+	at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local)
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+			"model2": getTestSimpleResponderConfig("model2"),
+		},
+		Profiles: map[string][]string{"heavy": {"model1", "model2"}},
+		Schedules: []ScheduleConfig{
+			{Cron: "0 9 * * *", Action: "preload", Group: "heavy"},
+			{Cron: "0 22 * * *", Action: "unload", Group: "heavy"},
+		},
+	})
+	defer proxy.StopProcesses()
+
+	runningStates := func() map[string]ProcessState {
+		proxy.Lock()
+		defer proxy.Unlock()
+		states := make(map[string]ProcessState)
+		for key, process := range proxy.currentProcesses {
+			states[key] = process.CurrentState()
+		}
+		return states
+	}
+
+	proxy.runDueSchedules(at)
+	assert.Equal(t, map[string]ProcessState{"heavy:model1": StateReady, "heavy:model2": StateReady}, runningStates())
+	assert.Equal(t, scheduleRun{Time: at}, proxy.schedules.lastRuns[scheduleKey(proxy.currentConfig().Schedules[0])])
+
+	proxy.runDueSchedules(at.Add(13 * time.Hour))
+	assert.Equal(t, map[string]ProcessState{"heavy:model1": StateStopped, "heavy:model2": StateStopped}, runningStates())
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SdNotify sends a state like READY=1 to systemd. It does nothing when not
+// started by systemd with Type=notify.
+func SdNotify(state string) error {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if socketPath == "" {
+		return nil
+	}
+
+	// abstract namespace sockets start with @
+	if socketPath[0] == '@' {
+		socketPath = "\x00" + socketPath[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval returns how often to send WATCHDOG=1, half of the
+// WatchdogSec configured in the systemd unit
+func WatchdogInterval() (time.Duration, bool) {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond / 2, true
+}
+
+// RunWatchdog sends WATCHDOG=1 every interval as long as the /health endpoint
+// on addr responds, so systemd restarts llama-swap when it is wedged. addr is
+// a host:port or a unix:/path/to.sock, see ListenAddr.
+func (pm *ProxyManager) RunWatchdog(addr string, interval time.Duration) {
+	healthURL := "http://" + localAddr(addr) + "/health"
+	client := &http.Client{Timeout: interval}
+	if path, ok := unixListenPath(addr); ok {
+		// in unixsocket.go
+		healthURL = unixUpstreamBase + "/health"
+		client.Transport = unixSocketTransport(path)
+	}
+
+	for range time.Tick(interval) {
+		resp, err := client.Get(healthURL)
+		if err != nil {
+			fmt.Fprintf(pm.logMonitor, "!!! Watchdog health check failed: %v\n", err)
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			fmt.Fprintf(pm.logMonitor, "!!! Watchdog health check failed: status %d\n", resp.StatusCode)
+			continue
+		}
+
+		if err := SdNotify("WATCHDOG=1"); err != nil {
+			fmt.Fprintf(pm.logMonitor, "!!! Watchdog notify failed: %v\n", err)
+		}
+	}
+}
+
+// localAddr replaces an unspecified listen host with the loopback address
+func localAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
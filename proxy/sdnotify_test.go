@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listenNotifySocket(t *testing.T) *net.UnixConn {
+	socketPath := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", socketPath)
+	return conn
+}
+
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	assert.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestSdNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	assert.NoError(t, SdNotify("READY=1"), "not running under systemd")
+
+	conn := listenNotifySocket(t)
+	assert.NoError(t, SdNotify("READY=1"))
+	assert.Equal(t, "READY=1", readNotify(t, conn))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "")
+	_, ok := WatchdogInterval()
+	assert.False(t, ok)
+
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	interval, ok := WatchdogInterval()
+	assert.True(t, ok)
+	assert.Equal(t, 15*time.Second, interval)
+
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	_, ok = WatchdogInterval()
+	assert.False(t, ok, "watchdog is for another process")
+}
+
+func TestProxyManager_RunWatchdog(t *testing.T) {
+	conn := listenNotifySocket(t)
+
+	proxy := New(&Config{HealthCheckTimeout: 15})
+	server := httptest.NewServer(http.HandlerFunc(proxy.HandlerFunc))
+	defer server.Close()
+
+	go proxy.RunWatchdog(server.Listener.Addr().String(), 50*time.Millisecond)
+	assert.Equal(t, "WATCHDOG=1", readNotify(t, conn))
+}
+
+func TestLocalAddr(t *testing.T) {
+	assert.Equal(t, "127.0.0.1:8080", localAddr("[::]:8080"))
+	assert.Equal(t, "127.0.0.1:8080", localAddr("0.0.0.0:8080"))
+	assert.Equal(t, "127.0.0.1:8080", localAddr(":8080"))
+	assert.Equal(t, "10.0.0.2:8080", localAddr("10.0.0.2:8080"))
+}
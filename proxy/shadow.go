@@ -0,0 +1,229 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// longest a mirrored request may take
+const shadowRequestTimeout = 10 * time.Minute
+
+func (c *Config) validateShadowModel(modelName string, m ModelConfig) error {
+	if m.ShadowPercent < 0 || m.ShadowPercent > 100 {
+		return fmt.Errorf("shadowPercent must be between 0 and 100")
+	}
+	if m.ShadowModel == "" {
+		return nil
+	}
+	shadow, found := c.RealModelName(m.ShadowModel)
+	if !found {
+		return fmt.Errorf("unknown shadowModel %s", m.ShadowModel)
+	}
+	if shadow == modelName {
+		return fmt.Errorf("shadowModel can not be the model itself")
+	}
+	// swapping the shadow model in would stop the model
+	if !c.Models[shadow].Standby {
+		return fmt.Errorf("shadowModel %s must be a standby model", m.ShadowModel)
+	}
+	return nil
+}
+
+// shadowStats compares the responses of a model and its shadow model
+type shadowStats struct {
+	Model       string `json:"model"`
+	ShadowModel string `json:"shadowModel"`
+
+	// copies sent, or skipped because the shadow model was not ready
+	Mirrored int64 `json:"mirrored"`
+	Skipped  int64 `json:"skipped"`
+	// copies with both responses done
+	Compared int64 `json:"compared"`
+
+	// responses with a status other than 200
+	Failed       int64 `json:"failed"`
+	ShadowFailed int64 `json:"shadowFailed"`
+
+	// average response times of the mirrored requests
+	AvgMs       float64 `json:"avgMs"`
+	ShadowAvgMs float64 `json:"shadowAvgMs"`
+
+	totalMs       float64
+	shadowTotalMs float64
+}
+
+type shadowTracker struct {
+	sync.Mutex
+	stats map[string]*shadowStats
+}
+
+func newShadowTracker() *shadowTracker {
+	return &shadowTracker{stats: make(map[string]*shadowStats)}
+}
+
+func (t *shadowTracker) get(model, shadowModel string) *shadowStats {
+	stats, found := t.stats[model]
+	if !found || stats.ShadowModel != shadowModel {
+		stats = &shadowStats{Model: model, ShadowModel: shadowModel}
+		t.stats[model] = stats
+	}
+	return stats
+}
+
+func (t *shadowTracker) skipped(model, shadowModel string) {
+	t.Lock()
+	defer t.Unlock()
+	t.get(model, shadowModel).Skipped++
+}
+
+func (t *shadowTracker) mirrored(model, shadowModel string) {
+	t.Lock()
+	defer t.Unlock()
+	t.get(model, shadowModel).Mirrored++
+}
+
+// record adds a mirrored request once both responses are done
+func (t *shadowTracker) record(model, shadowModel string, status, shadowStatus int, duration, shadowDuration time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+
+	stats := t.get(model, shadowModel)
+	if status != http.StatusOK {
+		stats.Failed++
+	}
+	if shadowStatus != http.StatusOK {
+		stats.ShadowFailed++
+	}
+	stats.Compared++
+	stats.totalMs += float64(duration.Milliseconds())
+	stats.shadowTotalMs += float64(shadowDuration.Milliseconds())
+	stats.AvgMs = stats.totalMs / float64(stats.Compared)
+	stats.ShadowAvgMs = stats.shadowTotalMs / float64(stats.Compared)
+}
+
+func (t *shadowTracker) list() []shadowStats {
+	t.Lock()
+	defer t.Unlock()
+
+	list := make([]shadowStats, 0, len(t.stats))
+	for _, stats := range t.stats {
+		list = append(list, *stats)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Model < list[j].Model })
+	return list
+}
+
+// discardResponse keeps only the status and time of a mirrored response
+type discardResponse struct {
+	header   http.Header
+	status   int
+	duration time.Duration
+}
+
+func (d *discardResponse) Header() http.Header {
+	return d.header
+}
+
+func (d *discardResponse) WriteHeader(statusCode int) {
+	if d.status == 0 {
+		d.status = statusCode
+	}
+}
+
+func (d *discardResponse) Write(p []byte) (int, error) {
+	d.WriteHeader(http.StatusOK)
+	return len(p), nil
+}
+
+// mirrorToShadow sends a copy of a sampled request for model to its
+// shadowModel without waiting for the response. The shadow model is a
+// standby model, the copy is skipped while it is not ready. The returned func
+// must be called when the request is done to record the model's response
+// time.
+func (pm *ProxyManager) mirrorToShadow(c *gin.Context, model string, requestBody map[string]interface{}) func() {
+	config := pm.currentConfig()
+	modelConfig, realName, found := config.FindConfig(model)
+	if !found || modelConfig.ShadowModel == "" {
+		return func() {}
+	}
+	percent := modelConfig.ShadowPercent
+	if percent == 0 {
+		percent = 100
+	}
+	if rand.IntN(100) >= percent {
+		return func() {}
+	}
+
+	shadowName, _ := config.RealModelName(modelConfig.ShadowModel)
+	var shadow *Process
+	pm.Lock()
+	for _, process := range pm.currentProcesses {
+		if process.ID == shadowName && process.isReady() {
+			shadow = process
+			break
+		}
+	}
+	pm.Unlock()
+
+	if shadow == nil {
+		pm.shadows.skipped(realName, shadowName)
+		return func() {}
+	}
+
+	body := make(map[string]interface{}, len(requestBody))
+	for k, v := range requestBody {
+		body[k] = v
+	}
+	body["model"] = shadowName
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return func() {}
+	}
+
+	// the copy outlives the client's request
+	ctx, cancel := context.WithTimeout(context.Background(), shadowRequestTimeout)
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Request.URL.String(), bytes.NewReader(bodyBytes))
+	if err != nil {
+		cancel()
+		return func() {}
+	}
+	req.Header = c.Request.Header.Clone()
+	req.Header.Del("transfer-encoding")
+	req.Header.Set("content-length", strconv.Itoa(len(bodyBytes)))
+
+	pm.shadows.mirrored(realName, shadowName)
+	traceRequest(c, "mirrored the request to shadow model %s", shadowName)
+
+	start := time.Now()
+	shadowDone := make(chan *discardResponse, 1)
+	go func() {
+		defer cancel()
+		response := &discardResponse{header: make(http.Header)}
+		shadow.ProxyRequest(response, req)
+		response.duration = time.Since(start)
+		shadowDone <- response
+	}()
+
+	return func() {
+		duration := time.Since(start)
+		status := c.Writer.Status()
+		go func() {
+			response := <-shadowDone
+			pm.shadows.record(realName, shadowName, status, response.status, duration, response.duration)
+		}()
+	}
+}
+
+func (pm *ProxyManager) apiListShadows(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"shadows": pm.shadows.list()})
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_ShadowModel(t *testing.T) {
+	_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    shadowModel: model2
+  model2:
+    cmd: server --port 9002
+    proxy: http://127.0.0.1:9002
+`))
+	assert.ErrorContains(t, err, "shadowModel model2 must be a standby model")
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    shadowModel: unknown
+`))
+	assert.ErrorContains(t, err, "unknown shadowModel unknown")
+}
+
+func TestProxyManager_ShadowModel(t *testing.T) {
+	model := getTestSimpleResponderConfig("model1")
+	model.ShadowModel = "shadow"
+	shadow := getTestSimpleResponderConfig("shadow")
+	shadow.Standby = true
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": model, "shadow": shadow},
+	})
+	defer proxy.StopProcesses()
+	proxy.WaitForStandby()
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model1"}`)))
+		assert.Equal(t, http.StatusOK, w.Code)
+		// the client only gets the model's response
+		assert.Equal(t, "model1", w.Body.String())
+	}
+
+	var response struct {
+		Shadows []shadowStats `json:"shadows"`
+	}
+	assert.Eventually(t, func() bool {
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/shadows", nil))
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return len(response.Shadows) == 1 && response.Shadows[0].Compared == 3
+	}, 5*time.Second, 20*time.Millisecond)
+
+	stats := response.Shadows[0]
+	assert.Equal(t, "model1", stats.Model)
+	assert.Equal(t, "shadow", stats.ShadowModel)
+	assert.Equal(t, int64(3), stats.Mirrored)
+	assert.Equal(t, int64(0), stats.Skipped)
+	assert.Equal(t, int64(0), stats.Failed)
+	assert.Equal(t, int64(0), stats.ShadowFailed)
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultSleepLevel = 1
+
+	// time for vLLM to offload or reload the weights
+	sleepRequestTimeout = 2 * time.Minute
+)
+
+func (m ModelConfig) validateSwapMode() error {
+	switch m.SwapMode {
+	case "", "process":
+	case "sleep":
+		if m.Remote.enabled() {
+			return fmt.Errorf("swapMode sleep is not supported by remote models")
+		}
+	default:
+		return fmt.Errorf("unknown swapMode %s, use process or sleep", m.SwapMode)
+	}
+
+	if m.SleepLevel < 0 || m.SleepLevel > 2 {
+		return fmt.Errorf("sleepLevel must be 1 or 2")
+	}
+	return nil
+}
+
+// sleeps is true when the model is put to sleep instead of being stopped
+func (m ModelConfig) sleeps() bool {
+	return m.SwapMode == "sleep"
+}
+
+// Sleep frees the GPU memory of a ready process with vLLM's /sleep endpoint
+// and keeps it running. When it fails the process should be stopped.
+func (p *Process) Sleep() error {
+	// wait for any inflight requests before proceeding
+	p.inFlightRequests.Wait()
+
+	p.stateMutex.Lock()
+	defer p.stateMutex.Unlock()
+
+	switch p.state {
+	case StateSleeping:
+		return nil
+	case StateReady:
+	default:
+		return fmt.Errorf("process is %s", p.state)
+	}
+
+	level := p.config.SleepLevel
+	if level == 0 {
+		level = defaultSleepLevel
+	}
+	if err := p.postUpstream(fmt.Sprintf("/sleep?level=%d", level)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(p.logMonitor, "!!! Put %s to sleep with level %d\n", p.ID, level)
+	p.setState(StateSleeping)
+	p.props.Store(nil)
+	return nil
+}
+
+// wakeUp reloads a sleeping process with vLLM's /wake_up endpoint, the state
+// lock must be held
+func (p *Process) wakeUp() error {
+	if err := p.postUpstream("/wake_up"); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(p.logMonitor, "!!! Woke up %s\n", p.ID)
+	p.setReady()
+	return nil
+}
+
+func (p *Process) postUpstream(path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sleepRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.upstreamURL()+path, nil)
+	if err != nil {
+		return err
+	}
+	p.config.setUpstreamHeaders(req.Header)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", path, resp.StatusCode)
+	}
+	return nil
+}
+
+// isSleeping is CurrentState() == StateSleeping without waiting for a start
+func (p *Process) isSleeping() bool {
+	return p.stateNoWait() == StateSleeping
+}
+
+// sleepOrStop puts processes in swapMode sleep to sleep and stops the others.
+// It returns true when the process sleeps and should be kept.
+func (pm *ProxyManager) sleepOrStop(process *Process) bool {
+	if process.config.sleeps() {
+		err := process.Sleep()
+		if err == nil {
+			return true
+		}
+		fmt.Fprintf(pm.logMonitor, "!!! Unable to put %s to sleep, stopping it: %v\n", process.ID, err)
+	}
+	process.Stop()
+	return false
+}
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVLLM records the sleep and wake up requests and responds to the others
+// with the model name
+type fakeVLLM struct {
+	sync.Mutex
+	calls      []string
+	wakeStatus int
+}
+
+func (f *fakeVLLM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	switch r.URL.Path {
+	case "/sleep":
+		f.calls = append(f.calls, r.URL.RequestURI())
+	case "/wake_up":
+		f.calls = append(f.calls, r.URL.RequestURI())
+		if f.wakeStatus != 0 {
+			w.WriteHeader(f.wakeStatus)
+		}
+	case "/health":
+	default:
+		io.Copy(w, r.Body)
+	}
+}
+
+func (f *fakeVLLM) recordedCalls() []string {
+	f.Lock()
+	defer f.Unlock()
+	return append([]string{}, f.calls...)
+}
+
+func TestModelConfig_ValidateSwapMode(t *testing.T) {
+	assert.NoError(t, ModelConfig{}.validateSwapMode())
+	assert.NoError(t, ModelConfig{SwapMode: "sleep", SleepLevel: 2}.validateSwapMode())
+	assert.ErrorContains(t, ModelConfig{SwapMode: "pause"}.validateSwapMode(), "unknown swapMode")
+	assert.ErrorContains(t, ModelConfig{SwapMode: "sleep", SleepLevel: 3}.validateSwapMode(), "sleepLevel must be 1 or 2")
+	assert.ErrorContains(t, ModelConfig{SwapMode: "sleep", Remote: RemoteConfig{Host: "http://gpu-box:8080"}}.validateSwapMode(), "not supported by remote models")
+}
+
+func TestProcess_SleepAndWakeUp(t *testing.T) {
+	vllm := &fakeVLLM{}
+	upstream := httptest.NewServer(vllm)
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	config := getTestSimpleResponderConfig("vllm")
+	config.Proxy = upstream.URL
+	config.SwapMode = "sleep"
+	config.SleepLevel = 2
+
+	process := NewProcess("vllm", 15, config, NewLogMonitorWriter(io.Discard))
+	defer process.Stop()
+
+	if !assert.NoError(t, process.start()) {
+		return
+	}
+	assert.NoError(t, process.Sleep())
+	assert.Equal(t, StateSleeping, process.CurrentState())
+
+	assert.NoError(t, process.start())
+	assert.Equal(t, StateReady, process.CurrentState())
+	assert.Equal(t, []string{"/sleep?level=2", "/wake_up"}, vllm.recordedCalls())
+
+	// a process that can not be woken up is restarted
+	assert.NoError(t, process.Sleep())
+	vllm.wakeStatus = http.StatusInternalServerError
+	assert.NoError(t, process.start())
+	assert.Equal(t, StateReady, process.CurrentState())
+}
+
+func TestProxyManager_SwapModeSleep(t *testing.T) {
+	vllm := &fakeVLLM{}
+	upstream := httptest.NewServer(vllm)
+	defer upstream.Close()
+
+	sleeper := getTestSimpleResponderConfig("sleeper")
+	sleeper.Proxy = upstream.URL
+	sleeper.SwapMode = "sleep"
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"sleeper": sleeper,
+			"model2":  getTestSimpleResponderConfig("model2"),
+		},
+	})
+	defer proxy.StopProcesses()
+
+	request := func(modelName string) {
+		body := fmt.Sprintf(`{"model":"%s"}`, modelName)
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(body)))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	sleeperKey := ProcessKeyName("", "sleeper")
+
+	request("sleeper")
+	process := proxy.currentProcesses[sleeperKey]
+	if !assert.NotNil(t, process) {
+		return
+	}
+
+	// swapping puts it to sleep and keeps it
+	request("model2")
+	assert.Len(t, proxy.currentProcesses, 2)
+	assert.Equal(t, StateSleeping, process.CurrentState())
+
+	// and wakes it up when it is requested again
+	request("sleeper")
+	assert.Len(t, proxy.currentProcesses, 1)
+	assert.Same(t, process, proxy.currentProcesses[sleeperKey])
+	assert.Equal(t, StateReady, process.CurrentState())
+	assert.Equal(t, []string{"/sleep?level=1", "/wake_up"}, vllm.recordedCalls())
+}
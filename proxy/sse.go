@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// padding comments are limited, a few KB is enough for buffering proxies
+const maxSSEPadding = 64 * 1024
+
+func (m ModelConfig) validateSSE() error {
+	if m.SSEFlushInterval < 0 || m.SSEPadding < 0 {
+		return fmt.Errorf("sseFlushInterval and ssePadding must not be negative")
+	}
+	if m.SSEPadding > maxSSEPadding {
+		return fmt.Errorf("ssePadding must not be more than %d bytes", maxSSEPadding)
+	}
+	if m.SSEFlushInterval > 0 && !m.SplitLargeChunks {
+		return fmt.Errorf("sseFlushInterval needs splitLargeChunks")
+	}
+	return nil
+}
+
+// sseWriter re-chunks a text/event-stream response. With splitLargeChunks
+// each event of a chunk from the upstream is written and flushed on its own,
+// sseFlushInterval apart. ssePadding adds a comment after each write so
+// buffering proxies pass the events on.
+type sseWriter struct {
+	w        http.ResponseWriter
+	split    bool
+	interval time.Duration
+	padding  []byte
+
+	// an event not complete yet
+	pending []byte
+	wrote   bool
+}
+
+// newSSEWriter returns nil when the response is not an event stream or the
+// model has no SSE options
+func (m ModelConfig) newSSEWriter(w http.ResponseWriter, contentType string) *sseWriter {
+	if !m.SplitLargeChunks && m.SSEPadding == 0 {
+		return nil
+	}
+	if !strings.HasPrefix(strings.ToLower(contentType), "text/event-stream") {
+		return nil
+	}
+	s := &sseWriter{
+		w:        w,
+		split:    m.SplitLargeChunks,
+		interval: time.Duration(m.SSEFlushInterval) * time.Millisecond,
+	}
+	if m.SSEPadding > 0 {
+		s.padding = []byte(":" + strings.Repeat(" ", m.SSEPadding) + "\n\n")
+	}
+	return s
+}
+
+func (s *sseWriter) Write(b []byte) (int, error) {
+	if !s.split {
+		if err := s.writeEvent(b); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	s.pending = append(s.pending, b...)
+	for {
+		end := sseEventEnd(s.pending)
+		if end < 0 {
+			return len(b), nil
+		}
+		if s.wrote && s.interval > 0 {
+			time.Sleep(s.interval)
+		}
+		if err := s.writeEvent(s.pending[:end]); err != nil {
+			return 0, err
+		}
+		s.pending = s.pending[end:]
+	}
+}
+
+// sseEventEnd returns the length of the first complete event in b, the
+// empty line after it included, or -1. Lines end with CRLF, LF or CR, also
+// mixed as the SSE spec allows.
+func sseEventEnd(b []byte) int {
+	for i := 0; i < len(b); {
+		first := sseLineEnding(b[i:])
+		if first == 0 {
+			i++
+			continue
+		}
+		j := i + first
+		second := sseLineEnding(b[j:])
+		if second == 0 {
+			i = j
+			continue
+		}
+		// a CR at the end can be the start of a CRLF, the rest is waited
+		// for unless the stream ends its lines with CR only
+		crOnly := first == 1 && b[i] == '\r'
+		if j+second == len(b) && second == 1 && b[j] == '\r' && !crOnly {
+			return -1
+		}
+		return j + second
+	}
+	return -1
+}
+
+// sseLineEnding returns the length of the line ending at the start of b
+func sseLineEnding(b []byte) int {
+	switch {
+	case len(b) >= 2 && b[0] == '\r' && b[1] == '\n':
+		return 2
+	case len(b) >= 1 && (b[0] == '\r' || b[0] == '\n'):
+		return 1
+	}
+	return 0
+}
+
+func (s *sseWriter) writeEvent(event []byte) error {
+	if _, err := s.w.Write(event); err != nil {
+		return err
+	}
+	if s.padding != nil {
+		if _, err := s.w.Write(s.padding); err != nil {
+			return err
+		}
+	}
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	s.wrote = true
+	return nil
+}
+
+// finish writes the rest of a stream that did not end with an empty line
+func (s *sseWriter) finish() {
+	if len(s.pending) > 0 {
+		s.writeEvent(s.pending)
+		s.pending = nil
+	}
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_SSE(t *testing.T) {
+	_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    sseFlushInterval: 20
+`))
+	assert.ErrorContains(t, err, "model model1: sseFlushInterval needs splitLargeChunks")
+}
+
+// flushRecorder records what was written at each flush
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed []string
+	last    int
+}
+
+func (f *flushRecorder) Flush() {
+	body := f.Body.String()
+	f.flushed = append(f.flushed, body[f.last:])
+	f.last = len(body)
+}
+
+func TestSSEWriter_SplitLargeChunks(t *testing.T) {
+	assert.Nil(t, ModelConfig{SplitLargeChunks: true}.newSSEWriter(httptest.NewRecorder(), "application/json"))
+	assert.Nil(t, ModelConfig{}.newSSEWriter(httptest.NewRecorder(), "text/event-stream"))
+
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	sse := ModelConfig{SplitLargeChunks: true, SSEFlushInterval: 10}.newSSEWriter(rec, "text/event-stream; charset=utf-8")
+	if !assert.NotNil(t, sse) {
+		return
+	}
+
+	start := time.Now()
+	sse.Write([]byte("data: a\n\ndata: b\n\ndata: "))
+	sse.Write([]byte("c\n\ndata: [DONE]"))
+	sse.finish()
+
+	assert.Equal(t, []string{"data: a\n\n", "data: b\n\n", "data: c\n\n", "data: [DONE]"}, rec.flushed)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestSSEWriter_SplitLargeChunksLineEndings(t *testing.T) {
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	sse := ModelConfig{SplitLargeChunks: true}.newSSEWriter(rec, "text/event-stream")
+
+	sse.Write([]byte("data: a\r\n\r\ndata: b\r\n\r"))
+	sse.Write([]byte("\ndata: c\r\rdata: d\n\n"))
+	sse.Write([]byte("data: e\r\n\ndata: f\n\r\ndata: g\r\n"))
+	sse.finish()
+
+	assert.Equal(t, []string{"data: a\r\n\r\n", "data: b\r\n\r\n", "data: c\r\r", "data: d\n\n", "data: e\r\n\n", "data: f\n\r\n", "data: g\r\n"}, rec.flushed)
+	assert.Equal(t, -1, sseEventEnd([]byte("data: h\r\n\r")))
+	assert.Equal(t, 9, sseEventEnd([]byte("data: h\r\r")))
+}
+
+func TestSSEWriter_Padding(t *testing.T) {
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	sse := ModelConfig{SSEPadding: 8}.newSSEWriter(rec, "text/event-stream")
+	sse.Write([]byte("data: a\n\ndata: b\n\n"))
+	sse.finish()
+
+	assert.Equal(t, []string{"data: a\n\ndata: b\n\n:        \n\n"}, rec.flushed)
+}
+
+func TestProxyManager_SSESplitLargeChunks(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"n\":1}\n\ndata: {\"n\":2}\n\ndata: [DONE]\n\n")
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Proxy = upstream.URL
+	modelConfig.SplitLargeChunks = true
+	modelConfig.SSEPadding = 4
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+	})
+	defer proxy.StopProcesses()
+
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	proxy.HandlerFunc(rec, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model1","stream":true}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	padding := ":    \n\n"
+	assert.Equal(t, "data: {\"n\":1}\n\n"+padding+"data: {\"n\":2}\n\n"+padding+"data: [DONE]\n\n"+padding, rec.Body.String())
+}
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// added to the env of model commands to find them after llama-swap crashed
+const (
+	markerPIDEnv   = "LLAMA_SWAP_PID"
+	markerModelEnv = "LLAMA_SWAP_MODEL"
+)
+
+// time a stale process has to exit after SIGTERM before it gets a SIGKILL
+const staleProcessStopTimeout = 5 * time.Second
+
+// markedEnv adds the llama-swap markers to a command's env. A nil env
+// inherits llama-swap's environment, as exec.Cmd does.
+func markedEnv(env []string, modelID string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+	return append(env,
+		fmt.Sprintf("%s=%d", markerPIDEnv, os.Getpid()),
+		fmt.Sprintf("%s=%s", markerModelEnv, modelID),
+	)
+}
+
+// parseMarker finds the llama-swap markers in the contents of
+// /proc/<pid>/environ
+func parseMarker(environ []byte) (pid int, modelID string, found bool) {
+	for _, entry := range bytes.Split(environ, []byte{0}) {
+		key, value, ok := bytes.Cut(entry, []byte("="))
+		if !ok {
+			continue
+		}
+		switch string(key) {
+		case markerPIDEnv:
+			pid, _ = strconv.Atoi(string(value))
+		case markerModelEnv:
+			modelID = string(value)
+		}
+	}
+	return pid, modelID, pid > 0
+}
+
+// ReapStaleProcesses stops the model commands left running by a llama-swap
+// that crashed, found by the markers in the env of the processes listening
+// on the local proxy ports. Processes without markers and those of a
+// llama-swap that is still running are left alone. Only supported on Linux.
+// Returns the number of processes stopped.
+func ReapStaleProcesses(config *Config, out io.Writer) int {
+	reaped := 0
+	checked := make(map[string]bool)
+	for modelID, modelConfig := range config.Models {
+		proxyURL, err := url.Parse(modelConfig.Proxy)
+		if err != nil {
+			continue
+		}
+		addr, ok := localProxyAddr(proxyURL)
+		if !ok || checked[addr] {
+			continue
+		}
+		checked[addr] = true
+
+		if !portInUse(addr) {
+			continue
+		}
+		pid := findListenerPID(proxyURL.Port())
+		if pid == 0 || pid == os.Getpid() {
+			continue
+		}
+
+		environ, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+		if err != nil {
+			continue
+		}
+		ownerPID, markedModel, found := parseMarker(environ)
+		if !found || ownerPID == os.Getpid() {
+			continue
+		}
+		if signalPID(ownerPID, syscall.Signal(0)) == nil {
+			fmt.Fprintf(out, "Port %s of model %s is used by PID %d of the running llama-swap PID %d, not reaping it\n", proxyURL.Port(), modelID, pid, ownerPID)
+			continue
+		}
+
+		if err := stopStaleProcess(pid, addr); err != nil {
+			fmt.Fprintf(out, "Unable to reap PID %d (model %s) on port %s: %v\n", pid, markedModel, proxyURL.Port(), err)
+			continue
+		}
+		fmt.Fprintf(out, "Reaped PID %d (model %s) left on port %s by llama-swap PID %d\n", pid, markedModel, proxyURL.Port(), ownerPID)
+		reaped++
+	}
+	return reaped
+}
+
+// stopStaleProcess sends a SIGTERM and a SIGKILL when addr is still in use
+// after staleProcessStopTimeout
+func stopStaleProcess(pid int, addr string) error {
+	if err := signalPID(pid, syscall.SIGTERM); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(staleProcessStopTimeout)
+	for time.Now().Before(deadline) {
+		if !portInUse(addr) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return signalPID(pid, syscall.SIGKILL)
+}
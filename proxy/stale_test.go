@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStale_ParseMarker(t *testing.T) {
+	pid, model, found := parseMarker([]byte("PATH=/bin\x00LLAMA_SWAP_PID=123\x00LLAMA_SWAP_MODEL=qwen\x00"))
+	assert.True(t, found)
+	assert.Equal(t, 123, pid)
+	assert.Equal(t, "qwen", model)
+
+	_, _, found = parseMarker([]byte("PATH=/bin\x00HOME=/root\x00"))
+	assert.False(t, found)
+}
+
+func TestStale_MarkedEnv(t *testing.T) {
+	env := markedEnv([]string{"A=1"}, "model1")
+	assert.Equal(t, []string{"A=1", fmt.Sprintf("LLAMA_SWAP_PID=%d", os.Getpid()), "LLAMA_SWAP_MODEL=model1"}, env)
+
+	// an empty env inherits llama-swap's
+	assert.Greater(t, len(markedEnv(nil, "model1")), 2)
+}
+
+func TestStale_ReapStaleProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("finding the listener of a port is only supported on Linux")
+	}
+
+	// the PID of a llama-swap that is not running anymore
+	exited := exec.Command("true")
+	if !assert.NoError(t, exited.Run()) {
+		return
+	}
+
+	config := getTestSimpleResponderConfig("stale")
+	args, _ := SanitizeCommand(config.Cmd)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d", markerPIDEnv, exited.Process.Pid), markerModelEnv+"=stale")
+	if !assert.NoError(t, cmd.Start()) {
+		return
+	}
+	exitedCh := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exitedCh)
+	}()
+	defer cmd.Process.Kill()
+
+	addr := strings.TrimPrefix(config.Proxy, "http://")
+	assert.Eventually(t, func() bool { return portInUse(addr) }, 5*time.Second, 20*time.Millisecond)
+
+	var out bytes.Buffer
+	reaped := ReapStaleProcesses(&Config{Models: map[string]ModelConfig{"stale": config}}, &out)
+	assert.Equal(t, 1, reaped, out.String())
+	assert.Contains(t, out.String(), "Reaped PID")
+
+	select {
+	case <-exitedCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stale process was not stopped")
+	}
+}
+
+func TestStale_KeepsUnmarkedProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("finding the listener of a port is only supported on Linux")
+	}
+
+	config := getTestSimpleResponderConfig("unmarked")
+	args, _ := SanitizeCommand(config.Cmd)
+	cmd := exec.Command(args[0], args[1:]...)
+	if !assert.NoError(t, cmd.Start()) {
+		return
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	addr := strings.TrimPrefix(config.Proxy, "http://")
+	assert.Eventually(t, func() bool { return portInUse(addr) }, 5*time.Second, 20*time.Millisecond)
+
+	var out bytes.Buffer
+	assert.Equal(t, 0, ReapStaleProcesses(&Config{Models: map[string]ModelConfig{"unmarked": config}}, &out))
+	assert.True(t, portInUse(addr))
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// task types of models, requests to endpoints of another task are rejected
+// before the model is loaded
+var taskTypes = []string{"chat", "completion", "embedding", "rerank", "audio"}
+
+// endpointTaskTypes maps endpoints to the task they serve. Endpoints not in
+// the map, like /tokenize, are not checked.
+var endpointTaskTypes = map[string]string{
+	"/v1/chat/completions":     "chat",
+	"/v1/completions":          "completion",
+	"/v1/embeddings":           "embedding",
+	"/v1/rerank":               "rerank",
+	"/v1/audio/speech":         "audio",
+	"/v1/audio/transcriptions": "audio",
+	"/v1/audio/translations":   "audio",
+}
+
+func validateTaskType(taskType string) error {
+	for _, t := range taskTypes {
+		if taskType == t {
+			return nil
+		}
+	}
+	if taskType == "" {
+		return nil
+	}
+	return fmt.Errorf("unknown taskType %q, expected %s", taskType, strings.Join(taskTypes, ", "))
+}
+
+// modelForTask returns the first listed model, by name, with taskType
+func (c *Config) modelForTask(taskType string) (string, bool) {
+	names := make([]string, 0, len(c.Models))
+	for name, modelConfig := range c.Models {
+		if modelConfig.TaskType == taskType && !modelConfig.Unlisted {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", false
+	}
+	sort.Strings(names)
+	return names[0], true
+}
+
+// checkTaskType responds with a 400 and returns false when the requested
+// model has a taskType that is not served by the request's endpoint. The
+// error suggests a model for the endpoint's task.
+func (pm *ProxyManager) checkTaskType(c *gin.Context, requestedModel string) bool {
+	endpointTask, checked := endpointTaskTypes[c.Request.URL.Path]
+	if !checked {
+		return true
+	}
+
+	config := pm.currentConfig()
+	modelConfig, _, found := config.findRequestedConfig(requestedModel)
+	if !found {
+		return true
+	}
+	modelTask := modelConfig.TaskType
+	if modelTask == "" || modelTask == endpointTask {
+		return true
+	}
+
+	message := fmt.Sprintf("model %s only serves %s requests, not %s", requestedModel, modelTask, c.Request.URL.Path)
+	if suggested, found := config.modelForTask(endpointTask); found {
+		message += fmt.Sprintf(", try model %s", suggested)
+	}
+	traceRequest(c, "%s", message)
+	c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{
+		"message": message,
+		"type":    "invalid_request_error",
+		"param":   "model",
+		"code":    "wrong_task_type",
+	}})
+	return false
+}
@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_TaskType(t *testing.T) {
+	_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    taskType: vision
+`))
+	assert.ErrorContains(t, err, `model model1: unknown taskType "vision"`)
+}
+
+func TestProxyManager_TaskTypeMismatch(t *testing.T) {
+	embed := getTestSimpleResponderConfig("embed")
+	embed.TaskType = "embedding"
+	chat := getTestSimpleResponderConfig("chat")
+	chat.TaskType = "chat"
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"embed": embed, "chat": chat},
+	})
+	defer proxy.StopProcesses()
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"embed"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response struct {
+		Error struct {
+			Message string `json:"message"`
+			Code    string `json:"code"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "wrong_task_type", response.Error.Code)
+	assert.Equal(t, "model embed only serves embedding requests, not /v1/chat/completions, try model chat", response.Error.Message)
+	// rejected before the model is loaded
+	proxy.Lock()
+	assert.Empty(t, proxy.currentProcesses)
+	proxy.Unlock()
+
+	// no suggestion without a completion model
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/completions", bytes.NewBufferString(`{"model":"chat"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "model chat only serves chat requests, not /v1/completions\"")
+
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"chat"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
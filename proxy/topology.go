@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reasons a model is stopped when another one is loaded
+const (
+	evictSwap    = "swap"
+	evictVRAM    = "vram"
+	evictProfile = "profile"
+)
+
+type topologyModel struct {
+	ID             string   `json:"id"`
+	Aliases        []string `json:"aliases,omitempty"`
+	Profiles       []string `json:"profiles,omitempty"`
+	Standby        bool     `json:"standby"`
+	Remote         bool     `json:"remote"`
+	VramEstimateMB int      `json:"vramEstimateMB,omitempty"`
+	Running        bool     `json:"running"`
+}
+
+type topologyProfile struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+// topologyEdge means loading From stops To. With reason vram only when To
+// does not fit next to From.
+type topologyEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+type topologyVRAM struct {
+	Enabled  bool   `json:"enabled"`
+	Provider string `json:"provider,omitempty"`
+	BudgetMB int    `json:"budgetMB,omitempty"`
+}
+
+type topology struct {
+	Models   []topologyModel   `json:"models"`
+	Profiles []topologyProfile `json:"profiles"`
+	VRAM     topologyVRAM      `json:"vram"`
+	Evicts   []topologyEdge    `json:"evicts"`
+}
+
+// apiTopology explains which models are stopped when another model or
+// profile is loaded
+func (pm *ProxyManager) apiTopology(c *gin.Context) {
+	pm.Lock()
+	config := pm.currentConfig()
+	running := make(map[string]bool)
+	for _, process := range pm.currentProcesses {
+		if process.isReady() {
+			running[process.ID] = true
+		}
+	}
+	pm.Unlock()
+
+	c.JSON(http.StatusOK, buildTopology(config, running))
+}
+
+func buildTopology(config *Config, running map[string]bool) topology {
+	ids := make([]string, 0, len(config.Models))
+	for id := range config.Models {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	profileNames := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		profileNames = append(profileNames, name)
+	}
+	sort.Strings(profileNames)
+
+	result := topology{
+		Models:   []topologyModel{},
+		Profiles: []topologyProfile{},
+		Evicts:   []topologyEdge{},
+		VRAM: topologyVRAM{
+			Enabled:  config.VRAM.Enabled(),
+			Provider: config.VRAM.Provider,
+			BudgetMB: config.VRAM.BudgetMB,
+		},
+	}
+
+	memberOf := make(map[string][]string)
+	for _, name := range profileNames {
+		members := []string{}
+		for _, member := range config.Profiles[name] {
+			if realName, found := config.RealModelName(member); found {
+				members = append(members, realName)
+				memberOf[realName] = append(memberOf[realName], name)
+			}
+		}
+		result.Profiles = append(result.Profiles, topologyProfile{Name: name, Members: members})
+	}
+
+	for _, id := range ids {
+		modelConfig := config.Models[id]
+		result.Models = append(result.Models, topologyModel{
+			ID:             id,
+			Aliases:        modelConfig.Aliases,
+			Profiles:       memberOf[id],
+			Standby:        modelConfig.Standby,
+			Remote:         modelConfig.Remote.enabled(),
+			VramEstimateMB: modelConfig.VramEstimateMB,
+			Running:        running[id],
+		})
+	}
+
+	// loading a single model, see swapModel and makeVRAMRoom
+	for _, from := range ids {
+		for _, to := range ids {
+			if from == to {
+				continue
+			}
+			if reason := evictReason(config, from, to); reason != "" {
+				result.Evicts = append(result.Evicts, topologyEdge{From: from, To: to, Reason: reason})
+			}
+		}
+	}
+
+	// loading a profile, see stopProcessesForSwap
+	for _, name := range profileNames {
+		for _, to := range ids {
+			if config.Models[to].Standby && !slices.Contains(config.Profiles[name], to) {
+				continue
+			}
+			from := name + PROFILE_SPLIT_CHAR + "*"
+			reason := evictProfile
+			// with an eviction policy only when there is no room
+			if config.ProfileEvictionPolicy[name] != "" && config.VRAM.Enabled() && config.Models[to].VramEstimateMB > 0 {
+				reason = evictVRAM
+			}
+			result.Evicts = append(result.Evicts, topologyEdge{From: from, To: to, Reason: reason})
+		}
+	}
+
+	return result
+}
+
+// evictReason is why loading from stops to, empty when it does not
+func evictReason(config *Config, from, to string) string {
+	// with estimates models are only stopped when there is no room
+	if config.VRAM.Enabled() && config.Models[from].VramEstimateMB > 0 && config.Models[to].VramEstimateMB > 0 {
+		return evictVRAM
+	}
+	if config.Models[to].Standby {
+		return ""
+	}
+	return evictSwap
+}
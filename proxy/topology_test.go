@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTopology(t *testing.T) {
+	config, err := LoadConfigFromBytes([]byte(`
+vram:
+  budgetMB: 24000
+models:
+  chat:
+    cmd: path/to/cmd
+    aliases: [gpt]
+    vramEstimateMB: 8000
+  coder:
+    cmd: path/to/cmd
+    vramEstimateMB: 16000
+  embed:
+    cmd: path/to/cmd
+    standby: true
+  big:
+    cmd: path/to/cmd
+profiles:
+  coding: [gpt, coder]
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	result := buildTopology(config, map[string]bool{"chat": true})
+
+	assert.Equal(t, topologyVRAM{Enabled: true, BudgetMB: 24000}, result.VRAM)
+	assert.Equal(t, []topologyProfile{{Name: "coding", Members: []string{"chat", "coder"}}}, result.Profiles)
+	if assert.Len(t, result.Models, 4) {
+		assert.Equal(t, topologyModel{ID: "chat", Aliases: []string{"gpt"}, Profiles: []string{"coding"}, VramEstimateMB: 8000, Running: true}, result.Models[1])
+	}
+
+	evicts := make(map[string]string)
+	for _, edge := range result.Evicts {
+		evicts[edge.From+" -> "+edge.To] = edge.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"big -> chat":    evictSwap,
+		"big -> coder":   evictSwap,
+		"chat -> big":    evictSwap,
+		"chat -> coder":  evictVRAM,
+		"coder -> big":   evictSwap,
+		"coder -> chat":  evictVRAM,
+		"embed -> big":   evictSwap,
+		"embed -> chat":  evictSwap,
+		"embed -> coder": evictSwap,
+
+		// standby models are kept unless they are part of the profile
+		"coding:* -> big":   evictProfile,
+		"coding:* -> chat":  evictProfile,
+		"coding:* -> coder": evictProfile,
+	}, evicts)
+}
+
+func TestProxyManager_TopologyEndpoint(t *testing.T) {
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+	})
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/topology", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result topology
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Len(t, result.Models, 1)
+	assert.Empty(t, result.Evicts)
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	debugHeader   = "X-LlamaSwap-Debug"
+	traceIDHeader = "X-LlamaSwap-Trace-ID"
+	ctxKeyTrace   = "llama-swap.trace"
+	maxTraces     = 100
+)
+
+// requestTrace records what the proxy did for a single request, see
+// traceMiddleware
+type requestTrace struct {
+	mu sync.Mutex
+
+	ID         string       `json:"id"`
+	Time       time.Time    `json:"time"`
+	Method     string       `json:"method"`
+	Path       string       `json:"path"`
+	Status     int          `json:"status"`
+	DurationMs float64      `json:"duration_ms"`
+	Events     []traceEvent `json:"events"`
+}
+
+type traceEvent struct {
+	ElapsedMs float64 `json:"elapsed_ms"`
+	Message   string  `json:"message"`
+}
+
+func (t *requestTrace) add(message string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Events = append(t.Events, traceEvent{
+		ElapsedMs: float64(time.Since(t.Time).Microseconds()) / 1000,
+		Message:   message,
+	})
+}
+
+// traceRequest adds an event to the trace of the request, if it has one
+func traceRequest(c *gin.Context, format string, args ...interface{}) {
+	if value, ok := c.Get(ctxKeyTrace); ok {
+		value.(*requestTrace).add(fmt.Sprintf(format, args...))
+	}
+}
+
+// traceMiddleware traces requests with the debug header from admin keys. The
+// trace ID is sent back in a header to look the trace up in /api/traces.
+func (pm *ProxyManager) traceMiddleware(c *gin.Context) {
+	if debug, _ := strconv.ParseBool(c.GetHeader(debugHeader)); !debug || !pm.isAdminRequest(c) {
+		c.Next()
+		return
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	trace := &requestTrace{
+		ID:     hex.EncodeToString(id),
+		Time:   time.Now(),
+		Method: c.Request.Method,
+		Path:   c.Request.URL.Path,
+	}
+
+	pm.tracesMutex.Lock()
+	pm.traces = append(pm.traces, trace)
+	if len(pm.traces) > maxTraces {
+		pm.traces = pm.traces[len(pm.traces)-maxTraces:]
+	}
+	pm.tracesMutex.Unlock()
+
+	c.Set(ctxKeyTrace, trace)
+	c.Header(traceIDHeader, trace.ID)
+
+	c.Next()
+
+	trace.add(fmt.Sprintf("responded with status %d", c.Writer.Status()))
+	trace.mu.Lock()
+	trace.Status = c.Writer.Status()
+	trace.DurationMs = float64(time.Since(trace.Time).Microseconds()) / 1000
+	trace.mu.Unlock()
+}
+
+func (pm *ProxyManager) apiGetTrace(c *gin.Context) {
+	pm.tracesMutex.Lock()
+	var trace *requestTrace
+	for _, t := range pm.traces {
+		if t.ID == c.Param("requestID") {
+			trace = t
+			break
+		}
+	}
+	pm.tracesMutex.Unlock()
+
+	if trace == nil {
+		pm.sendErrorResponse(c, http.StatusNotFound, "trace not found")
+		return
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	c.JSON(http.StatusOK, trace)
+}
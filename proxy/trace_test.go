@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyManager_DebugTrace(t *testing.T) {
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Aliases = []string{"gpt"}
+	config := &Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+		AdminKeys:          []string{"admin-key"},
+		aliases:            map[string]string{"gpt": "model1"},
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	request := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"gpt"}`))
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.Header.Set(debugHeader, "true")
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		return w
+	}
+
+	getTrace := func(id, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/traces/"+id, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		return w
+	}
+
+	// not traced without an admin key
+	w := request("user-key")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(traceIDHeader))
+
+	w = request("admin-key")
+	assert.Equal(t, http.StatusOK, w.Code)
+	id := w.Header().Get(traceIDHeader)
+	if !assert.NotEmpty(t, id) {
+		return
+	}
+
+	assert.Equal(t, http.StatusForbidden, getTrace(id, "user-key").Code)
+	assert.Equal(t, http.StatusNotFound, getTrace("unknown", "admin-key").Code)
+
+	w = getTrace(id, "admin-key")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var trace requestTrace
+	if !assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &trace)) {
+		return
+	}
+	assert.Equal(t, id, trace.ID)
+	assert.Equal(t, "/v1/chat/completions", trace.Path)
+	assert.Equal(t, http.StatusOK, trace.Status)
+
+	var messages []string
+	for _, event := range trace.Events {
+		messages = append(messages, event.Message)
+	}
+	if assert.Len(t, messages, 4) {
+		assert.Equal(t, `requested model "gpt"`, messages[0])
+		assert.Contains(t, messages[1], "resolved gpt to model model1")
+		assert.Contains(t, messages[2], "upstream model1 responded with status 200")
+		assert.Equal(t, "responded with status 200", messages[3])
+	}
+}
+
+func TestProxyManager_DebugTraceDisabledWithoutAdminKeys(t *testing.T) {
+	proxy := New(&Config{HealthCheckTimeout: 15})
+
+	req := httptest.NewRequest("GET", "/v1/models", nil)
+	req.Header.Set(debugHeader, "true")
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(traceIDHeader))
+
+	req = httptest.NewRequest("GET", "/api/traces/abc", nil)
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ttlHeader        = "X-Llama-Swap-TTL"
+	ctxKeyRequestTTL = "llama-swap.ttl"
+
+	// a TTL that never unloads the model
+	ttlPinned = -1
+)
+
+// requestTTL reads the TTL in seconds of a request from the Ollama style
+// keep_alive field or the X-Llama-Swap-TTL header. The field is removed from
+// the body. 0 unloads the model after the response, a negative value pins it.
+func requestTTL(c *gin.Context, requestBody map[string]interface{}) (int, bool, error) {
+	value, found := requestBody["keep_alive"]
+	delete(requestBody, "keep_alive")
+	if header := c.GetHeader(ttlHeader); header != "" {
+		value, found = header, true
+	}
+	if !found || value == nil {
+		return 0, false, nil
+	}
+
+	var seconds int
+	switch v := value.(type) {
+	case float64:
+		seconds = int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			seconds = n
+		} else if d, err := time.ParseDuration(v); err == nil {
+			seconds = int(d.Seconds())
+		} else {
+			return 0, false, fmt.Errorf("invalid keep_alive %q, use seconds or a duration like 5m", v)
+		}
+	default:
+		return 0, false, fmt.Errorf("invalid keep_alive %v, use seconds or a duration like 5m", v)
+	}
+
+	if seconds < 0 {
+		seconds = ttlPinned
+	}
+	return seconds, true, nil
+}
+
+// applyRequestTTL overrides the ttl of process with the request's, a
+// request without one resets it to the configured ttl
+func applyRequestTTL(c *gin.Context, process *Process) {
+	if ttl, found := c.Get(ctxKeyRequestTTL); found {
+		process.setTTL(ttl.(int))
+	} else {
+		process.resetTTL()
+	}
+}
+
+// setTTL overrides the model's ttl until the next request without one or
+// the process stops
+func (p *Process) setTTL(seconds int) {
+	p.ttlOverride.Store(&seconds)
+}
+
+func (p *Process) resetTTL() {
+	p.ttlOverride.Store(nil)
+}
+
+// ttl is the overridden or configured ttl, false when the process is not
+// unloaded after it
+func (p *Process) ttl() (time.Duration, bool) {
+	seconds := p.config.UnloadAfter
+	if override := p.ttlOverride.Load(); override != nil {
+		seconds = *override
+		if seconds == 0 {
+			return 0, true
+		}
+	}
+	if seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
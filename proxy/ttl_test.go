@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTTL(t *testing.T) {
+	tests := []struct {
+		keepAlive interface{}
+		header    string
+		ttl       int
+		found     bool
+	}{
+		{nil, "", 0, false},
+		{float64(300), "", 300, true},
+		{"5m", "", 300, true},
+		{"0", "", 0, true},
+		{float64(-1), "", ttlPinned, true},
+		{"-5m", "", ttlPinned, true},
+		{float64(300), "60", 60, true},
+	}
+
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		if tt.header != "" {
+			c.Request.Header.Set(ttlHeader, tt.header)
+		}
+
+		requestBody := map[string]interface{}{"model": "model1"}
+		if tt.keepAlive != nil {
+			requestBody["keep_alive"] = tt.keepAlive
+		}
+
+		ttl, found, err := requestTTL(c, requestBody)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.ttl, ttl, "keep_alive %v, header %q", tt.keepAlive, tt.header)
+		assert.Equal(t, tt.found, found)
+		assert.NotContains(t, requestBody, "keep_alive")
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	_, _, err := requestTTL(c, map[string]interface{}{"keep_alive": "soon"})
+	assert.ErrorContains(t, err, "invalid keep_alive")
+}
+
+func TestProcess_TTLOverride(t *testing.T) {
+	process := NewProcess("model1", 15, ModelConfig{UnloadAfter: 60}, NewLogMonitorWriter(io.Discard))
+
+	ttl, unloads := process.ttl()
+	assert.Equal(t, time.Minute, ttl)
+	assert.True(t, unloads)
+
+	process.setTTL(ttlPinned)
+	_, unloads = process.ttl()
+	assert.False(t, unloads)
+
+	process.setTTL(0)
+	ttl, unloads = process.ttl()
+	assert.Equal(t, time.Duration(0), ttl)
+	assert.True(t, unloads)
+
+	process.resetTTL()
+	ttl, _ = process.ttl()
+	assert.Equal(t, time.Minute, ttl)
+}
+
+func TestProxyManager_KeepAliveResetByLaterRequests(t *testing.T) {
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.UnloadAfter = 60
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+	})
+	defer proxy.StopProcesses()
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1","keep_alive":-1}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	process := proxy.currentProcesses[ProcessKeyName("", "model1")]
+	_, unloads := process.ttl()
+	assert.False(t, unloads)
+
+	// another client's request without keep_alive gets the configured ttl
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	ttl, unloads := process.ttl()
+	assert.True(t, unloads)
+	assert.Equal(t, time.Minute, ttl)
+}
+
+func TestProxyManager_KeepAliveZeroUnloads(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Proxy = upstream.URL
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+	})
+	defer proxy.StopProcesses()
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1","keep_alive":0}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"model":"model1"}`, w.Body.String())
+
+	process := proxy.currentProcesses[ProcessKeyName("", "model1")]
+	assert.Eventually(t, func() bool {
+		return process.CurrentState() == StateStopped
+	}, 5*time.Second, 50*time.Millisecond)
+}
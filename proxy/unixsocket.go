@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	unixProxyPrefix = "unix://"
+
+	// requests to unix socket upstreams use this base url, the connection
+	// goes to the socket whatever the host
+	unixUpstreamBase = "http://unix"
+)
+
+// unixSocketPath returns the socket of a unix:///path/to.sock proxy
+func unixSocketPath(proxy string) (string, bool) {
+	path, found := strings.CutPrefix(proxy, unixProxyPrefix)
+	if !found {
+		return "", false
+	}
+	return path, true
+}
+
+func validateUnixProxy(m ModelConfig) error {
+	path, ok := unixSocketPath(m.Proxy)
+	if !ok {
+		return nil
+	}
+	if path == "" || strings.Contains(path, "?") {
+		return fmt.Errorf("invalid unix socket proxy %q, expected unix:///path/to.sock", m.Proxy)
+	}
+	if !m.ProxyTLS.empty() {
+		return fmt.Errorf("proxyTLS can not be used with a unix socket proxy")
+	}
+	if m.Remote.enabled() {
+		return fmt.Errorf("remote models can not use a unix socket proxy")
+	}
+	return nil
+}
+
+// proxyAddress is the upstream shown to users, the socket of a unix socket
+// proxy instead of its request url
+func (p *Process) proxyAddress() string {
+	if _, ok := unixSocketPath(p.config.Proxy); ok {
+		return p.config.Proxy
+	}
+	return p.upstreamURL()
+}
+
+// unixSocketTransport sends every request to the socket at path
+func unixSocketTransport(path string) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, "unix", path)
+	}
+	return transport
+}
+
+// unixSocketInUse is true when something accepts connections on the socket
+func unixSocketInUse(path string) bool {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// removeStaleSocket removes a socket file nothing listens on anymore, like
+// one left by a crashed upstream, so the command can bind it again
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode().Type() != fs.ModeSocket || unixSocketInUse(path) {
+		return nil
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// socket paths are limited to about 100 bytes, t.TempDir() can be longer
+func shortSocketPath(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "ls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "upstream.sock")
+}
+
+func TestProcess_UnixSocketProxy(t *testing.T) {
+	path := shortSocketPath(t)
+	listener, err := net.Listen("unix", path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("unix upstream " + r.URL.Path))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	// the command only keeps the process running, requests go to the socket
+	config := getTestSimpleResponderConfig("unix")
+	config.Proxy = "unix://" + path
+
+	process := NewProcess("unix", 5, config, NewLogMonitorWriter(io.Discard))
+	defer process.Stop()
+
+	w := httptest.NewRecorder()
+	process.ProxyRequest(w, httptest.NewRequest("GET", "/v1/models", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "unix upstream /v1/models", w.Body.String())
+	assert.Equal(t, "unix://"+path, process.proxyAddress())
+}
+
+func TestUnixSocket_RemoveStaleSocket(t *testing.T) {
+	path := shortSocketPath(t)
+	listener, err := net.Listen("unix", path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// in use, kept
+	assert.NoError(t, removeStaleSocket(path))
+	assert.FileExists(t, path)
+
+	// closing a listener removes its socket, leave one behind like a crash
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	assert.NoError(t, removeStaleSocket(path))
+	assert.NoFileExists(t, path)
+
+	assert.NoError(t, removeStaleSocket(path))
+}
+
+func TestConfig_UnixSocketProxy(t *testing.T) {
+	config, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: llama-server --host /tmp/llama-1.sock
+    proxy: unix:///tmp/llama-1.sock
+`))
+	assert.NoError(t, err)
+	client, err := config.Models["model1"].upstreamClient()
+	assert.NoError(t, err)
+	assert.NotNil(t, client.Transport)
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: llama-server --host /tmp/llama-1.sock
+    proxy: unix://
+`))
+	assert.ErrorContains(t, err, "invalid unix socket proxy")
+
+	_, err = LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: llama-server --host /tmp/llama-1.sock
+    proxy: unix:///tmp/llama-1.sock
+    proxyTLS:
+      insecureSkipVerify: true
+`))
+	assert.ErrorContains(t, err, "proxyTLS can not be used with a unix socket proxy")
+}
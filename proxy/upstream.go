@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ProxyTLSConfig configures the connection to HTTPS upstreams
+type ProxyTLSConfig struct {
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+	CAFile             string `yaml:"caFile"`
+	ClientCert         string `yaml:"clientCert"`
+	ClientKey          string `yaml:"clientKey"`
+}
+
+func (t ProxyTLSConfig) validate() error {
+	if (t.ClientCert == "") != (t.ClientKey == "") {
+		return fmt.Errorf("proxyTLS clientCert and clientKey must be set together")
+	}
+	return nil
+}
+
+func (t ProxyTLSConfig) empty() bool {
+	return !t.InsecureSkipVerify && t.CAFile == "" && t.ClientCert == ""
+}
+
+func (t ProxyTLSConfig) tlsConfig() (*tls.Config, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read proxyTLS caFile: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in proxyTLS caFile %s", t.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if t.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load proxyTLS client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// upstreamClient returns the http client used for health checks and
+// proxying requests to the model's upstream
+func (m ModelConfig) upstreamClient() (*http.Client, error) {
+	// in unixsocket.go
+	if path, ok := unixSocketPath(m.Proxy); ok {
+		return &http.Client{Transport: unixSocketTransport(path)}, nil
+	}
+
+	if m.ProxyTLS.empty() {
+		return &http.Client{}, nil
+	}
+
+	tlsConfig, err := m.ProxyTLS.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// setUpstreamHeaders adds the configured upstreamHeaders, replacing any
+// values sent by the client
+func (m ModelConfig) setUpstreamHeaders(header http.Header) {
+	for name, value := range m.UpstreamHeaders {
+		header.Set(name, value)
+	}
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTLSUpstream(t *testing.T) (*httptest.Server, *string) {
+	var gotAuth string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			gotAuth = r.Header.Get("Authorization")
+		}
+		w.Write([]byte("tls upstream"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &gotAuth
+}
+
+func TestProcess_ProxyTLSWithCAFile(t *testing.T) {
+	server, gotAuth := newTestTLSUpstream(t)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(caFile, certPEM, 0644))
+
+	// the command only keeps the process running, requests go to the TLS server
+	config := getTestSimpleResponderConfig("tls")
+	config.Proxy = server.URL
+	config.ProxyTLS = ProxyTLSConfig{CAFile: caFile}
+	config.UpstreamHeaders = map[string]string{"Authorization": "Bearer upstream-key"}
+
+	process := NewProcess("tls", 5, config, NewLogMonitorWriter(io.Discard))
+	defer process.Stop()
+
+	req := httptest.NewRequest("GET", "/v1/models", nil)
+	req.Header.Set("Authorization", "Bearer client-key")
+	w := httptest.NewRecorder()
+	process.ProxyRequest(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "tls upstream", w.Body.String())
+	assert.Equal(t, "Bearer upstream-key", *gotAuth)
+}
+
+func TestProcess_ProxyTLSUntrustedCertificate(t *testing.T) {
+	server, _ := newTestTLSUpstream(t)
+
+	config := getTestSimpleResponderConfig("tls_untrusted")
+	config.Proxy = server.URL
+
+	process := NewProcess("tls_untrusted", 1, config, NewLogMonitorWriter(io.Discard))
+	defer process.Stop()
+	assert.Error(t, process.start())
+
+	config.ProxyTLS.InsecureSkipVerify = true
+	process = NewProcess("tls_insecure", 1, config, NewLogMonitorWriter(io.Discard))
+	defer process.Stop()
+	assert.NoError(t, process.start())
+}
+
+func TestProxyTLSConfig_Errors(t *testing.T) {
+	_, err := ProxyTLSConfig{ClientCert: "cert.pem"}.tlsConfig()
+	assert.ErrorContains(t, err, "must be set together")
+
+	_, err = ProxyTLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}.tlsConfig()
+	assert.ErrorContains(t, err, "unable to read proxyTLS caFile")
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(empty, []byte("not a certificate"), 0644)
+	_, err = ProxyTLSConfig{CAFile: empty}.tlsConfig()
+	assert.ErrorContains(t, err, "no certificates found")
+}
@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricFamily is a metric with its HELP and TYPE lines and the samples of
+// all upstreams
+type metricFamily struct {
+	name     string
+	comments []string
+	samples  []string
+}
+
+// metricFamilies merges the metrics of several upstreams, the families stay
+// in the order they were first seen
+type metricFamilies struct {
+	order    []string
+	families map[string]*metricFamily
+}
+
+func newMetricFamilies() *metricFamilies {
+	return &metricFamilies{families: make(map[string]*metricFamily)}
+}
+
+func (m *metricFamilies) family(name string) *metricFamily {
+	family, found := m.families[name]
+	if !found {
+		family = &metricFamily{name: name}
+		m.families[name] = family
+		m.order = append(m.order, name)
+	}
+	return family
+}
+
+// add adds the metrics in the Prometheus text format with the labels added
+// to each sample. The HELP and TYPE lines are kept once per family.
+func (m *metricFamilies) add(data []byte, labels string) {
+	var current *metricFamily
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if comment, found := strings.CutPrefix(line, "#"); found {
+			fields := strings.Fields(comment)
+			if len(fields) < 2 || (fields[0] != "HELP" && fields[0] != "TYPE") {
+				continue
+			}
+			current = m.family(fields[1])
+			if !current.hasComment(fields[0]) {
+				current.comments = append(current.comments, line)
+			}
+			continue
+		}
+
+		name, rest := line, ""
+		if i := strings.IndexAny(line, "{ "); i >= 0 {
+			name, rest = line[:i], line[i:]
+		}
+		// _bucket, _sum and _count samples follow the TYPE of their family
+		family := current
+		if family == nil || !strings.HasPrefix(name, family.name) {
+			family = m.family(name)
+		}
+
+		if strings.HasPrefix(rest, "{") {
+			if strings.HasPrefix(rest, "{}") {
+				rest = "{" + labels + "}" + rest[2:]
+			} else {
+				rest = "{" + labels + "," + rest[1:]
+			}
+		} else {
+			rest = "{" + labels + "}" + rest
+		}
+		family.samples = append(family.samples, name+rest)
+	}
+}
+
+// hasComment is true when the family has a HELP or TYPE line already
+func (f *metricFamily) hasComment(kind string) bool {
+	for _, comment := range f.comments {
+		if strings.HasPrefix(comment, "# "+kind+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *metricFamilies) write(sb *strings.Builder) {
+	for _, name := range m.order {
+		family := m.families[name]
+		for _, comment := range family.comments {
+			sb.WriteString(comment + "\n")
+		}
+		for _, sample := range family.samples {
+			sb.WriteString(sample + "\n")
+		}
+	}
+}
+
+// processLabels returns the Prometheus labels of a process
+func processLabels(key string, process *Process) string {
+	labels := fmt.Sprintf(`model="%s"`, labelValueEscaper.Replace(process.ID))
+	if profile, _, found := strings.Cut(key, PROFILE_SPLIT_CHAR); found && profile != "" {
+		labels += fmt.Sprintf(`,profile="%s"`, labelValueEscaper.Replace(profile))
+	}
+	return labels
+}
+
+// metricsHandler exports the /metrics of the ready upstreams, like
+// llama-server with --metrics or LLAMA_ARG_ENDPOINT_METRICS=1, with model
+// and profile labels. llamaswap_upstream_scrape_ok is 0 for upstreams that
+// did not return their metrics.
+func (pm *ProxyManager) metricsHandler(c *gin.Context) {
+	processes := pm.readyProcesses()
+	keys := make([]string, 0, len(processes))
+	for key := range processes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), propsFetchTimeout)
+	defer cancel()
+
+	scraped := make([][]byte, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, process *Process) {
+			defer wg.Done()
+			if data, err := process.getUpstream(ctx, "/metrics"); err == nil {
+				scraped[i] = data
+			}
+		}(i, processes[key])
+	}
+	wg.Wait()
+
+	families := newMetricFamilies()
+	scrapeOK := families.family("llamaswap_upstream_scrape_ok")
+	scrapeOK.comments = []string{
+		"# HELP llamaswap_upstream_scrape_ok Whether the metrics of the upstream were scraped.",
+		"# TYPE llamaswap_upstream_scrape_ok gauge",
+	}
+	for i, key := range keys {
+		labels := processLabels(key, processes[key])
+		ok := 0
+		if scraped[i] != nil {
+			ok = 1
+			families.add(scraped[i], labels)
+		}
+		scrapeOK.samples = append(scrapeOK.samples, fmt.Sprintf("llamaswap_upstream_scrape_ok{%s} %d", labels, ok))
+	}
+
+	var sb strings.Builder
+	families.write(&sb)
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(sb.String()))
+}
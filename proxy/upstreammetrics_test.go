@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testLlamaMetrics = `# HELP llamacpp:prompt_tokens_total Number of prompt tokens processed.
+# TYPE llamacpp:prompt_tokens_total counter
+llamacpp:prompt_tokens_total 120
+# HELP llamacpp:requests_processing Number of requests processing.
+# TYPE llamacpp:requests_processing gauge
+llamacpp:requests_processing{slot="0"} 1
+`
+
+func TestMetricFamilies_Add(t *testing.T) {
+	families := newMetricFamilies()
+	families.add([]byte(testLlamaMetrics), `model="qwen"`)
+	families.add([]byte(testLlamaMetrics), `model="llama",profile="coding"`)
+
+	var sb strings.Builder
+	families.write(&sb)
+	assert.Equal(t, `# HELP llamacpp:prompt_tokens_total Number of prompt tokens processed.
+# TYPE llamacpp:prompt_tokens_total counter
+llamacpp:prompt_tokens_total{model="qwen"} 120
+llamacpp:prompt_tokens_total{model="llama",profile="coding"} 120
+# HELP llamacpp:requests_processing Number of requests processing.
+# TYPE llamacpp:requests_processing gauge
+llamacpp:requests_processing{model="qwen",slot="0"} 1
+llamacpp:requests_processing{model="llama",profile="coding",slot="0"} 1
+`, sb.String())
+}
+
+func TestProxyManager_Metrics(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+		case "/metrics":
+			fmt.Fprint(w, testLlamaMetrics)
+		default:
+			fmt.Fprint(w, "model1")
+		}
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Proxy = upstream.URL
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig, "model2": getTestSimpleResponderConfig("model2")},
+		Profiles:           map[string][]string{"coding": {"model1", "model2"}},
+	})
+	defer proxy.StopProcesses()
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "# HELP llamaswap_upstream_scrape_ok Whether the metrics of the upstream were scraped.\n# TYPE llamaswap_upstream_scrape_ok gauge\n", w.Body.String())
+
+	for _, model := range []string{"coding:model1", "coding:model2"} {
+		w = httptest.NewRecorder()
+		proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"`+model+`"}`)))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+
+	body := w.Body.String()
+	assert.Contains(t, body, `llamaswap_upstream_scrape_ok{model="model1",profile="coding"} 1`)
+	// simple-responder has no /metrics
+	assert.Contains(t, body, `llamaswap_upstream_scrape_ok{model="model2",profile="coding"} 0`)
+	assert.Contains(t, body, `llamacpp:prompt_tokens_total{model="model1",profile="coding"} 120`)
+	assert.Equal(t, 1, strings.Count(body, "# TYPE llamacpp:prompt_tokens_total counter"))
+}
@@ -0,0 +1,198 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// prefix of the llama-server metrics in /metrics
+const llamaMetricsPrefix = "llamacpp:"
+
+// upstreamStatus merges the llama-server /slots, /props and /metrics of a
+// running model. Endpoints the upstream does not serve, e.g. /metrics
+// without --metrics, are left out and their error is in Errors.
+type upstreamStatus struct {
+	Model string `json:"model"`
+	Key   string `json:"key"`
+	State string `json:"state"`
+
+	ContextSize int            `json:"contextSize,omitempty"`
+	Slots       *slotsStatus   `json:"slots,omitempty"`
+	KVCache     *kvCacheStatus `json:"kvCache,omitempty"`
+
+	// llamacpp: metrics without their prefix, e.g. requests_processing
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type slotsStatus struct {
+	Total int `json:"total"`
+	Busy  int `json:"busy"`
+	Idle  int `json:"idle"`
+}
+
+type kvCacheStatus struct {
+	UsageRatio float64 `json:"usageRatio"`
+	Tokens     int     `json:"tokens"`
+}
+
+// getUpstream requests path from the upstream of the process
+func (p *Process) getUpstream(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(p.upstreamURL(), "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.config.setUpstreamHeaders(req.Header)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// fetchUpstreamStatus queries the llama-server endpoints of a ready process
+func (p *Process) fetchUpstreamStatus(ctx context.Context, key string) upstreamStatus {
+	status := upstreamStatus{Model: p.ID, Key: key, State: string(p.CurrentState()), Errors: make(map[string]string)}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	fetch := func(path string, parse func([]byte) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := p.getUpstream(ctx, path)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				err = parse(data)
+			}
+			if err != nil {
+				status.Errors[strings.TrimPrefix(path, "/")] = err.Error()
+			}
+		}()
+	}
+
+	fetch("/slots", func(data []byte) error {
+		var slots []struct {
+			IsProcessing bool `json:"is_processing"`
+			NCtx         int  `json:"n_ctx"`
+		}
+		if err := json.Unmarshal(data, &slots); err != nil {
+			return err
+		}
+		status.Slots = &slotsStatus{Total: len(slots)}
+		for _, slot := range slots {
+			if slot.IsProcessing {
+				status.Slots.Busy++
+			}
+		}
+		status.Slots.Idle = status.Slots.Total - status.Slots.Busy
+		return nil
+	})
+	fetch("/props", func(data []byte) error {
+		var props struct {
+			DefaultGenerationSettings struct {
+				NCtx int `json:"n_ctx"`
+			} `json:"default_generation_settings"`
+		}
+		if err := json.Unmarshal(data, &props); err != nil {
+			return err
+		}
+		status.ContextSize = props.DefaultGenerationSettings.NCtx
+		return nil
+	})
+	fetch("/metrics", func(data []byte) error {
+		status.Metrics = parseLlamaMetrics(data)
+		return nil
+	})
+	wg.Wait()
+
+	if ratio, found := status.Metrics["kv_cache_usage_ratio"]; found {
+		status.KVCache = &kvCacheStatus{UsageRatio: ratio, Tokens: int(status.Metrics["kv_cache_tokens"])}
+	}
+	if len(status.Errors) == 0 {
+		status.Errors = nil
+	}
+	return status
+}
+
+// parseLlamaMetrics reads the llamacpp: metrics of the Prometheus text
+// format, labels are ignored
+func parseLlamaMetrics(data []byte) map[string]float64 {
+	metrics := make(map[string]float64)
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		name, found := strings.CutPrefix(line, llamaMetricsPrefix)
+		if !found {
+			continue
+		}
+		fields := strings.Fields(name)
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		name, _, _ = strings.Cut(fields[0], "{")
+		metrics[name] = value
+	}
+	return metrics
+}
+
+// readyProcesses returns the ready processes by their key
+func (pm *ProxyManager) readyProcesses() map[string]*Process {
+	pm.Lock()
+	defer pm.Unlock()
+	processes := make(map[string]*Process)
+	for key, process := range pm.currentProcesses {
+		if process.isReady() {
+			processes[key] = process
+		}
+	}
+	return processes
+}
+
+// apiUpstreamsStatus returns the merged llama-server status of the ready
+// models
+func (pm *ProxyManager) apiUpstreamsStatus(c *gin.Context) {
+	processes := pm.readyProcesses()
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), propsFetchTimeout)
+	defer cancel()
+
+	upstreams := make([]upstreamStatus, 0, len(processes))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for key, process := range processes {
+		wg.Add(1)
+		go func(key string, process *Process) {
+			defer wg.Done()
+			status := process.fetchUpstreamStatus(ctx, key)
+			mu.Lock()
+			upstreams = append(upstreams, status)
+			mu.Unlock()
+		}(key, process)
+	}
+	wg.Wait()
+
+	sort.Slice(upstreams, func(i, j int) bool { return upstreams[i].Key < upstreams[j].Key })
+	c.JSON(http.StatusOK, gin.H{"upstreams": upstreams})
+}
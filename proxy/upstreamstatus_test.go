@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamStatus_ParseLlamaMetrics(t *testing.T) {
+	metrics := parseLlamaMetrics([]byte(`# HELP llamacpp:kv_cache_usage_ratio KV-cache usage. 1 means 100 percent usage.
+# TYPE llamacpp:kv_cache_usage_ratio gauge
+llamacpp:kv_cache_usage_ratio 0.25
+llamacpp:kv_cache_tokens 2048
+llamacpp:requests_processing{slot="0"} 1
+other_metric 5
+llamacpp:broken abc
+`))
+	assert.Equal(t, map[string]float64{"kv_cache_usage_ratio": 0.25, "kv_cache_tokens": 2048, "requests_processing": 1}, metrics)
+}
+
+func TestProxyManager_UpstreamsStatus(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+		case "/slots":
+			fmt.Fprint(w, `[{"id":0,"n_ctx":4096,"is_processing":true},{"id":1,"n_ctx":4096,"is_processing":false}]`)
+		case "/props":
+			fmt.Fprint(w, `{"default_generation_settings":{"n_ctx":8192},"total_slots":2}`)
+		case "/metrics":
+			// started without --metrics
+			http.Error(w, "metrics not enabled", http.StatusNotImplemented)
+		default:
+			fmt.Fprint(w, "model1")
+		}
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Proxy = upstream.URL
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig, "model2": getTestSimpleResponderConfig("model2")},
+	})
+	defer proxy.StopProcesses()
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model1"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/api/upstreams/status", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Upstreams []upstreamStatus `json:"upstreams"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []upstreamStatus{{
+		Model:       "model1",
+		Key:         ProcessKeyName("", "model1"),
+		State:       string(StateReady),
+		ContextSize: 8192,
+		Slots:       &slotsStatus{Total: 2, Busy: 1, Idle: 1},
+		Errors:      map[string]string{"metrics": "status 501"},
+	}}, response.Upstreams)
+}
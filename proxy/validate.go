@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ValidationIssue is an error or warning found in a config
+type ValidationIssue struct {
+	// error or warning
+	Level   string `json:"level"`
+	Model   string `json:"model,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidateConfig loads a config and runs semantic checks that need the
+// environment: shared proxy ports, missing binaries and unresolvable proxy
+// hosts. The config is valid when no issue has the error level.
+func ValidateConfig(r io.Reader) (*Config, []ValidationIssue) {
+	config, err := LoadConfigFromReader(r)
+	if err != nil {
+		return nil, []ValidationIssue{{Level: "error", Message: err.Error()}}
+	}
+
+	var issues []ValidationIssue
+	add := func(level, model, format string, args ...interface{}) {
+		issues = append(issues, ValidationIssue{level, model, fmt.Sprintf(format, args...)})
+	}
+
+	modelIDs := make([]string, 0, len(config.Models))
+	for modelID := range config.Models {
+		modelIDs = append(modelIDs, modelID)
+	}
+	sort.Strings(modelIDs)
+
+	addrModels := make(map[string][]string)
+	for _, modelID := range modelIDs {
+		modelConfig := config.Models[modelID]
+
+		if modelConfig.Remote.enabled() {
+			// no command runs on this machine
+		} else if args, err := modelConfig.SanitizedCommand(); err != nil {
+			add("error", modelID, "invalid cmd: %v", err)
+		} else if _, err := exec.LookPath(args[0]); err != nil {
+			add("error", modelID, "binary %s not found", args[0])
+		}
+
+		if strings.Contains(modelConfig.Proxy, portMacro) {
+			// the port is assigned when the model starts
+			continue
+		}
+
+		if path, ok := unixSocketPath(modelConfig.Proxy); ok {
+			addrModels[path] = append(addrModels[path], modelID)
+			continue
+		}
+
+		proxyURL, err := url.Parse(modelConfig.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			add("error", modelID, "invalid proxy url %q", modelConfig.Proxy)
+			continue
+		}
+
+		if addr, ok := localProxyAddr(proxyURL); ok {
+			addrModels[addr] = append(addrModels[addr], modelID)
+		} else if _, err := net.LookupHost(proxyURL.Hostname()); err != nil {
+			add("warning", modelID, "proxy host %s can not be resolved", proxyURL.Hostname())
+		}
+	}
+
+	profileNames := make([]string, 0, len(config.Profiles))
+	for profileName := range config.Profiles {
+		profileNames = append(profileNames, profileName)
+	}
+	sort.Strings(profileNames)
+
+	addrs := make([]string, 0, len(addrModels))
+	for addr := range addrModels {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	// models sharing a port can not run at the same time
+	for _, addr := range addrs {
+		models := addrModels[addr]
+		if len(models) < 2 {
+			continue
+		}
+
+		add("warning", "", "models %s share the proxy address %s and can not run at the same time", strings.Join(models, ", "), addr)
+
+		for _, profileName := range profileNames {
+			var shared []string
+			for _, name := range config.Profiles[profileName] {
+				if realName, found := config.RealModelName(name); found && slices.Contains(models, realName) {
+					shared = append(shared, realName)
+				}
+			}
+			if len(shared) > 1 {
+				add("error", "", "profile %s runs models %s on the same proxy address %s", profileName, strings.Join(shared, ", "), addr)
+			}
+		}
+	}
+
+	return config, issues
+}
+
+// ValidationFailed is true when an issue is an error
+func ValidationFailed(issues []ValidationIssue) bool {
+	for _, issue := range issues {
+		if issue.Level == "error" {
+			return true
+		}
+	}
+	return false
+}
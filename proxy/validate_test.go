@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig_Issues(t *testing.T) {
+	_, issues := ValidateConfig(strings.NewReader(`
+models:
+  model1:
+    cmd: sh -c "sleep 1"
+    proxy: http://127.0.0.1:9100
+  model2:
+    cmd: sh -c "sleep 1"
+    proxy: http://127.0.0.1:9100
+  model3:
+    cmd: does-not-exist-llama-server --port 9101
+    proxy: http://127.0.0.1:9101
+  model4:
+    cmd: sh -c "sleep 1"
+    proxy: http://host.invalid:9102
+profiles:
+  both:
+    - model1
+    - model2
+`))
+
+	assert.Equal(t, []ValidationIssue{
+		{"error", "model3", "binary does-not-exist-llama-server not found"},
+		{"warning", "model4", "proxy host host.invalid can not be resolved"},
+		{"warning", "", "models model1, model2 share the proxy address 127.0.0.1:9100 and can not run at the same time"},
+		{"error", "", "profile both runs models model1, model2 on the same proxy address 127.0.0.1:9100"},
+	}, issues)
+	assert.True(t, ValidationFailed(issues))
+}
+
+func TestValidateConfig_LoadError(t *testing.T) {
+	config, issues := ValidateConfig(strings.NewReader("unknownModelStatus: 500"))
+	assert.Nil(t, config)
+	assert.Equal(t, []ValidationIssue{{"error", "", "unknownModelStatus must be 400 or 404"}}, issues)
+}
+
+func TestProxyManager_ValidateConfigEndpoint(t *testing.T) {
+	proxy := New(&Config{HealthCheckTimeout: 15})
+
+	tests := []struct {
+		body  string
+		valid bool
+	}{
+		{"models:\n  model1:\n    cmd: sh -c \"sleep 1\"\n    proxy: http://127.0.0.1:9100\n", true},
+		{"models: [not a map]", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/config/validate", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		proxy.HandlerFunc(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Valid  bool              `json:"valid"`
+			Issues []ValidationIssue `json:"issues"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, tt.valid, response.Valid)
+		assert.Equal(t, tt.valid, len(response.Issues) == 0)
+	}
+}
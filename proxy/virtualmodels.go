@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VirtualModelConfig is a model served by the process of another model with
+// some request parameters overridden, e.g. a more creative variant of it
+type VirtualModelConfig struct {
+	BaseModel string `yaml:"baseModel"`
+
+	// fields set to these values, replacing those sent by the client
+	Overrides map[string]interface{} `yaml:"overrides"`
+
+	// not listed in /v1/models
+	Unlisted bool `yaml:"unlisted"`
+}
+
+func (c *Config) validateVirtualModels() error {
+	for name, virtual := range c.VirtualModels {
+		if _, found := c.Models[name]; found {
+			return fmt.Errorf("virtual model %s collides with model %s", name, name)
+		}
+		if model, found := c.aliases[name]; found {
+			return fmt.Errorf("virtual model %s collides with an alias of model %s", name, model)
+		}
+		if strings.Contains(name, PROFILE_SPLIT_CHAR) {
+			return fmt.Errorf("virtual model %s can not contain %s", name, PROFILE_SPLIT_CHAR)
+		}
+		if virtual.BaseModel == "" {
+			return fmt.Errorf("virtual model %s needs a baseModel", name)
+		}
+		if _, found := c.RealModelName(virtual.BaseModel); !found {
+			return fmt.Errorf("virtual model %s: unknown baseModel %s", name, virtual.BaseModel)
+		}
+		if _, found := virtual.Overrides["model"]; found {
+			return fmt.Errorf("virtual model %s: overrides can not set model", name)
+		}
+	}
+	return nil
+}
+
+// resolveVirtualModel returns the base model of a requested virtual model,
+// keeping the profile prefix, and its overrides
+func (c *Config) resolveVirtualModel(requestedModel string) (string, map[string]interface{}, bool) {
+	profileName, modelName, found := strings.Cut(requestedModel, PROFILE_SPLIT_CHAR)
+	if !found {
+		profileName, modelName = "", requestedModel
+	}
+	virtual, found := c.VirtualModels[modelName]
+	if !found {
+		return "", nil, false
+	}
+	baseModel, _ := c.RealModelName(virtual.BaseModel)
+	if profileName != "" {
+		baseModel = ProcessKeyName(profileName, baseModel)
+	}
+	return baseModel, virtual.Overrides, true
+}
+
+// applyVirtualModel sends requests to a virtual model to its base model
+// with the overrides merged into the body. It returns the model and body to
+// proxy, false when an error was sent.
+func (pm *ProxyManager) applyVirtualModel(c *gin.Context, model string, requestBody map[string]interface{}, bodyBytes []byte) (string, []byte, bool) {
+	baseModel, overrides, found := pm.currentConfig().resolveVirtualModel(model)
+	if !found {
+		return model, bodyBytes, true
+	}
+	traceRequest(c, "virtual model %q is served by %q", model, baseModel)
+
+	maps.Copy(requestBody, overrides)
+	requestBody["model"] = baseModel
+	bodyBytes, err := json.Marshal(requestBody)
+	if err != nil {
+		pm.sendErrorResponse(c, http.StatusInternalServerError, fmt.Sprintf("error encoding JSON %s", err.Error()))
+		return "", nil, false
+	}
+	return baseModel, bodyBytes, true
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_VirtualModels(t *testing.T) {
+	tests := []struct {
+		name     string
+		virtual  string
+		expected string
+	}{
+		{"unknown base", "model1-creative:\n    baseModel: model9", "virtual model model1-creative: unknown baseModel model9"},
+		{"no base", "model1-creative:\n    overrides: {temperature: 1}", "virtual model model1-creative needs a baseModel"},
+		{"collides", "model1:\n    baseModel: model1", "virtual model model1 collides with model model1"},
+		{"collides with alias", "m1:\n    baseModel: model1", "virtual model m1 collides with an alias of model model1"},
+		{"sets model", "model1-creative:\n    baseModel: model1\n    overrides: {model: model2}", "virtual model model1-creative: overrides can not set model"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    aliases: [m1]
+virtualModels:
+  ` + tt.virtual + "\n"))
+			assert.ErrorContains(t, err, tt.expected)
+		})
+	}
+
+	config, err := LoadConfigFromBytes([]byte(`
+models:
+  model1:
+    cmd: server --port 9001
+    proxy: http://127.0.0.1:9001
+    aliases: [m1]
+virtualModels:
+  model1-creative:
+    baseModel: m1
+    overrides:
+      temperature: 1.2
+`))
+	if assert.NoError(t, err) {
+		baseModel, overrides, found := config.resolveVirtualModel("coding:model1-creative")
+		assert.True(t, found)
+		assert.Equal(t, "coding:model1", baseModel)
+		assert.Equal(t, map[string]interface{}{"temperature": 1.2}, overrides)
+	}
+}
+
+func TestProxyManager_VirtualModels(t *testing.T) {
+	var received map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		w.Write([]byte("model1"))
+	}))
+	defer upstream.Close()
+
+	// the command only keeps the process running, requests go to upstream
+	modelConfig := getTestSimpleResponderConfig("model1")
+	modelConfig.Proxy = upstream.URL
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": modelConfig},
+		VirtualModels: map[string]VirtualModelConfig{
+			"model1-creative": {BaseModel: "model1", Overrides: map[string]interface{}{"temperature": 1.2, "top_p": 0.95}},
+			"model1-hidden":   {BaseModel: "model1", Unlisted: true},
+		},
+	})
+	defer proxy.StopProcesses()
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model1-creative","temperature":0.2,"seed":1}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]interface{}{"model": "model1", "temperature": 1.2, "top_p": 0.95, "seed": float64(1)}, received)
+
+	// served by the same process
+	proxy.Lock()
+	assert.Len(t, proxy.currentProcesses, 1)
+	proxy.Unlock()
+
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("GET", "/v1/models", nil))
+	var models struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &models))
+	ids := []string{}
+	for _, model := range models.Data {
+		ids = append(ids, model.ID)
+	}
+	assert.Equal(t, []string{"model1", "model1-creative"}, ids)
+}
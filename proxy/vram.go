@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VRAMProvider reports the amount of free GPU memory
+type VRAMProvider interface {
+	FreeMB() (int, error)
+}
+
+type VRAMProviderFunc func() (int, error)
+
+func (f VRAMProviderFunc) FreeMB() (int, error) {
+	return f()
+}
+
+func newVRAMProvider(name string) (VRAMProvider, error) {
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "nvidia-smi":
+		return VRAMProviderFunc(nvidiaSmiFreeMB), nil
+	case "rocm-smi":
+		return VRAMProviderFunc(rocmSmiFreeMB), nil
+	default:
+		return nil, fmt.Errorf("unknown vram provider: %s", name)
+	}
+}
+
+func nvidiaSmiFreeMB() (int, error) {
+	out, err := exec.Command("nvidia-smi", "--query-gpu=memory.free", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return 0, fmt.Errorf("nvidia-smi failed: %v", err)
+	}
+	return parseNvidiaSmiFreeMB(out)
+}
+
+// parseNvidiaSmiFreeMB sums the free memory of all GPUs
+func parseNvidiaSmiFreeMB(out []byte) (int, error) {
+	total := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		mb, err := strconv.Atoi(line)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected nvidia-smi output: %s", line)
+		}
+		total += mb
+	}
+	return total, nil
+}
+
+func rocmSmiFreeMB() (int, error) {
+	out, err := exec.Command("rocm-smi", "--showmeminfo", "vram", "--json").Output()
+	if err != nil {
+		return 0, fmt.Errorf("rocm-smi failed: %v", err)
+	}
+	return parseRocmSmiFreeMB(out)
+}
+
+// parseRocmSmiFreeMB sums the free memory of all cards in rocm-smi's json output
+func parseRocmSmiFreeMB(out []byte) (int, error) {
+	var cards map[string]map[string]string
+	if err := json.NewDecoder(bytes.NewReader(out)).Decode(&cards); err != nil {
+		return 0, fmt.Errorf("unexpected rocm-smi output: %v", err)
+	}
+
+	var free int64
+	for card, info := range cards {
+		total, err := strconv.ParseInt(info["VRAM Total Memory (B)"], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unable to read total memory for %s", card)
+		}
+		used, err := strconv.ParseInt(info["VRAM Total Used Memory (B)"], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unable to read used memory for %s", card)
+		}
+		free += total - used
+	}
+	return int(free / (1024 * 1024)), nil
+}
+
+// InsufficientVRAMError is returned when a model is not started because there
+// is not enough free GPU memory
+type InsufficientVRAMError struct {
+	Model  string
+	Reason string
+}
+
+func (e *InsufficientVRAMError) Error() string {
+	return fmt.Sprintf("insufficient VRAM to start %s: %s", e.Model, e.Reason)
+}
+
+// vramPreStartCheck returns a check that waits up to vram.checkWaitSeconds
+// for enough free memory to start the model, nil when there is nothing to
+// check. vram.checkCmd is run with LLAMA_SWAP_MODEL and LLAMA_SWAP_VRAM_MB
+// set, otherwise the free memory from the provider is compared to the
+// model's vramEstimateMB.
+func (pm *ProxyManager) vramPreStartCheck(modelID string, modelConfig ModelConfig) func() error {
+	vram := pm.currentConfig().VRAM
+	provider := pm.vramProvider
+	needMB := modelConfig.VramEstimateMB
+
+	if vram.CheckCmd == "" && (provider == nil || needMB <= 0) {
+		return nil
+	}
+
+	check := func() string {
+		if vram.CheckCmd != "" {
+			args, err := SanitizeCommand(vram.CheckCmd)
+			if err != nil {
+				return err.Error()
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+			cmd.Env = append(os.Environ(),
+				"LLAMA_SWAP_MODEL="+modelID,
+				"LLAMA_SWAP_VRAM_MB="+strconv.Itoa(needMB),
+			)
+			if output, err := cmd.CombinedOutput(); err != nil {
+				if line := lastLine(string(output)); line != "" {
+					return fmt.Sprintf("checkCmd: %v: %s", err, line)
+				}
+				return fmt.Sprintf("checkCmd: %v", err)
+			}
+			return ""
+		}
+
+		freeMB, err := provider.FreeMB()
+		if err != nil {
+			return err.Error()
+		}
+		if freeMB < needMB {
+			return fmt.Sprintf("%dMB free, %dMB needed", freeMB, needMB)
+		}
+		return ""
+	}
+
+	return func() error {
+		deadline := time.Now().Add(time.Duration(vram.CheckWaitSeconds) * time.Second)
+		for {
+			reason := check()
+			if reason == "" {
+				return nil
+			}
+			if !time.Now().Before(deadline) {
+				return &InsufficientVRAMError{Model: modelID, Reason: reason}
+			}
+			fmt.Fprintf(pm.logMonitor, "!!! Waiting for VRAM to start %s: %s\n", modelID, reason)
+			time.Sleep(time.Second)
+		}
+	}
+}
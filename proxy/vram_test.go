@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVRAM_ParseNvidiaSmi(t *testing.T) {
+	free, err := parseNvidiaSmiFreeMB([]byte("24000\n12000\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 36000, free)
+
+	_, err = parseNvidiaSmiFreeMB([]byte("[N/A]\n"))
+	assert.Error(t, err)
+}
+
+func TestVRAM_ParseRocmSmi(t *testing.T) {
+	out := `{"card0": {"VRAM Total Memory (B)": "17163091968", "VRAM Total Used Memory (B)": "8581545984"}}`
+	free, err := parseRocmSmiFreeMB([]byte(out))
+	assert.NoError(t, err)
+	assert.Equal(t, 8184, free)
+}
+
+func TestVRAM_UnknownProvider(t *testing.T) {
+	_, err := newVRAMProvider("magic-smi")
+	assert.Error(t, err)
+
+	provider, err := newVRAMProvider("none")
+	assert.NoError(t, err)
+	assert.Nil(t, provider)
+}
+
+func TestProxyManager_VRAMPreStartCheck(t *testing.T) {
+	model1 := getTestSimpleResponderConfig("model1")
+	model1.VramEstimateMB = 4000
+
+	config := &Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": model1},
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	var freeMB atomic.Int64
+	freeMB.Store(1000)
+	proxy.vramProvider = VRAMProviderFunc(func() (int, error) {
+		return int(freeMB.Load()), nil
+	})
+
+	// fail fast without checkWaitSeconds
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"insufficient_vram"`)
+	assert.Contains(t, w.Body.String(), "1000MB free, 4000MB needed")
+
+	// wait for the memory to be freed
+	proxy.StopProcesses()
+	config.VRAM.CheckWaitSeconds = 5
+	go func() {
+		time.Sleep(1500 * time.Millisecond)
+		freeMB.Store(8000)
+	}()
+
+	req = httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`))
+	w = httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestProxyManager_VRAMCheckCmd(t *testing.T) {
+	config := &Config{
+		HealthCheckTimeout: 15,
+		Models:             map[string]ModelConfig{"model1": getTestSimpleResponderConfig("model1")},
+		VRAM:               VRAMConfig{CheckCmd: `sh -c "echo not enough for $LLAMA_SWAP_MODEL; exit 1"`},
+	}
+
+	proxy := New(config)
+	defer proxy.StopProcesses()
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`))
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "not enough for model1")
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// events sent to webhooks
+const (
+	EventProcessStarted   = "process.started"
+	EventProcessCrashed   = "process.crashed"
+	EventProcessUnhealthy = "process.unhealthy"
+	EventModelPreloaded   = "model.preloaded"
+	EventSwapOccurred     = "swap.occurred"
+	EventProcessRecycled  = "process.recycled"
+	EventBenchyRegression = "benchy.regression"
+)
+
+var webhookEvents = []string{EventProcessStarted, EventProcessCrashed, EventProcessUnhealthy, EventModelPreloaded, EventSwapOccurred, EventProcessRecycled, EventBenchyRegression}
+
+const webhookTimeout = 10 * time.Second
+
+type HooksConfig struct {
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+}
+
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+
+	// events to send, empty = all
+	Events []string `yaml:"events"`
+}
+
+func (h HooksConfig) validate() error {
+	for _, webhook := range h.Webhooks {
+		if webhook.URL == "" {
+			return fmt.Errorf("hooks.webhooks: url is required")
+		}
+		for _, event := range webhook.Events {
+			if !slices.Contains(webhookEvents, event) {
+				return fmt.Errorf("hooks.webhooks: unknown event %s", event)
+			}
+		}
+	}
+	return nil
+}
+
+// WebhookPayload is the JSON body POSTed to webhooks
+type WebhookPayload struct {
+	Event   string    `json:"event"`
+	Time    time.Time `json:"time"`
+	Model   string    `json:"model"`
+	Message string    `json:"message,omitempty"`
+}
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// sendWebhooks posts the event to the webhooks subscribed to it without
+// waiting for them
+func (pm *ProxyManager) sendWebhooks(webhooks []WebhookConfig, event, model, message string) {
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, _ := json.Marshal(WebhookPayload{
+		Event:   event,
+		Time:    time.Now(),
+		Model:   model,
+		Message: message,
+	})
+
+	for _, webhook := range webhooks {
+		if len(webhook.Events) > 0 && !slices.Contains(webhook.Events, event) {
+			continue
+		}
+
+		go func(url string) {
+			resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+			if err != nil {
+				fmt.Fprintf(pm.logMonitor, "!!! Webhook %s for %s failed: %v\n", url, event, err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				fmt.Fprintf(pm.logMonitor, "!!! Webhook %s for %s failed: status %d\n", url, event, resp.StatusCode)
+			}
+		}(webhook.URL)
+	}
+}
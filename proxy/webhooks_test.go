@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newWebhookReceiver(t *testing.T) (*httptest.Server, chan WebhookPayload) {
+	payloads := make(chan WebhookPayload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err == nil {
+			payloads <- payload
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, payloads
+}
+
+func receiveWebhook(t *testing.T, payloads chan WebhookPayload) WebhookPayload {
+	select {
+	case payload := <-payloads:
+		return payload
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook received")
+		return WebhookPayload{}
+	}
+}
+
+func TestProxyManager_Webhooks(t *testing.T) {
+	all, allPayloads := newWebhookReceiver(t)
+	crashes, crashPayloads := newWebhookReceiver(t)
+
+	proxy := New(&Config{
+		HealthCheckTimeout: 15,
+		Models: map[string]ModelConfig{
+			"model1": getTestSimpleResponderConfig("model1"),
+		},
+		Hooks: HooksConfig{Webhooks: []WebhookConfig{
+			{URL: all.URL},
+			{URL: crashes.URL, Events: []string{EventProcessCrashed}},
+		}},
+	})
+	defer proxy.StopProcesses()
+
+	w := httptest.NewRecorder()
+	proxy.HandlerFunc(w, httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBufferString(`{"model":"model1"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	events := map[string]string{}
+	for i := 0; i < 2; i++ {
+		payload := receiveWebhook(t, allPayloads)
+		events[payload.Event] = payload.Model
+	}
+	assert.Equal(t, map[string]string{EventSwapOccurred: "model1", EventProcessStarted: "model1"}, events)
+
+	proxy.Lock()
+	process := proxy.currentProcesses[ProcessKeyName("", "model1")]
+	proxy.Unlock()
+	process.cmd.Process.Kill()
+
+	payload := receiveWebhook(t, crashPayloads)
+	assert.Equal(t, EventProcessCrashed, payload.Event)
+	assert.Equal(t, "model1", payload.Model)
+	assert.Contains(t, payload.Message, "exited unexpectedly")
+	assert.Equal(t, EventProcessCrashed, receiveWebhook(t, allPayloads).Event)
+}
+
+func TestHooksConfig_Validate(t *testing.T) {
+	assert.NoError(t, HooksConfig{Webhooks: []WebhookConfig{{URL: "http://ntfy", Events: []string{EventProcessCrashed}}}}.validate())
+	assert.ErrorContains(t, HooksConfig{Webhooks: []WebhookConfig{{}}}.validate(), "url is required")
+	assert.ErrorContains(t, HooksConfig{Webhooks: []WebhookConfig{{URL: "http://ntfy", Events: []string{"process.exploded"}}}}.validate(), "unknown event")
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Workspace is a directory of named config files, name.yaml, where one of
+// them is active at a time
+type Workspace struct {
+	sync.Mutex
+
+	dir    string
+	active string
+}
+
+// OpenWorkspace opens dir and activates the config named initial, or the
+// first config by name when initial does not exist
+func OpenWorkspace(dir string, initial string) (*Workspace, error) {
+	w := &Workspace{dir: dir}
+
+	names, err := w.Names()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no config files found in workspace %s", dir)
+	}
+
+	w.active = names[0]
+	for _, name := range names {
+		if name == initial {
+			w.active = name
+			break
+		}
+	}
+
+	return w, nil
+}
+
+// Names returns the sorted names of all configs in the workspace
+func (w *Workspace) Names() ([]string, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ext))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Path returns the file path of the named config
+func (w *Workspace) Path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == ".." {
+		return "", fmt.Errorf("invalid config name: %s", name)
+	}
+
+	for _, ext := range []string{".yaml", ".yml"} {
+		path := filepath.Join(w.dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("config %s not found in workspace", name)
+}
+
+func (w *Workspace) Active() string {
+	w.Lock()
+	defer w.Unlock()
+	return w.active
+}
+
+func (w *Workspace) setActive(name string) {
+	w.Lock()
+	defer w.Unlock()
+	w.active = name
+}
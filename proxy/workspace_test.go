@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkspace_Open(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "team-b.yaml"), testConfigYAML("b"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "team-a.yml"), testConfigYAML("a"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hi"), 0644))
+
+	w, err := OpenWorkspace(dir, "does-not-exist")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", w.Active())
+
+	names, err := w.Names()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"team-a", "team-b"}, names)
+
+	w, err = OpenWorkspace(dir, "team-b")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-b", w.Active())
+
+	path, err := w.Path("team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "team-a.yml"), path)
+
+	_, err = w.Path("../team-a")
+	assert.Error(t, err)
+
+	_, err = OpenWorkspace(t.TempDir(), "")
+	assert.Error(t, err)
+}
+
+func TestProxyManager_ActivateWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.yaml"), testConfigYAML("model-a"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.yaml"), testConfigYAML("model-b"), 0644))
+
+	w, err := OpenWorkspace(dir, "a")
+	assert.NoError(t, err)
+	config, err := LoadConfig(filepath.Join(dir, "a.yaml"))
+	assert.NoError(t, err)
+
+	proxy := New(config)
+	proxy.SetWorkspace(w)
+	defer proxy.StopProcesses()
+
+	req := httptest.NewRequest("POST", "/api/workspaces/b/activate", nil)
+	rec := httptest.NewRecorder()
+	proxy.HandlerFunc(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "b", w.Active())
+	assert.Contains(t, proxy.currentConfig().Models, "model-b")
+	assert.Equal(t, filepath.Join(dir, "b.yaml"), proxy.currentConfigPath())
+	assert.False(t, proxy.IsDraining())
+
+	req = httptest.NewRequest("POST", "/api/workspaces/nope/activate", nil)
+	rec = httptest.NewRecorder()
+	proxy.HandlerFunc(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// a switch in progress holds off requests without draining
+	proxy.switching.Store(true)
+	rec = httptest.NewRecorder()
+	proxy.HandlerFunc(rec, httptest.NewRequest("POST", "/api/workspaces/a/activate", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = httptest.NewRecorder()
+	proxy.HandlerFunc(rec, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"model-b"}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.False(t, proxy.IsDraining())
+	proxy.switching.Store(false)
+
+	// a drain is not cancelled by a switch
+	proxy.Drain()
+	rec = httptest.NewRecorder()
+	proxy.HandlerFunc(rec, httptest.NewRequest("POST", "/api/workspaces/a/activate", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.True(t, proxy.IsDraining())
+	assert.Equal(t, "b", w.Active())
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mostlygeek/llama-swap/proxy"
+)
+
+const replayUsage = `usage: llama-swap replay [flags] FILE...
+
+sends the requests recorded by a model's record config to a server again
+
+flags:
+`
+
+// runReplay replays recording files and returns the exit code
+func runReplay(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	server := flags.String("server", "http://127.0.0.1:8080", "url of the llama-swap server")
+	apiKey := flags.String("api-key", os.Getenv("LLAMA_SWAP_API_KEY"), "bearer token sent to the server, default: $LLAMA_SWAP_API_KEY")
+	model := flags.String("model", "", "model the requests are sent to, default: the recorded model")
+	out := flags.String("out", "", "file the new responses are written to as recordings")
+	limit := flags.Int("limit", 0, "number of requests replayed, 0 = all")
+	jsonOutput := flags.Bool("json", false, "print JSON instead of a table")
+	timeout := flags.Duration("timeout", 10*time.Minute, "time to wait for a response, loading a model can take a while")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), replayUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var recordings []proxy.Recording
+	for _, path := range flags.Args() {
+		fileRecordings, err := readRecordings(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		recordings = append(recordings, fileRecordings...)
+	}
+	if *limit > 0 && len(recordings) > *limit {
+		recordings = recordings[:*limit]
+	}
+
+	var outFile *os.File
+	if *out != "" {
+		var err error
+		if outFile, err = os.Create(*out); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer outFile.Close()
+	}
+
+	replayer := &replayer{
+		server: strings.TrimSuffix(*server, "/"),
+		apiKey: *apiKey,
+		model:  *model,
+		http:   &http.Client{Timeout: *timeout},
+	}
+
+	table := ctlTable{headers: []string{"request", "path", "status", "recordedMs", "ms", "match"}}
+	failed := false
+	for i, recording := range recordings {
+		replayed, err := replayer.replay(recording)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: request %d: %v\n", i+1, err)
+			failed = true
+			continue
+		}
+		if outFile != nil {
+			line, _ := json.Marshal(replayed)
+			outFile.Write(append(line, '\n'))
+		}
+		if replayed.Status != http.StatusOK {
+			failed = true
+		}
+		table.rows = append(table.rows, ctlRow{
+			"request":    i + 1,
+			"path":       recording.Path,
+			"status":     replayed.Status,
+			"recordedMs": recording.DurationMs,
+			"ms":         replayed.DurationMs,
+			"match":      responseContent(recording.Response) == responseContent(replayed.Response),
+		})
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(table)
+	} else {
+		printTable(stdout, table)
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func readRecordings(path string) ([]proxy.Recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var recordings []proxy.Recording
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var recording proxy.Recording
+		if err := json.Unmarshal(scanner.Bytes(), &recording); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		recordings = append(recordings, recording)
+	}
+	return recordings, scanner.Err()
+}
+
+type replayer struct {
+	server string
+	apiKey string
+	model  string
+	http   *http.Client
+}
+
+// replay sends a recorded request without streaming and returns the response
+// as a recording
+func (r *replayer) replay(recording proxy.Recording) (proxy.Recording, error) {
+	var body map[string]interface{}
+	if err := json.Unmarshal(recording.Request, &body); err != nil {
+		return proxy.Recording{}, err
+	}
+	if r.model != "" {
+		body["model"] = r.model
+	}
+	delete(body, "stream")
+	delete(body, "stream_options")
+	request, err := json.Marshal(body)
+	if err != nil {
+		return proxy.Recording{}, err
+	}
+
+	req, err := http.NewRequest("POST", r.server+recording.Path, bytes.NewReader(request))
+	if err != nil {
+		return proxy.Recording{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	}
+
+	start := time.Now()
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return proxy.Recording{}, err
+	}
+	defer resp.Body.Close()
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return proxy.Recording{}, err
+	}
+
+	model, _ := body["model"].(string)
+	replayed := proxy.Recording{
+		Time:       start,
+		Model:      model,
+		Path:       recording.Path,
+		Request:    request,
+		Status:     resp.StatusCode,
+		DurationMs: time.Since(start).Milliseconds(),
+		Response:   response,
+	}
+	if !json.Valid(response) || len(bytes.TrimSpace(response)) == 0 {
+		replayed.Response, _ = json.Marshal(string(response))
+	}
+	return replayed, nil
+}
+
+// responseContent is the generated text of a response to compare it. It is
+// the response itself when it has no choices, like embeddings.
+func responseContent(response json.RawMessage) string {
+	var parsed struct {
+		Choices []struct {
+			Text    string `json:"text"`
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if json.Unmarshal(response, &parsed) != nil || len(parsed.Choices) == 0 {
+		return string(response)
+	}
+
+	var content strings.Builder
+	for _, choice := range parsed.Choices {
+		content.WriteString(choice.Text)
+		content.WriteString(choice.Message.Content)
+		// tool call IDs differ between responses
+		for _, call := range choice.Message.ToolCalls {
+			fmt.Fprintf(&content, "\n%s(%s)", call.Function.Name, call.Function.Arguments)
+		}
+		content.WriteByte('\n')
+	}
+	return content.String()
+}